@@ -0,0 +1,68 @@
+package main
+
+import (
+    "sync"
+    "time"
+)
+
+// Tiempo durante el que reconocer una anomalía silencia las nuevas del mismo
+// dispositivo y tipo. Si reaparece después, se notifica con la severidad
+// escalada. 0 = reconocer no silencia.
+var acknowledgmentTTL = 1 * time.Hour
+
+// Reconocimientos por dispositivo y tipo de anomalía
+type AcknowledgmentTracker struct {
+    mutex        sync.Mutex
+    acknowledged map[alertDedupKey]time.Time
+}
+
+var acknowledgments = NewAcknowledgmentTracker()
+
+// Inicializar registro de reconocimientos
+func NewAcknowledgmentTracker() *AcknowledgmentTracker {
+    return &AcknowledgmentTracker{
+        acknowledged: make(map[alertDedupKey]time.Time),
+    }
+}
+
+// Registrar que un operador reconoció una anomalía del dispositivo y tipo
+func (at *AcknowledgmentTracker) Acknowledge(anomaly Anomaly, now time.Time) {
+    if acknowledgmentTTL <= 0 {
+        return
+    }
+
+    at.mutex.Lock()
+    defer at.mutex.Unlock()
+
+    at.acknowledged[alertDedupKey{deviceID: anomaly.DeviceID, anomalyType: anomaly.Type}] = now
+}
+
+// Comprobar una nueva anomalía contra los reconocimientos: suppress si el
+// reconocimiento sigue vigente; escalate si expiró. No consume el
+// reconocimiento expirado: se consume con ConsumeEscalation al notificar, de
+// modo que una reaparición descartada por otro filtro no gasta la escalada.
+func (at *AcknowledgmentTracker) Check(anomaly *Anomaly, now time.Time) (suppress bool, escalate bool) {
+    at.mutex.Lock()
+    defer at.mutex.Unlock()
+
+    acknowledgedAt, exists := at.acknowledged[alertDedupKey{deviceID: anomaly.DeviceID, anomalyType: anomaly.Type}]
+    if !exists {
+        return false, false
+    }
+    if now.Sub(acknowledgedAt) < acknowledgmentTTL {
+        return true, false
+    }
+    return false, true
+}
+
+// Consumir el reconocimiento expirado tras notificar la reaparición escalada
+// (solo la primera reaparición notificada se escala)
+func (at *AcknowledgmentTracker) ConsumeEscalation(anomaly *Anomaly, now time.Time) {
+    at.mutex.Lock()
+    defer at.mutex.Unlock()
+
+    key := alertDedupKey{deviceID: anomaly.DeviceID, anomalyType: anomaly.Type}
+    if acknowledgedAt, exists := at.acknowledged[key]; exists && now.Sub(acknowledgedAt) >= acknowledgmentTTL {
+        delete(at.acknowledged, key)
+    }
+}
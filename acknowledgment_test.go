@@ -0,0 +1,54 @@
+package main
+
+import (
+    "testing"
+    "time"
+)
+
+func TestNotifyAnomalies_Acknowledgment(t *testing.T) {
+    tests := []struct {
+        name string
+        // Antigüedad del reconocimiento
+        acknowledgedAgo time.Duration
+        minConfidence   float64
+        minSeverity     string
+        wantSent        int
+        wantSeverity    string
+        // Si la siguiente reaparición se escala todavía
+        wantPending bool
+    }{
+        {name: "reconocimiento vigente", acknowledgedAgo: time.Minute, wantSent: 0},
+        {name: "reaparece tras el TTL", acknowledgedAgo: 2 * time.Hour, wantSent: 1, wantSeverity: SEVERITY_MEDIUM},
+        {name: "descartada por confianza conserva la escalada", acknowledgedAgo: 2 * time.Hour, minConfidence: 0.9, wantPending: true},
+        {name: "descartada por severidad conserva la escalada", acknowledgedAgo: 2 * time.Hour, minSeverity: SEVERITY_HIGH, wantPending: true},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            clock := setupTestHub(t)
+            savedConfidence, savedSeverity := notificationMinConfidence, notificationMinSeverity
+            t.Cleanup(func() { notificationMinConfidence, notificationMinSeverity = savedConfidence, savedSeverity })
+            notificationMinConfidence, notificationMinSeverity = tt.minConfidence, tt.minSeverity
+            notifier := newRecordingNotifier("test")
+            notificationManager.Register(notifier)
+
+            anomaly := Anomaly{DeviceID: "sensor-1", Type: AnomalyTemperature, Severity: SEVERITY_LOW, Confidence: 0.5}
+            acknowledgments.Acknowledge(anomaly, clock.Now().Add(-tt.acknowledgedAgo))
+
+            notifyAnomalies([]Anomaly{anomaly})
+            flushNotifications(t)
+
+            sent := notifier.Anomalies()
+            if len(sent) != tt.wantSent {
+                t.Fatalf("alertas enviadas = %d, se esperaban %d", len(sent), tt.wantSent)
+            }
+            if tt.wantSent > 0 && sent[0].Severity != tt.wantSeverity {
+                t.Errorf("severidad = %s, se esperaba %s", sent[0].Severity, tt.wantSeverity)
+            }
+            _, pending := acknowledgments.Check(&anomaly, clock.Now())
+            if pending != tt.wantPending {
+                t.Errorf("escalada pendiente = %v, se esperaba %v", pending, tt.wantPending)
+            }
+        })
+    }
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+    "sync"
+    "time"
+)
+
+// Ventana en la que una anomalía del mismo dispositivo y tipo ya notificada
+// se registra pero no se vuelve a notificar (p. ej. un sensor atascado en
+// 80°C que genera una anomalía por mensaje). 0 = notificar todas.
+var alertDedupWindow = 5 * time.Minute
+
+// Última notificación por dispositivo y tipo de anomalía
+type AlertDeduplicator struct {
+    mutex        sync.Mutex
+    lastNotified map[alertDedupKey]time.Time
+}
+
+type alertDedupKey struct {
+    deviceID    string
+    anomalyType AnomalyType
+}
+
+var alertDeduplicator = NewAlertDeduplicator()
+
+// Inicializar deduplicador de alertas
+func NewAlertDeduplicator() *AlertDeduplicator {
+    return &AlertDeduplicator{
+        lastNotified: make(map[alertDedupKey]time.Time),
+    }
+}
+
+// Si la anomalía debe notificarse; registra la notificación en ese caso
+func (ad *AlertDeduplicator) ShouldNotify(anomaly *Anomaly, now time.Time) bool {
+    if alertDedupWindow <= 0 {
+        return true
+    }
+
+    ad.mutex.Lock()
+    defer ad.mutex.Unlock()
+
+    key := alertDedupKey{deviceID: anomaly.DeviceID, anomalyType: anomaly.Type}
+    if last, exists := ad.lastNotified[key]; exists && now.Sub(last) < alertDedupWindow {
+        return false
+    }
+    ad.lastNotified[key] = now
+
+    // Olvidar las entradas antiguas para no crecer sin límite
+    for other, last := range ad.lastNotified {
+        if now.Sub(last) >= alertDedupWindow {
+            delete(ad.lastNotified, other)
+        }
+    }
+    return true
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+    "testing"
+    "time"
+)
+
+func TestNotifyAnomalies_Deduplication(t *testing.T) {
+    tests := []struct {
+        name     string
+        window   string
+        interval time.Duration
+        devices  []string
+        types    []AnomalyType
+        wantSent int
+    }{
+        {name: "5 idénticas seguidas", window: "5m", interval: time.Second, devices: []string{"sensor-1"}, types: []AnomalyType{AnomalyTemperature}, wantSent: 1},
+        {name: "separadas más que la ventana", window: "5m", interval: 6 * time.Minute, devices: []string{"sensor-1"}, types: []AnomalyType{AnomalyTemperature}, wantSent: 5},
+        {name: "ventana configurada más corta", window: "30s", interval: 20 * time.Second, devices: []string{"sensor-1"}, types: []AnomalyType{AnomalyTemperature}, wantSent: 3},
+        {name: "deduplicación deshabilitada", window: "0s", interval: time.Second, devices: []string{"sensor-1"}, types: []AnomalyType{AnomalyTemperature}, wantSent: 5},
+        {name: "dispositivos distintos", window: "5m", interval: time.Second, devices: []string{"sensor-1", "sensor-2"}, types: []AnomalyType{AnomalyTemperature}, wantSent: 2},
+        {name: "tipos distintos", window: "5m", interval: time.Second, devices: []string{"sensor-1"}, types: []AnomalyType{AnomalyTemperature, AnomalyHumidity}, wantSent: 2},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            clock := setupTestHub(t)
+            t.Setenv("NOTIFICATION_DEDUP_WINDOW", tt.window)
+            cfg, err := LoadConfig()
+            if err != nil {
+                t.Fatalf("LoadConfig() error = %v", err)
+            }
+            saved := alertDedupWindow
+            t.Cleanup(func() { alertDedupWindow = saved })
+            alertDedupWindow = cfg.Notifications.DedupWindow
+
+            notifier := newRecordingNotifier("test")
+            notificationManager.Register(notifier)
+
+            for i := 0; i < 5; i++ {
+                for _, deviceID := range tt.devices {
+                    for _, anomalyType := range tt.types {
+                        notifyAnomalies([]Anomaly{{
+                            DeviceID:   deviceID,
+                            Type:       anomalyType,
+                            Severity:   SEVERITY_HIGH,
+                            Confidence: 1,
+                            Timestamp:  clock.Now(),
+                        }})
+                    }
+                }
+                clock.Advance(tt.interval)
+            }
+            flushNotifications(t)
+
+            if got := len(notifier.Anomalies()); got != tt.wantSent {
+                t.Errorf("notificaciones = %d, se esperaban %d", got, tt.wantSent)
+            }
+        })
+    }
+}
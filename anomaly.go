@@ -0,0 +1,150 @@
+package main
+
+import (
+    "fmt"
+    "strings"
+    "time"
+)
+
+// Tipo de anomalía
+type AnomalyType string
+
+const (
+    AnomalyTemperature        AnomalyType = "temperature"
+    AnomalyHumidity           AnomalyType = "humidity"
+    AnomalyBattery            AnomalyType = "battery"
+    AnomalyAccessAttempts     AnomalyType = "access_attempts"
+    AnomalySignal             AnomalyType = "signal"
+    AnomalyBehaviorPattern    AnomalyType = "behavior_pattern"
+    AnomalyDataQuality        AnomalyType = "data_quality"
+    AnomalyCalibrationDrift   AnomalyType = "calibration_drift"
+    AnomalyPayloadSize        AnomalyType = "payload_size"
+    AnomalyAccessFailureRatio AnomalyType = "access_failure_ratio"
+    AnomalyStorm              AnomalyType = "anomaly_storm"
+    AnomalySecurityState      AnomalyType = "security_state"
+    AnomalyDiagnostic         AnomalyType = "diagnostic"
+    // Humedad y temperatura incompatibles (punto de rocío inverosímil)
+    AnomalyHumidityTemperature AnomalyType = "humidity_temperature"
+    // Un mismo ID que reporta con varios tipos de dispositivo
+    AnomalyDeviceTypeMismatch AnomalyType = "device_type_mismatch"
+    // Mensaje fuera del horario de comunicación permitido
+    AnomalyOutsideSchedule AnomalyType = "outside_schedule"
+    // Resumen de las alertas retenidas durante las horas de silencio
+    AnomalyQuietHoursDigest AnomalyType = "quiet_hours_digest"
+)
+
+// Severidades de anomalía
+const (
+    SEVERITY_LOW    = "low"
+    SEVERITY_MEDIUM = "medium"
+    SEVERITY_HIGH   = "high"
+)
+
+// Orden de las severidades (low < medium < high); 0 si es desconocida
+func severityRank(severity string) int {
+    switch severity {
+    case SEVERITY_LOW:
+        return 1
+    case SEVERITY_MEDIUM:
+        return 2
+    case SEVERITY_HIGH:
+        return 3
+    default:
+        return 0
+    }
+}
+
+// Severidad un nivel por encima (high se mantiene)
+func escalateSeverity(severity string) string {
+    switch severity {
+    case SEVERITY_LOW:
+        return SEVERITY_MEDIUM
+    default:
+        return SEVERITY_HIGH
+    }
+}
+
+// Severidad base por tipo de dispositivo: {"smart_lock": "high", "parking_sensor": "low"}.
+// Sustituye a la severidad media por defecto de cualquier anomalía del tipo;
+// los detectores que fijan una severidad propia (deriva de calibración,
+// replay) la conservan.
+var deviceTypeSeverity = map[string]string{}
+
+// Severidad por defecto de las anomalías de un tipo de dispositivo
+func baseSeverity(deviceType string) string {
+    if severity, exists := deviceTypeSeverity[deviceType]; exists {
+        return severity
+    }
+    return SEVERITY_MEDIUM
+}
+
+// Validar que las severidades base sean conocidas
+func validateDeviceTypeSeverity(severities map[string]string) error {
+    for deviceType, severity := range severities {
+        switch severity {
+        case SEVERITY_LOW, SEVERITY_MEDIUM, SEVERITY_HIGH:
+        default:
+            return fmt.Errorf("severidad base inválida para %s: %q", deviceType, severity)
+        }
+    }
+    return nil
+}
+
+// Anomalía detectada en un dispositivo
+type Anomaly struct {
+    ID          string      `json:"id,omitempty"`
+    DeviceID    string      `json:"device_id"`
+    DeviceType  string      `json:"device_type,omitempty"`
+    Type        AnomalyType `json:"type"`
+    Severity    string      `json:"severity"`
+    Metric      string      `json:"metric,omitempty"`
+    Value       float64     `json:"value"`
+    History     []float64   `json:"history,omitempty"`
+    Description string      `json:"description"`
+    Timestamp   time.Time   `json:"timestamp"`
+    // Ciclo de vida: abierta → reconocida → resuelta
+    Status         string     `json:"status,omitempty"`
+    AcknowledgedAt *time.Time `json:"acknowledged_at,omitempty"`
+    ResolvedAt     *time.Time `json:"resolved_at,omitempty"`
+    // Revisión por un analista
+    Reviewed   bool   `json:"reviewed"`
+    ReviewedBy string `json:"reviewed_by,omitempty"`
+    // Condiciones exteriores (solo anomalías de temperatura con ubicación)
+    ExternalContext *WeatherConditions `json:"external_context,omitempty"`
+    // Confianza de la detección (0–1)
+    Confidence float64 `json:"confidence"`
+    // message_id de la lectura que la originó (ID de anomalía determinista)
+    MessageID string `json:"message_id,omitempty"`
+    // Etiquetas del dispositivo en el momento de la detección
+    DeviceMetadata map[string]string `json:"device_metadata,omitempty"`
+}
+
+// Crear una anomalía con la severidad base de su tipo de dispositivo (media
+// por defecto) y confianza total, fechada al procesar la lectura
+func newAnomaly(data *SensorData, anomalyType AnomalyType, value float64, format string, args ...interface{}) Anomaly {
+    timestamp := data.receivedAt
+    if timestamp.IsZero() {
+        timestamp = time.Now()
+    }
+    return Anomaly{
+        DeviceID:    data.DeviceID,
+        DeviceType:  data.DeviceType,
+        Type:        anomalyType,
+        Severity:    baseSeverity(data.DeviceType),
+        Metric:      metricForType(anomalyType),
+        Value:       value,
+        Description: fmt.Sprintf(format, args...),
+        Timestamp:   timestamp,
+        Confidence:  1,
+        MessageID:   data.MessageID,
+    }
+}
+
+// Resumen legible de una lista de anomalías para los logs
+func describeAnomalies(anomalies []Anomaly) string {
+    descriptions := make([]string, 0, len(anomalies))
+    for _, anomaly := range anomalies {
+        descriptions = append(descriptions, anomaly.Description)
+    }
+    return "[" + strings.Join(descriptions, " ") + "]"
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+    "encoding/csv"
+    "fmt"
+    "io"
+    "log"
+    "net/http"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// Formatos de exportación de anomalías
+const ANOMALY_EXPORT_FORMAT_CSV = "csv"
+
+// Columnas del CSV de auditoría
+var anomalyCSVHeader = []string{"id", "device_id", "type", "severity", "value", "timestamp", "description"}
+
+// Neutralizar celdas que una hoja de cálculo interpretaría como fórmula
+// (device_id y descripciones vienen del dispositivo). No se aplica a los
+// números: un valor negativo es legítimo.
+func csvSafe(value string) string {
+    if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+        return "'" + value
+    }
+    return value
+}
+
+// Escribir las anomalías como CSV, con cabecera
+func writeAnomaliesCSV(w io.Writer, anomalies []*Anomaly) error {
+    writer := csv.NewWriter(w)
+    if err := writer.Write(anomalyCSVHeader); err != nil {
+        return err
+    }
+
+    for _, anomaly := range anomalies {
+        record := []string{
+            csvSafe(anomaly.ID),
+            csvSafe(anomaly.DeviceID),
+            csvSafe(string(anomaly.Type)),
+            csvSafe(anomaly.Severity),
+            strconv.FormatFloat(anomaly.Value, 'f', -1, 64),
+            anomaly.Timestamp.UTC().Format(time.RFC3339),
+            csvSafe(anomaly.Description),
+        }
+        if err := writer.Write(record); err != nil {
+            return err
+        }
+    }
+
+    writer.Flush()
+    return writer.Error()
+}
+
+// Parámetro de fecha RFC3339 opcional (cero si no viene)
+func parseTimeParam(r *http.Request, name string) (time.Time, error) {
+    raw := r.URL.Query().Get(name)
+    if raw == "" {
+        return time.Time{}, nil
+    }
+    parsed, err := time.Parse(time.RFC3339, raw)
+    if err != nil {
+        return time.Time{}, fmt.Errorf("%s inválido: %s", name, raw)
+    }
+    return parsed, nil
+}
+
+// GET /anomalies/export?since=&until=&format=csv: volcado de anomalías para auditoría
+func handleExportAnomalies(w http.ResponseWriter, r *http.Request) {
+    format := r.URL.Query().Get("format")
+    if format == "" {
+        format = ANOMALY_EXPORT_FORMAT_CSV
+    }
+    if format != ANOMALY_EXPORT_FORMAT_CSV {
+        writeError(w, http.StatusBadRequest, "formato no soportado: "+format)
+        return
+    }
+
+    since, err := parseTimeParam(r, "since")
+    if err != nil {
+        writeError(w, http.StatusBadRequest, err.Error())
+        return
+    }
+    until, err := parseTimeParam(r, "until")
+    if err != nil {
+        writeError(w, http.StatusBadRequest, err.Error())
+        return
+    }
+    if !since.IsZero() && !until.IsZero() && !until.After(since) {
+        writeError(w, http.StatusBadRequest, "until debe ser posterior a since")
+        return
+    }
+
+    anomalies, err := anomalyRepository.GetAnomalies(r.Context(), since, until)
+    if err != nil {
+        writeError(w, http.StatusInternalServerError, "no se pudieron leer las anomalías: "+err.Error())
+        return
+    }
+
+    w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+    w.Header().Set("Content-Disposition", `attachment; filename="anomalies.csv"`)
+    if err := writeAnomaliesCSV(w, anomalies); err != nil {
+        log.Printf("❌ Error escribiendo exportación CSV: %v", err)
+    }
+}
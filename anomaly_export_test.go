@@ -0,0 +1,69 @@
+package main
+
+import (
+    "bytes"
+    "encoding/csv"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+)
+
+func TestCSVSafe(t *testing.T) {
+    tests := []struct {
+        value string
+        want  string
+    }{
+        {value: "sensor-1", want: "sensor-1"},
+        {value: "", want: ""},
+        {value: "=HYPERLINK(\"http://evil\")", want: "'=HYPERLINK(\"http://evil\")"},
+        {value: "+1+1", want: "'+1+1"},
+        {value: "-2+3", want: "'-2+3"},
+        {value: "@SUM(A1)", want: "'@SUM(A1)"},
+        {value: "\t=1", want: "'\t=1"},
+        {value: "temperatura = 80", want: "temperatura = 80"},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.value, func(t *testing.T) {
+            if got := csvSafe(tt.value); got != tt.want {
+                t.Errorf("csvSafe(%q) = %q, se esperaba %q", tt.value, got, tt.want)
+            }
+        })
+    }
+}
+
+func TestHandleExportAnomalies(t *testing.T) {
+    clock := setupTestHub(t)
+    anomalyRepository.Save([]Anomaly{{
+        DeviceID:    "=cmd|' /C calc'!A0",
+        Type:        AnomalyTemperature,
+        Severity:    SEVERITY_HIGH,
+        Value:       -12.5,
+        Description: "@SUM(1+1)",
+        Timestamp:   clock.Now(),
+    }})
+
+    recorder := httptest.NewRecorder()
+    handleExportAnomalies(recorder, httptest.NewRequest(http.MethodGet, "/anomalies/export", nil))
+    if recorder.Code != http.StatusOK {
+        t.Fatalf("estado = %d, se esperaba 200", recorder.Code)
+    }
+
+    records, err := csv.NewReader(bytes.NewReader(recorder.Body.Bytes())).ReadAll()
+    if err != nil {
+        t.Fatalf("CSV inválido: %v", err)
+    }
+    if len(records) != 2 {
+        t.Fatalf("filas = %d, se esperaban cabecera y una anomalía", len(records))
+    }
+    row := records[1]
+    if row[1] != "'=cmd|' /C calc'!A0" {
+        t.Errorf("device_id = %q, se esperaba neutralizado", row[1])
+    }
+    if row[4] != "-12.5" {
+        t.Errorf("value = %q, los números negativos no se alteran", row[4])
+    }
+    if row[6] != "'@SUM(1+1)" {
+        t.Errorf("description = %q, se esperaba neutralizada", row[6])
+    }
+}
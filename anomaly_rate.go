@@ -0,0 +1,82 @@
+package main
+
+import (
+    "fmt"
+    "log"
+    "time"
+)
+
+// Quarantine por tasa de anomalías: complementa el contador consecutivo
+// (AnomalyCount), que se reinicia y no detecta a un dispositivo que genera
+// una anomalía cada pocos minutos indefinidamente.
+var (
+    anomalyRateWindow    = 1 * time.Hour
+    anomalyRateThreshold = 10 // 0 = deshabilitado
+)
+
+// Tipos que no cuentan para la tasa: la deriva de calibración es un aviso de
+// mantenimiento del sensor, no una señal de compromiso
+func countsTowardAnomalyRate(anomaly *Anomaly) bool {
+    switch anomaly.Type {
+    case AnomalyCalibrationDrift, AnomalyStorm, AnomalyQuietHoursDigest:
+        return false
+    default:
+        return true
+    }
+}
+
+// Registrar las anomalías del dispositivo que cuentan para la tasa. Se llama
+// antes del muestreo de la protección ante tormentas, que persiste solo una
+// parte. Solo se guardan las de la ventana y como mucho las necesarias para
+// superar el umbral.
+func (qs *QuarantineSystem) RecordRateAnomalies(deviceID string, anomalies []Anomaly) {
+    if anomalyRateThreshold <= 0 {
+        return
+    }
+
+    qs.mutex.Lock()
+    defer qs.mutex.Unlock()
+
+    now := qs.clock.Now()
+    behavior := qs.behaviorLocked(deviceID)
+    for i := range anomalies {
+        if countsTowardAnomalyRate(&anomalies[i]) {
+            behavior.RateAnomalies = append(behavior.RateAnomalies, now)
+        }
+    }
+    behavior.RateAnomalies = pruneBefore(behavior.RateAnomalies, now.Add(-anomalyRateWindow))
+    if overflow := len(behavior.RateAnomalies) - (anomalyRateThreshold + 1); overflow > 0 {
+        behavior.RateAnomalies = behavior.RateAnomalies[overflow:]
+    }
+}
+
+// Anomalías del dispositivo que cuentan para la tasa dentro de la ventana.
+// Las anteriores a la última liberación ya no están: se descartan al liberar.
+func (qs *QuarantineSystem) RateAnomalyCount(deviceID string) int {
+    qs.mutex.Lock()
+    defer qs.mutex.Unlock()
+
+    behavior := qs.deviceBehavior[deviceID]
+    if behavior == nil {
+        return 0
+    }
+    behavior.RateAnomalies = pruneBefore(behavior.RateAnomalies, qs.clock.Now().Add(-anomalyRateWindow))
+    return len(behavior.RateAnomalies)
+}
+
+// Poner en quarantine el dispositivo si sus anomalías dentro de la ventana
+// superan el umbral. Devuelve true si se puso en quarantine.
+func checkAnomalyRate(deviceID string) bool {
+    if anomalyRateThreshold <= 0 {
+        return false
+    }
+
+    count := quarantineSystem.RateAnomalyCount(deviceID)
+    if count <= anomalyRateThreshold {
+        return false
+    }
+
+    log.Printf("📈 TASA DE ANOMALÍAS: %s acumula %d anomalías en %v (umbral: %d)", deviceID, count, anomalyRateWindow, anomalyRateThreshold)
+    quarantineSystem.QuarantineDeviceWithReason(deviceID, QuarantineReasonBehaviorAnomaly, fmt.Sprintf("%d anomalías en %v", count, anomalyRateWindow))
+    return true
+}
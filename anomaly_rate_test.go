@@ -0,0 +1,101 @@
+package main
+
+import (
+    "testing"
+    "time"
+)
+
+func TestCheckAnomalyRate(t *testing.T) {
+    temperature := Anomaly{DeviceID: "sensor-1", Type: AnomalyTemperature, Severity: SEVERITY_LOW}
+    drift := Anomaly{DeviceID: "sensor-1", Type: AnomalyCalibrationDrift, Severity: SEVERITY_LOW}
+
+    tests := []struct {
+        name    string
+        prepare func(t *testing.T, clock *FakeClock)
+        want    bool
+    }{
+        {
+            name: "por encima del umbral",
+            prepare: func(t *testing.T, clock *FakeClock) {
+                recordRepeated(temperature, 4, true)
+            },
+            want: true,
+        },
+        {
+            name: "en el umbral",
+            prepare: func(t *testing.T, clock *FakeClock) {
+                recordRepeated(temperature, 3, true)
+            },
+            want: false,
+        },
+        {
+            name: "deriva de calibración no cuenta",
+            prepare: func(t *testing.T, clock *FakeClock) {
+                recordRepeated(drift, 10, true)
+            },
+            want: false,
+        },
+        {
+            name: "ventana de actualización no cuenta",
+            prepare: func(t *testing.T, clock *FakeClock) {
+                recordRepeated(temperature, 10, false)
+            },
+            want: false,
+        },
+        {
+            name: "fuera de la ventana",
+            prepare: func(t *testing.T, clock *FakeClock) {
+                recordRepeated(temperature, 4, true)
+                clock.Advance(anomalyRateWindow + time.Second)
+            },
+            want: false,
+        },
+        {
+            name: "anteriores a la última liberación",
+            prepare: func(t *testing.T, clock *FakeClock) {
+                recordRepeated(temperature, 3, true)
+                quarantineSystem.QuarantineDevice("sensor-1", "prueba")
+                quarantineSystem.ReleaseFromQuarantine("sensor-1", ReleaseReasonManual, "operador")
+                recordRepeated(temperature, 2, true)
+            },
+            want: false,
+        },
+        {
+            name: "tormenta activa: el muestreo no reduce la cuenta",
+            prepare: func(t *testing.T, clock *FakeClock) {
+                savedThreshold, savedRate := anomalyStormThreshold, anomalyStormSampleRate
+                anomalyStormThreshold, anomalyStormSampleRate = 1, 10
+                t.Cleanup(func() { anomalyStormThreshold, anomalyStormSampleRate = savedThreshold, savedRate })
+                recordRepeated(temperature, 4, true)
+                if !stormProtection.Active() {
+                    t.Fatal("la protección ante tormentas debería estar activa")
+                }
+            },
+            want: true,
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            clock := setupTestHub(t)
+            savedThreshold := anomalyRateThreshold
+            anomalyRateThreshold = 3
+            t.Cleanup(func() { anomalyRateThreshold = savedThreshold })
+
+            tt.prepare(t, clock)
+            if got := checkAnomalyRate("sensor-1"); got != tt.want {
+                t.Errorf("checkAnomalyRate() = %v, se esperaba %v", got, tt.want)
+            }
+            if got := quarantineSystem.IsQuarantined("sensor-1"); got != tt.want {
+                t.Errorf("en cuarentena = %v, se esperaba %v", got, tt.want)
+            }
+        })
+    }
+}
+
+// Registrar n veces la anomalía por el mismo camino que el procesamiento
+func recordRepeated(anomaly Anomaly, n int, notify bool) {
+    for i := 0; i < n; i++ {
+        recordAnomalies([]Anomaly{anomaly}, notify)
+    }
+}
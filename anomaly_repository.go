@@ -0,0 +1,266 @@
+package main
+
+import (
+    "context"
+    "crypto/rand"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "sync"
+    "time"
+)
+
+// Número máximo por defecto de anomalías conservadas en memoria; al superarlo
+// se descartan las más antiguas
+const ANOMALY_REPOSITORY_SIZE = 10000
+
+// Estados de una anomalía
+const (
+    ANOMALY_STATUS_OPEN         = "open"
+    ANOMALY_STATUS_ACKNOWLEDGED = "acknowledged"
+    ANOMALY_STATUS_RESOLVED     = "resolved"
+)
+
+var ErrAnomalyNotFound = errors.New("anomalía no encontrada")
+
+// Almacén en memoria de las anomalías detectadas, con su ciclo de vida
+// (abierta → reconocida → resuelta)
+type AnomalyRepository struct {
+    mutex     sync.RWMutex
+    anomalies []*Anomaly
+    byID      map[string]*Anomaly
+    maxSize   int
+    clock     Clock
+}
+
+var anomalyRepository = NewAnomalyRepository(ANOMALY_REPOSITORY_SIZE)
+
+// Inicializar almacén de anomalías
+func NewAnomalyRepository(maxSize int) *AnomalyRepository {
+    return &AnomalyRepository{
+        anomalies: make([]*Anomaly, 0),
+        byID:      make(map[string]*Anomaly),
+        maxSize:   maxSize,
+        clock:     RealClock{},
+    }
+}
+
+// Cambiar el reloj con el que se fechan reconocimientos y resoluciones
+func (ar *AnomalyRepository) SetClock(clock Clock) {
+    ar.mutex.Lock()
+    defer ar.mutex.Unlock()
+
+    ar.clock = clock
+}
+
+// Hora actual según el reloj del repositorio
+func (ar *AnomalyRepository) Now() time.Time {
+    ar.mutex.RLock()
+    defer ar.mutex.RUnlock()
+
+    return ar.clock.Now()
+}
+
+// Identificador aleatorio de anomalía
+func newAnomalyID() string {
+    buf := make([]byte, 8)
+    rand.Read(buf)
+    return hex.EncodeToString(buf)
+}
+
+// Guardar anomalías recién detectadas; asigna ID y estado en el propio slice
+// para que las notificaciones lleven el ID. Las anomalías de lecturas con
+// message_id reciben un ID determinista y no se duplican al reprocesarlas.
+func (ar *AnomalyRepository) Save(anomalies []Anomaly) {
+    ar.mutex.Lock()
+    defer ar.mutex.Unlock()
+
+    for i := range anomalies {
+        anomalies[i].ID = newAnomalyID()
+        if anomalies[i].MessageID != "" {
+            anomalies[i].ID = idempotentAnomalyID(&anomalies[i])
+            // Reprocesado: la anomalía ya está guardada y conserva su estado
+            if existing, exists := ar.byID[anomalies[i].ID]; exists {
+                anomalies[i].Status = existing.Status
+                continue
+            }
+        }
+        anomalies[i].Status = ANOMALY_STATUS_OPEN
+
+        stored := anomalies[i]
+        ar.anomalies = append(ar.anomalies, &stored)
+        ar.byID[stored.ID] = &stored
+    }
+
+    // Descartar las más antiguas si se supera el tamaño
+    if overflow := len(ar.anomalies) - ar.maxSize; overflow > 0 {
+        for _, old := range ar.anomalies[:overflow] {
+            delete(ar.byID, old.ID)
+        }
+        ar.anomalies = ar.anomalies[overflow:]
+    }
+}
+
+// Obtener una anomalía por ID
+func (ar *AnomalyRepository) Get(id string) (Anomaly, error) {
+    ar.mutex.RLock()
+    defer ar.mutex.RUnlock()
+
+    anomaly, exists := ar.byID[id]
+    if !exists {
+        return Anomaly{}, ErrAnomalyNotFound
+    }
+    return *anomaly, nil
+}
+
+// Copia de todas las anomalías almacenadas, de la más antigua a la más reciente
+func (ar *AnomalyRepository) All() []Anomaly {
+    ar.mutex.RLock()
+    defer ar.mutex.RUnlock()
+
+    result := make([]Anomaly, 0, len(ar.anomalies))
+    for _, anomaly := range ar.anomalies {
+        result = append(result, *anomaly)
+    }
+    return result
+}
+
+// Copia de las anomalías con timestamp en [since, until), de la más antigua a
+// la más reciente. Un since o until cero no limita por ese extremo.
+func (ar *AnomalyRepository) GetAnomalies(ctx context.Context, since, until time.Time) ([]*Anomaly, error) {
+    ar.mutex.RLock()
+    defer ar.mutex.RUnlock()
+
+    result := make([]*Anomaly, 0)
+    for _, anomaly := range ar.anomalies {
+        if err := ctx.Err(); err != nil {
+            return nil, err
+        }
+        if !since.IsZero() && anomaly.Timestamp.Before(since) {
+            continue
+        }
+        if !until.IsZero() && !anomaly.Timestamp.Before(until) {
+            continue
+        }
+        stored := *anomaly
+        result = append(result, &stored)
+    }
+    return result, nil
+}
+
+// Eliminar las anomalías con timestamp anterior a before. Devuelve cuántas
+// se eliminaron.
+func (ar *AnomalyRepository) DeleteAnomaliesBefore(ctx context.Context, before time.Time) (int, error) {
+    if err := ctx.Err(); err != nil {
+        return 0, err
+    }
+
+    ar.mutex.Lock()
+    defer ar.mutex.Unlock()
+
+    // Los timestamps los pone el dispositivo: no se asume orden
+    kept := ar.anomalies[:0]
+    for _, anomaly := range ar.anomalies {
+        if anomaly.Timestamp.Before(before) {
+            delete(ar.byID, anomaly.ID)
+            continue
+        }
+        kept = append(kept, anomaly)
+    }
+    deleted := len(ar.anomalies) - len(kept)
+    clear(ar.anomalies[len(kept):])
+    ar.anomalies = kept
+    return deleted, nil
+}
+
+// Las limit anomalías más recientes, de la más reciente a la más antigua
+func (ar *AnomalyRepository) Recent(limit int) []Anomaly {
+    ar.mutex.RLock()
+    defer ar.mutex.RUnlock()
+
+    if limit > len(ar.anomalies) {
+        limit = len(ar.anomalies)
+    }
+    result := make([]Anomaly, 0, limit)
+    for i := len(ar.anomalies) - 1; i >= len(ar.anomalies)-limit; i-- {
+        result = append(result, *ar.anomalies[i])
+    }
+    return result
+}
+
+// Anomalías de un dispositivo, opcionalmente solo las no revisadas
+func (ar *AnomalyRepository) ByDevice(deviceID string, unreviewedOnly bool) []Anomaly {
+    ar.mutex.RLock()
+    defer ar.mutex.RUnlock()
+
+    result := make([]Anomaly, 0)
+    for _, anomaly := range ar.anomalies {
+        if anomaly.DeviceID != deviceID || (unreviewedOnly && anomaly.Reviewed) {
+            continue
+        }
+        result = append(result, *anomaly)
+    }
+    return result
+}
+
+// Marcar una anomalía como revisada por un analista. Revisar implica
+// reconocerla, así que una anomalía abierta pasa a reconocida.
+func (ar *AnomalyRepository) MarkReviewed(id string, by string) (Anomaly, error) {
+    ar.mutex.Lock()
+    defer ar.mutex.Unlock()
+
+    anomaly, exists := ar.byID[id]
+    if !exists {
+        return Anomaly{}, ErrAnomalyNotFound
+    }
+
+    anomaly.Reviewed = true
+    anomaly.ReviewedBy = by
+    if anomaly.Status == ANOMALY_STATUS_OPEN {
+        now := ar.clock.Now()
+        anomaly.Status = ANOMALY_STATUS_ACKNOWLEDGED
+        anomaly.AcknowledgedAt = &now
+    }
+    return *anomaly, nil
+}
+
+// Marcar una anomalía como reconocida por un operador
+func (ar *AnomalyRepository) Acknowledge(id string) (Anomaly, error) {
+    ar.mutex.Lock()
+    defer ar.mutex.Unlock()
+
+    anomaly, exists := ar.byID[id]
+    if !exists {
+        return Anomaly{}, ErrAnomalyNotFound
+    }
+    if anomaly.Status != ANOMALY_STATUS_OPEN {
+        return Anomaly{}, fmt.Errorf("la anomalía %s ya está en estado %s", id, anomaly.Status)
+    }
+
+    now := ar.clock.Now()
+    anomaly.Status = ANOMALY_STATUS_ACKNOWLEDGED
+    anomaly.AcknowledgedAt = &now
+    return *anomaly, nil
+}
+
+// Marcar una anomalía como resuelta (si no estaba reconocida, se reconoce a la vez)
+func (ar *AnomalyRepository) Resolve(id string) (Anomaly, error) {
+    ar.mutex.Lock()
+    defer ar.mutex.Unlock()
+
+    anomaly, exists := ar.byID[id]
+    if !exists {
+        return Anomaly{}, ErrAnomalyNotFound
+    }
+    if anomaly.Status == ANOMALY_STATUS_RESOLVED {
+        return Anomaly{}, fmt.Errorf("la anomalía %s ya está resuelta", id)
+    }
+
+    now := ar.clock.Now()
+    if anomaly.AcknowledgedAt == nil {
+        anomaly.AcknowledgedAt = &now
+    }
+    anomaly.Status = ANOMALY_STATUS_RESOLVED
+    anomaly.ResolvedAt = &now
+    return *anomaly, nil
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestAnomalyRepository_SaveRepeatedKeepsStatus(t *testing.T) {
+    tests := []struct {
+        name   string
+        update func(ar *AnomalyRepository, id string)
+        want   string
+    }{
+        {name: "abierta", update: func(ar *AnomalyRepository, id string) {}, want: ANOMALY_STATUS_OPEN},
+        {name: "reconocida", update: func(ar *AnomalyRepository, id string) { ar.Acknowledge(id) }, want: ANOMALY_STATUS_ACKNOWLEDGED},
+        {name: "resuelta", update: func(ar *AnomalyRepository, id string) { ar.Resolve(id) }, want: ANOMALY_STATUS_RESOLVED},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            ar := NewAnomalyRepository(ANOMALY_REPOSITORY_SIZE)
+            anomaly := Anomaly{DeviceID: "sensor-1", MessageID: "m1", Type: AnomalyTemperature, Description: "temperatura alta"}
+
+            first := []Anomaly{anomaly}
+            ar.Save(first)
+            tt.update(ar, first[0].ID)
+
+            repeated := []Anomaly{anomaly}
+            ar.Save(repeated)
+            if repeated[0].ID != first[0].ID {
+                t.Fatalf("ID = %s, se esperaba el mismo %s", repeated[0].ID, first[0].ID)
+            }
+            if repeated[0].Status != tt.want {
+                t.Errorf("estado = %q, se esperaba %q", repeated[0].Status, tt.want)
+            }
+            if got := len(ar.All()); got != 1 {
+                t.Errorf("anomalías guardadas = %d, se esperaba 1", got)
+            }
+        })
+    }
+}
+
+func TestAnomalyRepository_TransitionsUseClock(t *testing.T) {
+    tests := []struct {
+        name         string
+        transition   func(ar *AnomalyRepository, id string) (Anomaly, error)
+        wantResolved bool
+    }{
+        {name: "reconocer", transition: (*AnomalyRepository).Acknowledge},
+        {name: "revisar", transition: func(ar *AnomalyRepository, id string) (Anomaly, error) { return ar.MarkReviewed(id, "operador") }},
+        {name: "resolver", transition: (*AnomalyRepository).Resolve, wantResolved: true},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            clock := NewFakeClock(testEpoch)
+            ar := NewAnomalyRepository(ANOMALY_REPOSITORY_SIZE)
+            ar.SetClock(clock)
+            anomalies := []Anomaly{{DeviceID: "sensor-1", Type: AnomalyTemperature, Timestamp: testEpoch}}
+            ar.Save(anomalies)
+
+            clock.Advance(time.Hour)
+            anomaly, err := tt.transition(ar, anomalies[0].ID)
+            if err != nil {
+                t.Fatal(err)
+            }
+            want := testEpoch.Add(time.Hour)
+            if anomaly.AcknowledgedAt == nil || !anomaly.AcknowledgedAt.Equal(want) {
+                t.Errorf("reconocida en %v, se esperaba %v", anomaly.AcknowledgedAt, want)
+            }
+            if tt.wantResolved && (anomaly.ResolvedAt == nil || !anomaly.ResolvedAt.Equal(want)) {
+                t.Errorf("resuelta en %v, se esperaba %v", anomaly.ResolvedAt, want)
+            }
+        })
+    }
+}
+
+func TestAnomalyRepository_MaxSizeEvictsOldest(t *testing.T) {
+    tests := []struct {
+        name      string
+        maxSize   int
+        saved     int
+        wantFirst float64
+    }{
+        {name: "por debajo del máximo", maxSize: 5, saved: 3, wantFirst: 0},
+        {name: "supera el máximo", maxSize: 3, saved: 7, wantFirst: 4},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            repository := NewAnomalyRepository(tt.maxSize)
+            var ids []string
+            for i := 0; i < tt.saved; i++ {
+                anomalies := []Anomaly{{DeviceID: "sensor-1", Type: AnomalyTemperature, Value: float64(i)}}
+                repository.Save(anomalies)
+                ids = append(ids, anomalies[0].ID)
+            }
+
+            all := repository.All()
+            if want := tt.saved - int(tt.wantFirst); len(all) != want {
+                t.Fatalf("anomalías = %d, se esperaban %d", len(all), want)
+            }
+            if all[0].Value != tt.wantFirst {
+                t.Errorf("más antigua conservada = %v, se esperaba %v", all[0].Value, tt.wantFirst)
+            }
+            for i, id := range ids {
+                _, err := repository.Get(id)
+                if evicted := float64(i) < tt.wantFirst; evicted != (err != nil) {
+                    t.Errorf("anomalía %d: error = %v, desplazada = %v", i, err, evicted)
+                }
+            }
+        })
+    }
+}
+
+func TestAnomalyRepository_ByDeviceUnreviewedOnly(t *testing.T) {
+    ar := NewAnomalyRepository(ANOMALY_REPOSITORY_SIZE)
+    anomalies := []Anomaly{
+        {DeviceID: "sensor-1", Type: AnomalyTemperature, Description: "revisada"},
+        {DeviceID: "sensor-1", Type: AnomalyHumidity, Description: "pendiente"},
+        {DeviceID: "sensor-2", Type: AnomalyTemperature, Description: "otro dispositivo"},
+    }
+    ar.Save(anomalies)
+    if _, err := ar.MarkReviewed(anomalies[0].ID, "ana"); err != nil {
+        t.Fatal(err)
+    }
+
+    tests := []struct {
+        name           string
+        unreviewedOnly bool
+        want           []string
+    }{
+        {name: "todas", want: []string{"revisada", "pendiente"}},
+        {name: "solo sin revisar", unreviewedOnly: true, want: []string{"pendiente"}},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            var got []string
+            for _, anomaly := range ar.ByDevice("sensor-1", tt.unreviewedOnly) {
+                got = append(got, anomaly.Description)
+            }
+            if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+                t.Errorf("ByDevice() = %v, se esperaba %v", got, tt.want)
+            }
+        })
+    }
+}
+
+func TestHandleReviewAnomaly(t *testing.T) {
+    tests := []struct {
+        name         string
+        token        string
+        wantStatus   int
+        wantReviewer string
+    }{
+        {name: "sin token", wantStatus: http.StatusUnauthorized},
+        {name: "token compartido", token: testAPIToken, wantStatus: http.StatusOK, wantReviewer: API_TOKEN_OPERATOR},
+        {name: "token de operador", token: "token-de-ana", wantStatus: http.StatusOK, wantReviewer: "ana"},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            setupTestHub(t)
+            withAPIToken(t)
+            saved := apiOperatorTokens
+            t.Cleanup(func() { apiOperatorTokens = saved })
+            apiOperatorTokens = map[string]string{"ana": "token-de-ana"}
+            anomalies := []Anomaly{{DeviceID: "sensor-1", Type: AnomalyTemperature}}
+            anomalyRepository.Save(anomalies)
+
+            // El nombre que envía el cliente no cuenta: se registra el operador autenticado
+            request := httptest.NewRequest(http.MethodPost, "/anomalies/"+anomalies[0].ID+"/review", strings.NewReader(`{"reviewed_by": "otra-persona"}`))
+            if tt.token != "" {
+                request.Header.Set("Authorization", "Bearer "+tt.token)
+            }
+            recorder := httptest.NewRecorder()
+            newAPIRouter().ServeHTTP(recorder, request)
+
+            if recorder.Code != tt.wantStatus {
+                t.Fatalf("estado HTTP = %d, se esperaba %d", recorder.Code, tt.wantStatus)
+            }
+            anomaly, err := anomalyRepository.Get(anomalies[0].ID)
+            if err != nil {
+                t.Fatal(err)
+            }
+            if anomaly.ReviewedBy != tt.wantReviewer || anomaly.Reviewed != (tt.wantReviewer != "") {
+                t.Errorf("revisada por %q (revisada: %v), se esperaba %q", anomaly.ReviewedBy, anomaly.Reviewed, tt.wantReviewer)
+            }
+        })
+    }
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+    "context"
+    "log"
+    "time"
+)
+
+// Intervalo entre purgas de anomalías antiguas
+const ANOMALY_RETENTION_INTERVAL = 10 * time.Minute
+
+// Antigüedad a partir de la cual se eliminan las anomalías (0 = conservarlas
+// hasta que las desplace el tamaño máximo del repositorio)
+var anomalyRetention = 7 * 24 * time.Hour
+
+// Eliminar las anomalías anteriores a la retención configurada
+func pruneAnomalies(ctx context.Context, repository *AnomalyRepository, retention time.Duration) {
+    if retention <= 0 {
+        return
+    }
+
+    deleted, err := repository.DeleteAnomaliesBefore(ctx, repository.Now().Add(-retention))
+    if err != nil {
+        log.Printf("⚠️ Error purgando anomalías antiguas: %v", err)
+        return
+    }
+    if deleted > 0 {
+        log.Printf("🧹 PURGA: %d anomalías con más de %v eliminadas", deleted, retention)
+        metrics.Add("iot_anomalies_evicted", float64(deleted))
+    }
+}
+
+// Purga periódica de anomalías antiguas hasta que se cancele el contexto.
+// El canal devuelto se cierra cuando la goroutine termina.
+func startAnomalyRetention(ctx context.Context, repository *AnomalyRepository, interval time.Duration) <-chan struct{} {
+    done := make(chan struct{})
+
+    go func() {
+        defer close(done)
+        ticker := time.NewTicker(interval)
+        defer ticker.Stop()
+
+        for {
+            select {
+            case <-ctx.Done():
+                return
+            case <-ticker.C:
+                pruneAnomalies(ctx, repository, anomalyRetention)
+            }
+        }
+    }()
+
+    return done
+}
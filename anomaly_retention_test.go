@@ -0,0 +1,72 @@
+package main
+
+import (
+    "context"
+    "sort"
+    "testing"
+    "time"
+)
+
+func TestPruneAnomalies(t *testing.T) {
+    // Antigüedad de cada anomalía sembrada, fuera de orden a propósito
+    ages := []time.Duration{25 * time.Hour, time.Minute, 48 * time.Hour, 23 * time.Hour}
+
+    tests := []struct {
+        name      string
+        retention string
+        wantKept  []time.Duration
+    }{
+        {name: "retención de un día", retention: "24h", wantKept: []time.Duration{time.Minute, 23 * time.Hour}},
+        {name: "retención larga", retention: "72h", wantKept: []time.Duration{time.Minute, 23 * time.Hour, 25 * time.Hour, 48 * time.Hour}},
+        {name: "retención deshabilitada", retention: "0s", wantKept: []time.Duration{time.Minute, 23 * time.Hour, 25 * time.Hour, 48 * time.Hour}},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            clock := setupTestHub(t)
+            t.Setenv("ANOMALY_RETENTION", tt.retention)
+            cfg, err := LoadConfig()
+            if err != nil {
+                t.Fatalf("LoadConfig() error = %v", err)
+            }
+
+            for _, age := range ages {
+                anomalyRepository.Save([]Anomaly{{DeviceID: "sensor-1", Type: AnomalyTemperature, Timestamp: clock.Now().Add(-age)}})
+            }
+
+            pruneAnomalies(context.Background(), anomalyRepository, cfg.State.AnomalyRetention)
+
+            var kept []time.Duration
+            for _, anomaly := range anomalyRepository.All() {
+                kept = append(kept, clock.Now().Sub(anomaly.Timestamp))
+                // Las eliminadas tampoco se encuentran por ID
+                if _, err := anomalyRepository.Get(anomaly.ID); err != nil {
+                    t.Errorf("anomalía conservada %s no encontrada por ID: %v", anomaly.ID, err)
+                }
+            }
+            sort.Slice(kept, func(i, j int) bool { return kept[i] < kept[j] })
+            if len(kept) != len(tt.wantKept) {
+                t.Fatalf("conservadas = %v, se esperaban %v", kept, tt.wantKept)
+            }
+            for i := range kept {
+                if kept[i] != tt.wantKept[i] {
+                    t.Fatalf("conservadas = %v, se esperaban %v", kept, tt.wantKept)
+                }
+            }
+        })
+    }
+}
+
+func TestDeleteAnomaliesBefore_CancelledContext(t *testing.T) {
+    clock := setupTestHub(t)
+    anomalyRepository.Save([]Anomaly{{DeviceID: "sensor-1", Type: AnomalyTemperature, Timestamp: clock.Now().Add(-time.Hour)}})
+
+    ctx, cancel := context.WithCancel(context.Background())
+    cancel()
+    if _, err := anomalyRepository.DeleteAnomaliesBefore(ctx, clock.Now()); err != context.Canceled {
+        t.Fatalf("error = %v, se esperaba %v", err, context.Canceled)
+    }
+    if got := len(anomalyRepository.All()); got != 1 {
+        t.Errorf("anomalías = %d, se esperaba 1", got)
+    }
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+    "fmt"
+    "sync"
+)
+
+// Regla de detección de anomalías sobre una lectura, con los umbrales que
+// corresponden al tipo de dispositivo
+type Rule interface {
+    Name() string
+    Evaluate(data *SensorData, thresholds AnomalyThresholds) []Anomaly
+}
+
+// Motor de reglas: evalúa en orden una lista de reglas sobre cada lectura
+type RuleEngine struct {
+    mutex sync.RWMutex
+    rules []Rule
+}
+
+// Reglas incorporadas, en el orden de evaluación por defecto
+var builtinRules = []Rule{
+    TemperatureRule{},
+    HumidityRule{},
+    HumidityTemperatureRule{},
+    BatteryRule{},
+    AccessAttemptsRule{},
+    AccessFailureRatioRule{},
+    SignalRule{},
+    SecurityStateRule{},
+}
+
+// Motor de reglas activo
+var ruleEngine = NewRuleEngine(builtinRules...)
+
+// Inicializar motor de reglas
+func NewRuleEngine(rules ...Rule) *RuleEngine {
+    return &RuleEngine{rules: append([]Rule(nil), rules...)}
+}
+
+// Motor con las reglas incorporadas indicadas, en ese orden (vacío = todas)
+func newRuleEngineFromNames(names []string) (*RuleEngine, error) {
+    if len(names) == 0 {
+        return NewRuleEngine(builtinRules...), nil
+    }
+
+    rules := make([]Rule, 0, len(names))
+    for _, name := range names {
+        rule := builtinRule(name)
+        if rule == nil {
+            return nil, fmt.Errorf("regla de anomalías desconocida: %q", name)
+        }
+        rules = append(rules, rule)
+    }
+    return NewRuleEngine(rules...), nil
+}
+
+// Regla incorporada por nombre (nil si no existe)
+func builtinRule(name string) Rule {
+    for _, rule := range builtinRules {
+        if rule.Name() == name {
+            return rule
+        }
+    }
+    return nil
+}
+
+// Añadir una regla al final de la lista
+func (re *RuleEngine) Register(rule Rule) {
+    re.mutex.Lock()
+    defer re.mutex.Unlock()
+
+    re.rules = append(re.rules, rule)
+}
+
+// Nombres de las reglas activas, en orden de evaluación
+func (re *RuleEngine) Names() []string {
+    re.mutex.RLock()
+    defer re.mutex.RUnlock()
+
+    names := make([]string, len(re.rules))
+    for i, rule := range re.rules {
+        names[i] = rule.Name()
+    }
+    return names
+}
+
+// Evaluar todas las reglas sobre una lectura
+func (re *RuleEngine) Evaluate(data *SensorData, thresholds AnomalyThresholds) []Anomaly {
+    re.mutex.RLock()
+    rules := re.rules
+    re.mutex.RUnlock()
+
+    var anomalies []Anomaly
+    for _, rule := range rules {
+        anomalies = append(anomalies, rule.Evaluate(data, thresholds)...)
+    }
+    return anomalies
+}
+
+// Temperaturas fuera de rango
+type TemperatureRule struct{}
+
+func (TemperatureRule) Name() string { return "temperature" }
+
+func (TemperatureRule) Evaluate(data *SensorData, thresholds AnomalyThresholds) []Anomaly {
+    if !data.hasReading("temperature", data.Temperature) {
+        return nil
+    }
+    if data.Temperature <= thresholds.TemperatureMax && data.Temperature >= thresholds.TemperatureMin {
+        return nil
+    }
+    anomaly := newAnomaly(data, AnomalyTemperature, data.Temperature, "temperatura extrema: %.2f°C", data.Temperature)
+    anomaly.Confidence = rangeConfidence(data.Temperature, thresholds.TemperatureMin, thresholds.TemperatureMax)
+    return []Anomaly{anomaly}
+}
+
+// Humedad anómala (riesgo de condensación/moho o ambiente demasiado seco)
+type HumidityRule struct{}
+
+func (HumidityRule) Name() string { return "humidity" }
+
+func (HumidityRule) Evaluate(data *SensorData, thresholds AnomalyThresholds) []Anomaly {
+    if !data.hasReading("humidity", data.Humidity) {
+        return nil
+    }
+    if data.Humidity <= thresholds.HumidityMax && data.Humidity >= thresholds.HumidityMin {
+        return nil
+    }
+    anomaly := newAnomaly(data, AnomalyHumidity, data.Humidity, "humedad anómala: %.1f%%", data.Humidity)
+    anomaly.Confidence = rangeConfidence(data.Humidity, thresholds.HumidityMin, thresholds.HumidityMax)
+    return []Anomaly{anomaly}
+}
+
+// Humedad y temperatura incompatibles entre sí
+type HumidityTemperatureRule struct{}
+
+func (HumidityTemperatureRule) Name() string { return "humidity_temperature" }
+
+func (HumidityTemperatureRule) Evaluate(data *SensorData, thresholds AnomalyThresholds) []Anomaly {
+    return detectHumidityTemperature(data, thresholds)
+}
+
+// Batería crítica
+type BatteryRule struct{}
+
+func (BatteryRule) Name() string { return "battery" }
+
+func (BatteryRule) Evaluate(data *SensorData, thresholds AnomalyThresholds) []Anomaly {
+    if !data.hasReading("battery_level", data.BatteryLevel) || data.BatteryLevel >= thresholds.BatteryCritical {
+        return nil
+    }
+    anomaly := newAnomaly(data, AnomalyBattery, data.BatteryLevel, "batería crítica: %.1f%%", data.BatteryLevel)
+    anomaly.Confidence = thresholdConfidence(data.BatteryLevel, thresholds.BatteryCritical, thresholds.BatteryCritical)
+    return []Anomaly{anomaly}
+}
+
+// Múltiples intentos de acceso (posible ataque)
+type AccessAttemptsRule struct{}
+
+func (AccessAttemptsRule) Name() string { return "access_attempts" }
+
+func (AccessAttemptsRule) Evaluate(data *SensorData, thresholds AnomalyThresholds) []Anomaly {
+    if data.AccessAttempts <= thresholds.AccessAttemptsMax {
+        return nil
+    }
+    anomaly := newAnomaly(data, AnomalyAccessAttempts, float64(data.AccessAttempts), "múltiples intentos de acceso: %d", data.AccessAttempts)
+    anomaly.Confidence = thresholdConfidence(float64(data.AccessAttempts), float64(thresholds.AccessAttemptsMax), float64(thresholds.AccessAttemptsMax))
+    return []Anomaly{anomaly}
+}
+
+// Proporción alta de accesos fallidos: señal de fuerza bruta más fiable que
+// el número total de intentos, así que se evalúa con menos intentos
+type AccessFailureRatioRule struct{}
+
+func (AccessFailureRatioRule) Name() string { return "access_failure_ratio" }
+
+func (AccessFailureRatioRule) Evaluate(data *SensorData, thresholds AnomalyThresholds) []Anomaly {
+    if data.FailedAccessAttempts == 0 || data.AccessAttempts < thresholds.AccessFailureMinAttempts || thresholds.AccessFailureRatioMax <= 0 {
+        return nil
+    }
+    ratio := float64(data.FailedAccessAttempts) / float64(data.AccessAttempts)
+    if ratio <= thresholds.AccessFailureRatioMax {
+        return nil
+    }
+    anomaly := newAnomaly(data, AnomalyAccessFailureRatio, ratio, "proporción alta de accesos fallidos: %d de %d (%.0f%%)", data.FailedAccessAttempts, data.AccessAttempts, ratio*100)
+    anomaly.Confidence = thresholdConfidence(ratio, thresholds.AccessFailureRatioMax, 1-thresholds.AccessFailureRatioMax)
+    return []Anomaly{anomaly}
+}
+
+// Señal muy débil (posible jamming)
+type SignalRule struct{}
+
+func (SignalRule) Name() string { return "signal" }
+
+func (SignalRule) Evaluate(data *SensorData, thresholds AnomalyThresholds) []Anomaly {
+    if !data.hasReading("signal_strength", data.SignalStrength) || data.SignalStrength >= thresholds.SignalWeak {
+        return nil
+    }
+    anomaly := newAnomaly(data, AnomalySignal, data.SignalStrength, "señal débil: %.1f%%", data.SignalStrength)
+    anomaly.Confidence = thresholdConfidence(data.SignalStrength, thresholds.SignalWeak, thresholds.SignalWeak)
+    return []Anomaly{anomaly}
+}
+
+// Estado de seguridad de cerraduras y cámaras
+type SecurityStateRule struct{}
+
+func (SecurityStateRule) Name() string { return "security_state" }
+
+func (SecurityStateRule) Evaluate(data *SensorData, thresholds AnomalyThresholds) []Anomaly {
+    return detectSecurityState(data, thresholds)
+}
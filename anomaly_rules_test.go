@@ -0,0 +1,141 @@
+package main
+
+import "testing"
+
+// Regla propia: marca las lecturas de un tipo de dispositivo retirado
+type retiredTypeRule struct {
+    deviceType string
+}
+
+func (rr retiredTypeRule) Name() string { return "retired_type" }
+
+func (rr retiredTypeRule) Evaluate(data *SensorData, thresholds AnomalyThresholds) []Anomaly {
+    if data.DeviceType != rr.deviceType {
+        return nil
+    }
+    return []Anomaly{newAnomaly(data, AnomalyDiagnostic, 0, "tipo de dispositivo retirado %s", data.DeviceType)}
+}
+
+func TestRuleEngine_CustomRule(t *testing.T) {
+    tests := []struct {
+        name       string
+        deviceType string
+        want       int
+    }{
+        {name: "la regla propia dispara", deviceType: "legacy_gateway", want: 1},
+        {name: "la regla propia no dispara", deviceType: "sensor", want: 0},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            engine := NewRuleEngine(builtinRules...)
+            engine.Register(retiredTypeRule{deviceType: "legacy_gateway"})
+
+            data := SensorData{DeviceID: "sensor-1", Temperature: 21, DeviceType: tt.deviceType}
+            got := 0
+            for _, anomaly := range engine.Evaluate(&data, DefaultAnomalyThresholds()) {
+                if anomaly.Type == AnomalyDiagnostic {
+                    got++
+                }
+            }
+            if got != tt.want {
+                t.Errorf("anomalías de la regla propia = %d, se esperaban %d", got, tt.want)
+            }
+            if names := engine.Names(); names[len(names)-1] != "retired_type" {
+                t.Errorf("la regla registrada debe evaluarse la última: %v", names)
+            }
+        })
+    }
+}
+
+func TestNewRuleEngineFromNames(t *testing.T) {
+    tests := []struct {
+        name      string
+        names     []string
+        wantRules int
+        wantErr   bool
+    }{
+        {name: "todas por defecto", wantRules: len(builtinRules)},
+        {name: "subconjunto en orden", names: []string{"battery", "temperature"}, wantRules: 2},
+        {name: "regla desconocida", names: []string{"temperature", "inventada"}, wantErr: true},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            engine, err := newRuleEngineFromNames(tt.names)
+            if (err != nil) != tt.wantErr {
+                t.Fatalf("error = %v, se esperaba error: %v", err, tt.wantErr)
+            }
+            if err == nil && len(engine.Names()) != tt.wantRules {
+                t.Errorf("reglas = %v, se esperaban %d", engine.Names(), tt.wantRules)
+            }
+        })
+    }
+}
+
+func TestAccessFailureRatioRule(t *testing.T) {
+    tests := []struct {
+        name      string
+        attempts  int
+        failed    int
+        ratioMax  float64
+        wantRatio bool
+        wantCount bool
+    }{
+        {name: "casi todos fallidos por debajo del recuento", attempts: 4, failed: 4, wantRatio: true},
+        {name: "muchos intentos casi todos correctos", attempts: 8, failed: 1, wantCount: true},
+        {name: "muchos intentos casi todos fallidos", attempts: 8, failed: 7, wantRatio: true, wantCount: true},
+        {name: "pocos intentos", attempts: 2, failed: 2},
+        {name: "sin fallos reportados", attempts: 4},
+        {name: "umbral de proporción mayor", attempts: 4, failed: 3, ratioMax: 0.8},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            thresholds := DefaultAnomalyThresholds()
+            if tt.ratioMax > 0 {
+                thresholds.AccessFailureRatioMax = tt.ratioMax
+            }
+
+            data := SensorData{DeviceID: "sensor-1", AccessAttempts: tt.attempts, FailedAccessAttempts: tt.failed}
+            found := make(map[AnomalyType]bool)
+            for _, anomaly := range NewRuleEngine(builtinRules...).Evaluate(&data, thresholds) {
+                found[anomaly.Type] = true
+            }
+            if found[AnomalyAccessFailureRatio] != tt.wantRatio {
+                t.Errorf("anomalía por proporción = %v, se esperaba %v", found[AnomalyAccessFailureRatio], tt.wantRatio)
+            }
+            if found[AnomalyAccessAttempts] != tt.wantCount {
+                t.Errorf("anomalía por recuento = %v, se esperaba %v", found[AnomalyAccessAttempts], tt.wantCount)
+            }
+        })
+    }
+}
+
+func TestBatteryRule_DecodedPayload(t *testing.T) {
+    tests := []struct {
+        name        string
+        payload     string
+        wantFlagged bool
+    }{
+        // Un 0 enviado es una batería agotada, no un campo ausente
+        {name: "batería a cero", payload: `{"device_id":"sensor-1","temperature":21,"battery_level":0}`, wantFlagged: true},
+        {name: "batería baja", payload: `{"device_id":"sensor-1","temperature":21,"battery_level":5}`, wantFlagged: true},
+        {name: "batería normal", payload: `{"device_id":"sensor-1","temperature":21,"battery_level":80}`},
+        {name: "sin batería", payload: `{"device_id":"sensor-1","temperature":21}`},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            data, _, err := decodeSensorData([]byte(tt.payload), false)
+            if err != nil {
+                t.Fatalf("decodeSensorData() error = %v", err)
+            }
+
+            anomalies := BatteryRule{}.Evaluate(&data, DefaultAnomalyThresholds())
+            if flagged := len(anomalies) == 1 && anomalies[0].Type == AnomalyBattery; flagged != tt.wantFlagged {
+                t.Errorf("batería crítica = %v (%+v), se esperaba %v", flagged, anomalies, tt.wantFlagged)
+            }
+        })
+    }
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+    "context"
+    "log"
+    "sync"
+)
+
+// Destino de exportación de anomalías (Elasticsearch, ...). A diferencia de
+// los canales de notificación, recibe todas las anomalías persistidas, sin
+// enrutado, umbrales de confianza/severidad, deduplicación, reconocimientos
+// ni horas de silencio: es un registro completo, no una alerta.
+type AnomalySink interface {
+    Name() string
+    ExportAnomaly(ctx context.Context, anomaly *Anomaly) error
+}
+
+// Destino capaz de mostrar lo que exportaría sin hacerlo (modo dry-run)
+type AnomalySinkRenderer interface {
+    RenderAnomaly(anomaly *Anomaly) (string, error)
+}
+
+// Reparte las anomalías persistidas entre los destinos registrados. Las
+// exportaciones son asíncronas para no bloquear el procesamiento de mensajes.
+type AnomalyExporter struct {
+    mutex   sync.RWMutex
+    sinks   []AnomalySink
+    pending sync.WaitGroup
+}
+
+var anomalyExporter = NewAnomalyExporter()
+
+// Crear exportador de anomalías sin destinos
+func NewAnomalyExporter() *AnomalyExporter {
+    return &AnomalyExporter{
+        sinks: make([]AnomalySink, 0),
+    }
+}
+
+// Registrar un destino de exportación
+func (ae *AnomalyExporter) Register(sink AnomalySink) {
+    ae.mutex.Lock()
+    defer ae.mutex.Unlock()
+
+    ae.sinks = append(ae.sinks, sink)
+    log.Printf("📤 EXPORTACIÓN: Destino %s registrado", sink.Name())
+}
+
+// Copia de los destinos registrados
+func (ae *AnomalyExporter) Sinks() []AnomalySink {
+    ae.mutex.RLock()
+    defer ae.mutex.RUnlock()
+
+    sinks := make([]AnomalySink, len(ae.sinks))
+    copy(sinks, ae.sinks)
+    return sinks
+}
+
+// Exportar las anomalías a todos los destinos en segundo plano
+func (ae *AnomalyExporter) Export(anomalies []Anomaly) {
+    sinks := ae.Sinks()
+    if len(sinks) == 0 || len(anomalies) == 0 {
+        return
+    }
+
+    for i := range anomalies {
+        anomaly := anomalies[i]
+        for _, sink := range sinks {
+            if dryRunNotifications {
+                ae.logDryRun(sink, &anomaly)
+                continue
+            }
+            ae.pending.Add(1)
+            go func(sink AnomalySink) {
+                defer ae.pending.Done()
+
+                ctx, cancel := context.WithTimeout(context.Background(), NOTIFICATION_TIMEOUT)
+                defer cancel()
+
+                if err := sink.ExportAnomaly(ctx, &anomaly); err != nil {
+                    metrics.Inc("iot_anomaly_export_failures", "sink", sink.Name())
+                    log.Printf("❌ EXPORTACIÓN: Error exportando a %s: %v", sink.Name(), err)
+                }
+            }(sink)
+        }
+    }
+}
+
+// Registrar lo que el destino exportaría (modo dry-run)
+func (ae *AnomalyExporter) logDryRun(sink AnomalySink, anomaly *Anomaly) {
+    renderer, ok := sink.(AnomalySinkRenderer)
+    if !ok {
+        log.Printf("🧪 DRY-RUN [%s]: el destino no permite previsualizar la exportación", sink.Name())
+        return
+    }
+    message, err := renderer.RenderAnomaly(anomaly)
+    if err != nil {
+        log.Printf("❌ DRY-RUN [%s]: Error renderizando la exportación: %v", sink.Name(), err)
+        return
+    }
+    log.Printf("🧪 DRY-RUN [%s]: %s", sink.Name(), message)
+}
+
+// Esperar a que terminen las exportaciones pendientes (o a que expire el contexto)
+func (ae *AnomalyExporter) Flush(ctx context.Context) error {
+    done := make(chan struct{})
+    go func() {
+        ae.pending.Wait()
+        close(done)
+    }()
+
+    select {
+    case <-done:
+        return nil
+    case <-ctx.Done():
+        return ctx.Err()
+    }
+}
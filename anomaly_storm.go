@@ -0,0 +1,141 @@
+package main
+
+import (
+    "fmt"
+    "log"
+    "sync"
+    "time"
+)
+
+// Protección ante tormentas de anomalías: si la tasa global supera el umbral
+// en la ventana, se persiste solo una muestra de las anomalías y las
+// notificaciones se agrupan en una única alerta de tormenta. La protección
+// termina cuando la tasa baja de la mitad del umbral.
+var (
+    anomalyStormThreshold  = 100 // anomalías por ventana (0 = deshabilitado)
+    anomalyStormWindow     = 1 * time.Minute
+    anomalyStormSampleRate = 10 // durante la tormenta se persiste 1 de cada N
+)
+
+// Estado de la protección ante tormentas
+type StormProtection struct {
+    mutex      sync.Mutex
+    recent     []time.Time
+    active     bool
+    since      time.Time
+    suppressed int
+    sampled    int
+    clock      Clock
+}
+
+var stormProtection = NewStormProtection()
+
+// Crear protección ante tormentas de anomalías
+func NewStormProtection() *StormProtection {
+    return &StormProtection{
+        recent: make([]time.Time, 0),
+        clock:  RealClock{},
+    }
+}
+
+// Cambiar el reloj con el que se mide la ventana
+func (as *StormProtection) SetClock(clock Clock) {
+    as.mutex.Lock()
+    defer as.mutex.Unlock()
+
+    as.clock = clock
+}
+
+// Registrar anomalías detectadas y devolver las que deben persistirse y si
+// deben notificarse individualmente
+func (as *StormProtection) Observe(anomalies []Anomaly) ([]Anomaly, bool) {
+    if anomalyStormThreshold <= 0 || len(anomalies) == 0 {
+        return anomalies, true
+    }
+
+    as.mutex.Lock()
+    now := as.clock.Now()
+    for range anomalies {
+        as.recent = append(as.recent, now)
+    }
+    rate := as.evaluateLocked(now)
+
+    started := false
+    if !as.active && rate > anomalyStormThreshold {
+        as.active = true
+        as.since = now
+        as.suppressed = 0
+        started = true
+    }
+
+    if !as.active {
+        as.mutex.Unlock()
+        return anomalies, true
+    }
+
+    // Muestreo de la persistencia
+    persisted := make([]Anomaly, 0, len(anomalies))
+    for _, anomaly := range anomalies {
+        if as.sampled%anomalyStormSampleRate == 0 {
+            persisted = append(persisted, anomaly)
+        }
+        as.sampled++
+    }
+    as.suppressed += len(anomalies)
+    as.mutex.Unlock()
+
+    if started {
+        log.Printf("🌩️ TORMENTA DE ANOMALÍAS: %d anomalías en %v (umbral %d), activando protección", rate, anomalyStormWindow, anomalyStormThreshold)
+        metrics.Inc("iot_anomaly_storms")
+        notificationManager.SendAnomalyAlert(Anomaly{
+            DeviceID:    "*",
+            Type:        AnomalyStorm,
+            Severity:    SEVERITY_HIGH,
+            Value:       float64(rate),
+            Description: fmt.Sprintf("tormenta de anomalías: %d en %v; notificaciones agrupadas y persistencia muestreada (1 de cada %d)", rate, anomalyStormWindow, anomalyStormSampleRate),
+            Timestamp:   now,
+            Confidence:  1,
+        })
+    }
+    return persisted, false
+}
+
+// Descartar lo que ha salido de la ventana y terminar la tormenta si la
+// tasa ha bajado de la mitad del umbral. Devuelve la tasa actual (llamar
+// con el lock tomado).
+func (as *StormProtection) evaluateLocked(now time.Time) int {
+    as.recent = pruneBefore(as.recent, now.Add(-anomalyStormWindow))
+    rate := len(as.recent)
+    if as.active && rate < anomalyStormThreshold/2 {
+        as.active = false
+        log.Printf("🌩️ TORMENTA DE ANOMALÍAS terminada tras %v: %d anomalías sin notificar", now.Sub(as.since).Round(time.Second), as.suppressed)
+    }
+    return rate
+}
+
+// Verificar si la protección está activa. Se reevalúa al consultar: sin
+// anomalías nuevas la tormenta termina igualmente al vaciarse la ventana.
+func (as *StormProtection) Active() bool {
+    as.mutex.Lock()
+    defer as.mutex.Unlock()
+
+    as.evaluateLocked(as.clock.Now())
+    return as.active
+}
+
+// Persistir, exportar y notificar anomalías respetando la protección ante
+// tormentas. La exportación recibe todo lo persistido, se notifique o no.
+// Las que no se notifican (ventanas de actualización o mantenimiento)
+// tampoco cuentan para la quarantine por tasa.
+func recordAnomalies(anomalies []Anomaly, notify bool) {
+    quarantineSystem.AttachMetadata(anomalies)
+    if notify && len(anomalies) > 0 {
+        quarantineSystem.RecordRateAnomalies(anomalies[0].DeviceID, anomalies)
+    }
+    persisted, notifyEach := stormProtection.Observe(anomalies)
+    anomalyRepository.Save(persisted)
+    anomalyExporter.Export(persisted)
+    if notify && notifyEach {
+        notifyAnomalies(persisted)
+    }
+}
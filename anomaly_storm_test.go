@@ -0,0 +1,58 @@
+package main
+
+import (
+    "testing"
+    "time"
+)
+
+func TestStormProtection_Active(t *testing.T) {
+    tests := []struct {
+        name    string
+        burst   int
+        advance time.Duration
+        want    bool
+    }{
+        {name: "por debajo del umbral", burst: 5, want: false},
+        {name: "tormenta en curso", burst: 11, want: true},
+        {name: "dentro de la ventana", burst: 11, advance: anomalyStormWindow / 2, want: true},
+        {name: "termina sin anomalías nuevas al vaciarse la ventana", burst: 11, advance: anomalyStormWindow + time.Second, want: false},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            clock := setupTestHub(t)
+            savedThreshold := anomalyStormThreshold
+            anomalyStormThreshold = 10
+            t.Cleanup(func() { anomalyStormThreshold = savedThreshold })
+
+            for i := 0; i < tt.burst; i++ {
+                stormProtection.Observe([]Anomaly{{DeviceID: "sensor-1", Type: AnomalyTemperature}})
+            }
+            clock.Advance(tt.advance)
+
+            if got := stormProtection.Active(); got != tt.want {
+                t.Errorf("Active() = %v, se esperaba %v", got, tt.want)
+            }
+        })
+    }
+}
+
+func TestStormProtection_SamplesPersistence(t *testing.T) {
+    setupTestHub(t)
+    savedThreshold, savedRate := anomalyStormThreshold, anomalyStormSampleRate
+    anomalyStormThreshold, anomalyStormSampleRate = 1, 5
+    t.Cleanup(func() { anomalyStormThreshold, anomalyStormSampleRate = savedThreshold, savedRate })
+
+    persisted := 0
+    for i := 0; i < 22; i++ {
+        kept, notifyEach := stormProtection.Observe([]Anomaly{{DeviceID: "sensor-1", Type: AnomalyTemperature}})
+        persisted += len(kept)
+        if i >= 1 && notifyEach {
+            t.Fatalf("anomalía %d: durante la tormenta no se notifica individualmente", i+1)
+        }
+    }
+    // La primera pasa antes de la tormenta; de las 21 siguientes se guarda 1 de cada 5
+    if persisted != 1+5 {
+        t.Errorf("anomalías persistidas = %d, se esperaban %d", persisted, 1+5)
+    }
+}
@@ -0,0 +1,75 @@
+package main
+
+import "testing"
+
+func TestTemperatureRule_DeviceTypeSeverity(t *testing.T) {
+    tests := []struct {
+        name         string
+        deviceType   string
+        wantSeverity string
+    }{
+        {name: "cerradura", deviceType: "smart_lock", wantSeverity: SEVERITY_HIGH},
+        {name: "sensor de aparcamiento", deviceType: "parking_sensor", wantSeverity: SEVERITY_LOW},
+        {name: "tipo sin severidad base", deviceType: "thermostat", wantSeverity: SEVERITY_MEDIUM},
+    }
+
+    t.Setenv("DEVICE_TYPE_SEVERITY", `{"smart_lock": "high", "parking_sensor": "low"}`)
+    cfg, err := LoadConfig()
+    if err != nil {
+        t.Fatalf("LoadConfig() error = %v", err)
+    }
+    saved := deviceTypeSeverity
+    t.Cleanup(func() { deviceTypeSeverity = saved })
+    deviceTypeSeverity = cfg.DeviceTypeSeverity
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            // La misma temperatura extrema en cada tipo de dispositivo
+            data := SensorData{DeviceID: "sensor-1", DeviceType: tt.deviceType, Temperature: 95}
+            anomalies := TemperatureRule{}.Evaluate(&data, DefaultAnomalyThresholds())
+            if len(anomalies) != 1 {
+                t.Fatalf("anomalías = %d, se esperaba 1", len(anomalies))
+            }
+            if got := anomalies[0].Severity; got != tt.wantSeverity {
+                t.Errorf("severidad = %s, se esperaba %s", got, tt.wantSeverity)
+            }
+        })
+    }
+}
+
+func TestDetectAnomalies_DeviceProfiles(t *testing.T) {
+    tests := []struct {
+        name        string
+        deviceType  string
+        temperature float64
+        wantFlagged bool
+    }{
+        {name: "congelador bajo cero", deviceType: "freezer", temperature: -20},
+        {name: "congelador descongelándose", deviceType: "freezer", temperature: 5, wantFlagged: true},
+        {name: "perfil por defecto bajo cero", deviceType: "temperature_sensor", temperature: -20, wantFlagged: true},
+        {name: "perfil por defecto a temperatura ambiente", deviceType: "temperature_sensor", temperature: 21},
+    }
+
+    profiles, err := loadDeviceProfiles(`{"freezer": {"temperature_min": -40, "temperature_max": 0}}`, DefaultAnomalyThresholds())
+    if err != nil {
+        t.Fatalf("loadDeviceProfiles() error = %v", err)
+    }
+    savedProfiles, savedThresholds := deviceProfiles, anomalyThresholds
+    t.Cleanup(func() { deviceProfiles, anomalyThresholds = savedProfiles, savedThresholds })
+    deviceProfiles, anomalyThresholds = profiles, DefaultAnomalyThresholds()
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            data := SensorData{DeviceID: "sensor-1", DeviceType: tt.deviceType, Temperature: tt.temperature, Humidity: 40, BatteryLevel: 80, SignalStrength: 70}
+            flagged := false
+            for _, anomaly := range detectAnomalies(&data) {
+                if anomaly.Type == AnomalyTemperature {
+                    flagged = true
+                }
+            }
+            if flagged != tt.wantFlagged {
+                t.Errorf("anomalía de temperatura = %v, se esperaba %v", flagged, tt.wantFlagged)
+            }
+        })
+    }
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+    "context"
+    "net/http"
+    "sort"
+    "strconv"
+    "time"
+)
+
+// Valores por defecto de GET /anomalies/top
+const (
+    TOP_ANOMALOUS_DEFAULT_LIMIT  = 10
+    TOP_ANOMALOUS_DEFAULT_WINDOW = 24 * time.Hour
+)
+
+// Anomalías registradas de un dispositivo
+type DeviceAnomalyCount struct {
+    DeviceID  string `json:"device_id"`
+    Anomalies int    `json:"anomalies"`
+}
+
+// Dispositivos con más anomalías desde since, de más a menos (a igualdad,
+// por ID). limit <= 0 devuelve todos.
+func (ar *AnomalyRepository) GetTopAnomalousDevices(ctx context.Context, since time.Time, limit int) ([]DeviceAnomalyCount, error) {
+    if err := ctx.Err(); err != nil {
+        return nil, err
+    }
+
+    ar.mutex.RLock()
+    counts := make(map[string]int)
+    for _, anomaly := range ar.anomalies {
+        if !anomaly.Timestamp.Before(since) {
+            counts[anomaly.DeviceID]++
+        }
+    }
+    ar.mutex.RUnlock()
+
+    result := make([]DeviceAnomalyCount, 0, len(counts))
+    for deviceID, count := range counts {
+        result = append(result, DeviceAnomalyCount{DeviceID: deviceID, Anomalies: count})
+    }
+    sort.Slice(result, func(i, j int) bool {
+        if result[i].Anomalies != result[j].Anomalies {
+            return result[i].Anomalies > result[j].Anomalies
+        }
+        return result[i].DeviceID < result[j].DeviceID
+    })
+    if limit > 0 && len(result) > limit {
+        result = result[:limit]
+    }
+    return result, nil
+}
+
+// GET /anomalies/top?limit=10&since=: dispositivos con más anomalías
+// (por defecto en las últimas 24h)
+func handleTopAnomalousDevices(w http.ResponseWriter, r *http.Request) {
+    limit := TOP_ANOMALOUS_DEFAULT_LIMIT
+    if raw := r.URL.Query().Get("limit"); raw != "" {
+        parsed, err := strconv.Atoi(raw)
+        if err != nil || parsed <= 0 {
+            writeError(w, http.StatusBadRequest, "limit inválido: "+raw)
+            return
+        }
+        limit = parsed
+    }
+
+    since, err := parseTimeParam(r, "since")
+    if err != nil {
+        writeError(w, http.StatusBadRequest, err.Error())
+        return
+    }
+    if since.IsZero() {
+        since = anomalyRepository.Now().Add(-TOP_ANOMALOUS_DEFAULT_WINDOW)
+    }
+
+    top, err := anomalyRepository.GetTopAnomalousDevices(r.Context(), since, limit)
+    if err != nil {
+        writeError(w, http.StatusInternalServerError, err.Error())
+        return
+    }
+    writeJSON(w, http.StatusOK, top)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "reflect"
+    "testing"
+    "time"
+)
+
+func TestHandleTopAnomalousDevices(t *testing.T) {
+    // Anomalías por dispositivo en las últimas 24h y de hace dos días
+    seed := []struct {
+        deviceID string
+        recent   int
+        old      int
+    }{
+        {deviceID: "sensor-d", recent: 1},
+        {deviceID: "sensor-b", recent: 2, old: 5},
+        {deviceID: "sensor-a", recent: 4},
+        {deviceID: "sensor-c", recent: 2},
+    }
+
+    tests := []struct {
+        name       string
+        query      string
+        wantStatus int
+        want       []DeviceAnomalyCount
+    }{
+        {
+            name:       "últimas 24h por defecto",
+            wantStatus: http.StatusOK,
+            want:       []DeviceAnomalyCount{{"sensor-a", 4}, {"sensor-b", 2}, {"sensor-c", 2}, {"sensor-d", 1}},
+        },
+        {
+            name:       "limitado a 2",
+            query:      "?limit=2",
+            wantStatus: http.StatusOK,
+            want:       []DeviceAnomalyCount{{"sensor-a", 4}, {"sensor-b", 2}},
+        },
+        {
+            name:       "desde hace tres días",
+            query:      "?since=" + testEpoch.Add(-72*time.Hour).Format(time.RFC3339),
+            wantStatus: http.StatusOK,
+            want:       []DeviceAnomalyCount{{"sensor-b", 7}, {"sensor-a", 4}, {"sensor-c", 2}, {"sensor-d", 1}},
+        },
+        {
+            name:       "sin anomalías en la ventana",
+            query:      "?since=" + testEpoch.Add(time.Hour).Format(time.RFC3339),
+            wantStatus: http.StatusOK,
+            want:       []DeviceAnomalyCount{},
+        },
+        {name: "limit inválido", query: "?limit=0", wantStatus: http.StatusBadRequest},
+        {name: "since inválido", query: "?since=ayer", wantStatus: http.StatusBadRequest},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            clock := setupTestHub(t)
+            for _, device := range seed {
+                for i := 0; i < device.recent; i++ {
+                    anomalyRepository.Save([]Anomaly{{DeviceID: device.deviceID, Type: AnomalyTemperature, Timestamp: clock.Now().Add(-time.Hour)}})
+                }
+                for i := 0; i < device.old; i++ {
+                    anomalyRepository.Save([]Anomaly{{DeviceID: device.deviceID, Type: AnomalyTemperature, Timestamp: clock.Now().Add(-48 * time.Hour)}})
+                }
+            }
+
+            recorder := httptest.NewRecorder()
+            newAPIRouter().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/anomalies/top"+tt.query, nil))
+            if recorder.Code != tt.wantStatus {
+                t.Fatalf("estado = %d, se esperaba %d", recorder.Code, tt.wantStatus)
+            }
+            if tt.wantStatus != http.StatusOK {
+                return
+            }
+
+            var got []DeviceAnomalyCount
+            if err := json.Unmarshal(recorder.Body.Bytes(), &got); err != nil {
+                t.Fatalf("respuesta no válida: %v", err)
+            }
+            if !reflect.DeepEqual(got, tt.want) {
+                t.Errorf("ranking = %v, se esperaba %v", got, tt.want)
+            }
+        })
+    }
+}
@@ -0,0 +1,391 @@
+package main
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "log"
+    "net/http"
+    "os"
+    "path/filepath"
+    "strconv"
+    "time"
+)
+
+// Content-Type del formato de texto OpenMetrics
+const OPENMETRICS_CONTENT_TYPE = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+// Directorio donde se vuelcan las métricas bajo demanda
+var metricsDumpDir string
+
+// Tamaño máximo del cuerpo de POST /anomalies/whatif: unos umbrales caben de sobra
+const WHATIF_MAX_BODY_BYTES = 16 << 10
+
+// Crear el router de la API REST
+func newAPIRouter() *http.ServeMux {
+    mux := http.NewServeMux()
+    mux.HandleFunc("GET /{$}", handleDashboard)
+    mux.HandleFunc("POST /ingest", requireAPIToken(handleIngest))
+    mux.HandleFunc("GET /devices", handleListDevices)
+    mux.HandleFunc("GET /quarantines", handleListQuarantines)
+    mux.HandleFunc("GET /anomalies", handleRecentAnomalies)
+    mux.HandleFunc("POST /anomalies/whatif", requireAPIToken(handleWhatIf))
+    mux.HandleFunc("GET /anomalies/export", handleExportAnomalies)
+    mux.HandleFunc("GET /anomalies/top", handleTopAnomalousDevices)
+    mux.HandleFunc("GET /anomalies/{id}", handleGetAnomaly)
+    mux.HandleFunc("POST /anomalies/{id}/review", requireAPIToken(handleReviewAnomaly))
+    mux.HandleFunc("POST /anomalies/{id}/acknowledge", requireAPIToken(handleAcknowledgeAnomaly))
+    mux.HandleFunc("POST /anomalies/{id}/resolve", requireAPIToken(handleResolveAnomaly))
+    mux.HandleFunc("GET /anomalies/sla", handleAnomalySLA)
+    mux.HandleFunc("GET /metrics", handleMetrics)
+    mux.HandleFunc("POST /metrics/dump", requireAPIToken(handleMetricsDump))
+    mux.HandleFunc("GET /stats", handleStats)
+    mux.HandleFunc("GET /healthz", handleHealthz)
+    mux.HandleFunc("GET /config", requireAPIToken(handleGetConfig))
+    mux.HandleFunc("POST /devices/{id}/firmware-update", requireAPIToken(handleStartFirmwareUpdate))
+    mux.HandleFunc("DELETE /devices/{id}/firmware-update", requireAPIToken(handleEndFirmwareUpdate))
+    mux.HandleFunc("GET /devices/{id}/reputation", handleDeviceReputation)
+    mux.HandleFunc("GET /devices/{id}/stats", handleDeviceStats)
+    mux.HandleFunc("GET /devices/{id}/anomalies", handleDeviceAnomalies)
+    mux.HandleFunc("GET /devices/{id}/diagnostics", handleDeviceDiagnostics)
+    mux.HandleFunc("GET /devices/{id}/timeline", handleDeviceTimeline)
+    mux.HandleFunc("GET /devices/{id}/history", handleDeviceHistory)
+    mux.HandleFunc("GET /devices/{id}/routing", handleGetDeviceRouting)
+    mux.HandleFunc("PUT /devices/{id}/routing", requireAPIToken(handleSetDeviceRouting))
+    mux.HandleFunc("DELETE /devices/{id}/routing", requireAPIToken(handleClearDeviceRouting))
+    mux.HandleFunc("GET /devices/{id}/metadata", handleGetDeviceMetadata)
+    mux.HandleFunc("PUT /devices/{id}/metadata", requireAPIToken(handleSetDeviceMetadata))
+    mux.HandleFunc("DELETE /devices/{id}", requireAPIToken(handleDeleteDevice))
+    mux.HandleFunc("DELETE /devices/{id}/quarantine", requireAPIToken(handleReleaseQuarantine))
+    mux.HandleFunc("GET /quarantines/releases", handleQuarantineReleases)
+    mux.HandleFunc("GET /groups/{group}/summary", handleGroupSummary)
+    mux.HandleFunc("POST /groups/{group}/maintenance", requireAPIToken(handleStartGroupMaintenance))
+    mux.HandleFunc("DELETE /groups/{group}/maintenance", requireAPIToken(handleEndGroupMaintenance))
+    return mux
+}
+
+// Arrancar el servidor HTTP de la API
+func startAPIServer(addr string) *http.Server {
+    server := &http.Server{
+        Addr:    addr,
+        Handler: newAPIRouter(),
+    }
+
+    go func() {
+        if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+            log.Printf("❌ Error en servidor HTTP: %v", err)
+        }
+    }()
+
+    return server
+}
+
+// Responder con JSON
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(status)
+    if err := json.NewEncoder(w).Encode(value); err != nil {
+        log.Printf("❌ Error escribiendo respuesta JSON: %v", err)
+    }
+}
+
+// Responder con un error en JSON
+func writeError(w http.ResponseWriter, status int, message string) {
+    writeJSON(w, status, map[string]string{"error": message})
+}
+
+// POST /anomalies/whatif: evaluar umbrales propuestos contra el historial
+func handleWhatIf(w http.ResponseWriter, r *http.Request) {
+    // Los campos omitidos conservan el valor de los umbrales actuales
+    proposed := anomalyThresholds
+    body := http.MaxBytesReader(w, r.Body, WHATIF_MAX_BODY_BYTES)
+    if err := json.NewDecoder(body).Decode(&proposed); err != nil {
+        var tooLarge *http.MaxBytesError
+        if errors.As(err, &tooLarge) {
+            writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("cuerpo demasiado grande (máximo %d bytes)", WHATIF_MAX_BODY_BYTES))
+            return
+        }
+        writeError(w, http.StatusBadRequest, "umbrales inválidos: "+err.Error())
+        return
+    }
+
+    result := replayWithThresholds(readingHistory.All(), anomalyThresholds, proposed)
+    writeJSON(w, http.StatusOK, result)
+}
+
+// GET /anomalies/{id}: detalle de una anomalía
+func handleGetAnomaly(w http.ResponseWriter, r *http.Request) {
+    anomaly, err := anomalyRepository.Get(r.PathValue("id"))
+    if err != nil {
+        writeError(w, http.StatusNotFound, err.Error())
+        return
+    }
+    writeJSON(w, http.StatusOK, anomaly)
+}
+
+// POST /anomalies/{id}/review: revisión a nombre del operador autenticado
+func handleReviewAnomaly(w http.ResponseWriter, r *http.Request) {
+    anomaly, err := anomalyRepository.MarkReviewed(r.PathValue("id"), apiOperator(r))
+    if err == nil {
+        acknowledgments.Acknowledge(anomaly, notificationManager.Now())
+    }
+    writeAnomalyTransition(w, anomaly, err)
+}
+
+// POST /anomalies/{id}/acknowledge: un operador reconoce la anomalía
+func handleAcknowledgeAnomaly(w http.ResponseWriter, r *http.Request) {
+    anomaly, err := anomalyRepository.Acknowledge(r.PathValue("id"))
+    if err == nil {
+        acknowledgments.Acknowledge(anomaly, notificationManager.Now())
+    }
+    writeAnomalyTransition(w, anomaly, err)
+}
+
+// POST /anomalies/{id}/resolve: la anomalía queda resuelta
+func handleResolveAnomaly(w http.ResponseWriter, r *http.Request) {
+    anomaly, err := anomalyRepository.Resolve(r.PathValue("id"))
+    writeAnomalyTransition(w, anomaly, err)
+}
+
+// Responder al cambio de estado de una anomalía
+func writeAnomalyTransition(w http.ResponseWriter, anomaly Anomaly, err error) {
+    switch {
+    case errors.Is(err, ErrAnomalyNotFound):
+        writeError(w, http.StatusNotFound, err.Error())
+    case err != nil:
+        writeError(w, http.StatusConflict, err.Error())
+    default:
+        writeJSON(w, http.StatusOK, anomaly)
+    }
+}
+
+// GET /anomalies/sla: cumplimiento de los tiempos de reconocimiento/resolución por severidad
+func handleAnomalySLA(w http.ResponseWriter, r *http.Request) {
+    writeJSON(w, http.StatusOK, computeSLAReport(anomalyRepository.All(), slaTargets, anomalyRepository.Now()))
+}
+
+// GET /metrics: métricas actuales en formato OpenMetrics
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+    w.Header().Set("Content-Type", OPENMETRICS_CONTENT_TYPE)
+    if err := metrics.WriteOpenMetrics(w); err != nil {
+        log.Printf("❌ Error escribiendo métricas: %v", err)
+    }
+}
+
+// POST /metrics/dump: volcar las métricas a un fichero para análisis offline
+func handleMetricsDump(w http.ResponseWriter, r *http.Request) {
+    path := filepath.Join(metricsDumpDir, fmt.Sprintf("metrics-%s.txt", time.Now().Format("20060102-150405")))
+
+    file, err := os.Create(path)
+    if err != nil {
+        writeError(w, http.StatusInternalServerError, "no se pudo crear el volcado: "+err.Error())
+        return
+    }
+    defer file.Close()
+
+    if err := metrics.WriteOpenMetrics(file); err != nil {
+        writeError(w, http.StatusInternalServerError, "no se pudo escribir el volcado: "+err.Error())
+        return
+    }
+
+    log.Printf("📊 Métricas volcadas en %s", path)
+    writeJSON(w, http.StatusOK, map[string]string{"path": path})
+}
+
+// GET /stats: tasas de ingesta/procesamiento y profundidad de la cola
+func handleStats(w http.ResponseWriter, r *http.Request) {
+    writeJSON(w, http.StatusOK, throughput.Stats())
+}
+
+// POST /devices/{id}/firmware-update: marcar dispositivo en actualización
+// (query opcional ?window=15m, por defecto FIRMWARE_UPDATE_WINDOW)
+func handleStartFirmwareUpdate(w http.ResponseWriter, r *http.Request) {
+    window := firmwareUpdateWindow
+    if raw := r.URL.Query().Get("window"); raw != "" {
+        parsed, err := time.ParseDuration(raw)
+        if err != nil || parsed <= 0 {
+            writeError(w, http.StatusBadRequest, "window inválida: "+raw)
+            return
+        }
+        window = parsed
+    }
+
+    deviceID := r.PathValue("id")
+    until := quarantineSystem.SetUpdating(deviceID, window)
+    writeJSON(w, http.StatusOK, map[string]interface{}{
+        "device_id": deviceID,
+        "updating":  true,
+        "until":     until,
+    })
+}
+
+// DELETE /devices/{id}/firmware-update: terminar la actualización
+func handleEndFirmwareUpdate(w http.ResponseWriter, r *http.Request) {
+    deviceID := r.PathValue("id")
+    quarantineSystem.ClearUpdating(deviceID)
+    writeJSON(w, http.StatusOK, map[string]interface{}{
+        "device_id": deviceID,
+        "updating":  false,
+    })
+}
+
+// GET /devices/{id}/reputation: puntuación de confianza del dispositivo y sus factores
+func handleDeviceReputation(w http.ResponseWriter, r *http.Request) {
+    reputation, found := quarantineSystem.GetReputation(r.PathValue("id"))
+    if !found {
+        writeError(w, http.StatusNotFound, "dispositivo no encontrado")
+        return
+    }
+    writeJSON(w, http.StatusOK, reputation)
+}
+
+// GET /devices/{id}/stats: mensajes totales, rechazados y anómalos del dispositivo
+func handleDeviceStats(w http.ResponseWriter, r *http.Request) {
+    stats, found := quarantineSystem.GetDeviceStats(r.PathValue("id"))
+    if !found {
+        writeError(w, http.StatusNotFound, "dispositivo no encontrado")
+        return
+    }
+    writeJSON(w, http.StatusOK, stats)
+}
+
+// GET /devices/{id}/anomalies?unreviewed=true: anomalías del dispositivo
+func handleDeviceAnomalies(w http.ResponseWriter, r *http.Request) {
+    unreviewedOnly, _ := strconv.ParseBool(r.URL.Query().Get("unreviewed"))
+    writeJSON(w, http.StatusOK, anomalyRepository.ByDevice(r.PathValue("id"), unreviewedOnly))
+}
+
+// GET /devices/{id}/diagnostics: último diagnóstico reportado
+func handleDeviceDiagnostics(w http.ResponseWriter, r *http.Request) {
+    diagnostics, found := quarantineSystem.GetDiagnostics(r.PathValue("id"))
+    if !found {
+        writeError(w, http.StatusNotFound, "el dispositivo no ha reportado diagnósticos")
+        return
+    }
+    writeJSON(w, http.StatusOK, diagnostics)
+}
+
+// GET /devices/{id}/routing: canales fijados para el dispositivo
+func handleGetDeviceRouting(w http.ResponseWriter, r *http.Request) {
+    deviceID := r.PathValue("id")
+    channels, found := quarantineSystem.NotificationChannels(deviceID)
+    if !found {
+        writeError(w, http.StatusNotFound, "el dispositivo usa el enrutado general")
+        return
+    }
+    writeJSON(w, http.StatusOK, DeviceRouting{DeviceID: deviceID, Channels: channels})
+}
+
+// PUT /devices/{id}/routing: {"channels": ["low-priority"]}
+func handleSetDeviceRouting(w http.ResponseWriter, r *http.Request) {
+    var request struct {
+        Channels []string `json:"channels"`
+    }
+    if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+        writeError(w, http.StatusBadRequest, "cuerpo inválido: "+err.Error())
+        return
+    }
+    if len(request.Channels) == 0 {
+        writeError(w, http.StatusBadRequest, "channels es obligatorio (DELETE para volver al enrutado general)")
+        return
+    }
+
+    registered := make(map[string]bool)
+    for _, service := range notificationManager.Services() {
+        registered[service.Name()] = true
+    }
+    for _, name := range request.Channels {
+        if !registered[name] {
+            writeError(w, http.StatusBadRequest, "canal desconocido: "+name)
+            return
+        }
+    }
+
+    deviceID := r.PathValue("id")
+    if err := quarantineSystem.SetNotificationChannels(deviceID, request.Channels); err != nil {
+        writeError(w, http.StatusServiceUnavailable, err.Error())
+        return
+    }
+    writeJSON(w, http.StatusOK, DeviceRouting{DeviceID: deviceID, Channels: request.Channels})
+}
+
+// DELETE /devices/{id}/routing: volver al enrutado general
+func handleClearDeviceRouting(w http.ResponseWriter, r *http.Request) {
+    if !quarantineSystem.ClearNotificationChannels(r.PathValue("id")) {
+        writeError(w, http.StatusNotFound, "el dispositivo usa el enrutado general")
+        return
+    }
+    w.WriteHeader(http.StatusNoContent)
+}
+
+// DELETE /devices/{id}: olvidar un dispositivo y liberar su quarantine
+func handleDeleteDevice(w http.ResponseWriter, r *http.Request) {
+    deviceID := r.PathValue("id")
+    if !quarantineSystem.DeleteDevice(deviceID) {
+        writeError(w, http.StatusNotFound, "dispositivo no encontrado")
+        return
+    }
+    log.Printf("🗑️ Dispositivo %s eliminado vía API", deviceID)
+    w.WriteHeader(http.StatusNoContent)
+}
+
+// DELETE /devices/{id}/quarantine: liberación manual a nombre del operador autenticado
+func handleReleaseQuarantine(w http.ResponseWriter, r *http.Request) {
+    if !quarantineSystem.ReleaseFromQuarantine(r.PathValue("id"), ReleaseReasonManual, apiOperator(r)) {
+        writeError(w, http.StatusNotFound, "el dispositivo no está en cuarentena")
+        return
+    }
+    w.WriteHeader(http.StatusNoContent)
+}
+
+// GET /quarantines/releases?device=sensor_001: registro de liberaciones
+func handleQuarantineReleases(w http.ResponseWriter, r *http.Request) {
+    writeJSON(w, http.StatusOK, quarantineSystem.Releases(r.URL.Query().Get("device")))
+}
+
+// GET /groups/{group}/summary: estado agregado de un grupo de dispositivos
+func handleGroupSummary(w http.ResponseWriter, r *http.Request) {
+    summary, found := summarizeGroup(r.PathValue("group"), quarantineSystem, anomalyRepository)
+    if !found {
+        writeError(w, http.StatusNotFound, "grupo no encontrado")
+        return
+    }
+    writeJSON(w, http.StatusOK, summary)
+}
+
+// POST /groups/{group}/maintenance: declarar mantenimiento de todo el grupo
+// (query opcional ?window=4h, por defecto GROUP_MAINTENANCE_WINDOW)
+func handleStartGroupMaintenance(w http.ResponseWriter, r *http.Request) {
+    group := r.PathValue("group")
+    if _, exists := deviceGroups[group]; !exists {
+        writeError(w, http.StatusNotFound, "grupo no encontrado")
+        return
+    }
+
+    window := groupMaintenanceWindow
+    if raw := r.URL.Query().Get("window"); raw != "" {
+        parsed, err := time.ParseDuration(raw)
+        if err != nil || parsed <= 0 {
+            writeError(w, http.StatusBadRequest, "window inválida: "+raw)
+            return
+        }
+        window = parsed
+    }
+
+    until := groupMaintenance.Start(group, window)
+    writeJSON(w, http.StatusOK, map[string]interface{}{
+        "group":       group,
+        "maintenance": true,
+        "until":       until,
+    })
+}
+
+// DELETE /groups/{group}/maintenance: terminar el mantenimiento del grupo
+func handleEndGroupMaintenance(w http.ResponseWriter, r *http.Request) {
+    group := r.PathValue("group")
+    groupMaintenance.End(group)
+    writeJSON(w, http.StatusOK, map[string]interface{}{
+        "group":       group,
+        "maintenance": false,
+    })
+}
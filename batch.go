@@ -0,0 +1,71 @@
+package main
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "log"
+    "net/http"
+)
+
+// Separar un payload JSON que es un array de lecturas (gateways que agregan
+// varios dispositivos). Devuelve false si el payload no es un array JSON.
+func splitBatchPayload(payload []byte) ([]json.RawMessage, bool) {
+    trimmed := bytes.TrimLeft(payload, " \t\r\n")
+    if len(trimmed) == 0 || trimmed[0] != '[' {
+        return nil, false
+    }
+
+    var readings []json.RawMessage
+    if err := json.Unmarshal(trimmed, &readings); err != nil {
+        return nil, false
+    }
+    return readings, true
+}
+
+// Resultado de una lectura de un lote, para responder a la ingesta HTTP
+type BatchReadingResult struct {
+    Index    int    `json:"index"`
+    DeviceID string `json:"device_id,omitempty"`
+    Status   int    `json:"status"`
+    Error    string `json:"error,omitempty"`
+}
+
+// Procesar cada lectura de un lote por separado: rate limit, validación y
+// detección de anomalías se aplican por lectura. Devuelve el resultado de
+// cada una y los errores de las rechazadas combinados en uno solo.
+func processBatch(ctx context.Context, topic string, readings []json.RawMessage) ([]BatchReadingResult, error) {
+    log.Printf("📚 LOTE: %d lecturas", len(readings))
+
+    results := make([]BatchReadingResult, len(readings))
+    var errs []error
+    for i, raw := range readings {
+        results[i] = BatchReadingResult{Index: i, Status: http.StatusAccepted}
+        err := ctx.Err()
+        if err == nil {
+            var data SensorData
+            var droppedFields []string
+            data, droppedFields, err = decodeSensorData(raw, lenientDecoding)
+            if err != nil {
+                metrics.Inc("iot_messages_rejected", "reason", "invalid_json")
+                recordDeadLetter(topic, raw, err)
+            } else {
+                results[i].DeviceID = data.DeviceID
+                err = processSensorData(ctx, data, droppedFields, raw)
+            }
+        }
+        if err != nil {
+            results[i].Status = ingestErrorStatus(err)
+            results[i].Error = err.Error()
+            errs = append(errs, fmt.Errorf("lectura %d: %w", i, err))
+        }
+    }
+
+    err := errors.Join(errs...)
+    if err != nil {
+        log.Printf("⚠️ LOTE: %d de %d lecturas rechazadas: %v", len(errs), len(readings), err)
+    }
+    return results, err
+}
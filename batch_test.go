@@ -0,0 +1,60 @@
+package main
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestProcessBatch_OneInvalidReading(t *testing.T) {
+    tests := []struct {
+        name         string
+        invalid      string
+        wantDeviceID string
+    }{
+        {
+            name:         "timestamp fuera de rango",
+            invalid:      fmt.Sprintf(`{"device_id":"sensor-2","timestamp":%d,"temperature":21,"humidity":40,"battery_level":80}`, testEpoch.Add(24*time.Hour).Unix()),
+            wantDeviceID: "sensor-2",
+        },
+        {name: "lectura que no se decodifica", invalid: `{"device_id":5}`},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            setupTestHub(t)
+            readings := []json.RawMessage{
+                json.RawMessage(validReadingJSON("sensor-1")),
+                json.RawMessage(tt.invalid),
+                json.RawMessage(validReadingJSON("sensor-3")),
+            }
+
+            results, err := processBatch(context.Background(), "test", readings)
+            if err == nil || !strings.Contains(err.Error(), "lectura 1") {
+                t.Fatalf("error = %v, se esperaba uno sobre la lectura 1", err)
+            }
+            if len(results) != len(readings) {
+                t.Fatalf("resultados = %d, se esperaban %d", len(results), len(readings))
+            }
+
+            want := []BatchReadingResult{
+                {Index: 0, DeviceID: "sensor-1", Status: http.StatusAccepted},
+                {Index: 1, DeviceID: tt.wantDeviceID, Status: http.StatusBadRequest},
+                {Index: 2, DeviceID: "sensor-3", Status: http.StatusAccepted},
+            }
+            for i, result := range results {
+                if (result.Error != "") != (want[i].Status != http.StatusAccepted) {
+                    t.Errorf("lectura %d: error = %q con estado esperado %d", i, result.Error, want[i].Status)
+                }
+                result.Error = ""
+                if result != want[i] {
+                    t.Errorf("lectura %d: resultado = %+v, se esperaba %+v", i, result, want[i])
+                }
+            }
+        })
+    }
+}
@@ -0,0 +1,42 @@
+package main
+
+import "fmt"
+
+// Patrones de comportamiento con severidad configurable
+const (
+    BEHAVIOR_BRUTE_FORCE        = "brute_force"
+    BEHAVIOR_TEMPERATURE_CHANGE = "temperature_change"
+    BEHAVIOR_HUMIDITY_CHANGE    = "humidity_change"
+    BEHAVIOR_BATTERY_DROP       = "battery_drop"
+    BEHAVIOR_SECURITY_DOWNGRADE = "security_downgrade"
+)
+
+// Severidad por patrón de comportamiento: {"brute_force": "high"}. Los
+// patrones sin entrada usan la severidad base del tipo de dispositivo.
+var behaviorSeverity = map[string]string{
+    BEHAVIOR_BRUTE_FORCE:        SEVERITY_HIGH,
+    BEHAVIOR_SECURITY_DOWNGRADE: SEVERITY_HIGH,
+}
+
+// Severidad de una anomalía de comportamiento
+func behaviorPatternSeverity(pattern, deviceType string) string {
+    if severity, exists := behaviorSeverity[pattern]; exists {
+        return severity
+    }
+    return baseSeverity(deviceType)
+}
+
+// Validar patrones y severidades configurados
+func validateBehaviorSeverity(severities map[string]string) error {
+    for pattern, severity := range severities {
+        switch pattern {
+        case BEHAVIOR_BRUTE_FORCE, BEHAVIOR_TEMPERATURE_CHANGE, BEHAVIOR_HUMIDITY_CHANGE, BEHAVIOR_BATTERY_DROP, BEHAVIOR_SECURITY_DOWNGRADE:
+        default:
+            return fmt.Errorf("patrón de comportamiento desconocido: %q", pattern)
+        }
+        if severityRank(severity) == 0 {
+            return fmt.Errorf("severidad inválida para %s: %q", pattern, severity)
+        }
+    }
+    return nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+    "testing"
+    "time"
+)
+
+func TestAnalyzeDeviceBehavior_PatternSeverity(t *testing.T) {
+    baseline := SensorData{Temperature: 20, Humidity: 40, BatteryLevel: 90}
+
+    tests := []struct {
+        name         string
+        severityEnv  string
+        readings     []SensorData
+        metric       string
+        wantSeverity string
+    }{
+        {
+            name:         "fuerza bruta",
+            readings:     []SensorData{{AccessAttempts: 8}, {AccessAttempts: 8}, {AccessAttempts: 8}},
+            metric:       METRIC_ACCESS_ATTEMPTS,
+            wantSeverity: SEVERITY_HIGH,
+        },
+        {
+            name:         "cambio drástico de temperatura",
+            readings:     []SensorData{{Temperature: 45, Humidity: 40, BatteryLevel: 90}},
+            metric:       METRIC_TEMPERATURE,
+            wantSeverity: SEVERITY_MEDIUM,
+        },
+        {
+            name:         "caída súbita de batería",
+            readings:     []SensorData{{Temperature: 20, Humidity: 40, BatteryLevel: 30}},
+            metric:       METRIC_BATTERY_LEVEL,
+            wantSeverity: SEVERITY_MEDIUM,
+        },
+        {
+            name:         "fuerza bruta rebajada por configuración",
+            severityEnv:  `{"brute_force": "medium"}`,
+            readings:     []SensorData{{AccessAttempts: 8}, {AccessAttempts: 8}, {AccessAttempts: 8}},
+            metric:       METRIC_ACCESS_ATTEMPTS,
+            wantSeverity: SEVERITY_MEDIUM,
+        },
+        {
+            name:         "batería elevada por configuración",
+            severityEnv:  `{"battery_drop": "high"}`,
+            readings:     []SensorData{{Temperature: 20, Humidity: 40, BatteryLevel: 30}},
+            metric:       METRIC_BATTERY_LEVEL,
+            wantSeverity: SEVERITY_HIGH,
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            clock := setupTestHub(t)
+            if tt.severityEnv != "" {
+                t.Setenv("BEHAVIOR_SEVERITY", tt.severityEnv)
+            }
+            cfg, err := LoadConfig()
+            if err != nil {
+                t.Fatalf("LoadConfig() error = %v", err)
+            }
+            saved := behaviorSeverity
+            t.Cleanup(func() { behaviorSeverity = saved })
+            behaviorSeverity = cfg.BehaviorSeverity
+
+            var alerts []Anomaly
+            for _, reading := range append([]SensorData{baseline}, tt.readings...) {
+                clock.Advance(time.Minute)
+                reading.DeviceID = "sensor-1"
+                reading.Timestamp = clock.Now().Unix()
+                alerts = append(alerts, quarantineSystem.AnalyzeDeviceBehavior(&reading)...)
+            }
+
+            var found *Anomaly
+            for i := range alerts {
+                if alerts[i].Type == AnomalyBehaviorPattern && alerts[i].Metric == tt.metric {
+                    found = &alerts[i]
+                }
+            }
+            if found == nil {
+                t.Fatalf("no se detectó el patrón de %s entre %v", tt.metric, alerts)
+            }
+            if found.Severity != tt.wantSeverity {
+                t.Errorf("severidad = %s, se esperaba %s", found.Severity, tt.wantSeverity)
+            }
+        })
+    }
+}
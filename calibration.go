@@ -0,0 +1,92 @@
+package main
+
+import (
+    "math"
+    "time"
+)
+
+// Lecturas iniciales con las que se fija la línea base de calibración
+const CALIBRATION_BASELINE_SAMPLES = 20
+
+// Peso de cada lectura en el promedio a largo plazo (horizonte de ~1/α lecturas)
+const CALIBRATION_LONG_TERM_ALPHA = 0.01
+
+// Desviación absoluta respecto a la línea base que indica deriva (0 = deshabilitado)
+var calibrationDriftThreshold = 5.0
+
+// Tiempo mínimo desde la puesta en servicio antes de evaluar la deriva
+var calibrationDriftPeriod = 7 * 24 * time.Hour
+
+// Métricas en las que se vigila la deriva (la batería baja sola con el uso)
+var calibrationMetrics = []string{METRIC_TEMPERATURE, METRIC_HUMIDITY}
+
+// Línea base de una métrica y su promedio a largo plazo
+type CalibrationBaseline struct {
+    Baseline      float64
+    Samples       int
+    LongTermAvg   float64
+    EstablishedAt time.Time
+    // Evita repetir la alerta mientras la deriva persista
+    Flagged bool
+}
+
+// Detectar deriva lenta de calibración: el promedio a largo plazo se aleja de
+// la línea base de puesta en servicio (llamar con el lock tomado)
+func (qs *QuarantineSystem) detectCalibrationDriftLocked(data *SensorData, behavior *DeviceBehavior) []Anomaly {
+    if calibrationDriftThreshold <= 0 {
+        return nil
+    }
+    if behavior.Calibration == nil {
+        behavior.Calibration = make(map[string]*CalibrationBaseline)
+    }
+
+    var alerts []Anomaly
+    values := metricValues(data)
+    now := qs.clock.Now()
+
+    for _, metric := range calibrationMetrics {
+        value, present := values[metric]
+        if !present {
+            continue
+        }
+
+        calibration := behavior.Calibration[metric]
+        if calibration == nil {
+            calibration = &CalibrationBaseline{}
+            behavior.Calibration[metric] = calibration
+        }
+
+        // Fase de puesta en servicio: media de las primeras lecturas
+        if calibration.Samples < CALIBRATION_BASELINE_SAMPLES {
+            calibration.Samples++
+            calibration.Baseline += (value - calibration.Baseline) / float64(calibration.Samples)
+            calibration.LongTermAvg = calibration.Baseline
+            if calibration.Samples == CALIBRATION_BASELINE_SAMPLES {
+                calibration.EstablishedAt = now
+            }
+            continue
+        }
+
+        calibration.LongTermAvg += CALIBRATION_LONG_TERM_ALPHA * (value - calibration.LongTermAvg)
+        if now.Sub(calibration.EstablishedAt) < calibrationDriftPeriod {
+            continue
+        }
+
+        drift := calibration.LongTermAvg - calibration.Baseline
+        if math.Abs(drift) <= calibrationDriftThreshold {
+            calibration.Flagged = false
+            continue
+        }
+        if calibration.Flagged {
+            continue
+        }
+
+        calibration.Flagged = true
+        alert := newAnomaly(data, AnomalyCalibrationDrift, calibration.LongTermAvg, "deriva de calibración en %s: promedio %.1f vs línea base %.1f (%+.1f), revisar sensor", metric, calibration.LongTermAvg, calibration.Baseline, drift)
+        alert.Severity = SEVERITY_LOW
+        alert.Metric = metric
+        alerts = append(alerts, alert)
+    }
+
+    return alerts
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+    "testing"
+    "time"
+)
+
+func TestDetectCalibrationDrift(t *testing.T) {
+    tests := []struct {
+        name      string
+        drift     float64
+        threshold float64
+        interval  time.Duration
+        wantDrift bool
+    }{
+        {name: "línea base estable", drift: 0.5, interval: time.Hour},
+        {name: "deriva lenta por encima del umbral", drift: 10, interval: time.Hour, wantDrift: true},
+        {name: "deriva antes del periodo", drift: 10, interval: time.Minute},
+        {name: "deriva bajo un umbral mayor", drift: 10, threshold: 20, interval: time.Hour},
+    }
+
+    const readings = 600
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            clock := setupTestHub(t)
+            saved := calibrationDriftThreshold
+            t.Cleanup(func() { calibrationDriftThreshold = saved })
+            if tt.threshold > 0 {
+                calibrationDriftThreshold = tt.threshold
+            }
+            behavior := &DeviceBehavior{}
+
+            // Puesta en servicio a 20 °C y luego una subida lineal muy lenta
+            var alerts []Anomaly
+            for i := 0; i < readings; i++ {
+                temperature := 20.0
+                if i >= CALIBRATION_BASELINE_SAMPLES {
+                    temperature += tt.drift * float64(i-CALIBRATION_BASELINE_SAMPLES) / float64(readings-CALIBRATION_BASELINE_SAMPLES)
+                }
+                data := &SensorData{DeviceID: "sensor-1", Temperature: temperature, Humidity: 40}
+
+                quarantineSystem.mutex.Lock()
+                alerts = append(alerts, quarantineSystem.detectCalibrationDriftLocked(data, behavior)...)
+                quarantineSystem.mutex.Unlock()
+                clock.Advance(tt.interval)
+            }
+
+            if !tt.wantDrift {
+                if len(alerts) != 0 {
+                    t.Errorf("alertas = %d, no se esperaba ninguna", len(alerts))
+                }
+                return
+            }
+            // Una sola alerta mientras la deriva persista
+            if len(alerts) != 1 {
+                t.Fatalf("alertas = %d, se esperaba 1", len(alerts))
+            }
+            alert := alerts[0]
+            if alert.Type != AnomalyCalibrationDrift || alert.Severity != SEVERITY_LOW || alert.Metric != METRIC_TEMPERATURE {
+                t.Errorf("alerta = %s/%s/%s, se esperaba %s/%s/%s", alert.Type, alert.Severity, alert.Metric, AnomalyCalibrationDrift, SEVERITY_LOW, METRIC_TEMPERATURE)
+            }
+        })
+    }
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+    "fmt"
+    "strings"
+
+    "github.com/fxamacker/cbor/v2"
+)
+
+// Sufijo de topic con el que los dispositivos con recursos limitados indican
+// que publican en CBOR (p. ej. iot/sensors/cbor)
+const CBOR_TOPIC_SUFFIX = "/cbor"
+
+// Decodificar un payload según su formato. JSON es el formato por defecto;
+// se usa CBOR si el topic termina en CBOR_TOPIC_SUFFIX o si el payload no es
+// JSON pero sí un CBOR válido con device_id.
+func decodePayload(topic string, payload []byte, lenient bool) (SensorData, []string, error) {
+    if strings.HasSuffix(topic, CBOR_TOPIC_SUFFIX) {
+        data, err := decodeCBORSensorData(payload)
+        return data, nil, err
+    }
+
+    data, dropped, err := decodeSensorData(payload, lenient)
+    if err != nil {
+        if cborData, cborErr := decodeCBORSensorData(payload); cborErr == nil {
+            return cborData, nil, nil
+        }
+    }
+    return data, dropped, err
+}
+
+// Decodificar un payload CBOR de sensor (usa las mismas claves que el JSON)
+func decodeCBORSensorData(payload []byte) (SensorData, error) {
+    var data SensorData
+    if err := cbor.Unmarshal(payload, &data); err != nil {
+        return SensorData{}, fmt.Errorf("CBOR inválido: %w", err)
+    }
+    if data.DeviceID == "" {
+        return SensorData{}, fmt.Errorf("payload CBOR sin device_id")
+    }
+    data.present = cborFieldNames(payload)
+    return data, nil
+}
+
+// Leer solo el device_id de un payload CBOR ("" si no es CBOR o no lo trae)
+func peekCBORDeviceID(payload []byte) string {
+    var header struct {
+        DeviceID string `json:"device_id"`
+    }
+    if err := cbor.Unmarshal(payload, &header); err != nil {
+        return ""
+    }
+    return header.DeviceID
+}
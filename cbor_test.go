@@ -0,0 +1,61 @@
+package main
+
+import (
+    "encoding/json"
+    "reflect"
+    "testing"
+
+    "github.com/fxamacker/cbor/v2"
+)
+
+func TestDecodePayload_CBORMatchesJSON(t *testing.T) {
+    reading := map[string]interface{}{
+        "device_id":       "sensor-1",
+        "timestamp":       testEpoch.Unix(),
+        "temperature":     21.5,
+        "humidity":        40.0,
+        "battery_level":   80.0,
+        "signal_strength": 70.0,
+    }
+
+    tests := []struct {
+        name    string
+        topic   string
+        encode  func(v interface{}) ([]byte, error)
+        wantErr bool
+    }{
+        {name: "JSON por defecto", topic: "iot/sensors", encode: json.Marshal},
+        {name: "CBOR por sufijo de topic", topic: "iot/sensors" + CBOR_TOPIC_SUFFIX, encode: cbor.Marshal},
+        {name: "CBOR detectado al fallar JSON", topic: "iot/sensors", encode: cbor.Marshal},
+        {name: "JSON en topic CBOR", topic: "iot/sensors" + CBOR_TOPIC_SUFFIX, encode: json.Marshal, wantErr: true},
+    }
+
+    jsonPayload, err := json.Marshal(reading)
+    if err != nil {
+        t.Fatalf("json.Marshal: %v", err)
+    }
+    want, _, err := decodeSensorData(jsonPayload, false)
+    if err != nil {
+        t.Fatalf("decodeSensorData: %v", err)
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            payload, err := tt.encode(reading)
+            if err != nil {
+                t.Fatalf("codificar: %v", err)
+            }
+
+            got, _, err := decodePayload(tt.topic, payload, false)
+            if (err != nil) != tt.wantErr {
+                t.Fatalf("error = %v, se esperaba error: %v", err, tt.wantErr)
+            }
+            if tt.wantErr {
+                return
+            }
+            if !reflect.DeepEqual(got, want) {
+                t.Errorf("SensorData = %+v, se esperaba %+v", got, want)
+            }
+        })
+    }
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+    "sync"
+    "time"
+)
+
+// Fuente de la hora actual. La lógica que depende del tiempo (rate limits,
+// expiración de quarantines, horas de silencio) la recibe inyectada para
+// poder probarse sin esperas reales.
+type Clock interface {
+    Now() time.Time
+}
+
+// Reloj del sistema
+type RealClock struct{}
+
+func (RealClock) Now() time.Time {
+    return time.Now()
+}
+
+// Reloj manual para pruebas: solo avanza cuando se le indica
+type FakeClock struct {
+    mutex sync.Mutex
+    now   time.Time
+}
+
+// Crear un reloj manual parado en el instante indicado
+func NewFakeClock(now time.Time) *FakeClock {
+    return &FakeClock{now: now}
+}
+
+func (fc *FakeClock) Now() time.Time {
+    fc.mutex.Lock()
+    defer fc.mutex.Unlock()
+
+    return fc.now
+}
+
+// Avanzar el reloj
+func (fc *FakeClock) Advance(d time.Duration) {
+    fc.mutex.Lock()
+    defer fc.mutex.Unlock()
+
+    fc.now = fc.now.Add(d)
+}
+
+// Fijar el reloj en un instante concreto
+func (fc *FakeClock) Set(now time.Time) {
+    fc.mutex.Lock()
+    defer fc.mutex.Unlock()
+
+    fc.now = now
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "log"
+    "strings"
+    "time"
+
+    mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// Comandos que el hub envía a los dispositivos
+const (
+    COMMAND_QUARANTINE = "quarantine"
+)
+
+// Comando enviado a un dispositivo por su topic de control
+type DeviceCommand struct {
+    Command    string `json:"command"`
+    DeviceID   string `json:"device_id"`
+    Reason     string `json:"reason,omitempty"`
+    ReasonCode string `json:"reason_code,omitempty"`
+    Duration   int64  `json:"duration_seconds,omitempty"`
+    Timestamp  int64  `json:"timestamp"`
+}
+
+// Puerto para enviar comandos a dispositivos, independiente del transporte
+type CommandPublisher interface {
+    PublishCommand(command DeviceCommand) error
+}
+
+// Publicador que no hace nada (por defecto y para pruebas)
+type NoopCommandPublisher struct{}
+
+func (NoopCommandPublisher) PublishCommand(command DeviceCommand) error {
+    return nil
+}
+
+// Publicador de comandos sobre MQTT. El topic de control admite el
+// marcador {device_id}, p. ej. "iot/control/{device_id}"
+type MQTTCommandPublisher struct {
+    client       mqtt.Client
+    topicPattern string
+}
+
+func NewMQTTCommandPublisher(client mqtt.Client, topicPattern string) *MQTTCommandPublisher {
+    return &MQTTCommandPublisher{
+        client:       client,
+        topicPattern: topicPattern,
+    }
+}
+
+// Tiempo máximo esperando la confirmación del broker para un comando
+const COMMAND_PUBLISH_TIMEOUT = 5 * time.Second
+
+// Publicar un payload arbitrario en un topic. No bloquea: se llama desde el
+// camino de procesamiento de mensajes (y por tanto desde el callback de
+// paho), así que la confirmación del broker se espera en otra goroutine y
+// los fallos solo se registran
+func (p *MQTTCommandPublisher) Publish(topic string, payload []byte) error {
+    token := p.client.Publish(topic, controlQoS(mqttQoS), false, payload)
+    go func() {
+        if err := waitPublishToken(token, topic); err != nil {
+            metrics.Inc("iot_commands_failed")
+            log.Printf("❌ Error publicando comando en %s: %v", topic, err)
+        }
+    }()
+    return nil
+}
+
+// Esperar la confirmación de una publicación con timeout
+func waitPublishToken(token mqtt.Token, topic string) error {
+    if !token.WaitTimeout(COMMAND_PUBLISH_TIMEOUT) {
+        return fmt.Errorf("timeout publicando en %s", topic)
+    }
+    return token.Error()
+}
+
+// Caracteres con significado en un topic MQTT: un device_id que los
+// contenga publicaría en otro topic o en uno con comodines
+const MQTT_TOPIC_RESERVED_CHARS = "/+#"
+
+// Comprobar que un device_id puede sustituir a {device_id} en un topic
+func validateTopicDeviceID(deviceID string) error {
+    if strings.ContainsAny(deviceID, MQTT_TOPIC_RESERVED_CHARS) {
+        return fmt.Errorf("device_id inválido: no puede contener %q", MQTT_TOPIC_RESERVED_CHARS)
+    }
+    return nil
+}
+
+// Publicar un comando en el topic de control del dispositivo
+func (p *MQTTCommandPublisher) PublishCommand(command DeviceCommand) error {
+    if err := validateTopicDeviceID(command.DeviceID); err != nil {
+        return err
+    }
+
+    payload, err := json.Marshal(command)
+    if err != nil {
+        return err
+    }
+
+    topic := strings.ReplaceAll(p.topicPattern, "{device_id}", command.DeviceID)
+    return p.Publish(topic, payload)
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+    "encoding/json"
+    "errors"
+    "sync"
+    "testing"
+    "time"
+
+    mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// Token de paho controlable desde el test: no se completa hasta Complete()
+type fakeToken struct {
+    done chan struct{}
+    err  error
+}
+
+func newFakeToken() *fakeToken {
+    return &fakeToken{done: make(chan struct{})}
+}
+
+func (t *fakeToken) Complete(err error) {
+    t.err = err
+    close(t.done)
+}
+
+func (t *fakeToken) Wait() bool {
+    <-t.done
+    return true
+}
+
+func (t *fakeToken) WaitTimeout(timeout time.Duration) bool {
+    select {
+    case <-t.done:
+        return true
+    case <-time.After(timeout):
+        return false
+    }
+}
+
+func (t *fakeToken) Done() <-chan struct{} {
+    return t.done
+}
+
+func (t *fakeToken) Error() error {
+    return t.err
+}
+
+// Publicación registrada por fakeMQTTClient
+type fakePublish struct {
+    topic    string
+    qos      byte
+    retained bool
+    payload  []byte
+}
+
+// Cliente MQTT falso: solo implementa Publish, Subscribe y Unsubscribe. El resto de
+// métodos de mqtt.Client entran en pánico si se llaman
+type fakeMQTTClient struct {
+    mqtt.Client
+    mutex      sync.Mutex
+    published  []fakePublish
+    subscribed map[string]byte
+    token      *fakeToken
+}
+
+func (c *fakeMQTTClient) Unsubscribe(topics ...string) mqtt.Token {
+    c.mutex.Lock()
+    defer c.mutex.Unlock()
+    for _, topic := range topics {
+        delete(c.subscribed, topic)
+    }
+    token := newFakeToken()
+    token.Complete(nil)
+    return token
+}
+
+func (c *fakeMQTTClient) Publish(topic string, qos byte, retained bool, payload interface{}) mqtt.Token {
+    c.mutex.Lock()
+    defer c.mutex.Unlock()
+    var data []byte
+    switch value := payload.(type) {
+    case []byte:
+        data = value
+    case string:
+        data = []byte(value)
+    }
+    c.published = append(c.published, fakePublish{topic: topic, qos: qos, retained: retained, payload: data})
+    if c.token != nil {
+        return c.token
+    }
+    token := newFakeToken()
+    token.Complete(nil)
+    return token
+}
+
+func (c *fakeMQTTClient) Subscribe(topic string, qos byte, callback mqtt.MessageHandler) mqtt.Token {
+    c.mutex.Lock()
+    defer c.mutex.Unlock()
+    if c.subscribed == nil {
+        c.subscribed = make(map[string]byte)
+    }
+    c.subscribed[topic] = qos
+    token := newFakeToken()
+    token.Complete(nil)
+    return token
+}
+
+func (c *fakeMQTTClient) SubscribeMultiple(filters map[string]byte, callback mqtt.MessageHandler) mqtt.Token {
+    c.mutex.Lock()
+    defer c.mutex.Unlock()
+    if c.subscribed == nil {
+        c.subscribed = make(map[string]byte)
+    }
+    for topic, qos := range filters {
+        c.subscribed[topic] = qos
+    }
+    token := newFakeToken()
+    token.Complete(nil)
+    return token
+}
+
+func (c *fakeMQTTClient) Published() []fakePublish {
+    c.mutex.Lock()
+    defer c.mutex.Unlock()
+    return append([]fakePublish(nil), c.published...)
+}
+
+func TestMQTTCommandPublisher_PublishCommand(t *testing.T) {
+    tests := []struct {
+        name      string
+        pattern   string
+        command   DeviceCommand
+        wantTopic string
+    }{
+        {
+            name:      "marcador sustituido",
+            pattern:   "iot/control/{device_id}",
+            command:   DeviceCommand{Command: COMMAND_QUARANTINE, DeviceID: "sensor-1", Timestamp: 1},
+            wantTopic: "iot/control/sensor-1",
+        },
+        {
+            name:      "topic fijo",
+            pattern:   "iot/control",
+            command:   DeviceCommand{Command: COMMAND_QUARANTINE, DeviceID: "sensor-2", Timestamp: 2},
+            wantTopic: "iot/control",
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            client := &fakeMQTTClient{}
+            publisher := NewMQTTCommandPublisher(client, tt.pattern)
+
+            if err := publisher.PublishCommand(tt.command); err != nil {
+                t.Fatalf("PublishCommand() error = %v", err)
+            }
+
+            published := client.Published()
+            if len(published) != 1 {
+                t.Fatalf("publicaciones = %d, se esperaba 1", len(published))
+            }
+            if published[0].topic != tt.wantTopic {
+                t.Errorf("topic = %q, se esperaba %q", published[0].topic, tt.wantTopic)
+            }
+            var decoded DeviceCommand
+            if err := json.Unmarshal(published[0].payload, &decoded); err != nil {
+                t.Fatalf("payload no es JSON: %v", err)
+            }
+            if decoded != tt.command {
+                t.Errorf("comando = %+v, se esperaba %+v", decoded, tt.command)
+            }
+        })
+    }
+}
+
+func TestMQTTCommandPublisher_RejectsTopicCharacters(t *testing.T) {
+    for _, deviceID := range []string{"sensor/1", "sensor-+", "sensor-#", "+", "#"} {
+        t.Run(deviceID, func(t *testing.T) {
+            client := &fakeMQTTClient{}
+            publisher := NewMQTTCommandPublisher(client, "iot/control/{device_id}")
+
+            err := publisher.PublishCommand(DeviceCommand{Command: COMMAND_QUARANTINE, DeviceID: deviceID, Timestamp: 1})
+            if err == nil {
+                t.Fatal("se esperaba error por device_id con caracteres de topic")
+            }
+            if published := client.Published(); len(published) != 0 {
+                t.Errorf("se publicó en %q", published[0].topic)
+            }
+        })
+    }
+}
+
+func TestValidateSensorData_TopicCharacters(t *testing.T) {
+    tests := []struct {
+        deviceID string
+        wantErr  bool
+    }{
+        {deviceID: "sensor-1"},
+        {deviceID: "planta_2.sensor-1"},
+        {deviceID: "iot/control/otro", wantErr: true},
+        {deviceID: "sensor-+", wantErr: true},
+        {deviceID: "sensor-#", wantErr: true},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.deviceID, func(t *testing.T) {
+            cfg := DefaultValidationConfig()
+            cfg.Clock = NewFakeClock(testEpoch)
+            data := SensorData{DeviceID: tt.deviceID, Timestamp: testEpoch.Unix(), Temperature: 21, Humidity: 40, BatteryLevel: 80, SignalStrength: 70}
+
+            err := validateSensorDataWith(&data, cfg)
+            if (err != nil) != tt.wantErr {
+                t.Errorf("error = %v, se esperaba error: %v", err, tt.wantErr)
+            }
+        })
+    }
+}
+
+func TestMQTTCommandPublisher_PublishDoesNotBlock(t *testing.T) {
+    // El broker nunca confirma durante la llamada: Publish debe volver ya
+    token := newFakeToken()
+    client := &fakeMQTTClient{token: token}
+    publisher := NewMQTTCommandPublisher(client, "iot/control/{device_id}")
+    before := metrics.Value("iot_commands_failed")
+
+    returned := make(chan error, 1)
+    go func() {
+        returned <- publisher.PublishCommand(DeviceCommand{Command: COMMAND_QUARANTINE, DeviceID: "sensor-1"})
+    }()
+
+    select {
+    case err := <-returned:
+        if err != nil {
+            t.Fatalf("PublishCommand() error = %v", err)
+        }
+    case <-time.After(time.Second):
+        t.Fatal("PublishCommand bloqueó esperando la confirmación del broker")
+    }
+
+    // El fallo posterior se contabiliza en segundo plano
+    token.Complete(errors.New("broker caído"))
+    deadline := time.Now().Add(time.Second)
+    for metrics.Value("iot_commands_failed") == before {
+        if time.Now().After(deadline) {
+            t.Fatal("el fallo de publicación no se contabilizó")
+        }
+        time.Sleep(5 * time.Millisecond)
+    }
+}
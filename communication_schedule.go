@@ -0,0 +1,159 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "strings"
+    "time"
+)
+
+// Horario en el que un dispositivo puede comunicarse (p. ej. un sensor solo
+// diurno). Start/End en "HH:MM" de la zona horaria indicada; si End es
+// anterior a Start la ventana cruza la medianoche. Days vacío = todos los días.
+type CommunicationSchedule struct {
+    Start      string   `json:"start"`
+    End        string   `json:"end"`
+    Timezone   string   `json:"timezone,omitempty"`
+    Days       []string `json:"days,omitempty"`
+    Quarantine bool     `json:"quarantine"`
+
+    startMinute int
+    endMinute   int
+    location    *time.Location
+    weekdays    map[time.Weekday]bool
+}
+
+// Horarios por dispositivo y por tipo; el del dispositivo tiene prioridad
+type CommunicationSchedules struct {
+    ByDevice map[string]*CommunicationSchedule `json:"by_device"`
+    ByType   map[string]*CommunicationSchedule `json:"by_type"`
+}
+
+// Horarios activos (vacío = sin restricción)
+var communicationSchedules CommunicationSchedules
+
+var scheduleWeekdays = map[string]time.Weekday{
+    "sun": time.Sunday,
+    "mon": time.Monday,
+    "tue": time.Tuesday,
+    "wed": time.Wednesday,
+    "thu": time.Thursday,
+    "fri": time.Friday,
+    "sat": time.Saturday,
+}
+
+// Cargar horarios desde JSON:
+// {"by_type": {"parking_sensor": {"start": "07:00", "end": "22:00", "timezone": "Europe/Madrid"}}}
+func loadCommunicationSchedules(raw string) (CommunicationSchedules, error) {
+    var schedules CommunicationSchedules
+    if raw == "" {
+        return schedules, nil
+    }
+    if err := json.Unmarshal([]byte(raw), &schedules); err != nil {
+        return schedules, fmt.Errorf("horarios de comunicación inválidos: %w", err)
+    }
+
+    for deviceID, schedule := range schedules.ByDevice {
+        if err := schedule.compile(); err != nil {
+            return schedules, fmt.Errorf("horario del dispositivo %s: %w", deviceID, err)
+        }
+    }
+    for deviceType, schedule := range schedules.ByType {
+        if err := schedule.compile(); err != nil {
+            return schedules, fmt.Errorf("horario del tipo %s: %w", deviceType, err)
+        }
+    }
+    return schedules, nil
+}
+
+// Minutos desde medianoche de "HH:MM"
+func parseClockMinute(value string) (int, error) {
+    parsed, err := time.Parse("15:04", value)
+    if err != nil {
+        return 0, fmt.Errorf("hora inválida %q (formato HH:MM)", value)
+    }
+    return parsed.Hour()*60 + parsed.Minute(), nil
+}
+
+// Validar y preparar el horario
+func (s *CommunicationSchedule) compile() error {
+    if s == nil {
+        return fmt.Errorf("horario vacío")
+    }
+
+    var err error
+    if s.startMinute, err = parseClockMinute(s.Start); err != nil {
+        return err
+    }
+    if s.endMinute, err = parseClockMinute(s.End); err != nil {
+        return err
+    }
+    // Una ventana vacía bloquearía al dispositivo todo el día: casi seguro un
+    // error de configuración
+    if s.startMinute == s.endMinute {
+        return fmt.Errorf("el inicio y el fin no pueden coincidir (%s)", s.Start)
+    }
+
+    s.location = time.UTC
+    if s.Timezone != "" {
+        if s.location, err = time.LoadLocation(s.Timezone); err != nil {
+            return fmt.Errorf("zona horaria inválida %q: %w", s.Timezone, err)
+        }
+    }
+
+    s.weekdays = make(map[time.Weekday]bool, len(s.Days))
+    for _, day := range s.Days {
+        weekday, known := scheduleWeekdays[strings.ToLower(day)]
+        if !known {
+            return fmt.Errorf("día inválido %q (mon, tue, ...)", day)
+        }
+        s.weekdays[weekday] = true
+    }
+    return nil
+}
+
+// Si el horario permite comunicarse en el instante indicado
+func (s *CommunicationSchedule) Allows(at time.Time) bool {
+    local := at.In(s.location)
+    minute := local.Hour()*60 + local.Minute()
+
+    // En ventanas que cruzan la medianoche, lo posterior a medianoche
+    // pertenece a la ventana que empezó el día anterior
+    day := local.Weekday()
+    var inside bool
+    switch {
+    case s.startMinute <= s.endMinute:
+        inside = minute >= s.startMinute && minute < s.endMinute
+    case minute >= s.startMinute:
+        inside = true
+    case minute < s.endMinute:
+        inside = true
+        day = local.AddDate(0, 0, -1).Weekday()
+    }
+
+    if !inside {
+        return false
+    }
+    return len(s.weekdays) == 0 || s.weekdays[day]
+}
+
+// Horario que aplica a un dispositivo (nil si no tiene)
+func (cs CommunicationSchedules) For(deviceID, deviceType string) *CommunicationSchedule {
+    if schedule, exists := cs.ByDevice[deviceID]; exists {
+        return schedule
+    }
+    return cs.ByType[deviceType]
+}
+
+// Comprobar si la lectura llega fuera del horario permitido. Devuelve la
+// anomalía y el horario incumplido, o nil si está dentro o no hay horario.
+func checkCommunicationSchedule(data *SensorData, now time.Time) (*Anomaly, *CommunicationSchedule) {
+    schedule := communicationSchedules.For(data.DeviceID, data.DeviceType)
+    if schedule == nil || schedule.Allows(now) {
+        return nil, nil
+    }
+
+    local := now.In(schedule.location)
+    anomaly := newAnomaly(data, AnomalyOutsideSchedule, 0, "comunicación fuera de horario: %s (%s), permitido %s–%s", local.Format("Mon 15:04"), schedule.location, schedule.Start, schedule.End)
+    return &anomaly, schedule
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+    "testing"
+    "time"
+)
+
+func TestLoadCommunicationSchedules(t *testing.T) {
+    tests := []struct {
+        name    string
+        raw     string
+        wantErr bool
+    }{
+        {name: "sin horarios", raw: ""},
+        {name: "diurno", raw: `{"by_type": {"parking": {"start": "07:00", "end": "22:00", "timezone": "Europe/Madrid"}}}`},
+        {name: "cruza la medianoche", raw: `{"by_device": {"sensor-1": {"start": "22:00", "end": "06:00"}}}`},
+        {name: "inicio igual al fin", raw: `{"by_device": {"sensor-1": {"start": "08:00", "end": "08:00"}}}`, wantErr: true},
+        {name: "hora inválida", raw: `{"by_device": {"sensor-1": {"start": "25:00", "end": "08:00"}}}`, wantErr: true},
+        {name: "zona horaria inválida", raw: `{"by_device": {"sensor-1": {"start": "07:00", "end": "08:00", "timezone": "Marte/Olympus"}}}`, wantErr: true},
+        {name: "día inválido", raw: `{"by_device": {"sensor-1": {"start": "07:00", "end": "08:00", "days": ["lunes"]}}}`, wantErr: true},
+        {name: "horario nulo", raw: `{"by_device": {"sensor-1": null}}`, wantErr: true},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            _, err := loadCommunicationSchedules(tt.raw)
+            if (err != nil) != tt.wantErr {
+                t.Errorf("error = %v, se esperaba error: %v", err, tt.wantErr)
+            }
+        })
+    }
+}
+
+func TestCommunicationSchedule_Allows(t *testing.T) {
+    madrid, err := time.LoadLocation("Europe/Madrid")
+    if err != nil {
+        t.Skipf("sin datos de zonas horarias: %v", err)
+    }
+
+    tests := []struct {
+        name     string
+        schedule CommunicationSchedule
+        at       time.Time
+        want     bool
+    }{
+        {name: "dentro del horario diurno", schedule: CommunicationSchedule{Start: "07:00", End: "22:00"}, at: time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC), want: true},
+        {name: "fuera del horario diurno", schedule: CommunicationSchedule{Start: "07:00", End: "22:00"}, at: time.Date(2026, 3, 2, 23, 0, 0, 0, time.UTC)},
+        {name: "el fin queda fuera", schedule: CommunicationSchedule{Start: "07:00", End: "22:00"}, at: time.Date(2026, 3, 2, 22, 0, 0, 0, time.UTC)},
+        {name: "zona horaria local", schedule: CommunicationSchedule{Start: "07:00", End: "22:00", Timezone: "Europe/Madrid"}, at: time.Date(2026, 3, 2, 21, 30, 0, 0, time.UTC)},
+        {name: "nocturno tras la medianoche", schedule: CommunicationSchedule{Start: "22:00", End: "06:00"}, at: time.Date(2026, 3, 3, 2, 0, 0, 0, time.UTC), want: true},
+        {name: "nocturno a mediodía", schedule: CommunicationSchedule{Start: "22:00", End: "06:00"}, at: time.Date(2026, 3, 3, 12, 0, 0, 0, time.UTC)},
+        // El lunes 2 de marzo de 2026 a las 02:00 pertenece a la ventana del domingo
+        {name: "madrugada del día siguiente", schedule: CommunicationSchedule{Start: "22:00", End: "06:00", Days: []string{"sun"}}, at: time.Date(2026, 3, 2, 2, 0, 0, 0, madrid), want: true},
+        {name: "día no permitido", schedule: CommunicationSchedule{Start: "07:00", End: "22:00", Days: []string{"sat", "sun"}}, at: time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            schedule := tt.schedule
+            if err := schedule.compile(); err != nil {
+                t.Fatalf("compile: %v", err)
+            }
+            if got := schedule.Allows(tt.at); got != tt.want {
+                t.Errorf("Allows = %v, se esperaba %v", got, tt.want)
+            }
+        })
+    }
+}
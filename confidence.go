@@ -0,0 +1,46 @@
+package main
+
+import "math"
+
+// Exceso relativo sobre el umbral con el que la confianza llega a ~63%
+const CONFIDENCE_SCALE = 0.25
+
+// Lecturas a partir de las cuales la línea base del dispositivo es fiable
+const CONFIDENCE_MIN_HISTORY = 10
+
+// Confianza mínima para notificar una anomalía (0 = notificar todas).
+// Las anomalías por debajo se registran igualmente.
+var notificationMinConfidence = 0.0
+
+// Severidad mínima para notificar una anomalía (vacío = notificar todas).
+// Las anomalías por debajo se registran igualmente.
+var notificationMinSeverity = ""
+
+// Confianza (0–1) según cuánto supera el valor al umbral, relativo a la
+// escala de la métrica: justo en el umbral ~0, muy por encima → 1
+func thresholdConfidence(value, limit, scale float64) float64 {
+    if scale <= 0 {
+        return 1
+    }
+    excess := math.Abs(value-limit) / scale
+    return 1 - math.Exp(-excess/CONFIDENCE_SCALE)
+}
+
+// Confianza de un valor fuera del rango [min, max]
+func rangeConfidence(value, min, max float64) float64 {
+    limit := min
+    if value > max {
+        limit = max
+    }
+    return thresholdConfidence(value, limit, max-min)
+}
+
+// Reducir la confianza cuando el dispositivo tiene poco historial: su
+// promedio todavía no es una referencia fiable
+func historyConfidence(confidence float64, samples int) float64 {
+    if samples >= CONFIDENCE_MIN_HISTORY {
+        return confidence
+    }
+    weight := 0.5 + 0.5*float64(samples)/CONFIDENCE_MIN_HISTORY
+    return confidence * weight
+}
@@ -0,0 +1,88 @@
+package main
+
+import "testing"
+
+func TestRuleConfidence(t *testing.T) {
+    tests := []struct {
+        name     string
+        rule     Rule
+        data     SensorData
+        wantHigh bool
+    }{
+        {name: "temperatura justo por encima", rule: TemperatureRule{}, data: SensorData{Temperature: 50.5}},
+        {name: "temperatura extrema", rule: TemperatureRule{}, data: SensorData{Temperature: 95}, wantHigh: true},
+        {name: "batería justo por debajo", rule: BatteryRule{}, data: SensorData{BatteryLevel: 9.5}},
+        {name: "batería agotada", rule: BatteryRule{}, data: SensorData{BatteryLevel: 0.1}, wantHigh: true},
+        {name: "señal justo por debajo", rule: SignalRule{}, data: SensorData{SignalStrength: 19}},
+        {name: "señal casi nula", rule: SignalRule{}, data: SensorData{SignalStrength: 1}, wantHigh: true},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            tt.data.DeviceID = "sensor-1"
+            anomalies := tt.rule.Evaluate(&tt.data, DefaultAnomalyThresholds())
+            if len(anomalies) != 1 {
+                t.Fatalf("anomalías = %d, se esperaba 1", len(anomalies))
+            }
+
+            confidence := anomalies[0].Confidence
+            if tt.wantHigh && confidence < 0.9 {
+                t.Errorf("confianza = %.2f, se esperaba alta (≥ 0.9)", confidence)
+            }
+            if !tt.wantHigh && confidence > 0.25 {
+                t.Errorf("confianza = %.2f, se esperaba baja (≤ 0.25)", confidence)
+            }
+        })
+    }
+}
+
+func TestHistoryConfidence(t *testing.T) {
+    tests := []struct {
+        name    string
+        samples int
+        want    float64
+    }{
+        {name: "sin historial", samples: 0, want: 0.5},
+        {name: "historial a medias", samples: CONFIDENCE_MIN_HISTORY / 2, want: 0.75},
+        {name: "historial suficiente", samples: CONFIDENCE_MIN_HISTORY, want: 1},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if got := historyConfidence(1, tt.samples); got != tt.want {
+                t.Errorf("historyConfidence(1, %d) = %v, se esperaba %v", tt.samples, got, tt.want)
+            }
+        })
+    }
+}
+
+func TestNotifyAnomalies_ConfidenceFloor(t *testing.T) {
+    tests := []struct {
+        name          string
+        minConfidence float64
+        confidence    float64
+        wantNotified  bool
+    }{
+        {name: "sin mínimo", confidence: 0.1, wantNotified: true},
+        {name: "por debajo del mínimo", minConfidence: 0.5, confidence: 0.1},
+        {name: "por encima del mínimo", minConfidence: 0.5, confidence: 0.9, wantNotified: true},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            setupTestHub(t)
+            saved := notificationMinConfidence
+            t.Cleanup(func() { notificationMinConfidence = saved })
+            notificationMinConfidence = tt.minConfidence
+            notifier := newRecordingNotifier("test")
+            notificationManager.Register(notifier)
+
+            notifyAnomalies([]Anomaly{{DeviceID: "sensor-1", Type: AnomalyTemperature, Severity: SEVERITY_HIGH, Confidence: tt.confidence}})
+            flushNotifications(t)
+
+            if got := len(notifier.Anomalies()) > 0; got != tt.wantNotified {
+                t.Errorf("notificado = %v, se esperaba %v", got, tt.wantNotified)
+            }
+        })
+    }
+}
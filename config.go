@@ -0,0 +1,724 @@
+package main
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "strconv"
+    "time"
+)
+
+// Configuración del broker MQTT
+type MQTTConfig struct {
+    Host         string
+    Topic        string
+    Username     string
+    Password     string
+    ControlTopic string
+    // QoS de las suscripciones (0, 1 o 2); comandos y estado usan al menos 1
+    QoS byte
+    // Client ID estable y sesión limpia (false = el broker guarda los
+    // mensajes QoS ≥1 mientras el hub está desconectado)
+    ClientID     string
+    CleanSession bool
+    // TLS (mutuo si se indican certificado y clave de cliente)
+    UseTLS         bool
+    CACertPath     string
+    ClientCertPath string
+    ClientKeyPath  string
+    // Estado del hub (Last Will): topic retenido y payloads (topic vacío = deshabilitado)
+    StatusTopic   string
+    StatusOnline  string
+    StatusOffline string
+}
+
+// Configuración de la API HTTP
+type HTTPConfig struct {
+    Addr string
+    // Token para los endpoints protegidos (Authorization: Bearer ...)
+    APIToken string
+    // Tokens personales por operador: {"ana": "..."}. Las acciones hechas
+    // con ellos quedan registradas a nombre del operador
+    OperatorTokens map[string]string
+    // Servir el dashboard embebido en GET /
+    EnableDashboard bool
+}
+
+// Configuración de seguridad y procesamiento de mensajes
+type SecurityConfig struct {
+    // Rate limiting por dispositivo
+    RateLimitMaxMessages  int
+    RateLimitWindow       time.Duration
+    RateLimitBurst        int
+    RateLimitMode         string
+    SelfQuarantineSecret  string
+    LenientDecoding       bool
+    FirmwareUpdateWindow  time.Duration
+    DeduplicateQuarantine bool
+    DryRun                bool
+    // Ventana de deduplicación por message_id
+    MessageDedupWindow time.Duration
+    // Ventana de deduplicación por contenido exacto del payload
+    PayloadDedupWindow time.Duration
+    // Anomalía si un dispositivo reporta con varios tipos
+    DetectDeviceTypeChanges bool
+    // Escalado de quarantine para reincidentes
+    QuarantineEscalationFactor float64
+    QuarantineMaxDuration      time.Duration
+    QuarantineResetWindow      time.Duration
+    // Dispositivos sin reportar durante más de este tiempo se purgan
+    DeviceStaleTTL time.Duration
+    // Máximo de dispositivos en memoria (0 = sin límite) y qué hacer al alcanzarlo
+    MaxTrackedDevices int
+    DeviceLimitPolicy string
+    // Tiempo sin reportar tras el cual un dispositivo se considera offline
+    DeviceOnlineWindow time.Duration
+    // Ventana de mantenimiento por defecto de un grupo
+    GroupMaintenanceWindow time.Duration
+    // Detección de deriva de calibración
+    CalibrationDriftThreshold float64
+    CalibrationDriftPeriod    time.Duration
+    // Factor de desviación del tamaño de payload habitual
+    PayloadSizeDeviationFactor float64
+    // Límite de tamaño de payload
+    MaxPayloadSize              int
+    QuarantineOversizedPayloads bool
+    // Quarantine por tasa de anomalías en ventana deslizante
+    AnomalyRateWindow    time.Duration
+    AnomalyRateThreshold int
+    // Desfase máximo del reloj de los dispositivos
+    ClockSkewTolerance time.Duration
+    // Protección ante tormentas de anomalías
+    AnomalyStormThreshold  int
+    AnomalyStormWindow     time.Duration
+    AnomalyStormSampleRate int
+    // Periodo de prueba tras salir de quarantine
+    ProbationWindow           time.Duration
+    ProbationAnomalyThreshold int
+    // Temperaturas atípicas según la variabilidad de cada dispositivo
+    TemperatureOutlierSigma      float64
+    TemperatureOutlierMinSamples int
+    // Workers de procesamiento y mensajes en espera antes de descartar
+    ProcessingWorkers   int
+    ProcessingQueueSize int
+}
+
+// Configuración de notificaciones
+type NotificationsConfig struct {
+    EnableDiscord       bool
+    DiscordWebhookURL   string
+    DiscordChannels     map[string]string
+    Routes              NotificationRoutes
+    MetricHistoryLength int
+    SLATargets          SLATargets
+    // Confianza mínima de una anomalía para notificarla
+    MinConfidence float64
+    // Severidad mínima de una anomalía para notificarla (low, medium, high)
+    MinSeverity string
+    // Registrar los mensajes en lugar de enviarlos
+    DryRun bool
+    // No repetir alertas del mismo dispositivo y tipo dentro de esta ventana
+    DedupWindow time.Duration
+    // Silencio tras reconocer una anomalía; después se escala si reaparece
+    AcknowledgmentTTL time.Duration
+    // Horas de silencio para alertas no altas (nil = sin ventana)
+    QuietHours *CommunicationSchedule
+    // Reintentos de los envíos HTTP ante errores de red o 5xx
+    MaxRetries   int
+    RetryBackoff time.Duration
+    // Enriquecimiento de anomalías con el clima exterior
+    EnableWeatherEnrichment bool
+    WeatherAPIURL           string
+    WeatherCacheTTL         time.Duration
+    DeviceLocations         map[string]DeviceLocation
+    // Exportación de anomalías a Elasticsearch
+    EnableElasticsearch      bool
+    ElasticsearchURL         string
+    ElasticsearchIndexPrefix string
+    ElasticsearchFormat      string
+    // Webhook genérico con cuerpo por plantilla
+    EnableWebhook   bool
+    WebhookURL      string
+    WebhookTemplate string
+    WebhookHeaders  map[string]string
+    WebhookTimeout  time.Duration
+}
+
+// Persistencia del estado entre reinicios
+type StateConfig struct {
+    SnapshotFile string
+    // Redis compartido entre instancias (vacío = solo estado local)
+    RedisAddr string
+    // Reintentos de las escrituras en el repositorio compartido
+    RepositoryMaxRetries   int
+    RepositoryRetryBackoff time.Duration
+    // Dead-letter log de mensajes no decodificables (vacío = deshabilitado)
+    DeadLetterFile    string
+    DeadLetterMaxSize int
+    // Lecturas recientes conservadas por dispositivo
+    DeviceHistorySize int
+    // Retención y tamaño máximo del repositorio de anomalías
+    AnomalyRetention time.Duration
+    AnomalyMaxCount  int
+}
+
+// Configuración de logs
+type LoggingConfig struct {
+    Format string
+}
+
+// Configuración de métricas
+type MetricsConfig struct {
+    DumpDir                   string
+    BackpressureHighWaterMark int
+}
+
+// Configuración completa del hub
+type Config struct {
+    MQTT           MQTTConfig
+    HTTP           HTTPConfig
+    Security       SecurityConfig
+    Thresholds     AnomalyThresholds
+    DeviceProfiles DeviceProfiles
+    DeviceGroups   DeviceGroups
+    Notifications  NotificationsConfig
+    Metrics        MetricsConfig
+    Logging        LoggingConfig
+    State          StateConfig
+    // Severidad base de las anomalías por tipo de dispositivo
+    DeviceTypeSeverity map[string]string
+    // Severidad de las anomalías por patrón de comportamiento
+    BehaviorSeverity map[string]string
+    // Códigos de diagnóstico críticos por clave
+    DiagnosticAlertCodes map[string][]string
+    // Horarios de comunicación permitidos por dispositivo/tipo
+    CommunicationSchedules CommunicationSchedules
+    // Campos que deben venir en cada lectura, por tipo de dispositivo
+    RequiredFields map[string][]string
+    // Dispositivos autorizados (vacío = cualquiera)
+    DeviceAllowlist []string
+    // Reglas de detección activas, en orden (vacío = todas las incorporadas)
+    AnomalyRules []string
+}
+
+// Cargar configuración desde variables de entorno, con valores por defecto.
+// Un valor mal formado en una variable numérica, booleana o de duración es
+// un error, no una vuelta silenciosa al valor por defecto.
+func LoadConfig() (*Config, error) {
+    envParseErrors = nil
+    cfg, err := loadConfig()
+    if parseErr := errors.Join(envParseErrors...); parseErr != nil {
+        return nil, errors.Join(parseErr, err)
+    }
+    return cfg, err
+}
+
+func loadConfig() (*Config, error) {
+    defaults := DefaultAnomalyThresholds()
+    thresholds := AnomalyThresholds{
+        TemperatureMax:           getEnvFloat("THRESHOLD_TEMPERATURE_MAX", defaults.TemperatureMax),
+        TemperatureMin:           getEnvFloat("THRESHOLD_TEMPERATURE_MIN", defaults.TemperatureMin),
+        BatteryCritical:          getEnvFloat("THRESHOLD_BATTERY_CRITICAL", defaults.BatteryCritical),
+        AccessAttemptsMax:        getEnvInt("THRESHOLD_ACCESS_ATTEMPTS_MAX", defaults.AccessAttemptsMax),
+        SignalWeak:               getEnvFloat("THRESHOLD_SIGNAL_WEAK", defaults.SignalWeak),
+        HumidityMax:              getEnvFloat("THRESHOLD_HUMIDITY_MAX", defaults.HumidityMax),
+        HumidityMin:              getEnvFloat("THRESHOLD_HUMIDITY_MIN", defaults.HumidityMin),
+        AccessFailureRatioMax:    getEnvFloat("THRESHOLD_ACCESS_FAILURE_RATIO_MAX", defaults.AccessFailureRatioMax),
+        AccessFailureMinAttempts: getEnvInt("THRESHOLD_ACCESS_FAILURE_MIN_ATTEMPTS", defaults.AccessFailureMinAttempts),
+        DewPointMax:              getEnvFloat("THRESHOLD_DEW_POINT_MAX", defaults.DewPointMax),
+    }
+
+    // Los perfiles heredan de los umbrales ya configurados
+    profiles, err := loadDeviceProfiles(configValue("DEVICE_PROFILES"), thresholds)
+    if err != nil {
+        return nil, err
+    }
+
+    // Horarios de comunicación: {"by_type": {"parking_sensor": {"start": "07:00", "end": "22:00", "timezone": "Europe/Madrid"}}}
+    schedules, err := loadCommunicationSchedules(configValue("COMMUNICATION_SCHEDULES"))
+    if err != nil {
+        return nil, err
+    }
+
+    // Grupos de dispositivos: {"edificio-a": ["sensor_001", "lock_001"]}
+    groups := DeviceGroups{}
+    if err := parseEnvJSON("DEVICE_GROUPS", &groups); err != nil {
+        return nil, err
+    }
+
+    // Diagnósticos críticos: {"error_code": ["E42", "E99"], "self_test": ["fail"]}
+    alertCodes := make(map[string][]string)
+    if err := parseEnvJSON("DIAGNOSTIC_ALERT_CODES", &alertCodes); err != nil {
+        return nil, err
+    }
+
+    // Campos obligatorios por tipo: {"temperature_sensor": ["temperature", "battery_level"]}
+    required := make(map[string][]string)
+    if err := parseEnvJSON("REQUIRED_FIELDS", &required); err != nil {
+        return nil, err
+    }
+    if err := validateRequiredFieldsConfig(required); err != nil {
+        return nil, err
+    }
+
+    // Tokens de API por operador: {"ana": "token-de-ana"}
+    operatorTokens := make(map[string]string)
+    if err := parseEnvJSON("API_OPERATOR_TOKENS", &operatorTokens); err != nil {
+        return nil, err
+    }
+    if err := validateOperatorTokens(operatorTokens, configValue("API_TOKEN")); err != nil {
+        return nil, err
+    }
+
+    // Dispositivos autorizados: ["sensor-001", "lock-002"] (vacío = alta automática)
+    var allowlist []string
+    if err := parseEnvJSON("DEVICE_ALLOWLIST", &allowlist); err != nil {
+        return nil, err
+    }
+
+    // Reglas de detección activas y su orden: ["temperature", "battery"]
+    var anomalyRules []string
+    if err := parseEnvJSON("ANOMALY_RULES", &anomalyRules); err != nil {
+        return nil, err
+    }
+    if _, err := newRuleEngineFromNames(anomalyRules); err != nil {
+        return nil, err
+    }
+
+    // Severidad base por tipo: {"smart_lock": "high", "parking_sensor": "low"}
+    typeSeverity := make(map[string]string)
+    if err := parseEnvJSON("DEVICE_TYPE_SEVERITY", &typeSeverity); err != nil {
+        return nil, err
+    }
+    if err := validateDeviceTypeSeverity(typeSeverity); err != nil {
+        return nil, err
+    }
+
+    // Severidad por patrón de comportamiento: {"brute_force": "high", "battery_drop": "low"}
+    patternSeverity := map[string]string{BEHAVIOR_BRUTE_FORCE: SEVERITY_HIGH, BEHAVIOR_SECURITY_DOWNGRADE: SEVERITY_HIGH}
+    if err := parseEnvJSON("BEHAVIOR_SEVERITY", &patternSeverity); err != nil {
+        return nil, err
+    }
+    if err := validateBehaviorSeverity(patternSeverity); err != nil {
+        return nil, err
+    }
+
+    // Horas de silencio: alertas no altas retenidas y enviadas como resumen
+    quietHours, err := loadQuietHours(configValue("QUIET_HOURS_START"), configValue("QUIET_HOURS_END"), configValue("QUIET_HOURS_TIMEZONE"))
+    if err != nil {
+        return nil, err
+    }
+
+    // QoS MQTT
+    qos := getEnvInt("MQTT_QOS", MQTT_DEFAULT_QOS)
+    if qos < 0 || qos > 2 {
+        return nil, fmt.Errorf("MQTT_QOS inválido: %d (0, 1 o 2)", qos)
+    }
+
+    // Algoritmo de rate limiting
+    rateMode := getEnv("RATE_LIMIT_MODE", RATE_LIMIT_MODE_TOKEN_BUCKET)
+    if err := validateRateLimitMode(rateMode); err != nil {
+        return nil, err
+    }
+
+    // Política al alcanzar el máximo de dispositivos
+    limitPolicy := getEnv("DEVICE_LIMIT_POLICY", DEVICE_LIMIT_POLICY_REJECT)
+    if err := validateDeviceLimitPolicy(limitPolicy); err != nil {
+        return nil, err
+    }
+
+    // Severidad mínima para notificar
+    minSeverity := configValue("NOTIFICATION_MIN_SEVERITY")
+    if minSeverity != "" && severityRank(minSeverity) == 0 {
+        return nil, fmt.Errorf("NOTIFICATION_MIN_SEVERITY inválida: %q (low, medium o high)", minSeverity)
+    }
+
+    // Canales de Discord adicionales: {"facilities": "https://...", "security": "https://..."}
+    discordChannels := make(map[string]string)
+    if err := parseEnvJSON("DISCORD_CHANNELS", &discordChannels); err != nil {
+        return nil, err
+    }
+
+    // Cabeceras del webhook genérico: {"Authorization": "Bearer ..."}
+    webhookHeaders := make(map[string]string)
+    if err := parseEnvJSON("WEBHOOK_HEADERS", &webhookHeaders); err != nil {
+        return nil, err
+    }
+
+    // Ubicaciones: {"sensor_001": {"lat": 40.41, "lon": -3.70}}
+    locations := make(map[string]DeviceLocation)
+    if err := parseEnvJSON("DEVICE_LOCATIONS", &locations); err != nil {
+        return nil, err
+    }
+
+    // Objetivos de SLA por severidad: {"high": "5m", "medium": "30m"}
+    defaultSLA := DefaultSLATargets()
+    var rawAckTargets, rawResolveTargets map[string]string
+    if err := parseEnvJSON("SLA_ACKNOWLEDGE_TARGETS", &rawAckTargets); err != nil {
+        return nil, err
+    }
+    if err := parseEnvJSON("SLA_RESOLVE_TARGETS", &rawResolveTargets); err != nil {
+        return nil, err
+    }
+    ackTargets, err := parseSLATargets(rawAckTargets, defaultSLA.Acknowledge)
+    if err != nil {
+        return nil, err
+    }
+    resolveTargets, err := parseSLATargets(rawResolveTargets, defaultSLA.Resolve)
+    if err != nil {
+        return nil, err
+    }
+
+    // Enrutado: {"by_type": {"temperature": ["facilities"]}, "by_severity": {"high": ["security"]}}
+    var routes NotificationRoutes
+    if err := parseEnvJSON("NOTIFICATION_ROUTES", &routes); err != nil {
+        return nil, err
+    }
+
+    return &Config{
+        MQTT: MQTTConfig{
+            Host:           configValue("MQTT_HOST"),
+            Topic:          configValue("MQTT_TOPIC"),
+            Username:       configValue("MQTT_USERNAME"),
+            Password:       configValue("MQTT_PASSWORD"),
+            ControlTopic:   getEnv("MQTT_CONTROL_TOPIC", "iot/control/{device_id}"),
+            QoS:            byte(qos),
+            ClientID:       configValue("MQTT_CLIENT_ID"),
+            CleanSession:   getEnvBool("MQTT_CLEAN_SESSION", true),
+            UseTLS:         getEnvBool("MQTT_USE_TLS", false),
+            CACertPath:     configValue("MQTT_CA_CERT"),
+            ClientCertPath: configValue("MQTT_CLIENT_CERT"),
+            ClientKeyPath:  configValue("MQTT_CLIENT_KEY"),
+            StatusTopic:    getEnv("MQTT_STATUS_TOPIC", "iot-hub/status"),
+            StatusOnline:   getEnv("MQTT_STATUS_ONLINE", "online"),
+            StatusOffline:  getEnv("MQTT_STATUS_OFFLINE", "offline"),
+        },
+        HTTP: HTTPConfig{
+            Addr:            getEnv("HTTP_ADDR", ":8080"),
+            APIToken:        configValue("API_TOKEN"),
+            OperatorTokens:  operatorTokens,
+            EnableDashboard: getEnvBool("ENABLE_DASHBOARD", true),
+        },
+        Security: SecurityConfig{
+            RateLimitMaxMessages:         getEnvInt("RATE_LIMIT_MAX_MESSAGES", MAX_MESSAGES_PER_MINUTE),
+            RateLimitWindow:              getEnvDuration("RATE_LIMIT_WINDOW", 1*time.Minute),
+            RateLimitBurst:               getEnvInt("RATE_LIMIT_BURST", RATE_LIMIT_BURST),
+            RateLimitMode:                rateMode,
+            SelfQuarantineSecret:         configValue("SELF_QUARANTINE_SECRET"),
+            LenientDecoding:              getEnvBool("LENIENT_DECODING", false),
+            FirmwareUpdateWindow:         getEnvDuration("FIRMWARE_UPDATE_WINDOW", 10*time.Minute),
+            DeduplicateQuarantine:        getEnvBool("DEDUPLICATE_QUARANTINE", true),
+            DryRun:                       getEnvBool("DRY_RUN", false),
+            MessageDedupWindow:           getEnvDuration("MESSAGE_DEDUP_WINDOW", 10*time.Minute),
+            PayloadDedupWindow:           getEnvDuration("PAYLOAD_DEDUP_WINDOW", 5*time.Second),
+            DetectDeviceTypeChanges:      getEnvBool("DETECT_DEVICE_TYPE_CHANGES", true),
+            QuarantineEscalationFactor:   getEnvFloat("QUARANTINE_ESCALATION_FACTOR", 2.0),
+            QuarantineMaxDuration:        getEnvDuration("QUARANTINE_MAX_DURATION", 1*time.Hour),
+            QuarantineResetWindow:        getEnvDuration("QUARANTINE_RESET_WINDOW", 24*time.Hour),
+            DeviceStaleTTL:               getEnvDuration("DEVICE_STALE_TTL", 72*time.Hour),
+            MaxTrackedDevices:            getEnvInt("MAX_TRACKED_DEVICES", 10000),
+            DeviceLimitPolicy:            limitPolicy,
+            DeviceOnlineWindow:           getEnvDuration("DEVICE_ONLINE_WINDOW", 5*time.Minute),
+            GroupMaintenanceWindow:       getEnvDuration("GROUP_MAINTENANCE_WINDOW", 2*time.Hour),
+            CalibrationDriftThreshold:    getEnvFloat("CALIBRATION_DRIFT_THRESHOLD", 5.0),
+            CalibrationDriftPeriod:       getEnvDuration("CALIBRATION_DRIFT_PERIOD", 7*24*time.Hour),
+            PayloadSizeDeviationFactor:   getEnvFloat("PAYLOAD_SIZE_DEVIATION_FACTOR", 3.0),
+            MaxPayloadSize:               getEnvInt("MAX_PAYLOAD_SIZE", 4096),
+            QuarantineOversizedPayloads:  getEnvBool("QUARANTINE_OVERSIZED_PAYLOADS", false),
+            AnomalyRateWindow:            getEnvDuration("ANOMALY_RATE_WINDOW", 1*time.Hour),
+            AnomalyRateThreshold:         getEnvInt("ANOMALY_RATE_THRESHOLD", 10),
+            ClockSkewTolerance:           getEnvDuration("CLOCK_SKEW_TOLERANCE", DefaultValidationConfig().ClockSkewTolerance),
+            AnomalyStormThreshold:        getEnvInt("ANOMALY_STORM_THRESHOLD", 100),
+            AnomalyStormWindow:           getEnvDuration("ANOMALY_STORM_WINDOW", 1*time.Minute),
+            AnomalyStormSampleRate:       getEnvInt("ANOMALY_STORM_SAMPLE_RATE", 10),
+            ProbationWindow:              getEnvDuration("PROBATION_WINDOW", 30*time.Minute),
+            ProbationAnomalyThreshold:    getEnvInt("PROBATION_ANOMALY_THRESHOLD", 1),
+            TemperatureOutlierSigma:      getEnvFloat("TEMPERATURE_OUTLIER_SIGMA", 3.0),
+            TemperatureOutlierMinSamples: getEnvInt("TEMPERATURE_OUTLIER_MIN_SAMPLES", 10),
+            ProcessingWorkers:            getEnvInt("PROCESSING_WORKERS", 4),
+            ProcessingQueueSize:          getEnvInt("PROCESSING_QUEUE_SIZE", 1000),
+        },
+        Thresholds:             thresholds,
+        DeviceProfiles:         profiles,
+        DeviceGroups:           groups,
+        DeviceTypeSeverity:     typeSeverity,
+        BehaviorSeverity:       patternSeverity,
+        DiagnosticAlertCodes:   alertCodes,
+        CommunicationSchedules: schedules,
+        RequiredFields:         required,
+        DeviceAllowlist:        allowlist,
+        AnomalyRules:           anomalyRules,
+        Notifications: NotificationsConfig{
+            EnableDiscord:            getEnvBool("ENABLE_DISCORD", false),
+            DiscordWebhookURL:        configValue("DISCORD_WEBHOOK_URL"),
+            DiscordChannels:          discordChannels,
+            Routes:                   routes,
+            MetricHistoryLength:      getEnvInt("METRIC_HISTORY_LENGTH", 5),
+            MinConfidence:            getEnvFloat("NOTIFICATION_MIN_CONFIDENCE", 0),
+            MinSeverity:              minSeverity,
+            DryRun:                   getEnvBool("NOTIFICATION_DRY_RUN", false),
+            DedupWindow:              getEnvDuration("NOTIFICATION_DEDUP_WINDOW", 5*time.Minute),
+            AcknowledgmentTTL:        getEnvDuration("ACKNOWLEDGMENT_TTL", 1*time.Hour),
+            QuietHours:               quietHours,
+            MaxRetries:               getEnvInt("NOTIFICATION_MAX_RETRIES", 3),
+            RetryBackoff:             getEnvDuration("NOTIFICATION_RETRY_BACKOFF", 500*time.Millisecond),
+            EnableElasticsearch:      getEnvBool("ENABLE_ELASTICSEARCH", false),
+            ElasticsearchURL:         getEnv("ELASTICSEARCH_URL", "http://localhost:9200"),
+            ElasticsearchIndexPrefix: getEnv("ELASTICSEARCH_INDEX_PREFIX", "iot-anomalies"),
+            ElasticsearchFormat:      getEnv("ELASTICSEARCH_FORMAT", ELASTIC_FORMAT_NATIVE),
+            EnableWebhook:            getEnvBool("ENABLE_WEBHOOK", false),
+            WebhookURL:               configValue("WEBHOOK_URL"),
+            WebhookTemplate:          getEnv("WEBHOOK_TEMPLATE", DEFAULT_WEBHOOK_TEMPLATE),
+            WebhookHeaders:           webhookHeaders,
+            WebhookTimeout:           getEnvDuration("WEBHOOK_TIMEOUT", NOTIFICATION_TIMEOUT),
+            SLATargets:               SLATargets{Acknowledge: ackTargets, Resolve: resolveTargets},
+            EnableWeatherEnrichment:  getEnvBool("ENABLE_WEATHER_ENRICHMENT", false),
+            WeatherAPIURL:            getEnv("WEATHER_API_URL", "https://api.open-meteo.com/v1/forecast?latitude={lat}&longitude={lon}&current=temperature_2m,relative_humidity_2m"),
+            WeatherCacheTTL:          getEnvDuration("WEATHER_CACHE_TTL", 10*time.Minute),
+            DeviceLocations:          locations,
+        },
+        Metrics: MetricsConfig{
+            DumpDir:                   getEnv("METRICS_DUMP_DIR", "."),
+            BackpressureHighWaterMark: getEnvInt("BACKPRESSURE_HIGH_WATER_MARK", 100),
+        },
+        Logging: LoggingConfig{
+            Format: getEnv("LOG_FORMAT", LOG_FORMAT_TEXT),
+        },
+        State: StateConfig{
+            SnapshotFile:           configValue("STATE_SNAPSHOT_FILE"),
+            RedisAddr:              configValue("REDIS_ADDR"),
+            RepositoryMaxRetries:   getEnvInt("REPOSITORY_MAX_RETRIES", 2),
+            RepositoryRetryBackoff: getEnvDuration("REPOSITORY_RETRY_BACKOFF", 100*time.Millisecond),
+            DeadLetterFile:         configValue("DEAD_LETTER_FILE"),
+            DeadLetterMaxSize:      getEnvInt("DEAD_LETTER_MAX_SIZE", 10*1024*1024),
+            DeviceHistorySize:      getEnvInt("DEVICE_HISTORY_SIZE", 50),
+            AnomalyRetention:       getEnvDuration("ANOMALY_RETENTION", 7*24*time.Hour),
+            AnomalyMaxCount:        getEnvInt("ANOMALY_MAX_COUNT", ANOMALY_REPOSITORY_SIZE),
+        },
+    }, nil
+}
+
+// Comprobar que la configuración permite arrancar: campos obligatorios y
+// credenciales de los canales habilitados. Devuelve todos los problemas juntos.
+func (c *Config) Validate() error {
+    var errs []error
+    if c.MQTT.Host == "" {
+        errs = append(errs, errors.New("MQTT_HOST es obligatorio"))
+    }
+    if c.MQTT.QoS > 2 {
+        errs = append(errs, fmt.Errorf("MQTT_QOS inválido: %d (0, 1 o 2)", c.MQTT.QoS))
+    }
+    if !c.MQTT.CleanSession && (c.MQTT.ClientID == "" || c.MQTT.ClientID == MQTT_DEFAULT_CLIENT_ID) {
+        errs = append(errs, fmt.Errorf("MQTT_CLIENT_ID propio de la instancia (distinto de %q) es obligatorio con sesión persistente (MQTT_CLEAN_SESSION=false)", MQTT_DEFAULT_CLIENT_ID))
+    }
+    if len(parseTopics(c.MQTT.Topic)) == 0 {
+        errs = append(errs, errors.New("MQTT_TOPIC es obligatorio"))
+    }
+    if (c.MQTT.ClientCertPath == "") != (c.MQTT.ClientKeyPath == "") {
+        errs = append(errs, errors.New("TLS mutuo requiere MQTT_CLIENT_CERT y MQTT_CLIENT_KEY"))
+    }
+
+    if c.Notifications.EnableDiscord && c.Notifications.DiscordWebhookURL == "" && len(c.Notifications.DiscordChannels) == 0 {
+        errs = append(errs, errors.New("ENABLE_DISCORD requiere DISCORD_WEBHOOK_URL o DISCORD_CHANNELS"))
+    }
+    if c.Notifications.EnableElasticsearch && c.Notifications.ElasticsearchURL == "" {
+        errs = append(errs, errors.New("ENABLE_ELASTICSEARCH requiere ELASTICSEARCH_URL"))
+    }
+    if c.Notifications.EnableWebhook && c.Notifications.WebhookURL == "" {
+        errs = append(errs, errors.New("ENABLE_WEBHOOK requiere WEBHOOK_URL"))
+    }
+    if c.Notifications.EnableWeatherEnrichment && c.Notifications.WeatherAPIURL == "" {
+        errs = append(errs, errors.New("ENABLE_WEATHER_ENRICHMENT requiere WEATHER_API_URL"))
+    }
+    return errors.Join(errs...)
+}
+
+// Aplicar la configuración al estado global del hub
+func applyConfig(cfg *Config) {
+    runningConfig = cfg
+    apiToken = cfg.HTTP.APIToken
+    apiOperatorTokens = cfg.HTTP.OperatorTokens
+    dashboardEnabled = cfg.HTTP.EnableDashboard
+    anomalyThresholds = cfg.Thresholds
+    deviceProfiles = cfg.DeviceProfiles
+    deviceGroups = cfg.DeviceGroups
+    deviceTypeSeverity = cfg.DeviceTypeSeverity
+    behaviorSeverity = cfg.BehaviorSeverity
+    diagnosticAlertCodes = cfg.DiagnosticAlertCodes
+    communicationSchedules = cfg.CommunicationSchedules
+    requiredFields = cfg.RequiredFields
+    deviceAllowlist = newDeviceAllowlist(cfg.DeviceAllowlist)
+    mqttQoS = cfg.MQTT.QoS
+    if engine, err := newRuleEngineFromNames(cfg.AnomalyRules); err == nil {
+        ruleEngine = engine
+    }
+    deviceOnlineWindow = cfg.Security.DeviceOnlineWindow
+    groupMaintenanceWindow = cfg.Security.GroupMaintenanceWindow
+    if cfg.Security.RateLimitMaxMessages > 0 && cfg.Security.RateLimitWindow > 0 {
+        rateLimitMaxMessages = cfg.Security.RateLimitMaxMessages
+        rateLimitWindow = cfg.Security.RateLimitWindow
+    }
+    rateLimitBurst = cfg.Security.RateLimitBurst
+    rateLimitMode = cfg.Security.RateLimitMode
+    selfQuarantineSecret = cfg.Security.SelfQuarantineSecret
+    lenientDecoding = cfg.Security.LenientDecoding
+    firmwareUpdateWindow = cfg.Security.FirmwareUpdateWindow
+    deduplicateQuarantine = cfg.Security.DeduplicateQuarantine
+    dryRunProcessing = cfg.Security.DryRun
+    messageDedupWindow = cfg.Security.MessageDedupWindow
+    payloadDedupWindow = cfg.Security.PayloadDedupWindow
+    detectDeviceTypeChanges = cfg.Security.DetectDeviceTypeChanges
+    quarantineEscalationFactor = cfg.Security.QuarantineEscalationFactor
+    quarantineMaxDuration = cfg.Security.QuarantineMaxDuration
+    quarantineResetWindow = cfg.Security.QuarantineResetWindow
+    deviceStaleTTL = cfg.Security.DeviceStaleTTL
+    maxTrackedDevices = cfg.Security.MaxTrackedDevices
+    deviceLimitPolicy = cfg.Security.DeviceLimitPolicy
+    calibrationDriftThreshold = cfg.Security.CalibrationDriftThreshold
+    calibrationDriftPeriod = cfg.Security.CalibrationDriftPeriod
+    payloadSizeDeviationFactor = cfg.Security.PayloadSizeDeviationFactor
+    maxPayloadSize = cfg.Security.MaxPayloadSize
+    quarantineOversizedPayloads = cfg.Security.QuarantineOversizedPayloads
+    anomalyRateWindow = cfg.Security.AnomalyRateWindow
+    anomalyRateThreshold = cfg.Security.AnomalyRateThreshold
+    if cfg.Security.ClockSkewTolerance > 0 {
+        validationConfig.ClockSkewTolerance = cfg.Security.ClockSkewTolerance
+    }
+    anomalyStormThreshold = cfg.Security.AnomalyStormThreshold
+    anomalyStormWindow = cfg.Security.AnomalyStormWindow
+    if cfg.Security.AnomalyStormSampleRate > 0 {
+        anomalyStormSampleRate = cfg.Security.AnomalyStormSampleRate
+    }
+    probationWindow = cfg.Security.ProbationWindow
+    if cfg.Security.ProbationAnomalyThreshold > 0 {
+        probationAnomalyThreshold = cfg.Security.ProbationAnomalyThreshold
+    }
+    temperatureOutlierSigma = cfg.Security.TemperatureOutlierSigma
+    processingWorkers = cfg.Security.ProcessingWorkers
+    if cfg.Security.ProcessingQueueSize > 0 {
+        processingQueueSize = cfg.Security.ProcessingQueueSize
+    }
+    if cfg.Security.TemperatureOutlierMinSamples > 1 {
+        temperatureOutlierMinSamples = cfg.Security.TemperatureOutlierMinSamples
+    }
+    anomalyRetention = cfg.State.AnomalyRetention
+    if cfg.State.AnomalyMaxCount > 0 {
+        anomalyRepository = NewAnomalyRepository(cfg.State.AnomalyMaxCount)
+    }
+    if cfg.State.DeviceHistorySize > 0 {
+        deviceHistorySize = cfg.State.DeviceHistorySize
+    }
+    if cfg.Notifications.MetricHistoryLength > 0 {
+        metricHistoryLength = cfg.Notifications.MetricHistoryLength
+    }
+    slaTargets = cfg.Notifications.SLATargets
+    notificationMinConfidence = cfg.Notifications.MinConfidence
+    notificationMinSeverity = cfg.Notifications.MinSeverity
+    dryRunNotifications = cfg.Notifications.DryRun || cfg.Security.DryRun
+    alertDedupWindow = cfg.Notifications.DedupWindow
+    acknowledgmentTTL = cfg.Notifications.AcknowledgmentTTL
+    notificationManager.SetQuietHours(cfg.Notifications.QuietHours)
+    if cfg.Notifications.MaxRetries >= 0 {
+        notificationMaxRetries = cfg.Notifications.MaxRetries
+    }
+    if cfg.Notifications.RetryBackoff > 0 {
+        notificationRetryBackoff = cfg.Notifications.RetryBackoff
+    }
+    deviceLocations = cfg.Notifications.DeviceLocations
+    if cfg.Notifications.EnableWeatherEnrichment {
+        weatherClient = NewCachedWeatherClient(NewHTTPWeatherClient(cfg.Notifications.WeatherAPIURL), cfg.Notifications.WeatherCacheTTL)
+    }
+    metricsDumpDir = cfg.Metrics.DumpDir
+    backpressureHighWaterMark = cfg.Metrics.BackpressureHighWaterMark
+    stateSnapshotFile = cfg.State.SnapshotFile
+    if cfg.State.RepositoryMaxRetries >= 0 {
+        repositoryMaxRetries = cfg.State.RepositoryMaxRetries
+    }
+    if cfg.State.RepositoryRetryBackoff > 0 {
+        repositoryRetryBackoff = cfg.State.RepositoryRetryBackoff
+    }
+    if cfg.State.DeadLetterFile != "" {
+        deadLetters = NewDeadLetterLog(cfg.State.DeadLetterFile, int64(cfg.State.DeadLetterMaxSize))
+    }
+}
+
+// Decodificar una variable de entorno con JSON (vacía = sin cambios)
+func parseEnvJSON(key string, target interface{}) error {
+    raw := configValue(key)
+    if raw == "" {
+        return nil
+    }
+    if err := json.Unmarshal([]byte(raw), target); err != nil {
+        return fmt.Errorf("%s inválido: %w", key, err)
+    }
+    return nil
+}
+
+// Leer variable de entorno con valor por defecto
+func getEnv(key, defaultValue string) string {
+    if value := configValue(key); value != "" {
+        return value
+    }
+    return defaultValue
+}
+
+// Errores de formato encontrados por getEnvBool/Int/Float/Duration durante
+// la carga en curso (LoadConfig los devuelve todos juntos)
+var envParseErrors []error
+
+// Registrar un valor que no se pudo interpretar
+func recordEnvParseError(key, value, kind string) {
+    envParseErrors = append(envParseErrors, fmt.Errorf("%s inválido: %q no es %s", key, value, kind))
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+    raw := configValue(key)
+    if raw == "" {
+        return defaultValue
+    }
+    value, err := strconv.ParseBool(raw)
+    if err != nil {
+        recordEnvParseError(key, raw, "un booleano")
+        return defaultValue
+    }
+    return value
+}
+
+func getEnvInt(key string, defaultValue int) int {
+    raw := configValue(key)
+    if raw == "" {
+        return defaultValue
+    }
+    value, err := strconv.Atoi(raw)
+    if err != nil {
+        recordEnvParseError(key, raw, "un entero")
+        return defaultValue
+    }
+    return value
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+    raw := configValue(key)
+    if raw == "" {
+        return defaultValue
+    }
+    value, err := strconv.ParseFloat(raw, 64)
+    if err != nil {
+        recordEnvParseError(key, raw, "un número")
+        return defaultValue
+    }
+    return value
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+    raw := configValue(key)
+    if raw == "" {
+        return defaultValue
+    }
+    value, err := time.ParseDuration(raw)
+    if err != nil {
+        recordEnvParseError(key, raw, "una duración (p. ej. 30s, 5m)")
+        return defaultValue
+    }
+    return value
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+    "context"
+    "crypto/subtle"
+    "fmt"
+    "net/http"
+    "net/url"
+    "strings"
+)
+
+// Configuración efectiva en ejecución (para GET /config)
+var runningConfig *Config
+
+// Token de la API para los endpoints protegidos (vacío = deshabilitados)
+var apiToken string
+
+// Tokens personales de los operadores, por nombre
+var apiOperatorTokens map[string]string
+
+// Operador al que se atribuyen las acciones hechas con API_TOKEN
+const API_TOKEN_OPERATOR = "api"
+
+// Clave del operador autenticado en el contexto de la petición
+type apiOperatorKey struct{}
+
+// Caracteres visibles al final de un secreto enmascarado
+const SECRET_VISIBLE_SUFFIX = 4
+
+// Enmascarar un secreto dejando ver solo el final, para poder distinguir
+// qué valor está configurado sin exponerlo
+func maskSecret(secret string) string {
+    if secret == "" {
+        return ""
+    }
+    if len(secret) <= 2*SECRET_VISIBLE_SUFFIX {
+        return "****"
+    }
+    return "****" + secret[len(secret)-SECRET_VISIBLE_SUFFIX:]
+}
+
+// Enmascarar una URL: conserva esquema y host y oculta credenciales, ruta y
+// query (los webhooks llevan el token en la ruta)
+func maskURL(raw string) string {
+    if raw == "" {
+        return ""
+    }
+    parsed, err := url.Parse(raw)
+    if err != nil || parsed.Host == "" {
+        return maskSecret(raw)
+    }
+
+    masked := parsed.Scheme + "://"
+    if parsed.User != nil {
+        masked += "****@"
+    }
+    masked += parsed.Host
+    if parsed.Path != "" && parsed.Path != "/" {
+        masked += "/" + maskSecret(strings.Trim(parsed.Path, "/"))
+    }
+    if parsed.RawQuery != "" {
+        masked += "?****"
+    }
+    return masked
+}
+
+// Copia de la configuración con contraseñas, tokens y URLs de webhook enmascarados
+func redactConfig(cfg Config) Config {
+    cfg.MQTT.Password = maskSecret(cfg.MQTT.Password)
+    cfg.HTTP.APIToken = maskSecret(cfg.HTTP.APIToken)
+    operatorTokens := make(map[string]string, len(cfg.HTTP.OperatorTokens))
+    for operator, token := range cfg.HTTP.OperatorTokens {
+        operatorTokens[operator] = maskSecret(token)
+    }
+    cfg.HTTP.OperatorTokens = operatorTokens
+    cfg.Security.SelfQuarantineSecret = maskSecret(cfg.Security.SelfQuarantineSecret)
+    cfg.State.RedisAddr = maskURL(cfg.State.RedisAddr)
+
+    cfg.Notifications.DiscordWebhookURL = maskURL(cfg.Notifications.DiscordWebhookURL)
+    channels := make(map[string]string, len(cfg.Notifications.DiscordChannels))
+    for name, webhookURL := range cfg.Notifications.DiscordChannels {
+        channels[name] = maskURL(webhookURL)
+    }
+    cfg.Notifications.DiscordChannels = channels
+
+    cfg.Notifications.ElasticsearchURL = maskURL(cfg.Notifications.ElasticsearchURL)
+    cfg.Notifications.WebhookURL = maskURL(cfg.Notifications.WebhookURL)
+    headers := make(map[string]string, len(cfg.Notifications.WebhookHeaders))
+    for name, value := range cfg.Notifications.WebhookHeaders {
+        headers[name] = maskSecret(value)
+    }
+    cfg.Notifications.WebhookHeaders = headers
+    cfg.Notifications.WeatherAPIURL = maskURL(cfg.Notifications.WeatherAPIURL)
+    return cfg
+}
+
+// Exigir "Authorization: Bearer <token>" con API_TOKEN o el token de un
+// operador; sin tokens configurados el endpoint queda deshabilitado. El
+// operador autenticado queda en el contexto de la petición.
+func requireAPIToken(next http.HandlerFunc) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        if apiToken == "" && len(apiOperatorTokens) == 0 {
+            writeError(w, http.StatusForbidden, "endpoint deshabilitado: configura API_TOKEN")
+            return
+        }
+        token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+        operator := ""
+        if found {
+            operator = authenticateOperator(token)
+        }
+        if operator == "" {
+            writeError(w, http.StatusUnauthorized, "token de API inválido")
+            return
+        }
+        next(w, r.WithContext(context.WithValue(r.Context(), apiOperatorKey{}, operator)))
+    }
+}
+
+// Operador dueño del token (vacío si no coincide con ninguno). Se comparan
+// todos los tokens para no revelar cuál coincide por el tiempo de respuesta.
+func authenticateOperator(token string) string {
+    operator := ""
+    if apiToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(apiToken)) == 1 {
+        operator = API_TOKEN_OPERATOR
+    }
+    for name, operatorToken := range apiOperatorTokens {
+        if subtle.ConstantTimeCompare([]byte(token), []byte(operatorToken)) == 1 {
+            operator = name
+        }
+    }
+    return operator
+}
+
+// Operador autenticado por requireAPIToken
+func apiOperator(r *http.Request) string {
+    operator, _ := r.Context().Value(apiOperatorKey{}).(string)
+    return operator
+}
+
+// Validar los tokens de operador: nombres y tokens no vacíos y sin repetir
+// ningún token (ni el de API_TOKEN), para que cada uno identifique a una persona
+func validateOperatorTokens(tokens map[string]string, sharedToken string) error {
+    owners := make(map[string]string, len(tokens))
+    for operator, token := range tokens {
+        if operator == "" || token == "" {
+            return fmt.Errorf("API_OPERATOR_TOKENS: operador o token vacío (%q)", operator)
+        }
+        if operator == API_TOKEN_OPERATOR {
+            return fmt.Errorf("API_OPERATOR_TOKENS: el nombre %q está reservado para API_TOKEN", API_TOKEN_OPERATOR)
+        }
+        if token == sharedToken {
+            return fmt.Errorf("API_OPERATOR_TOKENS: el token de %s coincide con API_TOKEN", operator)
+        }
+        if other, repeated := owners[token]; repeated {
+            return fmt.Errorf("API_OPERATOR_TOKENS: %s y %s comparten token", other, operator)
+        }
+        owners[token] = operator
+    }
+    return nil
+}
+
+// GET /config: configuración efectiva con los secretos enmascarados
+func handleGetConfig(w http.ResponseWriter, r *http.Request) {
+    if runningConfig == nil {
+        writeError(w, http.StatusServiceUnavailable, "configuración no cargada")
+        return
+    }
+    writeJSON(w, http.StatusOK, redactConfig(*runningConfig))
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "os"
+    "strings"
+
+    "gopkg.in/yaml.v3"
+)
+
+// Valores leídos del fichero de configuración, por nombre de variable de
+// entorno. Solo se usan mientras LoadFromFile carga la configuración.
+var configFileValues map[string]string
+
+// Variables cuyo valor es JSON: en el fichero pueden escribirse como mapas
+// o listas YAML
+var configJSONKeys = map[string]bool{
+    "ANOMALY_RULES":           true,
+    "API_OPERATOR_TOKENS":     true,
+    "BEHAVIOR_SEVERITY":       true,
+    "COMMUNICATION_SCHEDULES": true,
+    "DEVICE_ALLOWLIST":        true,
+    "DEVICE_GROUPS":           true,
+    "DEVICE_LOCATIONS":        true,
+    "DEVICE_PROFILES":         true,
+    "DEVICE_TYPE_SEVERITY":    true,
+    "DIAGNOSTIC_ALERT_CODES":  true,
+    "DISCORD_CHANNELS":        true,
+    "NOTIFICATION_ROUTES":     true,
+    "REQUIRED_FIELDS":         true,
+    "SLA_ACKNOWLEDGE_TARGETS": true,
+    "SLA_RESOLVE_TARGETS":     true,
+    "WEBHOOK_HEADERS":         true,
+}
+
+// Valor de configuración: la variable de entorno si no está vacía y, si no,
+// el del fichero de configuración
+func configValue(key string) string {
+    if value := os.Getenv(key); value != "" {
+        return value
+    }
+    return configFileValues[key]
+}
+
+// Cargar la configuración desde un fichero YAML. Las claves son los nombres
+// de las variables de entorno en minúsculas, opcionalmente agrupadas en
+// secciones:
+//
+//	mqtt:
+//	  mqtt_host: tcp://localhost:1883
+//	  mqtt_topic: iot/sensors/#
+//	notifications:
+//	  enable_discord: true
+//	  discord_channels: {security: "https://..."}
+//
+// Las variables de entorno no vacías tienen prioridad sobre el fichero.
+func LoadFromFile(path string) (*Config, error) {
+    raw, err := os.ReadFile(path)
+    if err != nil {
+        return nil, fmt.Errorf("no se pudo leer el fichero de configuración %s: %w", path, err)
+    }
+
+    var document map[string]interface{}
+    if err := yaml.Unmarshal(raw, &document); err != nil {
+        return nil, fmt.Errorf("fichero de configuración %s inválido: %w", path, err)
+    }
+
+    values := make(map[string]string)
+    if err := flattenConfigFile(document, values); err != nil {
+        return nil, fmt.Errorf("fichero de configuración %s inválido: %w", path, err)
+    }
+
+    configFileValues = values
+    defer func() { configFileValues = nil }()
+    return LoadConfig()
+}
+
+// Convertir el documento YAML en valores por variable de entorno. Un mapa
+// cuya clave no es una variable conocida es una sección; los mapas y listas
+// de las variables JSON se codifican como JSON.
+func flattenConfigFile(document map[string]interface{}, values map[string]string) error {
+    for key, value := range document {
+        name := strings.ToUpper(key)
+        section, isMap := value.(map[string]interface{})
+        if isMap && !configJSONKeys[name] {
+            if err := flattenConfigFile(section, values); err != nil {
+                return err
+            }
+            continue
+        }
+
+        switch value.(type) {
+        case nil:
+            continue
+        case map[string]interface{}, []interface{}:
+            encoded, err := json.Marshal(value)
+            if err != nil {
+                return fmt.Errorf("%s: %w", key, err)
+            }
+            values[name] = string(encoded)
+        default:
+            values[name] = fmt.Sprint(value)
+        }
+    }
+    return nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+    "os"
+    "path/filepath"
+    "reflect"
+    "testing"
+    "time"
+)
+
+// Configuración de ejemplo con todas las secciones
+const sampleConfigYAML = `
+mqtt:
+  mqtt_host: tcp://broker.planta-1:1883
+  mqtt_topic: iot/sensors/#
+  mqtt_qos: 2
+  mqtt_status_topic: planta-1/hub
+http:
+  api_token: secreto
+  enable_dashboard: false
+security:
+  dry_run: true
+  device_allowlist: [sensor-1, sensor-2]
+notifications:
+  enable_discord: true
+  discord_channels:
+    security: https://discord.example/security
+  notification_min_severity: medium
+  notification_dedup_window: 90s
+state:
+  dead_letter_file: /var/lib/hub/dead-letters.jsonl
+  anomaly_retention: 48h
+required_fields:
+  temperature_sensor: [temperature, battery_level]
+`
+
+// Escribir un fichero de configuración temporal
+func writeConfigFile(t *testing.T, content string) string {
+    t.Helper()
+    path := filepath.Join(t.TempDir(), "config.yaml")
+    if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+        t.Fatalf("escribiendo configuración: %v", err)
+    }
+    return path
+}
+
+func TestLoadFromFile_PopulatesAllSections(t *testing.T) {
+    cfg, err := LoadFromFile(writeConfigFile(t, sampleConfigYAML))
+    if err != nil {
+        t.Fatalf("LoadFromFile: %v", err)
+    }
+
+    tests := []struct {
+        name string
+        got  interface{}
+        want interface{}
+    }{
+        {name: "mqtt_host", got: cfg.MQTT.Host, want: "tcp://broker.planta-1:1883"},
+        {name: "mqtt_topic", got: cfg.MQTT.Topic, want: "iot/sensors/#"},
+        {name: "mqtt_qos", got: cfg.MQTT.QoS, want: byte(2)},
+        {name: "mqtt_status_topic", got: cfg.MQTT.StatusTopic, want: "planta-1/hub"},
+        {name: "api_token", got: cfg.HTTP.APIToken, want: "secreto"},
+        {name: "enable_dashboard", got: cfg.HTTP.EnableDashboard, want: false},
+        {name: "dry_run", got: cfg.Security.DryRun, want: true},
+        {name: "device_allowlist", got: cfg.DeviceAllowlist, want: []string{"sensor-1", "sensor-2"}},
+        {name: "enable_discord", got: cfg.Notifications.EnableDiscord, want: true},
+        {name: "discord_channels", got: cfg.Notifications.DiscordChannels, want: map[string]string{"security": "https://discord.example/security"}},
+        {name: "notification_min_severity", got: cfg.Notifications.MinSeverity, want: SEVERITY_MEDIUM},
+        {name: "notification_dedup_window", got: cfg.Notifications.DedupWindow, want: 90 * time.Second},
+        {name: "dead_letter_file", got: cfg.State.DeadLetterFile, want: "/var/lib/hub/dead-letters.jsonl"},
+        {name: "anomaly_retention", got: cfg.State.AnomalyRetention, want: 48 * time.Hour},
+        {name: "required_fields", got: cfg.RequiredFields, want: map[string][]string{"temperature_sensor": {"temperature", "battery_level"}}},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if !reflect.DeepEqual(tt.got, tt.want) {
+                t.Errorf("%s = %v, se esperaba %v", tt.name, tt.got, tt.want)
+            }
+        })
+    }
+
+    // Los valores del fichero no quedan activos para cargas posteriores
+    if configFileValues != nil {
+        t.Error("los valores del fichero siguen activos tras cargarlo")
+    }
+}
+
+func TestLoadFromFile_EnvOverridesFile(t *testing.T) {
+    tests := []struct {
+        name     string
+        env      string
+        wantHost string
+    }{
+        {name: "solo fichero", wantHost: "tcp://broker.planta-1:1883"},
+        {name: "variable de entorno", env: "tcp://broker.planta-2:1883", wantHost: "tcp://broker.planta-2:1883"},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if tt.env != "" {
+                t.Setenv("MQTT_HOST", tt.env)
+            }
+            cfg, err := LoadFromFile(writeConfigFile(t, sampleConfigYAML))
+            if err != nil {
+                t.Fatalf("LoadFromFile: %v", err)
+            }
+            if cfg.MQTT.Host != tt.wantHost {
+                t.Errorf("MQTT host = %q, se esperaba %q", cfg.MQTT.Host, tt.wantHost)
+            }
+        })
+    }
+}
+
+func TestLoadFromFile_Errors(t *testing.T) {
+    tests := []struct {
+        name string
+        path func(t *testing.T) string
+    }{
+        {name: "fichero inexistente", path: func(t *testing.T) string { return filepath.Join(t.TempDir(), "no-existe.yaml") }},
+        {name: "YAML mal formado", path: func(t *testing.T) string { return writeConfigFile(t, "mqtt: [sin cerrar") }},
+        {name: "valor inválido", path: func(t *testing.T) string { return writeConfigFile(t, "notification_min_severity: critical") }},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if _, err := LoadFromFile(tt.path(t)); err == nil {
+                t.Error("LoadFromFile sin error, se esperaba uno")
+            }
+        })
+    }
+}
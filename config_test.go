@@ -0,0 +1,206 @@
+package main
+
+import (
+    "strings"
+    "testing"
+)
+
+func TestLoadConfig_RejectsInvalidValues(t *testing.T) {
+    tests := []struct {
+        name    string
+        key     string
+        value   string
+        wantErr string
+    }{
+        {name: "modo de rate limit desconocido", key: "RATE_LIMIT_MODE", value: "sliding_window", wantErr: "RATE_LIMIT_MODE"},
+        {name: "modo de rate limit con mayúsculas", key: "RATE_LIMIT_MODE", value: "Token_Bucket", wantErr: "RATE_LIMIT_MODE"},
+        {name: "política de límite desconocida", key: "DEVICE_LIMIT_POLICY", value: "drop", wantErr: "DEVICE_LIMIT_POLICY"},
+        {name: "QoS fuera de rango", key: "MQTT_QOS", value: "3", wantErr: "MQTT_QOS"},
+        {name: "severidad base desconocida", key: "DEVICE_TYPE_SEVERITY", value: `{"smart_lock": "critical"}`, wantErr: "smart_lock"},
+        {name: "token de operador vacío", key: "API_OPERATOR_TOKENS", value: `{"ana": ""}`, wantErr: "API_OPERATOR_TOKENS"},
+        {name: "tokens de operador repetidos", key: "API_OPERATOR_TOKENS", value: `{"ana": "mismo", "luis": "mismo"}`, wantErr: "comparten token"},
+        {name: "operador con el nombre reservado", key: "API_OPERATOR_TOKENS", value: `{"api": "token-api"}`, wantErr: "reservado"},
+        {name: "severidad mínima de notificación desconocida", key: "NOTIFICATION_MIN_SEVERITY", value: "critical", wantErr: "NOTIFICATION_MIN_SEVERITY"},
+        {name: "QoS que no es un número", key: "MQTT_QOS", value: "abc", wantErr: "MQTT_QOS"},
+        {name: "booleano mal escrito", key: "ENABLE_WEBHOOK", value: "si", wantErr: "ENABLE_WEBHOOK"},
+        {name: "umbral que no es un número", key: "THRESHOLD_TEMPERATURE_MAX", value: "50ºC", wantErr: "THRESHOLD_TEMPERATURE_MAX"},
+        {name: "duración sin unidad", key: "RATE_LIMIT_WINDOW", value: "60", wantErr: "RATE_LIMIT_WINDOW"},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            t.Setenv(tt.key, tt.value)
+
+            _, err := LoadConfig()
+            if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+                t.Errorf("LoadConfig() error = %v, se esperaba un error sobre %s", err, tt.wantErr)
+            }
+        })
+    }
+}
+
+func TestLoadConfig_ReportsEveryMalformedValue(t *testing.T) {
+    t.Setenv("MQTT_QOS", "abc")
+    t.Setenv("MQTT_USE_TLS", "quizá")
+    t.Setenv("WEBHOOK_TIMEOUT", "10")
+
+    _, err := LoadConfig()
+    if err == nil {
+        t.Fatal("LoadConfig() sin error, se esperaba uno por cada valor mal formado")
+    }
+    for _, key := range []string{"MQTT_QOS", "MQTT_USE_TLS", "WEBHOOK_TIMEOUT"} {
+        if !strings.Contains(err.Error(), key) {
+            t.Errorf("LoadConfig() error = %v, se esperaba mención de %s", err, key)
+        }
+    }
+
+    // Una carga posterior correcta no arrastra los errores anteriores
+    t.Setenv("MQTT_QOS", "1")
+    t.Setenv("MQTT_USE_TLS", "false")
+    t.Setenv("WEBHOOK_TIMEOUT", "10s")
+    if _, err := LoadConfig(); err != nil {
+        t.Errorf("LoadConfig() error = %v tras corregir los valores", err)
+    }
+}
+
+func TestLoadConfig_AcceptsRateLimitModes(t *testing.T) {
+    for _, mode := range []string{RATE_LIMIT_MODE_TOKEN_BUCKET, RATE_LIMIT_MODE_FIXED_WINDOW} {
+        t.Run(mode, func(t *testing.T) {
+            t.Setenv("RATE_LIMIT_MODE", mode)
+
+            cfg, err := LoadConfig()
+            if err != nil {
+                t.Fatalf("LoadConfig() error = %v", err)
+            }
+            if cfg.Security.RateLimitMode != mode {
+                t.Errorf("RateLimitMode = %q, se esperaba %q", cfg.Security.RateLimitMode, mode)
+            }
+        })
+    }
+}
+
+func TestLoadConfig_MQTTSession(t *testing.T) {
+    tests := []struct {
+        name             string
+        env              map[string]string
+        wantClientID     string
+        wantCleanSession bool
+    }{
+        {name: "por defecto sesión limpia", wantCleanSession: true},
+        {name: "client ID propio", env: map[string]string{"MQTT_CLIENT_ID": "hub-planta-2"}, wantClientID: "hub-planta-2", wantCleanSession: true},
+        {
+            name:         "sesión persistente",
+            env:          map[string]string{"MQTT_CLEAN_SESSION": "false", "MQTT_CLIENT_ID": "hub-planta-2"},
+            wantClientID: "hub-planta-2",
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            for key, value := range tt.env {
+                t.Setenv(key, value)
+            }
+
+            cfg, err := LoadConfig()
+            if err != nil {
+                t.Fatalf("LoadConfig() error = %v", err)
+            }
+            if cfg.MQTT.ClientID != tt.wantClientID || cfg.MQTT.CleanSession != tt.wantCleanSession {
+                t.Errorf("sesión = %q/%v, se esperaba %q/%v", cfg.MQTT.ClientID, cfg.MQTT.CleanSession, tt.wantClientID, tt.wantCleanSession)
+            }
+        })
+    }
+}
+
+func TestLoadConfig_CustomThresholdsChangeDetection(t *testing.T) {
+    tests := []struct {
+        name        string
+        temperature float64
+        wantDefault bool
+        wantCustom  bool
+    }{
+        {name: "por debajo de ambos", temperature: 35},
+        {name: "entre el umbral propio y el por defecto", temperature: 45, wantCustom: true},
+        {name: "por encima de ambos", temperature: 55, wantDefault: true, wantCustom: true},
+    }
+
+    t.Setenv("THRESHOLD_TEMPERATURE_MAX", "40")
+    cfg, err := LoadConfig()
+    if err != nil {
+        t.Fatalf("LoadConfig() error = %v", err)
+    }
+    if cfg.Thresholds.TemperatureMax != 40 {
+        t.Fatalf("TemperatureMax = %v, se esperaba 40", cfg.Thresholds.TemperatureMax)
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            data := SensorData{DeviceID: "sensor-1", Temperature: tt.temperature, Humidity: 15, BatteryLevel: 80}
+            if got := len(detectAnomaliesWith(&data, DefaultAnomalyThresholds())) > 0; got != tt.wantDefault {
+                t.Errorf("anomalía con umbrales por defecto = %v, se esperaba %v", got, tt.wantDefault)
+            }
+            if got := len(detectAnomaliesWith(&data, cfg.Thresholds)) > 0; got != tt.wantCustom {
+                t.Errorf("anomalía con TEMPERATURE_MAX=40 = %v, se esperaba %v", got, tt.wantCustom)
+            }
+        })
+    }
+}
+
+func TestConfig_Validate(t *testing.T) {
+    tests := []struct {
+        name string
+        env  map[string]string
+        // Fragmentos que debe incluir el error (ninguno = configuración válida)
+        wantErrs []string
+    }{
+        {name: "configuración mínima válida"},
+        {name: "sin host MQTT", env: map[string]string{"MQTT_HOST": ""}, wantErrs: []string{"MQTT_HOST"}},
+        {name: "sin topic MQTT", env: map[string]string{"MQTT_TOPIC": " , "}, wantErrs: []string{"MQTT_TOPIC"}},
+        {name: "Discord sin URL", env: map[string]string{"ENABLE_DISCORD": "true"}, wantErrs: []string{"DISCORD_WEBHOOK_URL"}},
+        {name: "Discord con URL", env: map[string]string{"ENABLE_DISCORD": "true", "DISCORD_WEBHOOK_URL": "https://discord.example/hook"}},
+        {name: "webhook sin URL", env: map[string]string{"ENABLE_WEBHOOK": "true"}, wantErrs: []string{"WEBHOOK_URL"}},
+        {name: "certificado sin clave", env: map[string]string{"MQTT_CLIENT_CERT": "/etc/hub/cert.pem"}, wantErrs: []string{"MQTT_CLIENT_KEY"}},
+        {name: "sesión persistente sin client ID", env: map[string]string{"MQTT_CLEAN_SESSION": "false"}, wantErrs: []string{"MQTT_CLIENT_ID"}},
+        {
+            name:     "sesión persistente con el client ID compartido",
+            env:      map[string]string{"MQTT_CLEAN_SESSION": "false", "MQTT_CLIENT_ID": MQTT_DEFAULT_CLIENT_ID},
+            wantErrs: []string{"MQTT_CLIENT_ID"},
+        },
+        {name: "sesión persistente con client ID propio", env: map[string]string{"MQTT_CLEAN_SESSION": "false", "MQTT_CLIENT_ID": "hub-planta-2"}},
+        {
+            name:     "varios errores a la vez",
+            env:      map[string]string{"MQTT_HOST": "", "ENABLE_DISCORD": "true", "ENABLE_WEBHOOK": "true"},
+            wantErrs: []string{"MQTT_HOST", "DISCORD_WEBHOOK_URL", "ENABLE_WEBHOOK"},
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            t.Setenv("MQTT_HOST", "tcp://localhost:1883")
+            t.Setenv("MQTT_TOPIC", "iot/sensors")
+            for key, value := range tt.env {
+                t.Setenv(key, value)
+            }
+            cfg, err := LoadConfig()
+            if err != nil {
+                t.Fatalf("LoadConfig() error = %v", err)
+            }
+
+            err = cfg.Validate()
+            if len(tt.wantErrs) == 0 {
+                if err != nil {
+                    t.Errorf("Validate() error = %v, se esperaba nil", err)
+                }
+                return
+            }
+            if err == nil {
+                t.Fatalf("Validate() sin error, se esperaba uno sobre %v", tt.wantErrs)
+            }
+            for _, want := range tt.wantErrs {
+                if !strings.Contains(err.Error(), want) {
+                    t.Errorf("Validate() error = %v, se esperaba mención de %s", err, want)
+                }
+            }
+        })
+    }
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+    _ "embed"
+    "net/http"
+    "sort"
+    "strconv"
+    "time"
+)
+
+// Dashboard mínimo servido por el propio hub: HTML y JS sin dependencias que
+// consume /devices, /quarantines y /anomalies
+//
+//go:embed dashboard/index.html
+var dashboardHTML []byte
+
+// Servir el dashboard en GET / (deshabilitable con ENABLE_DASHBOARD=false)
+var dashboardEnabled = true
+
+// Límite por defecto de GET /anomalies
+const RECENT_ANOMALIES_DEFAULT_LIMIT = 50
+
+// Quarantine activa tal como la muestra GET /quarantines
+type QuarantineSummary struct {
+    DeviceID string           `json:"device_id"`
+    Reason   QuarantineReason `json:"reason"`
+    Detail   string           `json:"detail,omitempty"`
+    Since    time.Time        `json:"since"`
+    Until    time.Time        `json:"until"`
+}
+
+// Estadísticas de todos los dispositivos conocidos, ordenadas por ID
+func (qs *QuarantineSystem) AllDeviceStats() []DeviceStats {
+    qs.mutex.RLock()
+    deviceIDs := make([]string, 0, len(qs.deviceBehavior))
+    for deviceID := range qs.deviceBehavior {
+        deviceIDs = append(deviceIDs, deviceID)
+    }
+    qs.mutex.RUnlock()
+    sort.Strings(deviceIDs)
+
+    result := make([]DeviceStats, 0, len(deviceIDs))
+    for _, deviceID := range deviceIDs {
+        if stats, found := qs.GetDeviceStats(deviceID); found {
+            result = append(result, *stats)
+        }
+    }
+    return result
+}
+
+// Quarantines activas, ordenadas por fin
+func (qs *QuarantineSystem) ActiveQuarantines() []QuarantineSummary {
+    qs.mutex.RLock()
+    defer qs.mutex.RUnlock()
+
+    result := make([]QuarantineSummary, 0, len(qs.quarantinedDevices))
+    for deviceID, entry := range qs.quarantinedDevices {
+        result = append(result, QuarantineSummary{
+            DeviceID: deviceID,
+            Reason:   entry.Reason,
+            Detail:   entry.Detail,
+            Since:    entry.Since,
+            Until:    entry.Since.Add(entry.Duration),
+        })
+    }
+    sort.Slice(result, func(i, j int) bool {
+        return result[i].Until.Before(result[j].Until)
+    })
+    return result
+}
+
+// GET /: dashboard embebido
+func handleDashboard(w http.ResponseWriter, r *http.Request) {
+    if !dashboardEnabled {
+        http.NotFound(w, r)
+        return
+    }
+    w.Header().Set("Content-Type", "text/html; charset=utf-8")
+    w.Write(dashboardHTML)
+}
+
+// GET /devices: estadísticas de todos los dispositivos
+func handleListDevices(w http.ResponseWriter, r *http.Request) {
+    writeJSON(w, http.StatusOK, quarantineSystem.AllDeviceStats())
+}
+
+// GET /quarantines: dispositivos en quarantine ahora mismo
+func handleListQuarantines(w http.ResponseWriter, r *http.Request) {
+    writeJSON(w, http.StatusOK, quarantineSystem.ActiveQuarantines())
+}
+
+// GET /anomalies?limit=50: anomalías más recientes primero
+func handleRecentAnomalies(w http.ResponseWriter, r *http.Request) {
+    limit := RECENT_ANOMALIES_DEFAULT_LIMIT
+    if raw := r.URL.Query().Get("limit"); raw != "" {
+        parsed, err := strconv.Atoi(raw)
+        if err != nil || parsed <= 0 {
+            writeError(w, http.StatusBadRequest, "limit inválido: "+raw)
+            return
+        }
+        limit = parsed
+    }
+    writeJSON(w, http.StatusOK, anomalyRepository.Recent(limit))
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+    "context"
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestHandleDashboard(t *testing.T) {
+    tests := []struct {
+        name       string
+        enabled    string
+        path       string
+        wantStatus int
+    }{
+        {name: "habilitado", enabled: "true", path: "/", wantStatus: http.StatusOK},
+        {name: "deshabilitado", enabled: "false", path: "/", wantStatus: http.StatusNotFound},
+        {name: "ruta desconocida", enabled: "true", path: "/no-existe", wantStatus: http.StatusNotFound},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            t.Setenv("ENABLE_DASHBOARD", tt.enabled)
+            cfg, err := LoadConfig()
+            if err != nil {
+                t.Fatalf("LoadConfig() error = %v", err)
+            }
+            saved := dashboardEnabled
+            t.Cleanup(func() { dashboardEnabled = saved })
+            dashboardEnabled = cfg.HTTP.EnableDashboard
+
+            recorder := httptest.NewRecorder()
+            newAPIRouter().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, tt.path, nil))
+            if recorder.Code != tt.wantStatus {
+                t.Fatalf("estado = %d, se esperaba %d", recorder.Code, tt.wantStatus)
+            }
+            if tt.wantStatus != http.StatusOK {
+                return
+            }
+            if got := recorder.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/html") {
+                t.Errorf("Content-Type = %q, se esperaba text/html", got)
+            }
+            if recorder.Body.String() != string(dashboardHTML) {
+                t.Error("la respuesta no es el HTML embebido")
+            }
+        })
+    }
+}
+
+// Las rutas que consume el dashboard responden con los datos del hub
+func TestDashboardEndpoints(t *testing.T) {
+    tests := []struct {
+        path       string
+        wantStatus int
+        wantItems  int
+    }{
+        {path: "/devices", wantStatus: http.StatusOK, wantItems: 2},
+        {path: "/quarantines", wantStatus: http.StatusOK, wantItems: 1},
+        {path: "/anomalies?limit=50", wantStatus: http.StatusOK, wantItems: 2},
+        {path: "/anomalies?limit=1", wantStatus: http.StatusOK, wantItems: 1},
+        {path: "/anomalies?limit=0", wantStatus: http.StatusBadRequest},
+    }
+
+    clock := setupTestHub(t)
+    for _, deviceID := range []string{"sensor-1", "sensor-2"} {
+        clock.Advance(time.Second)
+        data := SensorData{DeviceID: deviceID, Timestamp: clock.Now().Unix(), Temperature: 21, Humidity: 40, BatteryLevel: 80}
+        if err := processSensorData(context.Background(), data, nil, nil); err != nil {
+            t.Fatalf("processSensorData(%s): %v", deviceID, err)
+        }
+    }
+    anomalyRepository.Save([]Anomaly{
+        {DeviceID: "sensor-1", Type: AnomalyTemperature, Timestamp: clock.Now()},
+        {DeviceID: "sensor-2", Type: AnomalyHumidity, Timestamp: clock.Now()},
+    })
+    quarantineSystem.QuarantineDeviceWithReason("sensor-1", QuarantineReasonOther, "test")
+
+    if !strings.Contains(string(dashboardHTML), `getJSON("/devices")`) {
+        t.Fatal("el dashboard ya no consume /devices")
+    }
+    for _, tt := range tests {
+        t.Run(tt.path, func(t *testing.T) {
+            recorder := httptest.NewRecorder()
+            newAPIRouter().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, tt.path, nil))
+            if recorder.Code != tt.wantStatus {
+                t.Fatalf("estado = %d, se esperaba %d", recorder.Code, tt.wantStatus)
+            }
+            if tt.wantStatus != http.StatusOK {
+                return
+            }
+
+            var items []json.RawMessage
+            if err := json.Unmarshal(recorder.Body.Bytes(), &items); err != nil {
+                t.Fatalf("respuesta no válida: %v", err)
+            }
+            if len(items) != tt.wantItems {
+                t.Errorf("elementos = %d, se esperaban %d", len(items), tt.wantItems)
+            }
+        })
+    }
+}
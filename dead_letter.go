@@ -0,0 +1,108 @@
+package main
+
+import (
+    "encoding/base64"
+    "encoding/json"
+    "fmt"
+    "log"
+    "os"
+    "sync"
+    "time"
+    "unicode/utf8"
+)
+
+// Mensajes que no se pudieron decodificar, guardados para depurar firmware
+// defectuoso. Se escriben como JSON por líneas y al superar maxSize el
+// fichero se rota a "<path>.1" (se conserva una sola rotación).
+type DeadLetterLog struct {
+    mutex   sync.Mutex
+    path    string
+    maxSize int64
+}
+
+// Entrada del dead-letter log
+type DeadLetter struct {
+    Timestamp time.Time `json:"timestamp"`
+    Topic     string    `json:"topic"`
+    Error     string    `json:"error"`
+    Payload   string    `json:"payload"`
+    // "base64" si el payload no es texto (p. ej. CBOR)
+    Encoding string `json:"encoding,omitempty"`
+}
+
+// Dead-letter log activo (nil = deshabilitado)
+var deadLetters *DeadLetterLog
+
+// Crear dead-letter log en el fichero indicado
+func NewDeadLetterLog(path string, maxSize int64) *DeadLetterLog {
+    return &DeadLetterLog{path: path, maxSize: maxSize}
+}
+
+// Guardar un payload que no se pudo decodificar
+func (dl *DeadLetterLog) SaveDeadLetter(topic string, payload []byte, cause error) error {
+    entry := DeadLetter{
+        Timestamp: time.Now(),
+        Topic:     topic,
+        Error:     cause.Error(),
+        Payload:   string(payload),
+    }
+    if !utf8.Valid(payload) {
+        entry.Payload = base64.StdEncoding.EncodeToString(payload)
+        entry.Encoding = "base64"
+    }
+
+    line, err := json.Marshal(entry)
+    if err != nil {
+        return err
+    }
+    line = append(line, '\n')
+
+    dl.mutex.Lock()
+    defer dl.mutex.Unlock()
+
+    if err := dl.rotateLocked(int64(len(line))); err != nil {
+        return err
+    }
+
+    file, err := os.OpenFile(dl.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+    if err != nil {
+        return err
+    }
+    defer file.Close()
+
+    _, err = file.Write(line)
+    return err
+}
+
+// Rotar el fichero si la nueva entrada haría superar el tamaño máximo
+func (dl *DeadLetterLog) rotateLocked(incoming int64) error {
+    if dl.maxSize <= 0 {
+        return nil
+    }
+    info, err := os.Stat(dl.path)
+    if os.IsNotExist(err) {
+        return nil
+    }
+    if err != nil {
+        return err
+    }
+    if info.Size()+incoming <= dl.maxSize {
+        return nil
+    }
+    if err := os.Rename(dl.path, dl.path+".1"); err != nil {
+        return fmt.Errorf("no se pudo rotar el dead-letter log: %w", err)
+    }
+    return nil
+}
+
+// Registrar un mensaje no decodificable en el dead-letter log, si está activo
+func recordDeadLetter(topic string, payload []byte, cause error) {
+    if deadLetters == nil {
+        return
+    }
+    if err := deadLetters.SaveDeadLetter(topic, payload, cause); err != nil {
+        log.Printf("❌ Error guardando mensaje en el dead-letter log: %v", err)
+        return
+    }
+    metrics.Inc("iot_dead_letters")
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+    "bufio"
+    "context"
+    "encoding/json"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+// Activar un dead-letter log en un directorio temporal durante el test
+func withDeadLetterLog(t *testing.T, maxSize int64) string {
+    t.Helper()
+    saved := deadLetters
+    t.Cleanup(func() { deadLetters = saved })
+
+    path := filepath.Join(t.TempDir(), "dead-letters.jsonl")
+    deadLetters = NewDeadLetterLog(path, maxSize)
+    return path
+}
+
+// Leer las entradas del dead-letter log (ninguna si el fichero no existe)
+func readDeadLetters(t *testing.T, path string) []DeadLetter {
+    t.Helper()
+    file, err := os.Open(path)
+    if os.IsNotExist(err) {
+        return nil
+    }
+    if err != nil {
+        t.Fatalf("abriendo dead-letter log: %v", err)
+    }
+    defer file.Close()
+
+    var entries []DeadLetter
+    scanner := bufio.NewScanner(file)
+    for scanner.Scan() {
+        var entry DeadLetter
+        if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+            t.Fatalf("entrada no válida %q: %v", scanner.Text(), err)
+        }
+        entries = append(entries, entry)
+    }
+    return entries
+}
+
+func TestIngestPayload_DeadLetter(t *testing.T) {
+    tests := []struct {
+        name         string
+        payload      string
+        wantPayloads []string
+        wantEncoding string
+    }{
+        {name: "JSON válido", payload: validReadingJSON("sensor-1")},
+        {name: "JSON truncado", payload: `{"device_id": "sensor-1", "temperature": `, wantPayloads: []string{`{"device_id": "sensor-1", "temperature": `}},
+        {name: "tipo incorrecto", payload: `{"device_id": 42}`, wantPayloads: []string{`{"device_id": 42}`}},
+        {name: "binario", payload: "\xff\xfe\xfd", wantPayloads: []string{"//79"}, wantEncoding: "base64"},
+        {name: "lote con un elemento roto", payload: "[" + validReadingJSON("sensor-1") + `, {"device_id": 42}]`, wantPayloads: []string{`{"device_id": 42}`}},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            setupTestHub(t)
+            path := withDeadLetterLog(t, 0)
+
+            ingestPayload(context.Background(), "sensors/sensor-1/data", []byte(tt.payload))
+
+            entries := readDeadLetters(t, path)
+            if len(entries) != len(tt.wantPayloads) {
+                t.Fatalf("entradas = %d, se esperaban %d", len(entries), len(tt.wantPayloads))
+            }
+            for i, entry := range entries {
+                if entry.Payload != tt.wantPayloads[i] {
+                    t.Errorf("payload = %q, se esperaba %q", entry.Payload, tt.wantPayloads[i])
+                }
+                if entry.Encoding != tt.wantEncoding {
+                    t.Errorf("codificación = %q, se esperaba %q", entry.Encoding, tt.wantEncoding)
+                }
+                if entry.Topic != "sensors/sensor-1/data" || entry.Error == "" || entry.Timestamp.IsZero() {
+                    t.Errorf("entrada incompleta: %+v", entry)
+                }
+            }
+        })
+    }
+}
+
+func TestDeadLetterLog_Rotation(t *testing.T) {
+    tests := []struct {
+        name        string
+        maxSize     int64
+        messages    int
+        wantCurrent int
+        wantRotated bool
+    }{
+        {name: "sin límite", maxSize: 0, messages: 5, wantCurrent: 5},
+        {name: "por debajo del límite", maxSize: 10000, messages: 5, wantCurrent: 5},
+        {name: "rota al superar el límite", maxSize: 300, messages: 5, wantCurrent: 1, wantRotated: true},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            path := withDeadLetterLog(t, tt.maxSize)
+            payload := []byte(strings.Repeat("x", 100))
+
+            for i := 0; i < tt.messages; i++ {
+                if err := deadLetters.SaveDeadLetter("test", payload, ErrInvalidData); err != nil {
+                    t.Fatalf("SaveDeadLetter: %v", err)
+                }
+            }
+
+            if got := len(readDeadLetters(t, path)); got != tt.wantCurrent {
+                t.Errorf("entradas en el fichero actual = %d, se esperaban %d", got, tt.wantCurrent)
+            }
+            _, err := os.Stat(path + ".1")
+            if rotated := err == nil; rotated != tt.wantRotated {
+                t.Errorf("rotado = %v, se esperaba %v", rotated, tt.wantRotated)
+            }
+        })
+    }
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "sort"
+)
+
+// Decodificación tolerante: conservar los campos válidos de un payload con
+// algún campo mal formado (opcional, deshabilitada por defecto)
+var lenientDecoding bool
+
+// Decodificar un payload JSON de sensor. En modo tolerante, si el JSON es
+// válido pero algún campo no encaja con su tipo, se descarta ese campo y se
+// devuelven sus nombres en dropped.
+func decodeSensorData(payload []byte, lenient bool) (data SensorData, dropped []string, err error) {
+    err = json.Unmarshal(payload, &data)
+    if err == nil {
+        data.present = jsonFieldNames(payload)
+        return data, nil, nil
+    }
+    if !lenient {
+        return data, nil, err
+    }
+
+    var fields map[string]json.RawMessage
+    if jsonErr := json.Unmarshal(payload, &fields); jsonErr != nil {
+        // No es ni siquiera un objeto JSON: nada que rescatar
+        return SensorData{}, nil, err
+    }
+
+    data = SensorData{}
+    for name, raw := range fields {
+        single, _ := json.Marshal(map[string]json.RawMessage{name: raw})
+        if fieldErr := json.Unmarshal(single, &data); fieldErr != nil {
+            dropped = append(dropped, name)
+        }
+    }
+    sort.Strings(dropped)
+    data.present = jsonFieldNames(payload)
+    for _, name := range dropped {
+        delete(data.present, name)
+    }
+
+    if data.DeviceID == "" {
+        return SensorData{}, dropped, fmt.Errorf("payload sin device_id válido: %w", err)
+    }
+
+    return data, dropped, nil
+}
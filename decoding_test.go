@@ -0,0 +1,105 @@
+package main
+
+import (
+    "context"
+    "fmt"
+    "reflect"
+    "testing"
+)
+
+func TestDecodeSensorData(t *testing.T) {
+    tests := []struct {
+        name            string
+        payload         string
+        lenient         bool
+        wantErr         bool
+        wantDropped     []string
+        wantTemperature float64
+        wantHumidity    float64
+    }{
+        {name: "payload válido", payload: `{"device_id":"sensor-1","temperature":21.5,"humidity":40}`, wantTemperature: 21.5, wantHumidity: 40},
+        {name: "campo mal formado en modo estricto", payload: `{"device_id":"sensor-1","temperature":"caliente","humidity":40}`, wantErr: true},
+        {
+            name:         "campo mal formado en modo tolerante",
+            payload:      `{"device_id":"sensor-1","temperature":"caliente","humidity":40}`,
+            lenient:      true,
+            wantDropped:  []string{"temperature"},
+            wantHumidity: 40,
+        },
+        {
+            name:            "varios campos mal formados",
+            payload:         `{"device_id":"sensor-1","temperature":21.5,"humidity":"alta","battery_level":[80]}`,
+            lenient:         true,
+            wantDropped:     []string{"battery_level", "humidity"},
+            wantTemperature: 21.5,
+        },
+        {name: "device_id mal formado", payload: `{"device_id":7,"temperature":21.5}`, lenient: true, wantErr: true, wantDropped: []string{"device_id"}},
+        {name: "no es un objeto JSON", payload: `[1,2,3]`, lenient: true, wantErr: true},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            data, dropped, err := decodeSensorData([]byte(tt.payload), tt.lenient)
+            if (err != nil) != tt.wantErr {
+                t.Fatalf("error = %v, se esperaba error: %v", err, tt.wantErr)
+            }
+            if !reflect.DeepEqual(dropped, tt.wantDropped) {
+                t.Errorf("campos descartados = %v, se esperaban %v", dropped, tt.wantDropped)
+            }
+            if tt.wantErr {
+                return
+            }
+            if data.Temperature != tt.wantTemperature || data.Humidity != tt.wantHumidity {
+                t.Errorf("temperatura/humedad = %v/%v, se esperaban %v/%v", data.Temperature, data.Humidity, tt.wantTemperature, tt.wantHumidity)
+            }
+            for _, name := range tt.wantDropped {
+                if data.present[name] {
+                    t.Errorf("el campo descartado %s no debe figurar como presente", name)
+                }
+            }
+        })
+    }
+}
+
+func TestIngestPayload_LenientDecoding(t *testing.T) {
+    tests := []struct {
+        name          string
+        lenient       bool
+        wantErr       bool
+        wantAnomalies int
+    }{
+        {name: "modo estricto rechaza el payload", wantErr: true},
+        {name: "modo tolerante procesa los campos válidos", lenient: true, wantAnomalies: 1},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            clock := setupTestHub(t)
+            saved := lenientDecoding
+            t.Cleanup(func() { lenientDecoding = saved })
+            lenientDecoding = tt.lenient
+
+            payload := fmt.Sprintf(`{"device_id":"sensor-1","timestamp":%d,"temperature":"caliente","humidity":40,"battery_level":80}`, clock.Now().Unix())
+            err := ingestPayload(context.Background(), "test", []byte(payload))
+            if (err != nil) != tt.wantErr {
+                t.Fatalf("error = %v, se esperaba error: %v", err, tt.wantErr)
+            }
+
+            var quality []Anomaly
+            for _, anomaly := range anomalyRepository.ByDevice("sensor-1", false) {
+                if anomaly.Type == AnomalyDataQuality {
+                    quality = append(quality, anomaly)
+                }
+            }
+            if len(quality) != tt.wantAnomalies {
+                t.Fatalf("anomalías de calidad = %d, se esperaban %d", len(quality), tt.wantAnomalies)
+            }
+            if tt.lenient {
+                readings := readingHistory.All()
+                if len(readings) != 1 || readings[0].Humidity != 40 || readings[0].BatteryLevel != 80 {
+                    t.Errorf("lecturas procesadas = %+v, se esperaba la humedad y la batería del payload", readings)
+                }
+            }
+        })
+    }
+}
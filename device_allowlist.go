@@ -0,0 +1,36 @@
+package main
+
+import "fmt"
+
+// Dispositivos autorizados a enviar mensajes. Vacío = cualquier device_id se
+// acepta y se da de alta automáticamente.
+var deviceAllowlist = map[string]bool{}
+
+// Conjunto de IDs autorizados a partir de la lista configurada
+func newDeviceAllowlist(deviceIDs []string) map[string]bool {
+    allowlist := make(map[string]bool, len(deviceIDs))
+    for _, deviceID := range deviceIDs {
+        allowlist[deviceID] = true
+    }
+    return allowlist
+}
+
+// Si el dispositivo puede enviar mensajes
+func isDeviceAllowed(deviceID string) bool {
+    return len(deviceAllowlist) == 0 || deviceAllowlist[deviceID]
+}
+
+// Rechazar mensajes de dispositivos fuera de la lista. No se crea historial
+// ni quarantine para el ID: un atacante podría inventar IDs sin límite.
+func checkDeviceAllowed(deviceID string) error {
+    if isDeviceAllowed(deviceID) {
+        return nil
+    }
+
+    logger.WarnWith(fmt.Sprintf("🚷 SEGURIDAD: Mensaje de dispositivo desconocido %s rechazado", deviceID), map[string]interface{}{
+        "device_id":      deviceID,
+        "security_event": "unknown_device",
+    })
+    metrics.Inc("iot_messages_rejected", "reason", "unknown_device")
+    return fmt.Errorf("%s: %w", deviceID, ErrUnknownDevice)
+}
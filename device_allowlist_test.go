@@ -0,0 +1,79 @@
+package main
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "strings"
+    "testing"
+)
+
+// Usar la lista de dispositivos autorizados durante el test
+func withDeviceAllowlist(t *testing.T, deviceIDs ...string) {
+    t.Helper()
+    saved := deviceAllowlist
+    t.Cleanup(func() { deviceAllowlist = saved })
+    deviceAllowlist = newDeviceAllowlist(deviceIDs)
+}
+
+func TestCheckDeviceAllowed(t *testing.T) {
+    tests := []struct {
+        name      string
+        allowlist []string
+        deviceID  string
+        wantErr   bool
+    }{
+        {name: "sin lista se acepta cualquiera", deviceID: "sensor-9"},
+        {name: "en la lista", allowlist: []string{"sensor-1"}, deviceID: "sensor-1"},
+        {name: "fuera de la lista", allowlist: []string{"sensor-1"}, deviceID: "sensor-9", wantErr: true},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            withDeviceAllowlist(t, tt.allowlist...)
+            err := checkDeviceAllowed(tt.deviceID)
+            if (err != nil) != tt.wantErr {
+                t.Fatalf("error = %v, se esperaba error: %v", err, tt.wantErr)
+            }
+            if tt.wantErr && !errors.Is(err, ErrUnknownDevice) {
+                t.Errorf("error = %v, se esperaba ErrUnknownDevice", err)
+            }
+        })
+    }
+}
+
+func TestIngestPayload_OversizedFromUnknownDevice(t *testing.T) {
+    tests := []struct {
+        name           string
+        allowlist      []string
+        wantQuarantine bool
+    }{
+        {name: "lista activa y dispositivo desconocido", allowlist: []string{"sensor-1"}},
+        {name: "sin lista", wantQuarantine: true},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            setupTestHub(t)
+            withDeviceAllowlist(t, tt.allowlist...)
+            savedQuarantine := quarantineOversizedPayloads
+            quarantineOversizedPayloads = true
+            t.Cleanup(func() { quarantineOversizedPayloads = savedQuarantine })
+
+            payload := []byte(fmt.Sprintf(`{"device_id":"intruso","padding":"%s"}`, strings.Repeat("x", maxPayloadSize)))
+            if err := ingestPayload(context.Background(), "test", payload); !errors.Is(err, ErrPayloadTooLarge) {
+                t.Fatalf("error = %v, se esperaba ErrPayloadTooLarge", err)
+            }
+
+            if got := quarantineSystem.IsQuarantined("intruso"); got != tt.wantQuarantine {
+                t.Errorf("en quarantine = %v, se esperaba %v", got, tt.wantQuarantine)
+            }
+            quarantineSystem.mutex.RLock()
+            _, tracked := quarantineSystem.deviceBehavior["intruso"]
+            quarantineSystem.mutex.RUnlock()
+            if tracked != tt.wantQuarantine {
+                t.Errorf("con historial = %v, se esperaba %v", tracked, tt.wantQuarantine)
+            }
+        })
+    }
+}
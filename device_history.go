@@ -0,0 +1,76 @@
+package main
+
+import (
+    "net/http"
+    "time"
+)
+
+// Lecturas recientes conservadas por dispositivo
+var deviceHistorySize = 50
+
+// Lectura cruda tal como llegó, con la hora de recepción
+type ReadingSnapshot struct {
+    ReceivedAt time.Time  `json:"received_at"`
+    Reading    SensorData `json:"reading"`
+}
+
+// Buffer circular de lecturas: al llenarse sobrescribe la más antigua
+type readingRing struct {
+    entries []ReadingSnapshot
+    next    int
+}
+
+// Añadir una lectura. Si cambia la capacidad se conservan las más recientes.
+func (ring *readingRing) add(snapshot ReadingSnapshot, capacity int) {
+    if capacity <= 0 {
+        ring.entries, ring.next = nil, 0
+        return
+    }
+    if cap(ring.entries) != capacity {
+        recent := ring.snapshot()
+        if len(recent) > capacity-1 {
+            recent = recent[len(recent)-(capacity-1):]
+        }
+        ring.entries = append(make([]ReadingSnapshot, 0, capacity), recent...)
+        ring.next = len(ring.entries)
+    }
+
+    if len(ring.entries) < capacity {
+        ring.entries = append(ring.entries, snapshot)
+    } else {
+        ring.entries[ring.next] = snapshot
+    }
+    ring.next = (ring.next + 1) % capacity
+}
+
+// Copia de las lecturas, de la más antigua a la más reciente
+func (ring *readingRing) snapshot() []ReadingSnapshot {
+    result := make([]ReadingSnapshot, 0, len(ring.entries))
+    if len(ring.entries) < cap(ring.entries) {
+        return append(result, ring.entries...)
+    }
+    result = append(result, ring.entries[ring.next:]...)
+    return append(result, ring.entries[:ring.next]...)
+}
+
+// Lecturas recientes de un dispositivo; false si no se conoce
+func (qs *QuarantineSystem) RecentReadings(deviceID string) ([]ReadingSnapshot, bool) {
+    qs.mutex.RLock()
+    defer qs.mutex.RUnlock()
+
+    behavior, exists := qs.deviceBehavior[deviceID]
+    if !exists {
+        return nil, false
+    }
+    return behavior.RecentReadings.snapshot(), true
+}
+
+// GET /devices/{id}/history: últimas lecturas crudas del dispositivo
+func handleDeviceHistory(w http.ResponseWriter, r *http.Request) {
+    readings, found := quarantineSystem.RecentReadings(r.PathValue("id"))
+    if !found {
+        writeError(w, http.StatusNotFound, "dispositivo no encontrado")
+        return
+    }
+    writeJSON(w, http.StatusOK, readings)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+    "context"
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+    "time"
+)
+
+func TestHandleDeviceHistory_Eviction(t *testing.T) {
+    tests := []struct {
+        name      string
+        size      int
+        readings  int
+        wantFirst int
+    }{
+        {name: "por debajo de la capacidad", size: 5, readings: 3, wantFirst: 0},
+        {name: "justo la capacidad", size: 5, readings: 5, wantFirst: 0},
+        {name: "supera la capacidad", size: 5, readings: 12, wantFirst: 7},
+        {name: "capacidad 1", size: 1, readings: 4, wantFirst: 3},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            clock := setupTestHub(t)
+            saved := deviceHistorySize
+            t.Cleanup(func() { deviceHistorySize = saved })
+            deviceHistorySize = tt.size
+
+            // La temperatura identifica cada lectura
+            for i := 0; i < tt.readings; i++ {
+                clock.Advance(time.Minute)
+                data := SensorData{DeviceID: "sensor-1", Timestamp: clock.Now().Unix(), Temperature: 20 + float64(i)/10, Humidity: 40, BatteryLevel: 80}
+                if err := processSensorData(context.Background(), data, nil, nil); err != nil {
+                    t.Fatalf("lectura %d: %v", i, err)
+                }
+            }
+
+            recorder := httptest.NewRecorder()
+            newAPIRouter().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/devices/sensor-1/history", nil))
+            if recorder.Code != http.StatusOK {
+                t.Fatalf("estado = %d, se esperaba %d", recorder.Code, http.StatusOK)
+            }
+            var history []ReadingSnapshot
+            if err := json.Unmarshal(recorder.Body.Bytes(), &history); err != nil {
+                t.Fatalf("respuesta no válida: %v", err)
+            }
+
+            if want := tt.readings - tt.wantFirst; len(history) != want {
+                t.Fatalf("lecturas = %d, se esperaban %d", len(history), want)
+            }
+            for i, snapshot := range history {
+                index := tt.wantFirst + i
+                if want := 20 + float64(index)/10; snapshot.Reading.Temperature != want {
+                    t.Errorf("posición %d: temperatura = %v, se esperaba %v", i, snapshot.Reading.Temperature, want)
+                }
+                if want := testEpoch.Add(time.Duration(index+1) * time.Minute); !snapshot.ReceivedAt.Equal(want) {
+                    t.Errorf("posición %d: recibida %s, se esperaba %s", i, snapshot.ReceivedAt, want)
+                }
+            }
+        })
+    }
+}
+
+func TestHandleDeviceHistory_UnknownDevice(t *testing.T) {
+    setupTestHub(t)
+    recorder := httptest.NewRecorder()
+    newAPIRouter().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/devices/sensor-9/history", nil))
+    if recorder.Code != http.StatusNotFound {
+        t.Errorf("estado = %d, se esperaba %d", recorder.Code, http.StatusNotFound)
+    }
+}
+
+func TestReadingRing_CapacityChange(t *testing.T) {
+    tests := []struct {
+        name        string
+        before      int
+        after       int
+        wantBattery []float64
+    }{
+        {name: "reduce la capacidad", before: 5, after: 2, wantBattery: []float64{4, 5}},
+        {name: "amplía la capacidad", before: 2, after: 4, wantBattery: []float64{3, 4, 5}},
+        {name: "deshabilitado", before: 3, after: 0, wantBattery: []float64{}},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            var ring readingRing
+            for battery := 1; battery <= 4; battery++ {
+                ring.add(ReadingSnapshot{Reading: SensorData{BatteryLevel: float64(battery)}}, tt.before)
+            }
+            ring.add(ReadingSnapshot{Reading: SensorData{BatteryLevel: 5}}, tt.after)
+
+            got := make([]float64, 0)
+            for _, snapshot := range ring.snapshot() {
+                got = append(got, snapshot.Reading.BatteryLevel)
+            }
+            if len(got) != len(tt.wantBattery) {
+                t.Fatalf("lecturas = %v, se esperaban %v", got, tt.wantBattery)
+            }
+            for i := range got {
+                if got[i] != tt.wantBattery[i] {
+                    t.Fatalf("lecturas = %v, se esperaban %v", got, tt.wantBattery)
+                }
+            }
+        })
+    }
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+    "container/list"
+    "errors"
+    "fmt"
+    "log"
+    "sort"
+)
+
+// Qué hacer con un dispositivo nuevo cuando se alcanza el máximo
+const (
+    DEVICE_LIMIT_POLICY_REJECT = "reject"
+    DEVICE_LIMIT_POLICY_EVICT  = "evict"
+)
+
+// Máximo de dispositivos distintos en memoria (0 = sin límite). Evita que
+// un atacante agote la memoria emitiendo millones de device_id distintos.
+var (
+    maxTrackedDevices = 10000
+    deviceLimitPolicy = DEVICE_LIMIT_POLICY_REJECT
+)
+
+var ErrDeviceLimit = errors.New("límite de dispositivos alcanzado")
+
+// Validar la política de límite de dispositivos
+func validateDeviceLimitPolicy(policy string) error {
+    switch policy {
+    case DEVICE_LIMIT_POLICY_REJECT, DEVICE_LIMIT_POLICY_EVICT:
+        return nil
+    default:
+        return fmt.Errorf("DEVICE_LIMIT_POLICY inválida: %q (reject o evict)", policy)
+    }
+}
+
+// Admitir un dispositivo antes de empezar a seguirlo. Los ya conocidos
+// siempre se admiten; uno nuevo con el límite alcanzado se rechaza o
+// desplaza al visto hace más tiempo, según la política.
+func (qs *QuarantineSystem) AdmitDevice(deviceID string) error {
+    qs.mutex.Lock()
+    _, admitted := qs.admitBehaviorLocked(deviceID)
+    tracked := len(qs.deviceBehavior)
+    qs.mutex.Unlock()
+
+    if admitted {
+        return nil
+    }
+    logger.WarnWith(fmt.Sprintf("🚷 SEGURIDAD: Dispositivo nuevo %s rechazado, límite de %d dispositivos alcanzado", deviceID, maxTrackedDevices), map[string]interface{}{
+        "device_id":       deviceID,
+        "security_event":  "device_limit",
+        "tracked_devices": tracked,
+    })
+    metrics.Inc("iot_messages_rejected", "reason", "device_limit")
+    return fmt.Errorf("%s: %w", deviceID, ErrDeviceLimit)
+}
+
+// Obtener o crear el historial de un dispositivo respetando el límite, en
+// la misma sección crítica que la comprobación para que los workers
+// concurrentes no lo sobrepasen. Devuelve false si no hay sitio para él
+// (llamar con el lock tomado).
+func (qs *QuarantineSystem) admitBehaviorLocked(deviceID string) (*DeviceBehavior, bool) {
+    if behavior, known := qs.deviceBehavior[deviceID]; known {
+        return behavior, true
+    }
+    if maxTrackedDevices <= 0 || len(qs.deviceBehavior) < maxTrackedDevices {
+        return qs.behaviorLocked(deviceID), true
+    }
+    if deviceLimitPolicy != DEVICE_LIMIT_POLICY_EVICT {
+        return nil, false
+    }
+
+    // Los dispositivos en quarantine no se desplazan: así el desplazamiento
+    // no sirve para salir de ella
+    evicted := qs.deviceRecency.oldest(func(candidate string) bool {
+        _, quarantined := qs.quarantinedDevices[candidate]
+        return !quarantined
+    })
+    if evicted == "" {
+        return nil, false
+    }
+    qs.deleteDeviceLocked(evicted)
+    qs.rateLimiter.Reset(evicted)
+    log.Printf("🧹 LÍMITE DE DISPOSITIVOS: %s desplazado por el nuevo dispositivo %s (máximo %d)", evicted, deviceID, maxTrackedDevices)
+    metrics.Inc("iot_devices_evicted")
+    return qs.behaviorLocked(deviceID), true
+}
+
+// Orden de los dispositivos por última actividad (el más reciente delante).
+// Se mantiene junto al mapa de comportamiento para elegir el dispositivo a
+// desplazar sin recorrerlos todos.
+type deviceRecency struct {
+    order    *list.List
+    elements map[string]*list.Element
+}
+
+// Marcar actividad del dispositivo (lo añade si no estaba)
+func (dr *deviceRecency) touch(deviceID string) {
+    if dr.order == nil {
+        dr.order = list.New()
+        dr.elements = make(map[string]*list.Element)
+    }
+    if element, exists := dr.elements[deviceID]; exists {
+        dr.order.MoveToFront(element)
+        return
+    }
+    dr.elements[deviceID] = dr.order.PushFront(deviceID)
+}
+
+// Olvidar un dispositivo
+func (dr *deviceRecency) remove(deviceID string) {
+    if element, exists := dr.elements[deviceID]; exists {
+        dr.order.Remove(element)
+        delete(dr.elements, deviceID)
+    }
+}
+
+// Dispositivo con la actividad más antigua que cumple eligible ("" si ninguno)
+func (dr *deviceRecency) oldest(eligible func(deviceID string) bool) string {
+    if dr.order == nil {
+        return ""
+    }
+    for element := dr.order.Back(); element != nil; element = element.Prev() {
+        if deviceID := element.Value.(string); eligible(deviceID) {
+            return deviceID
+        }
+    }
+    return ""
+}
+
+// Reconstruir el orden a partir de los historiales restaurados
+func (dr *deviceRecency) rebuild(devices map[string]*DeviceBehavior) {
+    ids := make([]string, 0, len(devices))
+    for deviceID := range devices {
+        ids = append(ids, deviceID)
+    }
+    sort.Slice(ids, func(i, j int) bool {
+        return devices[ids[i]].lastActivity().Before(devices[ids[j]].lastActivity())
+    })
+
+    *dr = deviceRecency{}
+    for _, deviceID := range ids {
+        dr.touch(deviceID)
+    }
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+    "errors"
+    "fmt"
+    "sync"
+    "testing"
+    "time"
+)
+
+// Usar el límite y la política de dispositivos indicados durante el test
+func withDeviceLimit(t *testing.T, max int, policy string) {
+    t.Helper()
+    savedMax, savedPolicy := maxTrackedDevices, deviceLimitPolicy
+    t.Cleanup(func() { maxTrackedDevices, deviceLimitPolicy = savedMax, savedPolicy })
+    maxTrackedDevices, deviceLimitPolicy = max, policy
+}
+
+func TestAdmitDevice_Limit(t *testing.T) {
+    tests := []struct {
+        name        string
+        policy      string
+        quarantined string
+        wantErr     bool
+        wantEvicted string
+    }{
+        {name: "rechazar el dispositivo 1001", policy: DEVICE_LIMIT_POLICY_REJECT, wantErr: true},
+        {name: "desplazar al más antiguo", policy: DEVICE_LIMIT_POLICY_EVICT, wantEvicted: "sensor-0"},
+        {name: "no desplazar dispositivos en quarantine", policy: DEVICE_LIMIT_POLICY_EVICT, quarantined: "sensor-0", wantEvicted: "sensor-1"},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            clock := setupTestHub(t)
+            withDeviceLimit(t, 1000, tt.policy)
+
+            for i := 0; i < 1000; i++ {
+                if err := quarantineSystem.AdmitDevice(fmt.Sprintf("sensor-%d", i)); err != nil {
+                    t.Fatalf("dispositivo %d rechazado por debajo del límite: %v", i, err)
+                }
+                clock.Advance(time.Second)
+            }
+            if tt.quarantined != "" {
+                quarantineSystem.QuarantineDeviceWithReason(tt.quarantined, QuarantineReasonOther, "test")
+            }
+            // Un dispositivo conocido sigue admitiéndose con el límite alcanzado
+            if err := quarantineSystem.AdmitDevice("sensor-500"); err != nil {
+                t.Fatalf("dispositivo conocido rechazado: %v", err)
+            }
+
+            err := quarantineSystem.AdmitDevice("sensor-nuevo")
+            if (err != nil) != tt.wantErr {
+                t.Fatalf("error = %v, se esperaba error: %v", err, tt.wantErr)
+            }
+            if tt.wantErr && !errors.Is(err, ErrDeviceLimit) {
+                t.Errorf("error = %v, se esperaba ErrDeviceLimit", err)
+            }
+            if got := len(quarantineSystem.deviceBehavior); got != 1000 {
+                t.Errorf("dispositivos en memoria = %d, se esperaban 1000", got)
+            }
+            if tt.wantEvicted != "" {
+                if _, tracked := quarantineSystem.deviceBehavior[tt.wantEvicted]; tracked {
+                    t.Errorf("%s debería haberse desplazado", tt.wantEvicted)
+                }
+            }
+        })
+    }
+}
+
+func TestAdmitDevice_ConcurrentNewDevices(t *testing.T) {
+    setupTestHub(t)
+    withDeviceLimit(t, 100, DEVICE_LIMIT_POLICY_REJECT)
+
+    var wg sync.WaitGroup
+    for i := 0; i < 500; i++ {
+        wg.Add(1)
+        go func(i int) {
+            defer wg.Done()
+            quarantineSystem.AdmitDevice(fmt.Sprintf("sensor-%d", i))
+        }(i)
+    }
+    wg.Wait()
+
+    if got := len(quarantineSystem.deviceBehavior); got != 100 {
+        t.Errorf("dispositivos en memoria = %d, se esperaba exactamente el límite de 100", got)
+    }
+}
+
+func TestAdmitDevice_EvictsLeastRecentlySeen(t *testing.T) {
+    clock := setupTestHub(t)
+    withDeviceLimit(t, 3, DEVICE_LIMIT_POLICY_EVICT)
+
+    for _, deviceID := range []string{"sensor-a", "sensor-b", "sensor-c"} {
+        quarantineSystem.AdmitDevice(deviceID)
+        clock.Advance(time.Second)
+    }
+    // sensor-a vuelve a reportar: el más antiguo pasa a ser sensor-b
+    quarantineSystem.AnalyzeDeviceBehavior(&SensorData{DeviceID: "sensor-a", Timestamp: clock.Now().Unix(), Temperature: 21})
+
+    quarantineSystem.AdmitDevice("sensor-d")
+    for deviceID, want := range map[string]bool{"sensor-a": true, "sensor-b": false, "sensor-c": true, "sensor-d": true} {
+        if _, tracked := quarantineSystem.deviceBehavior[deviceID]; tracked != want {
+            t.Errorf("%s en memoria = %v, se esperaba %v", deviceID, tracked, want)
+        }
+    }
+}
+
+func TestQuarantineUnknownDevice_RespectsLimit(t *testing.T) {
+    setupTestHub(t)
+    withDeviceLimit(t, 1, DEVICE_LIMIT_POLICY_REJECT)
+
+    quarantineSystem.AdmitDevice("sensor-1")
+    quarantineSystem.QuarantineDeviceWithReason("intruso", QuarantineReasonOther, "test")
+    quarantineSystem.ReleaseFromQuarantine("intruso", ReleaseReasonManual, "operador")
+
+    if got := len(quarantineSystem.deviceBehavior); got != 1 {
+        t.Errorf("dispositivos en memoria = %d, la quarantine y la liberación no deben crear historial por encima del límite", got)
+    }
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "log"
+    "maps"
+    "net/http"
+    "slices"
+    "strings"
+    "unicode/utf8"
+)
+
+// Claves de metadatos como máximo por dispositivo, tanto reportadas en las
+// lecturas como fijadas por el operador
+const DEVICE_METADATA_MAX_KEYS = 32
+
+// Longitud máxima de cada valor; los más largos se recortan
+const DEVICE_METADATA_MAX_VALUE_LENGTH = 1024
+
+// Etiquetas libres de un dispositivo (site, location, owner...) que
+// acompañan a sus alertas
+type DeviceMetadata struct {
+    DeviceID string            `json:"device_id"`
+    Metadata map[string]string `json:"metadata"`
+}
+
+// Recortar un valor a DEVICE_METADATA_MAX_VALUE_LENGTH caracteres
+func truncateMetadataValue(value string) string {
+    if utf8.RuneCountInString(value) <= DEVICE_METADATA_MAX_VALUE_LENGTH {
+        return value
+    }
+    return string([]rune(value)[:DEVICE_METADATA_MAX_VALUE_LENGTH])
+}
+
+// Sustituir los metadatos que fija el operador para un dispositivo ya
+// conocido (false si no se conoce). Tienen prioridad sobre los que reporta
+// el propio dispositivo, que no puede sobrescribirlos.
+func (qs *QuarantineSystem) SetMetadata(deviceID string, metadata map[string]string) bool {
+    operator := make(map[string]string, len(metadata))
+    for key, value := range metadata {
+        operator[key] = truncateMetadataValue(value)
+    }
+
+    qs.mutex.Lock()
+    behavior, exists := qs.deviceBehavior[deviceID]
+    if exists {
+        behavior.OperatorMetadata = operator
+    }
+    qs.mutex.Unlock()
+
+    if !exists {
+        return false
+    }
+    log.Printf("🏷️ METADATOS: %s → %s", deviceID, formatMetadata(operator))
+    return true
+}
+
+// Incorporar los metadatos que trae la lectura: las claves reportadas se
+// actualizan y el resto se conserva. Las claves que fijó el operador y las
+// nuevas por encima de DEVICE_METADATA_MAX_KEYS se ignoran.
+func (qs *QuarantineSystem) RecordMetadata(data *SensorData) {
+    if len(data.Metadata) == 0 {
+        return
+    }
+
+    qs.mutex.Lock()
+    defer qs.mutex.Unlock()
+
+    behavior := qs.behaviorLocked(data.DeviceID)
+    if behavior.Metadata == nil {
+        behavior.Metadata = make(map[string]string, len(data.Metadata))
+    }
+    for key, value := range data.Metadata {
+        if _, operator := behavior.OperatorMetadata[key]; operator {
+            continue
+        }
+        if _, exists := behavior.Metadata[key]; !exists && len(behavior.Metadata) >= DEVICE_METADATA_MAX_KEYS {
+            continue
+        }
+        behavior.Metadata[key] = truncateMetadataValue(value)
+    }
+}
+
+// Metadatos efectivos: los reportados con los del operador encima (nil si
+// no hay ninguno)
+func (behavior *DeviceBehavior) effectiveMetadata() map[string]string {
+    if len(behavior.Metadata) == 0 && len(behavior.OperatorMetadata) == 0 {
+        return nil
+    }
+    metadata := maps.Clone(behavior.Metadata)
+    if metadata == nil {
+        metadata = make(map[string]string, len(behavior.OperatorMetadata))
+    }
+    maps.Copy(metadata, behavior.OperatorMetadata)
+    return metadata
+}
+
+// Metadatos de un dispositivo (false si no se conoce)
+func (qs *QuarantineSystem) Metadata(deviceID string) (map[string]string, bool) {
+    qs.mutex.RLock()
+    defer qs.mutex.RUnlock()
+
+    behavior, exists := qs.deviceBehavior[deviceID]
+    if !exists {
+        return nil, false
+    }
+    return behavior.effectiveMetadata(), true
+}
+
+// Adjuntar a cada anomalía los metadatos de su dispositivo
+func (qs *QuarantineSystem) AttachMetadata(anomalies []Anomaly) {
+    qs.mutex.RLock()
+    defer qs.mutex.RUnlock()
+
+    for i := range anomalies {
+        if behavior := qs.deviceBehavior[anomalies[i].DeviceID]; behavior != nil {
+            anomalies[i].DeviceMetadata = behavior.effectiveMetadata()
+        }
+    }
+}
+
+// Formatear los metadatos como "owner=ops, site=madrid" (ordenados por clave)
+func formatMetadata(metadata map[string]string) string {
+    parts := make([]string, 0, len(metadata))
+    for _, key := range slices.Sorted(maps.Keys(metadata)) {
+        parts = append(parts, fmt.Sprintf("%s=%s", key, metadata[key]))
+    }
+    return strings.Join(parts, ", ")
+}
+
+// GET /devices/{id}/metadata: etiquetas del dispositivo
+func handleGetDeviceMetadata(w http.ResponseWriter, r *http.Request) {
+    deviceID := r.PathValue("id")
+    metadata, found := quarantineSystem.Metadata(deviceID)
+    if !found {
+        writeError(w, http.StatusNotFound, "dispositivo no encontrado")
+        return
+    }
+    if metadata == nil {
+        metadata = map[string]string{}
+    }
+    writeJSON(w, http.StatusOK, DeviceMetadata{DeviceID: deviceID, Metadata: metadata})
+}
+
+// PUT /devices/{id}/metadata: {"metadata": {"site": "madrid", "owner": "facilities"}}.
+// Sustituye las etiquetas del operador de un dispositivo que ya ha reportado;
+// responde con los metadatos efectivos.
+func handleSetDeviceMetadata(w http.ResponseWriter, r *http.Request) {
+    var request struct {
+        Metadata map[string]string `json:"metadata"`
+    }
+    if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+        writeError(w, http.StatusBadRequest, "cuerpo inválido: "+err.Error())
+        return
+    }
+    if request.Metadata == nil {
+        writeError(w, http.StatusBadRequest, "metadata es obligatorio")
+        return
+    }
+    if len(request.Metadata) > DEVICE_METADATA_MAX_KEYS {
+        writeError(w, http.StatusBadRequest, fmt.Sprintf("como máximo %d claves de metadatos", DEVICE_METADATA_MAX_KEYS))
+        return
+    }
+
+    deviceID := r.PathValue("id")
+    if !quarantineSystem.SetMetadata(deviceID, request.Metadata) {
+        writeError(w, http.StatusNotFound, "dispositivo no encontrado")
+        return
+    }
+    metadata, _ := quarantineSystem.Metadata(deviceID)
+    writeJSON(w, http.StatusOK, DeviceMetadata{DeviceID: deviceID, Metadata: metadata})
+}
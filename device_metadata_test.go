@@ -0,0 +1,134 @@
+package main
+
+import (
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "unicode/utf8"
+)
+
+func TestDecodeSensorData_Metadata(t *testing.T) {
+    tests := []struct {
+        name    string
+        payload string
+        want    map[string]string
+    }{
+        {name: "con metadatos", payload: `{"device_id":"sensor-1","temperature":21,"metadata":{"site":"madrid","owner":"ops"}}`, want: map[string]string{"site": "madrid", "owner": "ops"}},
+        {name: "sin metadatos", payload: `{"device_id":"sensor-1","temperature":21}`},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            data, _, err := decodeSensorData([]byte(tt.payload), false)
+            if err != nil {
+                t.Fatalf("decodeSensorData: %v", err)
+            }
+            if formatMetadata(data.Metadata) != formatMetadata(tt.want) {
+                t.Errorf("metadatos = %v, se esperaban %v", data.Metadata, tt.want)
+            }
+        })
+    }
+}
+
+func TestDeviceMetadata(t *testing.T) {
+    long := strings.Repeat("ñ", DEVICE_METADATA_MAX_VALUE_LENGTH+10)
+
+    tests := []struct {
+        name     string
+        operator map[string]string
+        reported []map[string]string
+        want     map[string]string
+    }{
+        {
+            name:     "el dispositivo no sobrescribe al operador",
+            operator: map[string]string{"site": "madrid"},
+            reported: []map[string]string{{"site": "falso", "rack": "3"}},
+            want:     map[string]string{"site": "madrid", "rack": "3"},
+        },
+        {
+            name:     "lecturas sucesivas conservan las claves",
+            reported: []map[string]string{{"site": "madrid"}, {"rack": "3"}},
+            want:     map[string]string{"site": "madrid", "rack": "3"},
+        },
+        {
+            name:     "valores recortados",
+            reported: []map[string]string{{"note": long}},
+            want:     map[string]string{"note": strings.Repeat("ñ", DEVICE_METADATA_MAX_VALUE_LENGTH)},
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            setupTestHub(t)
+            quarantineSystem.RecordMessageOutcome("sensor-1", 0)
+            if tt.operator != nil && !quarantineSystem.SetMetadata("sensor-1", tt.operator) {
+                t.Fatal("SetMetadata de un dispositivo conocido devolvió false")
+            }
+            for _, metadata := range tt.reported {
+                quarantineSystem.RecordMetadata(&SensorData{DeviceID: "sensor-1", Metadata: metadata})
+            }
+
+            got, _ := quarantineSystem.Metadata("sensor-1")
+            if formatMetadata(got) != formatMetadata(tt.want) {
+                t.Errorf("metadatos = %v, se esperaban %v", got, tt.want)
+            }
+            for key, value := range got {
+                if utf8.RuneCountInString(value) > DEVICE_METADATA_MAX_VALUE_LENGTH {
+                    t.Errorf("valor de %s con %d caracteres", key, utf8.RuneCountInString(value))
+                }
+            }
+        })
+    }
+}
+
+func TestHandleSetDeviceMetadata(t *testing.T) {
+    tests := []struct {
+        name       string
+        deviceID   string
+        body       string
+        wantStatus int
+    }{
+        {name: "dispositivo conocido", deviceID: "sensor-1", body: `{"metadata":{"site":"madrid"}}`, wantStatus: http.StatusOK},
+        {name: "dispositivo desconocido", deviceID: "sensor-9", body: `{"metadata":{"site":"madrid"}}`, wantStatus: http.StatusNotFound},
+        {name: "sin metadata", deviceID: "sensor-1", body: `{}`, wantStatus: http.StatusBadRequest},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            setupTestHub(t)
+            quarantineSystem.RecordMessageOutcome("sensor-1", 0)
+
+            request := httptest.NewRequest(http.MethodPut, "/devices/"+tt.deviceID+"/metadata", strings.NewReader(tt.body))
+            request.SetPathValue("id", tt.deviceID)
+            recorder := httptest.NewRecorder()
+            handleSetDeviceMetadata(recorder, request)
+
+            if recorder.Code != tt.wantStatus {
+                t.Errorf("estado HTTP = %d, se esperaba %d", recorder.Code, tt.wantStatus)
+            }
+            if _, known := quarantineSystem.Metadata("sensor-9"); known {
+                t.Error("la API no debe dar de alta dispositivos desconocidos")
+            }
+        })
+    }
+}
+
+func TestToECSDocument_MetadataLabels(t *testing.T) {
+    anomaly := Anomaly{
+        DeviceID:       "sensor-1",
+        Type:           AnomalyTemperature,
+        DeviceMetadata: map[string]string{"site": "madrid", "inventada": "x"},
+    }
+
+    labels := toECSDocument(&anomaly).Labels
+    if labels["device_site"] != "madrid" {
+        t.Errorf("device_site = %q, se esperaba madrid", labels["device_site"])
+    }
+    if _, exists := labels["device_inventada"]; exists {
+        t.Error("una clave libre no debe crear su propia etiqueta")
+    }
+    if labels["device_metadata"] != "inventada=x, site=madrid" {
+        t.Errorf("device_metadata = %q", labels["device_metadata"])
+    }
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+    "context"
+    "log"
+    "time"
+)
+
+// Intervalo entre purgas de dispositivos inactivos
+const DEVICE_PURGE_INTERVAL = 1 * time.Hour
+
+// Tiempo sin reportar tras el cual un dispositivo se elimina (0 = no purgar)
+var deviceStaleTTL = 72 * time.Hour
+
+// Eliminar todo el estado de un dispositivo: comportamiento, quarantine,
+// actualización de firmware y rate limit. Devuelve false si no se conocía.
+func (qs *QuarantineSystem) DeleteDevice(deviceID string) bool {
+    qs.mutex.Lock()
+    deleted := qs.deleteDeviceLocked(deviceID)
+    qs.mutex.Unlock()
+
+    qs.rateLimiter.Reset(deviceID)
+    qs.writeRepository(context.Background(), "DeleteDevice", func(ctx context.Context, repository DeviceRepository) error {
+        return repository.DeleteDevice(ctx, deviceID)
+    })
+    return deleted
+}
+
+// Eliminar el estado de un dispositivo (llamar con el lock tomado)
+func (qs *QuarantineSystem) deleteDeviceLocked(deviceID string) bool {
+    _, known := qs.deviceBehavior[deviceID]
+    if _, quarantined := qs.quarantinedDevices[deviceID]; quarantined {
+        known = true
+    }
+
+    delete(qs.deviceBehavior, deviceID)
+    qs.deviceRecency.remove(deviceID)
+    delete(qs.quarantinedDevices, deviceID)
+    delete(qs.updatingDevices, deviceID)
+    return known
+}
+
+// Última actividad del dispositivo (uno recién creado aún no tiene LastSeen)
+func (behavior *DeviceBehavior) lastActivity() time.Time {
+    if behavior.LastSeen.Before(behavior.FirstSeen) {
+        return behavior.FirstSeen
+    }
+    return behavior.LastSeen
+}
+
+// Eliminar los dispositivos que no reportan desde hace más de ttl
+func (qs *QuarantineSystem) PurgeStaleDevices(ttl time.Duration) int {
+    if ttl <= 0 {
+        return 0
+    }
+
+    qs.mutex.Lock()
+    cutoff := qs.clock.Now().Add(-ttl)
+    purged := make([]string, 0)
+    for deviceID, behavior := range qs.deviceBehavior {
+        if behavior.lastActivity().Before(cutoff) {
+            qs.deleteDeviceLocked(deviceID)
+            purged = append(purged, deviceID)
+        }
+    }
+    qs.mutex.Unlock()
+
+    for _, deviceID := range purged {
+        qs.rateLimiter.Reset(deviceID)
+        qs.writeRepository(context.Background(), "DeleteDevice", func(ctx context.Context, repository DeviceRepository) error {
+            return repository.DeleteDevice(ctx, deviceID)
+        })
+        log.Printf("🧹 PURGA: Dispositivo %s eliminado tras %v sin reportar", deviceID, ttl)
+    }
+    return len(purged)
+}
+
+// Purga periódica de dispositivos inactivos hasta que se cancele el contexto.
+// El canal devuelto se cierra cuando la goroutine termina.
+func startDevicePurge(ctx context.Context, qs *QuarantineSystem, interval time.Duration) <-chan struct{} {
+    done := make(chan struct{})
+
+    go func() {
+        defer close(done)
+        ticker := time.NewTicker(interval)
+        defer ticker.Stop()
+
+        for {
+            select {
+            case <-ctx.Done():
+                return
+            case <-ticker.C:
+                qs.PurgeStaleDevices(deviceStaleTTL)
+            }
+        }
+    }()
+
+    return done
+}
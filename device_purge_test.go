@@ -0,0 +1,99 @@
+package main
+
+import (
+    "context"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+    "time"
+)
+
+// Esperar a que el repositorio reciba los SaveDevice pendientes
+func flushDeviceSaves(t *testing.T) {
+    t.Helper()
+    ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+    defer cancel()
+    if err := quarantineSystem.FlushDeviceSaves(ctx); err != nil {
+        t.Fatalf("FlushDeviceSaves: %v", err)
+    }
+}
+
+func TestPurgeStaleDevices_Repository(t *testing.T) {
+    clock := setupTestHub(t)
+    repository := newFakeDeviceRepository()
+    quarantineSystem.SetRepository(repository)
+
+    quarantineSystem.RecordMessageOutcome("sensor-inactivo", 0)
+    quarantineSystem.QuarantineDeviceWithReason("sensor-inactivo", QuarantineReasonInvalidData, "prueba")
+    clock.Advance(deviceStaleTTL - time.Hour)
+    quarantineSystem.RecordMessageOutcome("sensor-reciente", 0)
+    flushDeviceSaves(t)
+    clock.Advance(2 * time.Hour)
+
+    if purged := quarantineSystem.PurgeStaleDevices(deviceStaleTTL); purged != 1 {
+        t.Fatalf("dispositivos purgados = %d, se esperaba 1", purged)
+    }
+
+    tests := []struct {
+        deviceID        string
+        wantKnown       bool
+        wantStored      bool
+        wantQuarantined bool
+    }{
+        {deviceID: "sensor-inactivo"},
+        {deviceID: "sensor-reciente", wantKnown: true, wantStored: true},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.deviceID, func(t *testing.T) {
+            if _, known := quarantineSystem.Metadata(tt.deviceID); known != tt.wantKnown {
+                t.Errorf("conocido localmente = %v, se esperaba %v", known, tt.wantKnown)
+            }
+            repository.mutex.Lock()
+            _, stored := repository.devices[tt.deviceID]
+            _, quarantined := repository.quarantines[tt.deviceID]
+            repository.mutex.Unlock()
+            if stored != tt.wantStored {
+                t.Errorf("en el repositorio = %v, se esperaba %v", stored, tt.wantStored)
+            }
+            if quarantined != tt.wantQuarantined {
+                t.Errorf("quarantine en el repositorio = %v, se esperaba %v", quarantined, tt.wantQuarantined)
+            }
+        })
+    }
+}
+
+func TestHandleDeleteDevice(t *testing.T) {
+    tests := []struct {
+        name       string
+        deviceID   string
+        wantStatus int
+    }{
+        {name: "dispositivo conocido", deviceID: "sensor-1", wantStatus: http.StatusNoContent},
+        {name: "dispositivo desconocido", deviceID: "sensor-9", wantStatus: http.StatusNotFound},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            setupTestHub(t)
+            withAPIToken(t)
+            repository := newFakeDeviceRepository()
+            quarantineSystem.SetRepository(repository)
+            quarantineSystem.RecordMessageOutcome("sensor-1", 0)
+            flushDeviceSaves(t)
+
+            recorder := httptest.NewRecorder()
+            newAPIRouter().ServeHTTP(recorder, newAuthorizedRequest(http.MethodDelete, "/devices/"+tt.deviceID, nil))
+
+            if recorder.Code != tt.wantStatus {
+                t.Fatalf("estado HTTP = %d, se esperaba %d", recorder.Code, tt.wantStatus)
+            }
+            repository.mutex.Lock()
+            _, stored := repository.devices["sensor-1"]
+            repository.mutex.Unlock()
+            if stored != (tt.deviceID != "sensor-1") {
+                t.Errorf("sensor-1 en el repositorio = %v tras borrar %s", stored, tt.deviceID)
+            }
+        })
+    }
+}
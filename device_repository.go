@@ -0,0 +1,198 @@
+package main
+
+import (
+    "context"
+    "log"
+    "sync"
+    "time"
+)
+
+// Timeout de cada operación contra el repositorio compartido
+const DEVICE_REPOSITORY_TIMEOUT = 2 * time.Second
+
+// Reintentos de las escrituras en el repositorio compartido, con backoff
+// exponencial (repositoryRetryBackoff, 2×, 4×...)
+var (
+    repositoryMaxRetries   = 2
+    repositoryRetryBackoff = 100 * time.Millisecond
+)
+
+// Resumen de un dispositivo guardado en el repositorio compartido
+type DeviceRecord struct {
+    DeviceID         string
+    FirstSeen        time.Time
+    LastSeen         time.Time
+    MessageCount     int
+    TotalAnomalies   int
+    RejectedMessages int
+}
+
+// Estado de dispositivos compartido entre varias instancias del hub detrás
+// del mismo broker. El estado local sigue siendo la referencia para el
+// análisis de comportamiento; el repositorio propaga las quarantines.
+type DeviceRepository interface {
+    SaveDevice(ctx context.Context, record DeviceRecord) error
+    SaveQuarantine(ctx context.Context, deviceID string, entry QuarantineEntry) error
+    IsDeviceQuarantined(ctx context.Context, deviceID string) (bool, error)
+    ReleaseQuarantine(ctx context.Context, deviceID string) error
+    DeleteDevice(ctx context.Context, deviceID string) error
+    CleanExpiredQuarantines(ctx context.Context) error
+}
+
+// Configurar el repositorio compartido (nil = solo estado local)
+func (qs *QuarantineSystem) SetRepository(repository DeviceRepository) {
+    qs.mutex.Lock()
+    defer qs.mutex.Unlock()
+
+    qs.repository = repository
+}
+
+// Repositorio compartido configurado (nil si no hay)
+func (qs *QuarantineSystem) currentRepository() DeviceRepository {
+    qs.mutex.RLock()
+    defer qs.mutex.RUnlock()
+
+    return qs.repository
+}
+
+// Una llamada al repositorio con su propio timeout, dentro del plazo de ctx
+func callRepository(ctx context.Context, repository DeviceRepository, fn func(ctx context.Context, repository DeviceRepository) error) error {
+    ctx, cancel := context.WithTimeout(ctx, DEVICE_REPOSITORY_TIMEOUT)
+    defer cancel()
+    return fn(ctx, repository)
+}
+
+// Ejecutar una operación contra el repositorio compartido, si hay uno.
+// Los errores se registran sin interrumpir el procesamiento local.
+func (qs *QuarantineSystem) withRepository(ctx context.Context, operation string, fn func(ctx context.Context, repository DeviceRepository) error) {
+    repository := qs.currentRepository()
+    if repository == nil {
+        return
+    }
+
+    if err := callRepository(ctx, repository, fn); err != nil {
+        log.Printf("⚠️ Repositorio compartido: error en %s: %v", operation, err)
+    }
+}
+
+// Como withRepository, pero reintentando con backoff: para escrituras cuyo
+// fallo puntual no debe perder el estado (quarantines y liberaciones). Si todos los intentos fallan se
+// registra y se continúa, sin invalidar el procesamiento ya hecho.
+func (qs *QuarantineSystem) writeRepository(ctx context.Context, operation string, fn func(ctx context.Context, repository DeviceRepository) error) {
+    repository := qs.currentRepository()
+    if repository == nil {
+        return
+    }
+
+    backoff := repositoryRetryBackoff
+    for attempt := 0; ; attempt++ {
+        err := callRepository(ctx, repository, fn)
+        if err == nil {
+            if attempt > 0 {
+                log.Printf("✅ Repositorio compartido: %s completado tras %d reintentos", operation, attempt)
+            }
+            return
+        }
+        if attempt >= repositoryMaxRetries || ctx.Err() != nil {
+            log.Printf("⚠️ Repositorio compartido: error en %s tras %d intentos, se continúa con el estado local: %v", operation, attempt+1, err)
+            metrics.Inc("iot_repository_write_failures", "operation", operation)
+            return
+        }
+        select {
+        case <-time.After(backoff):
+        case <-ctx.Done():
+        }
+        backoff *= 2
+    }
+}
+
+// Resúmenes de dispositivo pendientes de guardar. Se escriben tras cada
+// mensaje, así que no se reintentan ni bloquean el procesamiento: un único
+// goroutine guarda el último resumen de cada dispositivo y, si falla, el
+// siguiente mensaje del dispositivo lo vuelve a intentar.
+type deviceSaveQueue struct {
+    mutex   sync.Mutex
+    pending map[string]DeviceRecord
+    running bool
+    done    sync.WaitGroup
+}
+
+// Encolar el resumen del dispositivo (sustituye al pendiente, si lo hay)
+func (qs *QuarantineSystem) saveDeviceAsync(record DeviceRecord) {
+    if qs.currentRepository() == nil {
+        return
+    }
+
+    queue := &qs.deviceSaves
+    queue.mutex.Lock()
+    defer queue.mutex.Unlock()
+
+    if queue.pending == nil {
+        queue.pending = make(map[string]DeviceRecord)
+    }
+    queue.pending[record.DeviceID] = record
+    if !queue.running {
+        queue.running = true
+        queue.done.Add(1)
+        go qs.drainDeviceSaves()
+    }
+}
+
+// Guardar los resúmenes pendientes hasta vaciar la cola
+func (qs *QuarantineSystem) drainDeviceSaves() {
+    queue := &qs.deviceSaves
+    defer queue.done.Done()
+
+    for {
+        queue.mutex.Lock()
+        pending := queue.pending
+        queue.pending = nil
+        if len(pending) == 0 {
+            queue.running = false
+            queue.mutex.Unlock()
+            return
+        }
+        queue.mutex.Unlock()
+
+        repository := qs.currentRepository()
+        if repository == nil {
+            continue
+        }
+        for _, record := range pending {
+            err := callRepository(context.Background(), repository, func(ctx context.Context, repository DeviceRepository) error {
+                return repository.SaveDevice(ctx, record)
+            })
+            if err != nil {
+                log.Printf("⚠️ Repositorio compartido: error en SaveDevice de %s: %v", record.DeviceID, err)
+                metrics.Inc("iot_repository_write_failures", "operation", "SaveDevice")
+            }
+        }
+    }
+}
+
+// Esperar a que se guarden los resúmenes pendientes (p. ej. al apagar)
+func (qs *QuarantineSystem) FlushDeviceSaves(ctx context.Context) error {
+    done := make(chan struct{})
+    go func() {
+        qs.deviceSaves.done.Wait()
+        close(done)
+    }()
+
+    select {
+    case <-done:
+        return nil
+    case <-ctx.Done():
+        return ctx.Err()
+    }
+}
+
+// Verificar si otra instancia del hub puso el dispositivo en quarantine
+func (qs *QuarantineSystem) isQuarantinedElsewhere(deviceID string) bool {
+    quarantined := false
+    qs.withRepository(context.Background(), "IsDeviceQuarantined", func(ctx context.Context, repository DeviceRepository) error {
+        var err error
+        quarantined, err = repository.IsDeviceQuarantined(ctx, deviceID)
+        return err
+    })
+    return quarantined
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+    "context"
+    "errors"
+    "sync"
+    "testing"
+    "time"
+)
+
+// Repositorio compartido en memoria, con fallos inyectables
+type fakeDeviceRepository struct {
+    mutex       sync.Mutex
+    devices     map[string]DeviceRecord
+    quarantines map[string]QuarantineEntry
+    calls       map[string]int
+    // Fallos pendientes por operación
+    failures map[string]int
+}
+
+func newFakeDeviceRepository() *fakeDeviceRepository {
+    return &fakeDeviceRepository{
+        devices:     make(map[string]DeviceRecord),
+        quarantines: make(map[string]QuarantineEntry),
+        calls:       make(map[string]int),
+        failures:    make(map[string]int),
+    }
+}
+
+// Registrar la llamada y devolver el fallo inyectado, si queda alguno
+func (fr *fakeDeviceRepository) call(operation string) error {
+    fr.calls[operation]++
+    if fr.failures[operation] > 0 {
+        fr.failures[operation]--
+        return errors.New("repositorio no disponible")
+    }
+    return nil
+}
+
+func (fr *fakeDeviceRepository) Calls(operation string) int {
+    fr.mutex.Lock()
+    defer fr.mutex.Unlock()
+
+    return fr.calls[operation]
+}
+
+func (fr *fakeDeviceRepository) FailNext(operation string, times int) {
+    fr.mutex.Lock()
+    defer fr.mutex.Unlock()
+
+    fr.failures[operation] = times
+}
+
+func (fr *fakeDeviceRepository) SaveDevice(ctx context.Context, record DeviceRecord) error {
+    fr.mutex.Lock()
+    defer fr.mutex.Unlock()
+
+    if err := fr.call("SaveDevice"); err != nil {
+        return err
+    }
+    fr.devices[record.DeviceID] = record
+    return nil
+}
+
+func (fr *fakeDeviceRepository) SaveQuarantine(ctx context.Context, deviceID string, entry QuarantineEntry) error {
+    fr.mutex.Lock()
+    defer fr.mutex.Unlock()
+
+    if err := fr.call("SaveQuarantine"); err != nil {
+        return err
+    }
+    fr.quarantines[deviceID] = entry
+    return nil
+}
+
+func (fr *fakeDeviceRepository) IsDeviceQuarantined(ctx context.Context, deviceID string) (bool, error) {
+    fr.mutex.Lock()
+    defer fr.mutex.Unlock()
+
+    if err := fr.call("IsDeviceQuarantined"); err != nil {
+        return false, err
+    }
+    _, exists := fr.quarantines[deviceID]
+    return exists, nil
+}
+
+func (fr *fakeDeviceRepository) ReleaseQuarantine(ctx context.Context, deviceID string) error {
+    fr.mutex.Lock()
+    defer fr.mutex.Unlock()
+
+    if err := fr.call("ReleaseQuarantine"); err != nil {
+        return err
+    }
+    delete(fr.quarantines, deviceID)
+    return nil
+}
+
+func (fr *fakeDeviceRepository) DeleteDevice(ctx context.Context, deviceID string) error {
+    fr.mutex.Lock()
+    defer fr.mutex.Unlock()
+
+    if err := fr.call("DeleteDevice"); err != nil {
+        return err
+    }
+    delete(fr.devices, deviceID)
+    delete(fr.quarantines, deviceID)
+    return nil
+}
+
+func (fr *fakeDeviceRepository) CleanExpiredQuarantines(ctx context.Context) error {
+    return nil
+}
+
+func TestWriteRepository_Retries(t *testing.T) {
+    tests := []struct {
+        name         string
+        failures     int
+        wantCalls    int
+        wantFailures float64
+    }{
+        {name: "sin fallos", failures: 0, wantCalls: 1},
+        {name: "un fallo puntual", failures: 1, wantCalls: 2},
+        {name: "falla siempre", failures: 10, wantCalls: repositoryMaxRetries + 1, wantFailures: 1},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            setupTestHub(t)
+            savedBackoff := repositoryRetryBackoff
+            repositoryRetryBackoff = time.Millisecond
+            t.Cleanup(func() { repositoryRetryBackoff = savedBackoff })
+
+            repository := newFakeDeviceRepository()
+            repository.FailNext("SaveQuarantine", tt.failures)
+            quarantineSystem.SetRepository(repository)
+            before := metrics.Value("iot_repository_write_failures", "operation", "SaveQuarantine")
+
+            quarantineSystem.writeRepository(context.Background(), "SaveQuarantine", func(ctx context.Context, repository DeviceRepository) error {
+                return repository.SaveQuarantine(ctx, "sensor-1", QuarantineEntry{})
+            })
+
+            if got := repository.Calls("SaveQuarantine"); got != tt.wantCalls {
+                t.Errorf("intentos = %d, se esperaban %d", got, tt.wantCalls)
+            }
+            if got := metrics.Value("iot_repository_write_failures", "operation", "SaveQuarantine") - before; got != tt.wantFailures {
+                t.Errorf("fallos contabilizados = %v, se esperaban %v", got, tt.wantFailures)
+            }
+        })
+    }
+}
+
+// Repositorio cuyo SaveDevice no responde hasta que se libera
+type blockingDeviceRepository struct {
+    *fakeDeviceRepository
+    release chan struct{}
+}
+
+func (br *blockingDeviceRepository) SaveDevice(ctx context.Context, record DeviceRecord) error {
+    <-br.release
+    return br.fakeDeviceRepository.SaveDevice(ctx, record)
+}
+
+func TestRecordMessageOutcome_SavesDeviceInBackground(t *testing.T) {
+    tests := []struct {
+        name         string
+        messages     int
+        failures     int
+        maxCalls     int
+        wantSaved    bool
+        wantFailures float64
+    }{
+        // Los resúmenes del mismo dispositivo se agrupan
+        {name: "guardado", messages: 3, maxCalls: 2, wantSaved: true},
+        {name: "fallo sin reintentos", messages: 1, failures: 1, maxCalls: 1, wantFailures: 1},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            setupTestHub(t)
+            repository := &blockingDeviceRepository{fakeDeviceRepository: newFakeDeviceRepository(), release: make(chan struct{})}
+            repository.FailNext("SaveDevice", tt.failures)
+            quarantineSystem.SetRepository(repository)
+            before := metrics.Value("iot_repository_write_failures", "operation", "SaveDevice")
+
+            // El procesamiento del mensaje no espera al repositorio
+            returned := make(chan struct{})
+            go func() {
+                for i := 0; i < tt.messages; i++ {
+                    quarantineSystem.RecordMessageOutcome("sensor-1", 0)
+                }
+                close(returned)
+            }()
+            select {
+            case <-returned:
+            case <-time.After(time.Second):
+                t.Fatal("RecordMessageOutcome bloqueó esperando al repositorio")
+            }
+
+            close(repository.release)
+            ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+            defer cancel()
+            if err := quarantineSystem.FlushDeviceSaves(ctx); err != nil {
+                t.Fatalf("FlushDeviceSaves: %v", err)
+            }
+
+            if got := repository.Calls("SaveDevice"); got > tt.maxCalls {
+                t.Errorf("escrituras = %d, se esperaban como mucho %d", got, tt.maxCalls)
+            }
+            repository.mutex.Lock()
+            _, saved := repository.devices["sensor-1"]
+            repository.mutex.Unlock()
+            if saved != tt.wantSaved {
+                t.Errorf("guardado = %v, se esperaba %v", saved, tt.wantSaved)
+            }
+            if got := metrics.Value("iot_repository_write_failures", "operation", "SaveDevice") - before; got != tt.wantFailures {
+                t.Errorf("fallos contabilizados = %v, se esperaban %v", got, tt.wantFailures)
+            }
+        })
+    }
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+    "fmt"
+    "log"
+)
+
+// Canales de notificación fijados en tiempo de ejecución para un dispositivo
+// (p. ej. mandar las alertas de un sensor inestable a un canal de baja
+// prioridad). Tiene prioridad sobre el enrutado por tipo/severidad.
+type DeviceRouting struct {
+    DeviceID string   `json:"device_id"`
+    Channels []string `json:"channels"`
+}
+
+// Fijar los canales de un dispositivo. Puede hacerse antes de que el
+// dispositivo reporte: no crea estado de comportamiento. El número de
+// dispositivos con enrutado propio está acotado como el de dispositivos.
+func (qs *QuarantineSystem) SetNotificationChannels(deviceID string, channels []string) error {
+    qs.mutex.Lock()
+    _, exists := qs.notificationRouting[deviceID]
+    if !exists && maxTrackedDevices > 0 && len(qs.notificationRouting) >= maxTrackedDevices {
+        qs.mutex.Unlock()
+        return fmt.Errorf("%s: %w", deviceID, ErrDeviceLimit)
+    }
+    qs.notificationRouting[deviceID] = append([]string(nil), channels...)
+    qs.mutex.Unlock()
+
+    log.Printf("🔔 NOTIFICACIONES: Alertas de %s enrutadas a %v", deviceID, channels)
+    return nil
+}
+
+// Quitar el enrutado propio de un dispositivo (false si no tenía)
+func (qs *QuarantineSystem) ClearNotificationChannels(deviceID string) bool {
+    qs.mutex.Lock()
+    defer qs.mutex.Unlock()
+
+    if _, exists := qs.notificationRouting[deviceID]; !exists {
+        return false
+    }
+    delete(qs.notificationRouting, deviceID)
+    return true
+}
+
+// Canales fijados para un dispositivo (false si usa el enrutado general)
+func (qs *QuarantineSystem) NotificationChannels(deviceID string) ([]string, bool) {
+    qs.mutex.RLock()
+    defer qs.mutex.RUnlock()
+
+    channels, exists := qs.notificationRouting[deviceID]
+    if !exists {
+        return nil, false
+    }
+    return append([]string(nil), channels...), true
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+    "errors"
+    "testing"
+    "time"
+)
+
+func TestNotificationChannels(t *testing.T) {
+    tests := []struct {
+        name    string
+        prepare func(t *testing.T, clock *FakeClock)
+        want    bool
+    }{
+        {name: "sin enrutado propio", prepare: func(t *testing.T, clock *FakeClock) {}, want: false},
+        {
+            name: "dispositivo que aún no ha reportado",
+            prepare: func(t *testing.T, clock *FakeClock) {
+                quarantineSystem.SetNotificationChannels("sensor-1", []string{"ops"})
+            },
+            want: true,
+        },
+        {
+            name: "sobrevive a la purga de dispositivos inactivos",
+            prepare: func(t *testing.T, clock *FakeClock) {
+                quarantineSystem.RecordMessageOutcome("sensor-1", 0)
+                quarantineSystem.SetNotificationChannels("sensor-1", []string{"ops"})
+                clock.Advance(deviceStaleTTL + time.Hour)
+                if purged := quarantineSystem.PurgeStaleDevices(deviceStaleTTL); purged != 1 {
+                    t.Fatalf("dispositivos purgados = %d, se esperaba 1", purged)
+                }
+            },
+            want: true,
+        },
+        {
+            name: "sobrevive al borrado del dispositivo",
+            prepare: func(t *testing.T, clock *FakeClock) {
+                quarantineSystem.SetNotificationChannels("sensor-1", []string{"ops"})
+                quarantineSystem.DeleteDevice("sensor-1")
+            },
+            want: true,
+        },
+        {
+            name: "quitado explícitamente",
+            prepare: func(t *testing.T, clock *FakeClock) {
+                quarantineSystem.SetNotificationChannels("sensor-1", []string{"ops"})
+                quarantineSystem.ClearNotificationChannels("sensor-1")
+            },
+            want: false,
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            clock := setupTestHub(t)
+            tt.prepare(t, clock)
+
+            channels, found := quarantineSystem.NotificationChannels("sensor-1")
+            if found != tt.want {
+                t.Fatalf("NotificationChannels() found = %v, se esperaba %v", found, tt.want)
+            }
+            if found && (len(channels) != 1 || channels[0] != "ops") {
+                t.Errorf("canales = %v, se esperaba [ops]", channels)
+            }
+        })
+    }
+}
+
+func TestSetNotificationChannels_DoesNotTrackDevice(t *testing.T) {
+    setupTestHub(t)
+    savedMax := maxTrackedDevices
+    maxTrackedDevices = 2
+    t.Cleanup(func() { maxTrackedDevices = savedMax })
+
+    for _, deviceID := range []string{"sensor-1", "sensor-2"} {
+        if err := quarantineSystem.SetNotificationChannels(deviceID, []string{"ops"}); err != nil {
+            t.Fatalf("SetNotificationChannels(%s) error = %v", deviceID, err)
+        }
+    }
+    if got := len(quarantineSystem.deviceBehavior); got != 0 {
+        t.Errorf("dispositivos seguidos = %d, el enrutado no debe crear estado", got)
+    }
+
+    // Actualizar uno existente se permite; uno nuevo supera el máximo
+    if err := quarantineSystem.SetNotificationChannels("sensor-1", []string{"security"}); err != nil {
+        t.Errorf("actualizar enrutado existente: error = %v", err)
+    }
+    if err := quarantineSystem.SetNotificationChannels("sensor-3", []string{"ops"}); !errors.Is(err, ErrDeviceLimit) {
+        t.Errorf("enrutado por encima del máximo: error = %v, se esperaba ErrDeviceLimit", err)
+    }
+}
@@ -0,0 +1,84 @@
+package main
+
+import "time"
+
+// Ventana de actividad reciente por dispositivo, en buckets de un minuto
+const DEVICE_ACTIVITY_WINDOW_MINUTES = 60
+
+// Actividad de un dispositivo durante un minuto (exportada para el snapshot de estado)
+type activityBucket struct {
+    Minute    int64
+    Messages  int
+    Rejected  int
+    Anomalous int
+}
+
+// Estadísticas de mensajes de un dispositivo
+type DeviceStats struct {
+    DeviceID          string    `json:"device_id"`
+    TotalMessages     int       `json:"total_messages"`
+    RejectedMessages  int       `json:"rejected_messages"`
+    AnomalousMessages int       `json:"anomalous_messages"`
+    AnomalyCount      int       `json:"anomaly_count"`
+    LastSeen          time.Time `json:"last_seen"`
+    LastHour          HourStats `json:"last_hour"`
+}
+
+// Actividad de la última hora
+type HourStats struct {
+    Messages          int `json:"messages"`
+    RejectedMessages  int `json:"rejected_messages"`
+    AnomalousMessages int `json:"anomalous_messages"`
+}
+
+// Bucket del minuto actual, reiniciándolo si pertenece a una vuelta anterior
+// (llamar con el lock tomado)
+func (behavior *DeviceBehavior) activityBucketLocked(now time.Time) *activityBucket {
+    minute := now.Unix() / 60
+    bucket := &behavior.RecentActivity[minute%DEVICE_ACTIVITY_WINDOW_MINUTES]
+    if bucket.Minute != minute {
+        *bucket = activityBucket{Minute: minute}
+    }
+    return bucket
+}
+
+// Registrar un mensaje rechazado (quarantine, rate limit, datos inválidos)
+func (qs *QuarantineSystem) RecordRejected(deviceID string) {
+    qs.mutex.Lock()
+    defer qs.mutex.Unlock()
+
+    behavior := qs.behaviorLocked(deviceID)
+    behavior.RejectedMessages++
+    behavior.activityBucketLocked(qs.clock.Now()).Rejected++
+}
+
+// Estadísticas de mensajes de un dispositivo (false si no se conoce)
+func (qs *QuarantineSystem) GetDeviceStats(deviceID string) (*DeviceStats, bool) {
+    qs.mutex.RLock()
+    defer qs.mutex.RUnlock()
+
+    behavior, exists := qs.deviceBehavior[deviceID]
+    if !exists {
+        return nil, false
+    }
+
+    stats := &DeviceStats{
+        DeviceID:          deviceID,
+        TotalMessages:     behavior.MessageCount + behavior.RejectedMessages,
+        RejectedMessages:  behavior.RejectedMessages,
+        AnomalousMessages: behavior.AnomalousMessages,
+        AnomalyCount:      behavior.TotalAnomalies,
+        LastSeen:          behavior.LastSeen,
+    }
+
+    oldest := qs.clock.Now().Unix()/60 - DEVICE_ACTIVITY_WINDOW_MINUTES
+    for _, bucket := range behavior.RecentActivity {
+        if bucket.Minute > oldest {
+            stats.LastHour.Messages += bucket.Messages + bucket.Rejected
+            stats.LastHour.RejectedMessages += bucket.Rejected
+            stats.LastHour.AnomalousMessages += bucket.Anomalous
+        }
+    }
+
+    return stats, true
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+    "context"
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+    "time"
+)
+
+func TestHandleDeviceStats(t *testing.T) {
+    tests := []struct {
+        name       string
+        deviceID   string
+        wantStatus int
+        want       DeviceStats
+    }{
+        {
+            name:       "dispositivo conocido",
+            deviceID:   "sensor-1",
+            wantStatus: http.StatusOK,
+            want: DeviceStats{
+                DeviceID:          "sensor-1",
+                TotalMessages:     3,
+                RejectedMessages:  1,
+                AnomalousMessages: 1,
+                LastHour:          HourStats{Messages: 3, RejectedMessages: 1, AnomalousMessages: 1},
+            },
+        },
+        {name: "dispositivo desconocido", deviceID: "sensor-9", wantStatus: http.StatusNotFound},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            clock := setupTestHub(t)
+            for _, temperature := range []float64{21, 95} {
+                data := SensorData{DeviceID: "sensor-1", Timestamp: clock.Now().Unix(), Temperature: temperature, Humidity: 40, BatteryLevel: 80, SignalStrength: 70}
+                if err := processSensorData(context.Background(), data, nil, nil); err != nil {
+                    t.Fatalf("processSensorData: %v", err)
+                }
+                clock.Advance(time.Minute)
+            }
+            quarantineSystem.RecordRejected("sensor-1")
+
+            recorder := httptest.NewRecorder()
+            newAPIRouter().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/devices/"+tt.deviceID+"/stats", nil))
+
+            if recorder.Code != tt.wantStatus {
+                t.Fatalf("estado HTTP = %d, se esperaba %d", recorder.Code, tt.wantStatus)
+            }
+            if tt.wantStatus != http.StatusOK {
+                return
+            }
+            var got DeviceStats
+            if err := json.NewDecoder(recorder.Body).Decode(&got); err != nil {
+                t.Fatalf("respuesta no es JSON: %v", err)
+            }
+            // LastSeen depende del procesamiento: solo se comprueba que es reciente
+            if got.LastSeen.IsZero() || got.LastSeen.After(clock.Now()) {
+                t.Errorf("last_seen = %v, se esperaba como mucho %v", got.LastSeen, clock.Now())
+            }
+            got.LastSeen = time.Time{}
+            // Una lectura a 95°C dispara varias reglas: todas cuentan
+            tt.want.AnomalyCount = len(anomalyRepository.ByDevice("sensor-1", false))
+            if got != tt.want {
+                t.Errorf("estadísticas = %+v, se esperaba %+v", got, tt.want)
+            }
+        })
+    }
+}
@@ -0,0 +1,40 @@
+package main
+
+import "strings"
+
+// Detectar IDs que reportan con varios tipos de dispositivo (suplantación o
+// un gateway que reutiliza el ID)
+var detectDeviceTypeChanges = true
+
+// Tipos distintos que se guardan por dispositivo. Los siguientes siguen
+// marcando anomalía, pero no se acumulan: un dispositivo que reporta un tipo
+// distinto en cada mensaje no puede hacer crecer la memoria sin límite.
+const MAX_DEVICE_TYPES_TRACKED = 8
+
+// Registrar el tipo reportado y marcar anomalía si difiere del asignado, que
+// es el primero que reportó el dispositivo (llamar con el lock tomado)
+func (qs *QuarantineSystem) detectDeviceTypeChangeLocked(data *SensorData, behavior *DeviceBehavior) []Anomaly {
+    if data.DeviceType == "" {
+        return nil
+    }
+
+    known := false
+    for _, deviceType := range behavior.DeviceTypes {
+        if deviceType == data.DeviceType {
+            known = true
+            break
+        }
+    }
+    if !known && len(behavior.DeviceTypes) < MAX_DEVICE_TYPES_TRACKED {
+        behavior.DeviceTypes = append(behavior.DeviceTypes, data.DeviceType)
+    }
+
+    assigned := behavior.DeviceTypes[0]
+    if !detectDeviceTypeChanges || data.DeviceType == assigned {
+        return nil
+    }
+
+    anomaly := newAnomaly(data, AnomalyDeviceTypeMismatch, float64(len(behavior.DeviceTypes)), "reporta como %q pero su tipo asignado es %q (tipos vistos: %s)", data.DeviceType, assigned, strings.Join(behavior.DeviceTypes, ", "))
+    anomaly.Severity = SEVERITY_HIGH
+    return []Anomaly{anomaly}
+}
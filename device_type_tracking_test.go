@@ -0,0 +1,47 @@
+package main
+
+import (
+    "fmt"
+    "testing"
+)
+
+func TestDetectDeviceTypeChange(t *testing.T) {
+    manyTypes := make([]string, MAX_DEVICE_TYPES_TRACKED+5)
+    for i := range manyTypes {
+        manyTypes[i] = fmt.Sprintf("tipo-%d", i)
+    }
+
+    tests := []struct {
+        name          string
+        types         []string
+        wantAnomalies int
+        wantTracked   int
+    }{
+        {name: "tipo constante", types: []string{"sensor", "sensor", "sensor"}, wantAnomalies: 0, wantTracked: 1},
+        {name: "alterna tipos", types: []string{"sensor", "camera", "sensor", "camera"}, wantAnomalies: 2, wantTracked: 2},
+        {name: "sin tipo", types: []string{"sensor", "", ""}, wantAnomalies: 0, wantTracked: 1},
+        {name: "tipos sin límite", types: manyTypes, wantAnomalies: len(manyTypes) - 1, wantTracked: MAX_DEVICE_TYPES_TRACKED},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            setupTestHub(t)
+            quarantineSystem.mutex.Lock()
+            defer quarantineSystem.mutex.Unlock()
+
+            behavior := quarantineSystem.behaviorLocked("sensor-1")
+            anomalies := 0
+            for _, deviceType := range tt.types {
+                data := SensorData{DeviceID: "sensor-1", DeviceType: deviceType}
+                anomalies += len(quarantineSystem.detectDeviceTypeChangeLocked(&data, behavior))
+            }
+
+            if anomalies != tt.wantAnomalies {
+                t.Errorf("anomalías = %d, se esperaban %d", anomalies, tt.wantAnomalies)
+            }
+            if got := len(behavior.DeviceTypes); got != tt.wantTracked {
+                t.Errorf("tipos guardados = %d, se esperaban %d", got, tt.wantTracked)
+            }
+        })
+    }
+}
@@ -0,0 +1,34 @@
+package main
+
+import "math"
+
+// Coeficientes de la fórmula de Magnus (Alduchov y Eskridge), válidos
+// aproximadamente entre -40°C y 50°C
+const (
+    MAGNUS_A = 17.625
+    MAGNUS_B = 243.04
+)
+
+// Punto de rocío (°C) a partir de temperatura (°C) y humedad relativa (%)
+func dewPoint(temperature, humidity float64) float64 {
+    gamma := math.Log(humidity/100) + MAGNUS_A*temperature/(MAGNUS_B+temperature)
+    return MAGNUS_B * gamma / (MAGNUS_A - gamma)
+}
+
+// Detectar combinaciones de humedad y temperatura físicamente inverosímiles
+// (p. ej. 100% de humedad a 80°C): un punto de rocío por encima de
+// DewPointMax no se da en un ambiente normal e indica fallo del sensor
+func detectHumidityTemperature(data *SensorData, thresholds AnomalyThresholds) []Anomaly {
+    if thresholds.DewPointMax == 0 || !data.hasReading("temperature", data.Temperature) || data.Humidity <= 0 || data.Humidity > 100 {
+        return nil
+    }
+
+    dew := dewPoint(data.Temperature, data.Humidity)
+    if dew <= thresholds.DewPointMax {
+        return nil
+    }
+
+    anomaly := newAnomaly(data, AnomalyHumidityTemperature, dew, "combinación humedad/temperatura inverosímil: %.1f%% a %.1f°C (punto de rocío %.1f°C)", data.Humidity, data.Temperature, dew)
+    anomaly.Confidence = thresholdConfidence(dew, thresholds.DewPointMax, thresholds.DewPointMax)
+    return []Anomaly{anomaly}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+    "math"
+    "testing"
+)
+
+func TestDewPoint(t *testing.T) {
+    tests := []struct {
+        name        string
+        temperature float64
+        humidity    float64
+        want        float64
+    }{
+        {name: "ambiente templado", temperature: 20, humidity: 50, want: 9.3},
+        {name: "saturado", temperature: 25, humidity: 100, want: 25},
+        {name: "seco y frío", temperature: 0, humidity: 30, want: -15.5},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if got := dewPoint(tt.temperature, tt.humidity); math.Abs(got-tt.want) > 0.1 {
+                t.Errorf("dewPoint(%v, %v) = %.2f, se esperaba %.1f", tt.temperature, tt.humidity, got, tt.want)
+            }
+        })
+    }
+}
+
+func TestDetectHumidityTemperature(t *testing.T) {
+    tests := []struct {
+        name        string
+        temperature float64
+        humidity    float64
+        dewPointMax float64
+        wantAnomaly bool
+    }{
+        {name: "oficina", temperature: 22, humidity: 45},
+        {name: "verano húmedo", temperature: 30, humidity: 70},
+        {name: "100% de humedad a 80°C", temperature: 80, humidity: 100, wantAnomaly: true},
+        {name: "bochorno extremo", temperature: 35, humidity: 90, wantAnomaly: true},
+        {name: "bochorno con umbral relajado", temperature: 35, humidity: 90, dewPointMax: 40},
+        {name: "sin humedad", temperature: 80},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            thresholds := DefaultAnomalyThresholds()
+            if tt.dewPointMax > 0 {
+                thresholds.DewPointMax = tt.dewPointMax
+            }
+
+            data := SensorData{DeviceID: "sensor-1", Temperature: tt.temperature, Humidity: tt.humidity}
+            anomalies := detectHumidityTemperature(&data, thresholds)
+            if got := len(anomalies) > 0; got != tt.wantAnomaly {
+                t.Fatalf("anomalía = %v, se esperaba %v", got, tt.wantAnomaly)
+            }
+            if tt.wantAnomaly && anomalies[0].Type != AnomalyHumidityTemperature {
+                t.Errorf("tipo = %s, se esperaba %s", anomalies[0].Type, AnomalyHumidityTemperature)
+            }
+        })
+    }
+}
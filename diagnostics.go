@@ -0,0 +1,75 @@
+package main
+
+import (
+    "sort"
+    "time"
+)
+
+// Códigos de diagnóstico que se consideran anomalía, por clave:
+// {"error_code": ["E42", "E99"], "self_test": ["fail"]}
+var diagnosticAlertCodes = map[string][]string{}
+
+// Último diagnóstico reportado por un dispositivo
+type DeviceDiagnostics struct {
+    DeviceID    string            `json:"device_id"`
+    Diagnostics map[string]string `json:"diagnostics"`
+    ReportedAt  time.Time         `json:"reported_at"`
+}
+
+// Guardar el diagnóstico reportado y marcar como anomalía los códigos
+// configurados como críticos
+func (qs *QuarantineSystem) RecordDiagnostics(data *SensorData) []Anomaly {
+    if len(data.Diagnostics) == 0 {
+        return nil
+    }
+
+    qs.mutex.Lock()
+    behavior := qs.behaviorLocked(data.DeviceID)
+    behavior.Diagnostics = make(map[string]string, len(data.Diagnostics))
+    for key, value := range data.Diagnostics {
+        behavior.Diagnostics[key] = value
+    }
+    behavior.DiagnosticsAt = qs.clock.Now()
+    qs.mutex.Unlock()
+
+    keys := make([]string, 0, len(data.Diagnostics))
+    for key := range data.Diagnostics {
+        keys = append(keys, key)
+    }
+    sort.Strings(keys)
+
+    var anomalies []Anomaly
+    for _, key := range keys {
+        value := data.Diagnostics[key]
+        for _, code := range diagnosticAlertCodes[key] {
+            if value == code {
+                anomaly := newAnomaly(data, AnomalyDiagnostic, 0, "diagnóstico crítico reportado: %s=%s", key, value)
+                anomaly.Severity = SEVERITY_HIGH
+                anomalies = append(anomalies, anomaly)
+                break
+            }
+        }
+    }
+    return anomalies
+}
+
+// Último diagnóstico de un dispositivo (false si no ha reportado ninguno)
+func (qs *QuarantineSystem) GetDiagnostics(deviceID string) (*DeviceDiagnostics, bool) {
+    qs.mutex.RLock()
+    defer qs.mutex.RUnlock()
+
+    behavior, exists := qs.deviceBehavior[deviceID]
+    if !exists || behavior.Diagnostics == nil {
+        return nil, false
+    }
+
+    diagnostics := make(map[string]string, len(behavior.Diagnostics))
+    for key, value := range behavior.Diagnostics {
+        diagnostics[key] = value
+    }
+    return &DeviceDiagnostics{
+        DeviceID:    deviceID,
+        Diagnostics: diagnostics,
+        ReportedAt:  behavior.DiagnosticsAt,
+    }, true
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "net/http/httptest"
+    "reflect"
+    "testing"
+)
+
+func TestIngestPayload_Diagnostics(t *testing.T) {
+    tests := []struct {
+        name        string
+        diagnostics map[string]string
+        wantAnomaly bool
+    }{
+        {name: "código crítico", diagnostics: map[string]string{"error_code": "E42", "self_test": "ok"}, wantAnomaly: true},
+        {name: "autotest fallido", diagnostics: map[string]string{"self_test": "fail"}, wantAnomaly: true},
+        {name: "código benigno", diagnostics: map[string]string{"error_code": "E01", "self_test": "ok"}},
+        {name: "clave no vigilada", diagnostics: map[string]string{"uptime": "E42"}},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            setupTestHub(t)
+            saved := diagnosticAlertCodes
+            t.Cleanup(func() { diagnosticAlertCodes = saved })
+            diagnosticAlertCodes = map[string][]string{"error_code": {"E42", "E99"}, "self_test": {"fail"}}
+
+            encoded, err := json.Marshal(tt.diagnostics)
+            if err != nil {
+                t.Fatalf("json.Marshal: %v", err)
+            }
+            reading := validReadingJSON("sensor-1")
+            payload := fmt.Sprintf(`%s,"diagnostics":%s}`, reading[:len(reading)-1], encoded)
+            if err := ingestPayload(context.Background(), "test", []byte(payload)); err != nil {
+                t.Fatalf("ingestPayload: %v", err)
+            }
+
+            diagnosticAnomalies := 0
+            for _, anomaly := range anomalyRepository.ByDevice("sensor-1", false) {
+                if anomaly.Type == AnomalyDiagnostic {
+                    diagnosticAnomalies++
+                }
+            }
+            if got := diagnosticAnomalies > 0; got != tt.wantAnomaly {
+                t.Errorf("anomalía de diagnóstico = %v, se esperaba %v", got, tt.wantAnomaly)
+            }
+
+            // El último diagnóstico queda disponible en la API
+            recorder := httptest.NewRecorder()
+            newAPIRouter().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/devices/sensor-1/diagnostics", nil))
+            if recorder.Code != http.StatusOK {
+                t.Fatalf("estado = %d, se esperaba %d", recorder.Code, http.StatusOK)
+            }
+            var stored DeviceDiagnostics
+            if err := json.Unmarshal(recorder.Body.Bytes(), &stored); err != nil {
+                t.Fatalf("respuesta no válida: %v", err)
+            }
+            if !reflect.DeepEqual(stored.Diagnostics, tt.diagnostics) || !stored.ReportedAt.Equal(testEpoch) {
+                t.Errorf("diagnóstico = %v en %v, se esperaba %v en %v", stored.Diagnostics, stored.ReportedAt, tt.diagnostics, testEpoch)
+            }
+        })
+    }
+}
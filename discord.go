@@ -0,0 +1,190 @@
+package main
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "time"
+)
+
+// Cliente de notificaciones por webhook de Discord
+type DiscordClient struct {
+    name       string
+    webhookURL string
+    httpClient *http.Client
+}
+
+// Embed de Discord
+type discordEmbed struct {
+    Title       string              `json:"title"`
+    Description string              `json:"description,omitempty"`
+    Color       int                 `json:"color"`
+    Fields      []discordEmbedField `json:"fields,omitempty"`
+    Timestamp   string              `json:"timestamp,omitempty"`
+}
+
+type discordEmbedField struct {
+    Name   string `json:"name"`
+    Value  string `json:"value"`
+    Inline bool   `json:"inline"`
+}
+
+type discordMessage struct {
+    Username string         `json:"username,omitempty"`
+    Embeds   []discordEmbed `json:"embeds"`
+}
+
+// Crear cliente de Discord
+func NewDiscordClient(webhookURL string) *DiscordClient {
+    return NewNamedDiscordClient("discord", webhookURL)
+}
+
+// Crear cliente de Discord con nombre propio, para tener varios canales
+// (p. ej. "facilities", "security") y enrutar anomalías a cada uno
+func NewNamedDiscordClient(name string, webhookURL string) *DiscordClient {
+    return &DiscordClient{
+        name:       name,
+        webhookURL: webhookURL,
+        httpClient: &http.Client{Timeout: NOTIFICATION_TIMEOUT},
+    }
+}
+
+func (dc *DiscordClient) Name() string {
+    return dc.name
+}
+
+// Color del embed según severidad (Discord usa enteros RGB)
+func (dc *DiscordClient) getColorBySeverity(severity string) int {
+    switch severity {
+    case SEVERITY_HIGH:
+        return 0xE74C3C // rojo
+    case SEVERITY_MEDIUM:
+        return 0xF39C12 // naranja
+    case SEVERITY_LOW:
+        return 0x3498DB // azul
+    default:
+        return 0x95A5A6 // gris
+    }
+}
+
+// Enviar alerta de anomalía
+func (dc *DiscordClient) SendAnomalyAlert(ctx context.Context, anomaly *Anomaly) error {
+    return dc.sendMessage(ctx, dc.anomalyMessage(anomaly))
+}
+
+// Enviar alerta de cuarentena
+func (dc *DiscordClient) SendQuarantineAlert(ctx context.Context, deviceID string, reason QuarantineReason, detail string, duration time.Duration) error {
+    return dc.sendMessage(ctx, dc.quarantineMessage(deviceID, reason, detail, duration))
+}
+
+// Enviar aviso de dispositivo recuperado (fin de cuarentena)
+func (dc *DiscordClient) SendRecoveryAlert(ctx context.Context, deviceID string, reason ReleaseReason, actor string) error {
+    return dc.sendMessage(ctx, dc.recoveryMessage(deviceID, reason, actor))
+}
+
+// Mensaje que se enviaría para una anomalía (modo dry-run)
+func (dc *DiscordClient) RenderAnomalyAlert(anomaly *Anomaly) (string, error) {
+    body, err := json.Marshal(dc.anomalyMessage(anomaly))
+    return string(body), err
+}
+
+// Mensaje que se enviaría para una cuarentena (modo dry-run)
+func (dc *DiscordClient) RenderQuarantineAlert(deviceID string, reason QuarantineReason, detail string, duration time.Duration) (string, error) {
+    body, err := json.Marshal(dc.quarantineMessage(deviceID, reason, detail, duration))
+    return string(body), err
+}
+
+// Mensaje que se enviaría para una recuperación (modo dry-run)
+func (dc *DiscordClient) RenderRecoveryAlert(deviceID string, reason ReleaseReason, actor string) (string, error) {
+    body, err := json.Marshal(dc.recoveryMessage(deviceID, reason, actor))
+    return string(body), err
+}
+
+// Construir el mensaje de una anomalía
+func (dc *DiscordClient) anomalyMessage(anomaly *Anomaly) discordMessage {
+    embed := discordEmbed{
+        Title:       fmt.Sprintf("%s Anomalía detectada: %s", getEmojiByType(anomaly.Type), anomaly.Type),
+        Description: anomaly.Description,
+        Color:       dc.getColorBySeverity(anomaly.Severity),
+        Fields: []discordEmbedField{
+            {Name: "Dispositivo", Value: anomaly.DeviceID, Inline: true},
+            {Name: "Tipo", Value: string(anomaly.Type), Inline: true},
+            {Name: "Severidad", Value: anomaly.Severity, Inline: true},
+            {Name: "Valor", Value: fmt.Sprintf("%.2f", anomaly.Value), Inline: true},
+            {Name: "Confianza", Value: fmt.Sprintf("%.0f%%", anomaly.Confidence*100), Inline: true},
+        },
+        Timestamp: anomaly.Timestamp.Format(time.RFC3339),
+    }
+    if anomaly.ExternalContext != nil {
+        embed.Fields = append(embed.Fields, discordEmbedField{
+            Name:  "Exterior",
+            Value: fmt.Sprintf("%.1f°C, %.0f%% humedad", anomaly.ExternalContext.OutdoorTemperature, anomaly.ExternalContext.OutdoorHumidity),
+        })
+    }
+    if len(anomaly.DeviceMetadata) > 0 {
+        embed.Fields = append(embed.Fields, discordEmbedField{Name: "Metadatos", Value: formatMetadata(anomaly.DeviceMetadata)})
+    }
+    if len(anomaly.History) > 0 {
+        embed.Fields = append(embed.Fields, discordEmbedField{
+            Name:  fmt.Sprintf("Últimas %d lecturas de %s", len(anomaly.History), anomaly.Metric),
+            Value: formatHistory(anomaly.History),
+        })
+    }
+
+    return discordMessage{Username: "IoT Security Hub", Embeds: []discordEmbed{embed}}
+}
+
+// Construir el mensaje de una cuarentena
+func (dc *DiscordClient) quarantineMessage(deviceID string, reason QuarantineReason, detail string, duration time.Duration) discordMessage {
+    embed := discordEmbed{
+        Title:       "🔒 Dispositivo en cuarentena",
+        Description: describeQuarantineReason(reason, detail),
+        Color:       dc.getColorBySeverity(SEVERITY_HIGH),
+        Fields: []discordEmbedField{
+            {Name: "Dispositivo", Value: deviceID, Inline: true},
+            {Name: "Duración", Value: duration.String(), Inline: true},
+            {Name: "Motivo", Value: reason.Label(), Inline: true},
+        },
+        Timestamp: time.Now().Format(time.RFC3339),
+    }
+
+    return discordMessage{Username: "IoT Security Hub", Embeds: []discordEmbed{embed}}
+}
+
+// Construir el mensaje de una recuperación
+func (dc *DiscordClient) recoveryMessage(deviceID string, reason ReleaseReason, actor string) discordMessage {
+    embed := discordEmbed{
+        Title:       "✅ Dispositivo recuperado",
+        Description: "La cuarentena terminó y el dispositivo vuelve a aceptarse",
+        Color:       0x2ECC71, // verde
+        Fields: []discordEmbedField{
+            {Name: "Dispositivo", Value: deviceID, Inline: true},
+            {Name: "Motivo", Value: reason.Label(), Inline: true},
+        },
+        Timestamp: time.Now().Format(time.RFC3339),
+    }
+    if actor != "" {
+        embed.Fields = append(embed.Fields, discordEmbedField{Name: "Liberado por", Value: actor, Inline: true})
+    }
+
+    return discordMessage{Username: "IoT Security Hub", Embeds: []discordEmbed{embed}}
+}
+
+// POST del mensaje al webhook, con reintentos
+func (dc *DiscordClient) sendMessage(ctx context.Context, message discordMessage) error {
+    body, err := json.Marshal(message)
+    if err != nil {
+        return err
+    }
+
+    return sendWithRetry(ctx, dc.httpClient, "discord", func(ctx context.Context) (*http.Request, error) {
+        req, err := http.NewRequestWithContext(ctx, http.MethodPost, dc.webhookURL, bytes.NewReader(body))
+        if err != nil {
+            return nil, err
+        }
+        req.Header.Set("Content-Type", "application/json")
+        return req, nil
+    })
+}
@@ -0,0 +1,111 @@
+package main
+
+import "time"
+
+// Documento de anomalía en Elastic Common Schema (ECS), para herramientas
+// SIEM como Elastic Security
+type ECSDocument struct {
+    Timestamp time.Time         `json:"@timestamp"`
+    Message   string            `json:"message"`
+    ECS       ecsVersion        `json:"ecs"`
+    Event     ecsEvent          `json:"event"`
+    Host      ecsHost           `json:"host"`
+    Rule      ecsRule           `json:"rule"`
+    Labels    map[string]string `json:"labels,omitempty"`
+}
+
+type ecsVersion struct {
+    Version string `json:"version"`
+}
+
+type ecsEvent struct {
+    ID       string   `json:"id,omitempty"`
+    Kind     string   `json:"kind"`
+    Category []string `json:"category"`
+    Type     []string `json:"type"`
+    Severity int      `json:"severity"`
+    Module   string   `json:"module"`
+    Dataset  string   `json:"dataset"`
+}
+
+type ecsHost struct {
+    ID   string `json:"id"`
+    Type string `json:"type,omitempty"`
+}
+
+type ecsRule struct {
+    Name     string `json:"name"`
+    Category string `json:"category"`
+}
+
+// Metadatos que se exportan como etiqueta propia (device_site...). Las claves
+// son libres: una etiqueta por clave crearía un campo nuevo en el índice por
+// cada clave que invente un dispositivo. El resto va junto en device_metadata.
+var ecsMetadataLabels = []string{"site", "location", "owner"}
+
+// Versión de ECS que siguen los documentos
+const ECS_VERSION = "8.11.0"
+
+// Severidad numérica ECS (misma escala que las alertas de Elastic Security)
+func ecsSeverity(severity string) int {
+    switch severity {
+    case SEVERITY_HIGH:
+        return 73
+    case SEVERITY_MEDIUM:
+        return 47
+    default:
+        return 21
+    }
+}
+
+// Categoría ECS del evento según el tipo de anomalía
+func ecsCategory(anomalyType AnomalyType) []string {
+    switch anomalyType {
+    case AnomalyAccessAttempts, AnomalyAccessFailureRatio, AnomalyBehaviorPattern, AnomalySecurityState:
+        return []string{"intrusion_detection"}
+    case AnomalySignal:
+        return []string{"network"}
+    default:
+        return []string{"host"}
+    }
+}
+
+// Convertir una anomalía al formato ECS
+func toECSDocument(anomaly *Anomaly) ECSDocument {
+    labels := map[string]string{}
+    for _, key := range ecsMetadataLabels {
+        if value, exists := anomaly.DeviceMetadata[key]; exists {
+            labels["device_"+key] = value
+        }
+    }
+    if len(anomaly.DeviceMetadata) > 0 {
+        labels["device_metadata"] = formatMetadata(anomaly.DeviceMetadata)
+    }
+    if anomaly.Metric != "" {
+        labels["metric"] = anomaly.Metric
+    }
+
+    return ECSDocument{
+        Timestamp: anomaly.Timestamp.UTC(),
+        Message:   anomaly.Description,
+        ECS:       ecsVersion{Version: ECS_VERSION},
+        Event: ecsEvent{
+            ID:       anomaly.ID,
+            Kind:     "alert",
+            Category: ecsCategory(anomaly.Type),
+            Type:     []string{"info"},
+            Severity: ecsSeverity(anomaly.Severity),
+            Module:   "iot_hub",
+            Dataset:  "iot_hub.anomaly",
+        },
+        Host: ecsHost{
+            ID:   anomaly.DeviceID,
+            Type: anomaly.DeviceType,
+        },
+        Rule: ecsRule{
+            Name:     string(anomaly.Type),
+            Category: anomaly.Severity,
+        },
+        Labels: labels,
+    }
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+    "encoding/json"
+    "reflect"
+    "testing"
+    "time"
+)
+
+func TestToECSDocument(t *testing.T) {
+    timestamp := time.Date(2026, 3, 2, 13, 0, 0, 0, time.FixedZone("CET", 3600))
+
+    tests := []struct {
+        name         string
+        anomaly      Anomaly
+        wantCategory []interface{}
+        wantSeverity float64
+    }{
+        {
+            name:         "temperatura",
+            anomaly:      Anomaly{ID: "a1", DeviceID: "sensor-1", Type: AnomalyTemperature, Severity: SEVERITY_MEDIUM, Description: "temperatura alta", Timestamp: timestamp},
+            wantCategory: []interface{}{"host"},
+            wantSeverity: 47,
+        },
+        {
+            name:         "accesos",
+            anomaly:      Anomaly{ID: "a2", DeviceID: "sensor-1", Type: AnomalyAccessAttempts, Severity: SEVERITY_HIGH, Description: "fuerza bruta", Timestamp: timestamp},
+            wantCategory: []interface{}{"intrusion_detection"},
+            wantSeverity: 73,
+        },
+        {
+            name:         "señal",
+            anomaly:      Anomaly{ID: "a3", DeviceID: "sensor-1", Type: AnomalySignal, Severity: SEVERITY_LOW, Description: "señal débil", Timestamp: timestamp},
+            wantCategory: []interface{}{"network"},
+            wantSeverity: 21,
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            // Comprobar los campos tal y como llegan a Elastic
+            encoded, err := json.Marshal(toECSDocument(&tt.anomaly))
+            if err != nil {
+                t.Fatalf("json.Marshal: %v", err)
+            }
+            var document map[string]interface{}
+            if err := json.Unmarshal(encoded, &document); err != nil {
+                t.Fatalf("json.Unmarshal: %v", err)
+            }
+            event, _ := document["event"].(map[string]interface{})
+            host, _ := document["host"].(map[string]interface{})
+
+            if got := document["@timestamp"]; got != "2026-03-02T12:00:00Z" {
+                t.Errorf("@timestamp = %v, se esperaba 2026-03-02T12:00:00Z", got)
+            }
+            if got := document["message"]; got != tt.anomaly.Description {
+                t.Errorf("message = %v, se esperaba %q", got, tt.anomaly.Description)
+            }
+            if got := host["id"]; got != tt.anomaly.DeviceID {
+                t.Errorf("host.id = %v, se esperaba %q", got, tt.anomaly.DeviceID)
+            }
+            if got := event["category"]; !reflect.DeepEqual(got, tt.wantCategory) {
+                t.Errorf("event.category = %v, se esperaba %v", got, tt.wantCategory)
+            }
+            if got := event["severity"]; got != tt.wantSeverity {
+                t.Errorf("event.severity = %v, se esperaba %v", got, tt.wantSeverity)
+            }
+            if got := event["id"]; got != tt.anomaly.ID {
+                t.Errorf("event.id = %v, se esperaba %q", got, tt.anomaly.ID)
+            }
+        })
+    }
+}
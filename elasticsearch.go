@@ -0,0 +1,120 @@
+package main
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "strings"
+    "time"
+)
+
+// Exporta cada anomalía como documento a un índice diario de Elasticsearch
+// ("<prefijo>-2006.01.02"), para búsquedas y dashboards en Kibana. Es un
+// destino de exportación, no un canal de notificación: recibe todas las
+// anomalías persistidas aunque no se notifiquen.
+type ElasticExporter struct {
+    baseURL     string
+    indexPrefix string
+    format      string
+    httpClient  *http.Client
+}
+
+// Formatos de documento soportados
+const (
+    ELASTIC_FORMAT_NATIVE = "native"
+    ELASTIC_FORMAT_ECS    = "ecs"
+)
+
+// Documento indexado: los nombres de campo siguen la convención de ES
+// (@timestamp como fecha, value numérico, el resto keyword/text)
+type elasticAnomalyDocument struct {
+    Timestamp   time.Time   `json:"@timestamp"`
+    DeviceID    string      `json:"device_id"`
+    DeviceType  string      `json:"device_type,omitempty"`
+    Type        AnomalyType `json:"anomaly_type"`
+    Severity    string      `json:"severity"`
+    Metric      string      `json:"metric,omitempty"`
+    Value       float64     `json:"value"`
+    History     []float64   `json:"history,omitempty"`
+    Description string      `json:"description"`
+}
+
+// Crear exportador a Elasticsearch con documentos en formato propio
+func NewElasticExporter(baseURL string, indexPrefix string) *ElasticExporter {
+    return NewElasticExporterWithFormat(baseURL, indexPrefix, ELASTIC_FORMAT_NATIVE)
+}
+
+// Crear exportador a Elasticsearch con el formato indicado ("native" o "ecs")
+func NewElasticExporterWithFormat(baseURL string, indexPrefix string, format string) *ElasticExporter {
+    if format != ELASTIC_FORMAT_ECS {
+        format = ELASTIC_FORMAT_NATIVE
+    }
+    return &ElasticExporter{
+        baseURL:     strings.TrimRight(baseURL, "/"),
+        indexPrefix: indexPrefix,
+        format:      format,
+        httpClient:  &http.Client{Timeout: NOTIFICATION_TIMEOUT},
+    }
+}
+
+func (en *ElasticExporter) Name() string {
+    return "elasticsearch"
+}
+
+// Índice del día de la anomalía
+func (en *ElasticExporter) indexFor(timestamp time.Time) string {
+    return fmt.Sprintf("%s-%s", en.indexPrefix, timestamp.UTC().Format("2006.01.02"))
+}
+
+// Indexar la anomalía
+func (en *ElasticExporter) ExportAnomaly(ctx context.Context, anomaly *Anomaly) error {
+    return en.indexDocument(ctx, en.indexFor(anomaly.Timestamp), en.anomalyDocument(anomaly))
+}
+
+// Documento que se indexaría para una anomalía (modo dry-run)
+func (en *ElasticExporter) RenderAnomaly(anomaly *Anomaly) (string, error) {
+    body, err := json.Marshal(en.anomalyDocument(anomaly))
+    if err != nil {
+        return "", err
+    }
+    return fmt.Sprintf("POST %s/%s/_doc %s", en.baseURL, en.indexFor(anomaly.Timestamp), body), nil
+}
+
+// Documento de la anomalía en el formato configurado
+func (en *ElasticExporter) anomalyDocument(anomaly *Anomaly) interface{} {
+    if en.format == ELASTIC_FORMAT_ECS {
+        return toECSDocument(anomaly)
+    }
+
+    return elasticAnomalyDocument{
+        Timestamp:   anomaly.Timestamp.UTC(),
+        DeviceID:    anomaly.DeviceID,
+        DeviceType:  anomaly.DeviceType,
+        Type:        anomaly.Type,
+        Severity:    anomaly.Severity,
+        Metric:      anomaly.Metric,
+        Value:       anomaly.Value,
+        History:     anomaly.History,
+        Description: anomaly.Description,
+    }
+}
+
+// POST del documento a /<índice>/_doc
+func (en *ElasticExporter) indexDocument(ctx context.Context, index string, document interface{}) error {
+    body, err := json.Marshal(document)
+    if err != nil {
+        return err
+    }
+
+    url := fmt.Sprintf("%s/%s/_doc", en.baseURL, index)
+    return sendWithRetry(ctx, en.httpClient, "elasticsearch", func(ctx context.Context) (*http.Request, error) {
+        req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+        if err != nil {
+            return nil, err
+        }
+        req.Header.Set("Content-Type", "application/json")
+        return req, nil
+    })
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+    "context"
+    "encoding/json"
+    "io"
+    "net/http"
+    "net/http/httptest"
+    "sync"
+    "testing"
+    "time"
+)
+
+// Servidor Elasticsearch falso que guarda los documentos por ruta
+type fakeElastic struct {
+    mutex     sync.Mutex
+    documents map[string][]map[string]interface{}
+}
+
+func newFakeElastic(t *testing.T) (*fakeElastic, *httptest.Server) {
+    t.Helper()
+
+    fake := &fakeElastic{documents: make(map[string][]map[string]interface{})}
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        body, _ := io.ReadAll(r.Body)
+        var document map[string]interface{}
+        if err := json.Unmarshal(body, &document); err != nil {
+            w.WriteHeader(http.StatusBadRequest)
+            return
+        }
+        fake.mutex.Lock()
+        fake.documents[r.URL.Path] = append(fake.documents[r.URL.Path], document)
+        fake.mutex.Unlock()
+        w.WriteHeader(http.StatusCreated)
+    }))
+    t.Cleanup(server.Close)
+    return fake, server
+}
+
+func (fe *fakeElastic) count() int {
+    fe.mutex.Lock()
+    defer fe.mutex.Unlock()
+
+    total := 0
+    for _, documents := range fe.documents {
+        total += len(documents)
+    }
+    return total
+}
+
+func TestElasticExporter_ExportAnomaly(t *testing.T) {
+    anomaly := Anomaly{
+        DeviceID:    "sensor-1",
+        Type:        AnomalyTemperature,
+        Severity:    SEVERITY_HIGH,
+        Metric:      METRIC_TEMPERATURE,
+        Value:       85,
+        Description: "temperatura crítica",
+        Timestamp:   testEpoch,
+    }
+
+    tests := []struct {
+        name     string
+        format   string
+        wantPath string
+        wantKey  string
+    }{
+        {name: "formato propio", format: ELASTIC_FORMAT_NATIVE, wantPath: "/iot-anomalies-2026.03.02/_doc", wantKey: "anomaly_type"},
+        {name: "formato ECS", format: ELASTIC_FORMAT_ECS, wantPath: "/iot-anomalies-2026.03.02/_doc", wantKey: "ecs"},
+        {name: "formato desconocido cae al propio", format: "xml", wantPath: "/iot-anomalies-2026.03.02/_doc", wantKey: "anomaly_type"},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            fake, server := newFakeElastic(t)
+            exporter := NewElasticExporterWithFormat(server.URL+"/", "iot-anomalies", tt.format)
+
+            if err := exporter.ExportAnomaly(context.Background(), &anomaly); err != nil {
+                t.Fatalf("ExportAnomaly() error = %v", err)
+            }
+
+            documents := fake.documents[tt.wantPath]
+            if len(documents) != 1 {
+                t.Fatalf("documentos en %s = %d, se esperaba 1 (%v)", tt.wantPath, len(documents), fake.documents)
+            }
+            if _, ok := documents[0][tt.wantKey]; !ok {
+                t.Errorf("el documento no tiene el campo %q: %v", tt.wantKey, documents[0])
+            }
+        })
+    }
+}
+
+func TestRecordAnomalies_ExportsEveryPersistedAnomaly(t *testing.T) {
+    tests := []struct {
+        name          string
+        notify        bool
+        minConfidence float64
+        anomalies     []Anomaly
+    }{
+        {
+            name:   "notificadas",
+            notify: true,
+            anomalies: []Anomaly{
+                {DeviceID: "sensor-1", Type: AnomalyTemperature, Severity: SEVERITY_HIGH, Confidence: 1},
+            },
+        },
+        {
+            name:   "sin notificar (ventana de actualización)",
+            notify: false,
+            anomalies: []Anomaly{
+                {DeviceID: "sensor-1", Type: AnomalyTemperature, Severity: SEVERITY_HIGH, Confidence: 1},
+                {DeviceID: "sensor-1", Type: AnomalyBattery, Severity: SEVERITY_LOW, Confidence: 1},
+            },
+        },
+        {
+            name:          "por debajo de la confianza mínima",
+            notify:        true,
+            minConfidence: 0.9,
+            anomalies: []Anomaly{
+                {DeviceID: "sensor-2", Type: AnomalySignal, Severity: SEVERITY_LOW, Confidence: 0.1},
+            },
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            clock := setupTestHub(t)
+            savedConfidence := notificationMinConfidence
+            notificationMinConfidence = tt.minConfidence
+            t.Cleanup(func() { notificationMinConfidence = savedConfidence })
+
+            fake, server := newFakeElastic(t)
+            anomalyExporter.Register(NewElasticExporter(server.URL, "iot-anomalies"))
+
+            for i := range tt.anomalies {
+                tt.anomalies[i].Timestamp = clock.Now()
+            }
+            recordAnomalies(tt.anomalies, tt.notify)
+
+            ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+            defer cancel()
+            if err := anomalyExporter.Flush(ctx); err != nil {
+                t.Fatalf("Flush() error = %v", err)
+            }
+            if got := fake.count(); got != len(tt.anomalies) {
+                t.Errorf("documentos exportados = %d, se esperaban %d", got, len(tt.anomalies))
+            }
+        })
+    }
+}
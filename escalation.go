@@ -0,0 +1,50 @@
+package main
+
+import (
+    "math"
+    "time"
+)
+
+// Escalado de quarantine para reincidentes: cada quarantine dura
+// QUARANTINE_DURATION * factor^(n-1), con un máximo. El contador se reinicia
+// si el dispositivo se porta bien durante quarantineResetWindow.
+var (
+    quarantineEscalationFactor = 2.0
+    quarantineMaxDuration      = 1 * time.Hour
+    quarantineResetWindow      = 24 * time.Hour
+)
+
+// Duración de la próxima quarantine de un dispositivo (llamar con el lock tomado).
+// Actualiza el contador de reincidencias del dispositivo; si no cabe en el
+// límite de dispositivos se aplica la duración base sin seguimiento.
+func (qs *QuarantineSystem) nextQuarantineDurationLocked(deviceID string, now time.Time) time.Duration {
+    behavior, admitted := qs.admitBehaviorLocked(deviceID)
+    if !admitted {
+        return escalatedQuarantineDuration(1)
+    }
+
+    // Buen comportamiento suficiente desde la última quarantine: empezar de cero
+    if behavior.QuarantineCount > 0 && now.Sub(behavior.LastQuarantineEnd) > quarantineResetWindow {
+        behavior.QuarantineCount = 0
+    }
+
+    behavior.QuarantineCount++
+    behavior.TotalQuarantines++
+    duration := escalatedQuarantineDuration(behavior.QuarantineCount)
+    behavior.LastQuarantineEnd = now.Add(duration)
+    return duration
+}
+
+// Duración escalada para la n-ésima quarantine consecutiva
+func escalatedQuarantineDuration(count int) time.Duration {
+    if count < 1 {
+        count = 1
+    }
+
+    factor := math.Pow(quarantineEscalationFactor, float64(count-1))
+    duration := time.Duration(float64(QUARANTINE_DURATION) * factor)
+    if duration > quarantineMaxDuration || duration <= 0 {
+        duration = quarantineMaxDuration
+    }
+    return duration
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+    "testing"
+    "time"
+)
+
+func TestQuarantineEscalation_RepeatOffenders(t *testing.T) {
+    tests := []struct {
+        name        string
+        maxDuration time.Duration
+        gaps        []time.Duration
+        want        []time.Duration
+    }{
+        {
+            name: "tres quarantines seguidas",
+            gaps: []time.Duration{time.Minute, time.Minute},
+            want: []time.Duration{5 * time.Minute, 10 * time.Minute, 20 * time.Minute},
+        },
+        {
+            name: "buen comportamiento reinicia el contador",
+            gaps: []time.Duration{time.Minute, 25 * time.Hour},
+            want: []time.Duration{5 * time.Minute, 10 * time.Minute, 5 * time.Minute},
+        },
+        {
+            name:        "con máximo",
+            maxDuration: 15 * time.Minute,
+            gaps:        []time.Duration{time.Minute, time.Minute},
+            want:        []time.Duration{5 * time.Minute, 10 * time.Minute, 15 * time.Minute},
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            clock := setupTestHub(t)
+            saved := quarantineMaxDuration
+            t.Cleanup(func() { quarantineMaxDuration = saved })
+            if tt.maxDuration > 0 {
+                quarantineMaxDuration = tt.maxDuration
+            }
+
+            for i, want := range tt.want {
+                quarantineSystem.QuarantineDeviceWithReason("sensor-1", QuarantineReasonBehaviorAnomaly, "test")
+                entry, quarantined := quarantineSystem.ActiveQuarantine("sensor-1")
+                if !quarantined {
+                    t.Fatalf("quarantine %d: el dispositivo no está en quarantine", i+1)
+                }
+                if entry.Duration != want {
+                    t.Errorf("quarantine %d: duración = %v, se esperaba %v", i+1, entry.Duration, want)
+                }
+
+                // Cumplir la quarantine y esperar hasta la siguiente
+                clock.Advance(entry.Duration + time.Second)
+                quarantineSystem.CleanExpiredQuarantines()
+                if quarantineSystem.IsQuarantined("sensor-1") {
+                    t.Fatalf("quarantine %d: no se liberó al expirar", i+1)
+                }
+                if i < len(tt.gaps) {
+                    clock.Advance(tt.gaps[i])
+                }
+            }
+        })
+    }
+}
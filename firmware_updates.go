@@ -0,0 +1,69 @@
+package main
+
+import (
+    "log"
+    "time"
+)
+
+// Ventana durante la que un dispositivo se considera en actualización de firmware
+var firmwareUpdateWindow = 10 * time.Minute
+
+// Marcar un dispositivo como "actualizando firmware" durante la ventana indicada.
+// Mientras dure, sus anomalías se registran pero no se notifican ni cuentan
+// para la quarantine.
+func (qs *QuarantineSystem) SetUpdating(deviceID string, window time.Duration) time.Time {
+    qs.mutex.Lock()
+    defer qs.mutex.Unlock()
+
+    until := qs.clock.Now().Add(window)
+    qs.updatingDevices[deviceID] = until
+    log.Printf("🛠️ FIRMWARE: Dispositivo %s en actualización hasta %s", deviceID, until.Format(time.RFC3339))
+    return until
+}
+
+// Quitar el estado de actualización de un dispositivo
+func (qs *QuarantineSystem) ClearUpdating(deviceID string) {
+    qs.mutex.Lock()
+    defer qs.mutex.Unlock()
+
+    if _, exists := qs.updatingDevices[deviceID]; exists {
+        delete(qs.updatingDevices, deviceID)
+        log.Printf("🛠️ FIRMWARE: Dispositivo %s terminó la actualización", deviceID)
+    }
+}
+
+// Verificar si el dispositivo está actualizando firmware (limpia ventanas expiradas)
+func (qs *QuarantineSystem) IsUpdating(deviceID string) bool {
+    qs.mutex.Lock()
+    defer qs.mutex.Unlock()
+
+    return qs.isUpdatingLocked(deviceID)
+}
+
+// Igual que IsUpdating pero con el lock ya tomado
+func (qs *QuarantineSystem) isUpdatingLocked(deviceID string) bool {
+    until, exists := qs.updatingDevices[deviceID]
+    if !exists {
+        return false
+    }
+
+    if qs.clock.Now().After(until) {
+        delete(qs.updatingDevices, deviceID)
+        log.Printf("🛠️ FIRMWARE: Ventana de actualización de %s expirada", deviceID)
+        return false
+    }
+    return true
+}
+
+// Aplicar el estado de actualización reportado por el propio dispositivo
+func applyReportedFirmwareState(data *SensorData) {
+    if data.FirmwareUpdating == nil {
+        return
+    }
+
+    if *data.FirmwareUpdating {
+        quarantineSystem.SetUpdating(data.DeviceID, firmwareUpdateWindow)
+    } else {
+        quarantineSystem.ClearUpdating(data.DeviceID)
+    }
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+    "context"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+    "time"
+)
+
+func TestProcessSensorData_FirmwareUpdateSuppression(t *testing.T) {
+    truth := true
+
+    tests := []struct {
+        name string
+        // Preparar el estado de actualización antes de las lecturas
+        prepare        func(clock *FakeClock)
+        reported       *bool
+        wantQuarantine bool
+        wantNotified   bool
+    }{
+        {name: "sin actualización", prepare: func(clock *FakeClock) {}, wantQuarantine: true, wantNotified: true},
+        {name: "actualización vía API", prepare: func(clock *FakeClock) { quarantineSystem.SetUpdating("sensor-1", time.Hour) }},
+        {name: "actualización reportada por el dispositivo", prepare: func(clock *FakeClock) {}, reported: &truth},
+        {
+            name: "ventana de actualización expirada",
+            prepare: func(clock *FakeClock) {
+                quarantineSystem.SetUpdating("sensor-1", time.Minute)
+                clock.Advance(2 * time.Minute)
+            },
+            wantQuarantine: true,
+            wantNotified:   true,
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            clock := setupTestHub(t)
+            notifier := newRecordingNotifier("test")
+            notificationManager.Register(notifier)
+            tt.prepare(clock)
+
+            for i := 0; i < 5; i++ {
+                clock.Advance(time.Second)
+                data := SensorData{DeviceID: "sensor-1", Timestamp: clock.Now().Unix(), Temperature: 95, Humidity: 10, BatteryLevel: 80, FirmwareUpdating: tt.reported}
+                processSensorData(context.Background(), data, nil, nil)
+            }
+            flushNotifications(t)
+
+            if got := quarantineSystem.IsQuarantined("sensor-1"); got != tt.wantQuarantine {
+                t.Errorf("en quarantine = %v, se esperaba %v", got, tt.wantQuarantine)
+            }
+            if got := len(notifier.Anomalies()) > 0; got != tt.wantNotified {
+                t.Errorf("notificado = %v, se esperaba %v", got, tt.wantNotified)
+            }
+            // Las anomalías se registran siempre
+            if len(anomalyRepository.ByDevice("sensor-1", false)) == 0 {
+                t.Error("las anomalías durante la actualización deben registrarse")
+            }
+        })
+    }
+}
+
+func TestHandleStartFirmwareUpdate_Auth(t *testing.T) {
+    tests := []struct {
+        name         string
+        authorized   bool
+        wantStatus   int
+        wantUpdating bool
+    }{
+        {name: "sin token", wantStatus: http.StatusUnauthorized},
+        {name: "con token", authorized: true, wantStatus: http.StatusOK, wantUpdating: true},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            setupTestHub(t)
+            withAPIToken(t)
+
+            request := httptest.NewRequest(http.MethodPost, "/devices/sensor-1/firmware-update", nil)
+            if tt.authorized {
+                request = newAuthorizedRequest(http.MethodPost, "/devices/sensor-1/firmware-update", nil)
+            }
+            recorder := httptest.NewRecorder()
+            newAPIRouter().ServeHTTP(recorder, request)
+            if recorder.Code != tt.wantStatus {
+                t.Fatalf("estado = %d, se esperaba %d", recorder.Code, tt.wantStatus)
+            }
+            if got := quarantineSystem.IsUpdating("sensor-1"); got != tt.wantUpdating {
+                t.Errorf("actualizando = %v, se esperaba %v", got, tt.wantUpdating)
+            }
+        })
+    }
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+    "log"
+    "sync"
+    "time"
+)
+
+// Ventana de mantenimiento por defecto de un grupo
+var groupMaintenanceWindow = 2 * time.Hour
+
+// Mantenimiento a nivel de grupo: mientras dure, los dispositivos del grupo
+// se tratan como en actualización de firmware (anomalías registradas pero sin
+// notificar ni contar para quarantine)
+type GroupMaintenance struct {
+    mutex sync.Mutex
+    until map[string]time.Time
+    clock Clock
+}
+
+var groupMaintenance = NewGroupMaintenance()
+
+// Inicializar mantenimiento de grupos
+func NewGroupMaintenance() *GroupMaintenance {
+    return &GroupMaintenance{
+        until: make(map[string]time.Time),
+        clock: RealClock{},
+    }
+}
+
+// Cambiar el reloj con el que expiran las ventanas de mantenimiento
+func (gm *GroupMaintenance) SetClock(clock Clock) {
+    gm.mutex.Lock()
+    defer gm.mutex.Unlock()
+
+    gm.clock = clock
+}
+
+// Declarar mantenimiento de un grupo durante la ventana indicada
+func (gm *GroupMaintenance) Start(group string, window time.Duration) time.Time {
+    gm.mutex.Lock()
+    defer gm.mutex.Unlock()
+
+    until := gm.clock.Now().Add(window)
+    gm.until[group] = until
+    log.Printf("🧰 MANTENIMIENTO: Grupo %s en mantenimiento hasta %s", group, until.Format(time.RFC3339))
+    return until
+}
+
+// Terminar el mantenimiento de un grupo
+func (gm *GroupMaintenance) End(group string) {
+    gm.mutex.Lock()
+    defer gm.mutex.Unlock()
+
+    if _, exists := gm.until[group]; exists {
+        delete(gm.until, group)
+        log.Printf("🧰 MANTENIMIENTO: Grupo %s terminó el mantenimiento", group)
+    }
+}
+
+// Verificar si algún grupo del dispositivo está en mantenimiento (limpia ventanas expiradas)
+func (gm *GroupMaintenance) InMaintenance(deviceID string) bool {
+    gm.mutex.Lock()
+    defer gm.mutex.Unlock()
+
+    if len(gm.until) == 0 {
+        return false
+    }
+
+    now := gm.clock.Now()
+    inMaintenance := false
+    for _, group := range deviceGroups.GroupsOf(deviceID) {
+        until, exists := gm.until[group]
+        if !exists {
+            continue
+        }
+        if now.After(until) {
+            delete(gm.until, group)
+            log.Printf("🧰 MANTENIMIENTO: Ventana de mantenimiento del grupo %s expirada", group)
+            continue
+        }
+        inMaintenance = true
+    }
+    return inMaintenance
+}
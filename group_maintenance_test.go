@@ -0,0 +1,107 @@
+package main
+
+import (
+    "context"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+    "time"
+)
+
+func TestGroupMaintenance_InMaintenance(t *testing.T) {
+    tests := []struct {
+        name     string
+        deviceID string
+        advance  time.Duration
+        want     bool
+    }{
+        {name: "dentro de la ventana", deviceID: "sensor-1", advance: time.Hour, want: true},
+        {name: "ventana expirada", deviceID: "sensor-1", advance: 2*time.Hour + time.Second},
+        {name: "fuera del grupo", deviceID: "sensor-2", advance: time.Minute},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            clock := setupTestHub(t)
+            withDeviceGroups(t, DeviceGroups{"planta-1": {"sensor-1"}})
+
+            until := groupMaintenance.Start("planta-1", 2*time.Hour)
+            if want := testEpoch.Add(2 * time.Hour); !until.Equal(want) {
+                t.Errorf("fin del mantenimiento = %v, se esperaba %v", until, want)
+            }
+
+            clock.Advance(tt.advance)
+            if got := groupMaintenance.InMaintenance(tt.deviceID); got != tt.want {
+                t.Errorf("InMaintenance = %v, se esperaba %v", got, tt.want)
+            }
+        })
+    }
+}
+
+func TestProcessSensorData_GroupMaintenanceSuppression(t *testing.T) {
+    tests := []struct {
+        name string
+        path string
+        // Petición sin el token de API
+        anonymous  bool
+        wantStatus int
+        // Dispositivos cuyas anomalías deben quedar suprimidas
+        suppressed map[string]bool
+    }{
+        {name: "mantenimiento de planta-1", path: "/groups/planta-1/maintenance", wantStatus: http.StatusOK, suppressed: map[string]bool{"sensor-1": true, "sensor-2": true}},
+        {name: "mantenimiento de planta-2", path: "/groups/planta-2/maintenance", wantStatus: http.StatusOK, suppressed: map[string]bool{"sensor-3": true}},
+        {name: "ventana ya expirada", path: "/groups/planta-1/maintenance?window=1s", wantStatus: http.StatusOK},
+        {name: "grupo desconocido", path: "/groups/sotano/maintenance", wantStatus: http.StatusNotFound},
+        {name: "sin token", path: "/groups/planta-1/maintenance", anonymous: true, wantStatus: http.StatusUnauthorized},
+    }
+
+    devices := []string{"sensor-1", "sensor-2", "sensor-3"}
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            clock := setupTestHub(t)
+            withDeviceGroups(t, DeviceGroups{"planta-1": {"sensor-1", "sensor-2"}, "planta-2": {"sensor-3"}})
+            notifier := newRecordingNotifier("test")
+            notificationManager.Register(notifier)
+            withAPIToken(t)
+
+            request := newAuthorizedRequest(http.MethodPost, tt.path, nil)
+            if tt.anonymous {
+                request = httptest.NewRequest(http.MethodPost, tt.path, nil)
+            }
+            recorder := httptest.NewRecorder()
+            newAPIRouter().ServeHTTP(recorder, request)
+            if recorder.Code != tt.wantStatus {
+                t.Fatalf("estado = %d, se esperaba %d", recorder.Code, tt.wantStatus)
+            }
+
+            // Lecturas anómalas de todos los dispositivos, suficientes para la quarantine
+            for i := 0; i < 5; i++ {
+                clock.Advance(time.Second)
+                for _, deviceID := range devices {
+                    data := SensorData{DeviceID: deviceID, Timestamp: clock.Now().Unix(), Temperature: 95, Humidity: 10, BatteryLevel: 80}
+                    processSensorData(context.Background(), data, nil, nil)
+                }
+            }
+            flushNotifications(t)
+
+            notified := make(map[string]bool)
+            for _, anomaly := range notifier.Anomalies() {
+                notified[anomaly.DeviceID] = true
+            }
+            for _, deviceID := range devices {
+                suppressed := tt.suppressed[deviceID]
+                if got := quarantineSystem.IsQuarantined(deviceID); got == suppressed {
+                    t.Errorf("%s en quarantine = %v, se esperaba %v", deviceID, got, !suppressed)
+                }
+                if notified[deviceID] == suppressed {
+                    t.Errorf("%s notificado = %v, se esperaba %v", deviceID, notified[deviceID], !suppressed)
+                }
+                // Las anomalías se registran siempre
+                if len(anomalyRepository.ByDevice(deviceID, false)) == 0 {
+                    t.Errorf("%s: las anomalías durante el mantenimiento deben registrarse", deviceID)
+                }
+            }
+        })
+    }
+}
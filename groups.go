@@ -0,0 +1,91 @@
+package main
+
+import "time"
+
+// Grupos de dispositivos (edificio, planta, ...): nombre → IDs de dispositivo
+type DeviceGroups map[string][]string
+
+// Grupos activos
+var deviceGroups = DeviceGroups{}
+
+// Tiempo sin reportar tras el cual un dispositivo se considera offline
+var deviceOnlineWindow = 5 * time.Minute
+
+// Dispositivo con más anomalías del grupo
+type GroupOffender struct {
+    DeviceID  string `json:"device_id"`
+    Anomalies int    `json:"anomalies"`
+}
+
+// Estado agregado de un grupo de dispositivos
+type GroupSummary struct {
+    Group           string              `json:"group"`
+    TotalDevices    int                 `json:"total_devices"`
+    Online          int                 `json:"online"`
+    Offline         int                 `json:"offline"`
+    Quarantined     int                 `json:"quarantined"`
+    AnomaliesByType map[AnomalyType]int `json:"anomalies_by_type"`
+    WorstOffender   *GroupOffender      `json:"worst_offender,omitempty"`
+}
+
+// Grupos a los que pertenece un dispositivo
+func (g DeviceGroups) GroupsOf(deviceID string) []string {
+    groups := make([]string, 0)
+    for group, members := range g {
+        for _, member := range members {
+            if member == deviceID {
+                groups = append(groups, group)
+                break
+            }
+        }
+    }
+    return groups
+}
+
+// Resumen de un grupo (false si no existe)
+func summarizeGroup(group string, qs *QuarantineSystem, repository *AnomalyRepository) (*GroupSummary, bool) {
+    members, exists := deviceGroups[group]
+    if !exists {
+        return nil, false
+    }
+
+    summary := &GroupSummary{
+        Group:           group,
+        TotalDevices:    len(members),
+        AnomaliesByType: make(map[AnomalyType]int),
+    }
+
+    now := qs.Now()
+    for _, deviceID := range members {
+        if lastSeen, seen := qs.LastSeen(deviceID); seen && now.Sub(lastSeen) <= deviceOnlineWindow {
+            summary.Online++
+        } else {
+            summary.Offline++
+        }
+        if qs.IsQuarantined(deviceID) {
+            summary.Quarantined++
+        }
+
+        anomalies := repository.ByDevice(deviceID, false)
+        for _, anomaly := range anomalies {
+            summary.AnomaliesByType[anomaly.Type]++
+        }
+        if len(anomalies) > 0 && (summary.WorstOffender == nil || len(anomalies) > summary.WorstOffender.Anomalies) {
+            summary.WorstOffender = &GroupOffender{DeviceID: deviceID, Anomalies: len(anomalies)}
+        }
+    }
+
+    return summary, true
+}
+
+// Último mensaje procesado de un dispositivo (false si nunca se ha visto)
+func (qs *QuarantineSystem) LastSeen(deviceID string) (time.Time, bool) {
+    qs.mutex.RLock()
+    defer qs.mutex.RUnlock()
+
+    behavior, exists := qs.deviceBehavior[deviceID]
+    if !exists || behavior.LastSeen.IsZero() {
+        return time.Time{}, false
+    }
+    return behavior.LastSeen, true
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "reflect"
+    "testing"
+    "time"
+)
+
+// Sustituir los grupos de dispositivos durante el test
+func withDeviceGroups(t *testing.T, groups DeviceGroups) {
+    t.Helper()
+
+    saved := deviceGroups
+    t.Cleanup(func() { deviceGroups = saved })
+    deviceGroups = groups
+}
+
+func TestHandleGroupSummary(t *testing.T) {
+    tests := []struct {
+        name       string
+        group      string
+        wantStatus int
+        want       GroupSummary
+    }{
+        {
+            name:       "planta con actividad",
+            group:      "planta-1",
+            wantStatus: http.StatusOK,
+            want: GroupSummary{
+                Group:           "planta-1",
+                TotalDevices:    3,
+                Online:          1,
+                Offline:         2,
+                Quarantined:     1,
+                AnomaliesByType: map[AnomalyType]int{AnomalyTemperature: 2, AnomalyBattery: 1},
+                WorstOffender:   &GroupOffender{DeviceID: "sensor-1", Anomalies: 2},
+            },
+        },
+        {
+            name:       "planta sin anomalías",
+            group:      "planta-2",
+            wantStatus: http.StatusOK,
+            want: GroupSummary{
+                Group:           "planta-2",
+                TotalDevices:    1,
+                Online:          1,
+                AnomaliesByType: map[AnomalyType]int{},
+            },
+        },
+        {name: "grupo desconocido", group: "sotano", wantStatus: http.StatusNotFound},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            clock := setupTestHub(t)
+            withDeviceGroups(t, DeviceGroups{
+                "planta-1": {"sensor-1", "sensor-2", "sensor-3"},
+                "planta-2": {"sensor-4"},
+            })
+
+            // sensor-2 dejó de reportar hace tiempo; sensor-3 nunca lo hizo
+            quarantineSystem.AnalyzeDeviceBehavior(&SensorData{DeviceID: "sensor-2", Temperature: 21, Humidity: 40, BatteryLevel: 80})
+            clock.Advance(deviceOnlineWindow + time.Minute)
+            quarantineSystem.AnalyzeDeviceBehavior(&SensorData{DeviceID: "sensor-1", Temperature: 21, Humidity: 40, BatteryLevel: 80})
+            quarantineSystem.AnalyzeDeviceBehavior(&SensorData{DeviceID: "sensor-4", Temperature: 21, Humidity: 40, BatteryLevel: 80})
+            quarantineSystem.QuarantineDeviceWithReason("sensor-1", QuarantineReasonBehaviorAnomaly, "test")
+            anomalyRepository.Save([]Anomaly{
+                {DeviceID: "sensor-1", Type: AnomalyTemperature, Severity: SEVERITY_HIGH},
+                {DeviceID: "sensor-1", Type: AnomalyTemperature, Severity: SEVERITY_HIGH},
+                {DeviceID: "sensor-2", Type: AnomalyBattery, Severity: SEVERITY_LOW},
+                {DeviceID: "sensor-9", Type: AnomalySignal, Severity: SEVERITY_LOW},
+            })
+
+            recorder := httptest.NewRecorder()
+            newAPIRouter().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/groups/"+tt.group+"/summary", nil))
+            if recorder.Code != tt.wantStatus {
+                t.Fatalf("estado = %d, se esperaba %d", recorder.Code, tt.wantStatus)
+            }
+            if tt.wantStatus != http.StatusOK {
+                return
+            }
+
+            var got GroupSummary
+            if err := json.Unmarshal(recorder.Body.Bytes(), &got); err != nil {
+                t.Fatalf("respuesta no válida: %v", err)
+            }
+            if !reflect.DeepEqual(got, tt.want) {
+                t.Errorf("resumen = %+v, se esperaba %+v", got, tt.want)
+            }
+        })
+    }
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+    "net/http"
+    "sync"
+    "time"
+)
+
+// Conexión cuyo estado se reporta en /healthz (el mqtt.Client de paho la
+// cumple). Se usa IsConnectionOpen y no IsConnected: con auto-reconnect paho
+// sigue diciendo "conectado" mientras reintenta, aunque no haya conexión.
+type BrokerConnection interface {
+    IsConnectionOpen() bool
+}
+
+// Momento de arranque del proceso, para el uptime
+var startedAt = time.Now()
+
+var (
+    brokerMutex      sync.RWMutex
+    brokerConnection BrokerConnection
+)
+
+// Registrar la conexión al broker que reporta /healthz
+func setBrokerConnection(connection BrokerConnection) {
+    brokerMutex.Lock()
+    defer brokerMutex.Unlock()
+
+    brokerConnection = connection
+}
+
+// Si hay conexión con el broker (false mientras no se ha creado el cliente)
+func brokerConnected() bool {
+    brokerMutex.RLock()
+    defer brokerMutex.RUnlock()
+
+    return brokerConnection != nil && brokerConnection.IsConnectionOpen()
+}
+
+// Estado de salud del hub
+type HealthStatus struct {
+    Status        string  `json:"status"`
+    MQTTConnected bool    `json:"mqtt_connected"`
+    UptimeSeconds float64 `json:"uptime_seconds"`
+}
+
+// GET /healthz: 200 con el broker conectado, 503 si no (para liveness/readiness)
+func handleHealthz(w http.ResponseWriter, r *http.Request) {
+    health := HealthStatus{
+        Status:        "ok",
+        MQTTConnected: brokerConnected(),
+        UptimeSeconds: time.Since(startedAt).Seconds(),
+    }
+
+    status := http.StatusOK
+    if !health.MQTTConnected {
+        health.Status = "unavailable"
+        status = http.StatusServiceUnavailable
+    }
+    writeJSON(w, status, health)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+)
+
+// Conexión con el estado que indique el test
+type fakeBrokerConnection struct {
+    open bool
+}
+
+func (fb *fakeBrokerConnection) IsConnectionOpen() bool {
+    return fb.open
+}
+
+func TestHandleHealthz(t *testing.T) {
+    tests := []struct {
+        name          string
+        connection    BrokerConnection
+        wantStatus    int
+        wantConnected bool
+    }{
+        {name: "conectado", connection: &fakeBrokerConnection{open: true}, wantStatus: http.StatusOK, wantConnected: true},
+        {name: "reconectando", connection: &fakeBrokerConnection{open: false}, wantStatus: http.StatusServiceUnavailable},
+        {name: "sin cliente", connection: nil, wantStatus: http.StatusServiceUnavailable},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            setBrokerConnection(tt.connection)
+            t.Cleanup(func() { setBrokerConnection(nil) })
+
+            recorder := httptest.NewRecorder()
+            handleHealthz(recorder, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+            if recorder.Code != tt.wantStatus {
+                t.Errorf("estado HTTP = %d, se esperaba %d", recorder.Code, tt.wantStatus)
+            }
+            var health HealthStatus
+            if err := json.NewDecoder(recorder.Body).Decode(&health); err != nil {
+                t.Fatalf("respuesta no es JSON: %v", err)
+            }
+            if health.MQTTConnected != tt.wantConnected {
+                t.Errorf("mqtt_connected = %v, se esperaba %v", health.MQTTConnected, tt.wantConnected)
+            }
+        })
+    }
+}
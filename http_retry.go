@@ -0,0 +1,55 @@
+package main
+
+import (
+    "context"
+    "fmt"
+    "log"
+    "net/http"
+    "time"
+)
+
+// Reintentos de los envíos HTTP de notificaciones ante errores de red o 5xx.
+// Los 4xx no se reintentan: el mensaje o la configuración son incorrectos.
+var (
+    notificationMaxRetries   = 3
+    notificationRetryBackoff = 500 * time.Millisecond
+)
+
+// Enviar una petición reintentando con backoff exponencial (backoff, 2×backoff,
+// 4×backoff...). newRequest se llama en cada intento porque el cuerpo se consume.
+func sendWithRetry(ctx context.Context, httpClient *http.Client, service string, newRequest func(ctx context.Context) (*http.Request, error)) error {
+    backoff := notificationRetryBackoff
+    for attempt := 0; ; attempt++ {
+        retryable, err := sendOnce(ctx, httpClient, service, newRequest)
+        if err == nil || !retryable || attempt >= notificationMaxRetries {
+            return err
+        }
+
+        log.Printf("🔁 NOTIFICACIONES: Reintentando %s en %v (intento %d de %d): %v", service, backoff, attempt+1, notificationMaxRetries, err)
+        select {
+        case <-time.After(backoff):
+        case <-ctx.Done():
+            return err
+        }
+        backoff *= 2
+    }
+}
+
+// Un intento de envío; indica si el error admite reintento
+func sendOnce(ctx context.Context, httpClient *http.Client, service string, newRequest func(ctx context.Context) (*http.Request, error)) (bool, error) {
+    req, err := newRequest(ctx)
+    if err != nil {
+        return false, err
+    }
+
+    resp, err := httpClient.Do(req)
+    if err != nil {
+        return ctx.Err() == nil, err
+    }
+    defer resp.Body.Close()
+
+    if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+        return resp.StatusCode >= 500, fmt.Errorf("%s respondió con estado %d", service, resp.StatusCode)
+    }
+    return false, nil
+}
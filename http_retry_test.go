@@ -0,0 +1,79 @@
+package main
+
+import (
+    "context"
+    "net/http"
+    "net/http/httptest"
+    "sync/atomic"
+    "testing"
+    "time"
+)
+
+func TestSendWithRetry(t *testing.T) {
+    tests := []struct {
+        name         string
+        statuses     []int // respuesta de cada intento; el último se repite
+        wantAttempts int32
+        wantErr      bool
+    }{
+        {name: "falla dos veces y luego funciona", statuses: []int{500, 503, 200}, wantAttempts: 3},
+        {name: "funciona a la primera", statuses: []int{204}, wantAttempts: 1},
+        {name: "4xx no se reintenta", statuses: []int{400}, wantAttempts: 1, wantErr: true},
+        {name: "5xx persistente agota los reintentos", statuses: []int{502}, wantAttempts: 4, wantErr: true},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            savedRetries, savedBackoff := notificationMaxRetries, notificationRetryBackoff
+            notificationMaxRetries, notificationRetryBackoff = 3, time.Millisecond
+            t.Cleanup(func() { notificationMaxRetries, notificationRetryBackoff = savedRetries, savedBackoff })
+
+            var attempts atomic.Int32
+            server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+                attempt := int(attempts.Add(1)) - 1
+                if attempt >= len(tt.statuses) {
+                    attempt = len(tt.statuses) - 1
+                }
+                w.WriteHeader(tt.statuses[attempt])
+            }))
+            defer server.Close()
+
+            err := sendWithRetry(context.Background(), server.Client(), "test", func(ctx context.Context) (*http.Request, error) {
+                return http.NewRequestWithContext(ctx, http.MethodPost, server.URL, nil)
+            })
+
+            if (err != nil) != tt.wantErr {
+                t.Errorf("sendWithRetry() error = %v, se esperaba error: %v", err, tt.wantErr)
+            }
+            if got := attempts.Load(); got != tt.wantAttempts {
+                t.Errorf("intentos = %d, se esperaban %d", got, tt.wantAttempts)
+            }
+        })
+    }
+}
+
+func TestSendWithRetry_StopsWhenContextExpires(t *testing.T) {
+    savedBackoff := notificationRetryBackoff
+    notificationRetryBackoff = time.Hour
+    t.Cleanup(func() { notificationRetryBackoff = savedBackoff })
+
+    var attempts atomic.Int32
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        attempts.Add(1)
+        w.WriteHeader(http.StatusServiceUnavailable)
+    }))
+    defer server.Close()
+
+    ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+    defer cancel()
+    err := sendWithRetry(ctx, server.Client(), "test", func(ctx context.Context) (*http.Request, error) {
+        return http.NewRequestWithContext(ctx, http.MethodPost, server.URL, nil)
+    })
+
+    if err == nil {
+        t.Fatal("se esperaba error al expirar el contexto")
+    }
+    if got := attempts.Load(); got != 1 {
+        t.Errorf("intentos = %d, el backoff no debe sobrevivir al contexto", got)
+    }
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+    "context"
+    "errors"
+    "io"
+    "net/http"
+)
+
+// Origen con el que se registran las lecturas recibidas por HTTP
+const HTTP_INGEST_SOURCE = "http/ingest"
+
+// POST /ingest: recibir lecturas por HTTP (dispositivos sin MQTT). Siguen
+// exactamente el mismo camino que los mensajes MQTT, incluidos los lotes.
+// Requiere el token de API: sin él cualquiera podría inyectar lecturas.
+func handleIngest(w http.ResponseWriter, r *http.Request) {
+    // Se lee un byte más del máximo para que el control de tamaño lo detecte
+    reader := io.Reader(r.Body)
+    if maxPayloadSize > 0 {
+        reader = io.LimitReader(r.Body, int64(maxPayloadSize)+1)
+    }
+    payload, err := io.ReadAll(reader)
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "error leyendo el cuerpo: "+err.Error())
+        return
+    }
+
+    results, err := ingestPayloadResults(r.Context(), HTTP_INGEST_SOURCE, payload)
+    if results != nil {
+        // Lote: 202 si se aceptan todas; si no, 207 con el resultado de cada
+        // lectura para que el dispositivo reenvíe solo las rechazadas
+        status := http.StatusAccepted
+        if err != nil {
+            status = http.StatusMultiStatus
+        }
+        writeJSON(w, status, map[string]interface{}{"results": results})
+        return
+    }
+    if err != nil {
+        writeError(w, ingestErrorStatus(err), err.Error())
+        return
+    }
+    writeJSON(w, http.StatusAccepted, map[string]string{"status": "accepted"})
+}
+
+// Código HTTP para el motivo de rechazo de una lectura
+func ingestErrorStatus(err error) int {
+    switch {
+    case errors.Is(err, ErrRateLimited):
+        return http.StatusTooManyRequests
+    case errors.Is(err, ErrPayloadTooLarge):
+        return http.StatusRequestEntityTooLarge
+    case errors.Is(err, ErrUnknownDevice), errors.Is(err, ErrQuarantined):
+        return http.StatusForbidden
+    case errors.Is(err, ErrDeviceLimit), errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+        return http.StatusServiceUnavailable
+    default:
+        return http.StatusBadRequest
+    }
+}
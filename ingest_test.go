@@ -0,0 +1,94 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "time"
+)
+
+// Lectura válida en el instante de los tests
+func validReadingJSON(deviceID string) string {
+    return fmt.Sprintf(`{"device_id":%q,"timestamp":%d,"temperature":21,"humidity":40,"battery_level":80}`, deviceID, testEpoch.Unix())
+}
+
+func TestHandleIngest(t *testing.T) {
+    const token = "secreto"
+
+    tests := []struct {
+        name         string
+        token        string
+        body         string
+        prepare      func(t *testing.T)
+        wantStatus   int
+        wantStatuses []int
+    }{
+        {name: "sin token", body: validReadingJSON("sensor-1"), wantStatus: http.StatusUnauthorized},
+        {name: "token incorrecto", token: "otro", body: validReadingJSON("sensor-1"), wantStatus: http.StatusUnauthorized},
+        {name: "aceptada", token: token, body: validReadingJSON("sensor-1"), wantStatus: http.StatusAccepted},
+        {name: "inválida", token: token, body: `{"device_id":"sensor-1","timestamp":1,"temperature":21}`, wantStatus: http.StatusBadRequest},
+        {
+            name:  "rate limit",
+            token: token,
+            body:  validReadingJSON("sensor-1"),
+            prepare: func(t *testing.T) {
+                limiter := NewFixedWindowRateLimiter(1, time.Minute)
+                limiter.SetClock(NewFakeClock(testEpoch))
+                quarantineSystem.SetRateLimiter(limiter)
+                quarantineSystem.CheckRateLimit("sensor-1")
+            },
+            wantStatus: http.StatusTooManyRequests,
+        },
+        {name: "lote aceptado", token: token, body: "[" + validReadingJSON("sensor-1") + "," + validReadingJSON("sensor-2") + "]", wantStatus: http.StatusAccepted, wantStatuses: []int{http.StatusAccepted, http.StatusAccepted}},
+        {
+            name:         "lote con una lectura inválida",
+            token:        token,
+            body:         "[" + validReadingJSON("sensor-1") + `,{"device_id":"sensor-2","timestamp":1},` + validReadingJSON("sensor-3") + "]",
+            wantStatus:   http.StatusMultiStatus,
+            wantStatuses: []int{http.StatusAccepted, http.StatusBadRequest, http.StatusAccepted},
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            setupTestHub(t)
+            savedToken := apiToken
+            apiToken = token
+            t.Cleanup(func() { apiToken = savedToken })
+            if tt.prepare != nil {
+                tt.prepare(t)
+            }
+
+            request := httptest.NewRequest(http.MethodPost, "/ingest", strings.NewReader(tt.body))
+            if tt.token != "" {
+                request.Header.Set("Authorization", "Bearer "+tt.token)
+            }
+            recorder := httptest.NewRecorder()
+            newAPIRouter().ServeHTTP(recorder, request)
+
+            if recorder.Code != tt.wantStatus {
+                t.Fatalf("estado HTTP = %d, se esperaba %d (%s)", recorder.Code, tt.wantStatus, recorder.Body.String())
+            }
+            if tt.wantStatuses == nil {
+                return
+            }
+            var response struct {
+                Results []BatchReadingResult `json:"results"`
+            }
+            if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+                t.Fatalf("respuesta no es JSON: %v", err)
+            }
+            if len(response.Results) != len(tt.wantStatuses) {
+                t.Fatalf("resultados = %d, se esperaban %d", len(response.Results), len(tt.wantStatuses))
+            }
+            for i, result := range response.Results {
+                if result.Index != i || result.Status != tt.wantStatuses[i] {
+                    t.Errorf("lectura %d: índice %d, estado %d, se esperaba estado %d", i, result.Index, result.Status, tt.wantStatuses[i])
+                }
+            }
+        })
+    }
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+    "encoding/json"
+    "io"
+    "os"
+    "strings"
+    "sync"
+    "time"
+)
+
+// Formatos de log soportados
+const (
+    LOG_FORMAT_TEXT = "text"
+    LOG_FORMAT_JSON = "json"
+)
+
+// Logger del hub. En modo "text" deja pasar los mensajes con emoji tal cual;
+// en modo "json" emite un objeto JSON por línea con level, msg, timestamp y
+// campos opcionales como device_id, apto para agregadores de logs.
+type Logger struct {
+    mutex  sync.Mutex
+    format string
+    out    io.Writer
+}
+
+var logger = NewLogger()
+
+// Crear logger en modo texto
+func NewLogger() *Logger {
+    return NewLoggerWithFormat(LOG_FORMAT_TEXT)
+}
+
+// Crear logger con el formato indicado ("text" o "json")
+func NewLoggerWithFormat(format string) *Logger {
+    if format != LOG_FORMAT_JSON {
+        format = LOG_FORMAT_TEXT
+    }
+    return &Logger{
+        format: format,
+        out:    os.Stderr,
+    }
+}
+
+// Formato activo
+func (l *Logger) Format() string {
+    return l.format
+}
+
+// Implementa io.Writer para usarse con log.SetOutput, de modo que los
+// log.Printf existentes también salen en JSON cuando corresponde
+func (l *Logger) Write(p []byte) (int, error) {
+    if l.format != LOG_FORMAT_JSON {
+        l.mutex.Lock()
+        defer l.mutex.Unlock()
+        return l.out.Write(p)
+    }
+
+    msg := strings.TrimRight(string(p), "\n")
+    if err := l.writeJSON(levelFromMessage(msg), msg, nil); err != nil {
+        return 0, err
+    }
+    return len(p), nil
+}
+
+// Log de nivel info con campos estructurados
+func (l *Logger) InfoWith(msg string, fields map[string]interface{}) {
+    l.logWith("info", msg, fields)
+}
+
+// Log de nivel warn con campos estructurados
+func (l *Logger) WarnWith(msg string, fields map[string]interface{}) {
+    l.logWith("warn", msg, fields)
+}
+
+// Log de nivel error con campos estructurados
+func (l *Logger) ErrorWith(msg string, fields map[string]interface{}) {
+    l.logWith("error", msg, fields)
+}
+
+func (l *Logger) logWith(level string, msg string, fields map[string]interface{}) {
+    if l.format == LOG_FORMAT_JSON {
+        l.writeJSON(level, msg, fields)
+        return
+    }
+
+    // En modo texto los campos se añaden como clave=valor
+    var sb strings.Builder
+    sb.WriteString(time.Now().Format("2006/01/02 15:04:05 "))
+    sb.WriteString(msg)
+    for key, value := range fields {
+        sb.WriteString(" ")
+        sb.WriteString(key)
+        sb.WriteString("=")
+        encoded, _ := json.Marshal(value)
+        sb.Write(encoded)
+    }
+    sb.WriteString("\n")
+
+    l.mutex.Lock()
+    defer l.mutex.Unlock()
+    l.out.Write([]byte(sb.String()))
+}
+
+// Escribir una línea JSON
+func (l *Logger) writeJSON(level string, msg string, fields map[string]interface{}) error {
+    entry := make(map[string]interface{}, len(fields)+3)
+    for key, value := range fields {
+        entry[key] = value
+    }
+    entry["level"] = level
+    entry["msg"] = msg
+    entry["timestamp"] = time.Now().UTC().Format(time.RFC3339Nano)
+
+    line, err := json.Marshal(entry)
+    if err != nil {
+        return err
+    }
+
+    l.mutex.Lock()
+    defer l.mutex.Unlock()
+    _, err = l.out.Write(append(line, '\n'))
+    return err
+}
+
+// Deducir el nivel a partir del emoji con el que empiezan los mensajes del hub
+func levelFromMessage(msg string) string {
+    switch {
+    case strings.HasPrefix(msg, "❌"):
+        return "error"
+    case strings.HasPrefix(msg, "⚠️"), strings.HasPrefix(msg, "🚨"), strings.HasPrefix(msg, "🚫"):
+        return "warn"
+    case strings.HasPrefix(msg, "🔍 DEBUG"):
+        return "debug"
+    default:
+        return "info"
+    }
+}
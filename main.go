@@ -1,11 +1,17 @@
 package main
 
 import (
-    "encoding/json"
+    "context"
+    "errors"
+    "flag"
     "fmt"
     "log"
+    "math"
     "os"
+    "os/signal"
+    "strings"
     "sync"
+    "syscall"
     "time"
     
     mqtt "github.com/eclipse/paho.mqtt.golang"
@@ -14,88 +20,215 @@ import (
 
 // Estructura de los datos del sensor
 type SensorData struct {
-    DeviceID       string  `json:"device_id"`
-    DeviceType     string  `json:"device_type,omitempty"`
-    Timestamp      int64   `json:"timestamp"`
-    SecurityLevel  string  `json:"security_level,omitempty"`
-    Temperature    float64 `json:"temperature,omitempty"`
-    Humidity       float64 `json:"humidity,omitempty"`
-    MotionDetected *bool   `json:"motion_detected,omitempty"`
-    Recording      *bool   `json:"recording,omitempty"`
-    BatteryLevel   float64 `json:"battery_level,omitempty"`
-    Locked         *bool   `json:"locked,omitempty"`
-    AccessAttempts int     `json:"access_attempts,omitempty"`
-    SignalStrength float64 `json:"signal_strength,omitempty"`
-}
-
-// Rate limiting por dispositivo
-type DeviceRateLimit struct {
-    Count     int
-    LastReset time.Time
-    Blocked   bool
+    DeviceID             string  `json:"device_id"`
+    DeviceType           string  `json:"device_type,omitempty"`
+    Timestamp            int64   `json:"timestamp"`
+    SecurityLevel        string  `json:"security_level,omitempty"`
+    Temperature          float64 `json:"temperature,omitempty"`
+    Humidity             float64 `json:"humidity,omitempty"`
+    MotionDetected       *bool   `json:"motion_detected,omitempty"`
+    Recording            *bool   `json:"recording,omitempty"`
+    BatteryLevel         float64 `json:"battery_level,omitempty"`
+    Locked               *bool   `json:"locked,omitempty"`
+    AccessAttempts       int     `json:"access_attempts,omitempty"`
+    FailedAccessAttempts int     `json:"failed_access_attempts,omitempty"`
+    SignalStrength       float64 `json:"signal_strength,omitempty"`
+    MessageType          string  `json:"message_type,omitempty"`
+    Reason               string  `json:"reason,omitempty"`
+    Signature            string  `json:"signature,omitempty"`
+    FirmwareUpdating     *bool   `json:"firmware_updating,omitempty"`
+    // Diagnósticos internos (códigos de error, autotest)
+    Diagnostics map[string]string `json:"diagnostics,omitempty"`
+    // Identificador generado por el dispositivo para deduplicar retransmisiones
+    MessageID string `json:"message_id,omitempty"`
+    // Etiquetas del dispositivo (site, location, owner...)
+    Metadata map[string]string `json:"metadata,omitempty"`
+
+    // Campos presentes en el payload decodificado (nil si no viene de un payload)
+    present map[string]bool
+    // Instante de procesamiento según el reloj del hub (fecha las anomalías)
+    receivedAt time.Time
 }
 
 // Historial de comportamiento del dispositivo
 type DeviceBehavior struct {
+    FirstSeen      time.Time
     LastSeen       time.Time
     MessageCount   int
     AvgTemperature float64
     AvgBattery     float64
+    AvgHumidity    float64
     AccessAttempts []int
     AnomalyCount   int
+    MetricHistory  map[string][]float64
+    // Lecturas incluidas en cada promedio
+    TemperatureSamples int
+    BatterySamples     int
+    HumiditySamples    int
+    // Reincidencias de quarantine y fin de la última
+    QuarantineCount   int
+    LastQuarantineEnd time.Time
+    // Totales históricos para la reputación del dispositivo
+    AnomalousMessages int
+    TotalAnomalies    int
+    TotalQuarantines  int
+    // Líneas base de calibración por métrica
+    Calibration map[string]*CalibrationBaseline
+    // Mensajes rechazados y actividad de la última hora
+    RejectedMessages int
+    RecentActivity   [DEVICE_ACTIVITY_WINDOW_MINUTES]activityBucket
+    // Tamaño habitual del payload
+    AvgPayloadSize float64
+    PayloadSamples int
+    // Último timestamp procesado y huellas de los payloads aceptados con él
+    LastTimestamp        int64
+    LastTimestampDigests []uint64
+    // Último diagnóstico reportado por el dispositivo
+    Diagnostics   map[string]string
+    DiagnosticsAt time.Time
+    // Fin del periodo de prueba tras la última liberación
+    ProbationUntil time.Time
+    // Tipos de dispositivo reportados; el primero es el asignado
+    DeviceTypes []string
+    // Últimas lecturas crudas (acotadas a deviceHistorySize)
+    RecentReadings readingRing
+    // Media y varianza recientes de las temperaturas del dispositivo
+    TemperatureStats RunningStats
+    // Etiquetas reportadas en las lecturas y fijadas vía API por el operador
+    Metadata         map[string]string
+    OperatorMetadata map[string]string
+    // Último nivel de seguridad declarado
+    SecurityLevel string
+    // Anomalías recientes que cuentan para la quarantine por tasa
+    RateAnomalies []time.Time
+    // Alta marcada como sospechosa (solo se marca una vez)
+    ProvisioningFlagged bool
+}
+
+// Entrada de quarantine de un dispositivo
+type QuarantineEntry struct {
+    Since    time.Time        `json:"since"`
+    Duration time.Duration    `json:"duration"`
+    Reason   QuarantineReason `json:"reason"`
+    Detail   string           `json:"detail,omitempty"`
+}
+
+// Verificar si la quarantine ha expirado
+func (qe *QuarantineEntry) Expired(now time.Time) bool {
+    return now.Sub(qe.Since) > qe.Duration
 }
 
 // Sistema de quarantine
 type QuarantineSystem struct {
     mutex              sync.RWMutex
-    quarantinedDevices map[string]time.Time
-    rateLimits         map[string]*DeviceRateLimit
+    quarantinedDevices map[string]*QuarantineEntry
+    rateLimiter        RateLimiter
     deviceBehavior     map[string]*DeviceBehavior
+    commandPublisher   CommandPublisher
+    updatingDevices    map[string]time.Time
+    repository         DeviceRepository
+    releases           []QuarantineRelease
+    clock              Clock
+    // Canales fijados vía API por dispositivo. Es configuración del operador,
+    // no estado del dispositivo: sobrevive a la purga y al desplazamiento.
+    notificationRouting map[string][]string
+    // Resúmenes de dispositivo pendientes de guardar en el repositorio
+    deviceSaves deviceSaveQueue
+    // Dispositivos por última actividad, para desplazar sin recorrerlos todos
+    deviceRecency deviceRecency
 }
 
 // Configuración del sistema
 const (
     MAX_MESSAGES_PER_MINUTE = 20
+    RATE_LIMIT_BURST        = 10
     QUARANTINE_DURATION     = 5 * time.Minute
     ANOMALY_THRESHOLD       = 3
 )
 
+// Rate limiting configurable (por defecto MAX_MESSAGES_PER_MINUTE por minuto).
+// Es el único limitador del hub: el token bucket de QuarantineSystem se crea
+// con estos valores, así que la configuración es siempre la que se aplica.
+var (
+    rateLimitMaxMessages = MAX_MESSAGES_PER_MINUTE
+    rateLimitWindow      = 1 * time.Minute
+    rateLimitBurst       = RATE_LIMIT_BURST
+)
+
+// Parámetros de validación de los datos del sensor
+type ValidationConfig struct {
+    // Desfase máximo del reloj del dispositivo respecto al hub
+    ClockSkewTolerance time.Duration
+    // Reloj del hub con el que se compara el timestamp
+    Clock Clock
+}
+
+// Validación por defecto: ±1 hora de desfase de reloj
+func DefaultValidationConfig() ValidationConfig {
+    return ValidationConfig{
+        ClockSkewTolerance: 1 * time.Hour,
+        Clock:              RealClock{},
+    }
+}
+
+// Validación activa (configurable)
+var validationConfig = DefaultValidationConfig()
+
 // Función para validar los datos del sensor
 func validateSensorData(data *SensorData) error {
+    return validateSensorDataWith(data, validationConfig)
+}
+
+// Validar los datos del sensor con unos parámetros concretos
+func validateSensorDataWith(data *SensorData, cfg ValidationConfig) error {
     // Validar DeviceID
     if data.DeviceID == "" || len(data.DeviceID) > 50 {
         return fmt.Errorf("device_id inválido: debe tener entre 1-50 caracteres")
     }
+    if err := validateTopicDeviceID(data.DeviceID); err != nil {
+        return err
+    }
     
-    // Validar timestamp (no más de 1 hora en el futuro o pasado)
-    now := time.Now().Unix()
-    if data.Timestamp < now-3600 || data.Timestamp > now+3600 {
-        return fmt.Errorf("timestamp inválido: %d fuera del rango permitido", data.Timestamp)
+    // Validar timestamp (desfase de reloj dentro de la tolerancia)
+    now := cfg.Clock.Now().Unix()
+    tolerance := int64(cfg.ClockSkewTolerance / time.Second)
+    if data.Timestamp < now-tolerance || data.Timestamp > now+tolerance {
+        return fmt.Errorf("timestamp inválido: %d fuera del rango permitido (±%v)", data.Timestamp, cfg.ClockSkewTolerance)
+    }
+    
+    // NaN e Inf pasan cualquier comparación de rango: rechazarlos explícitamente
+    if err := data.checkFiniteReadings(); err != nil {
+        return err
+    }
+    
+    // Validar nivel de seguridad si está presente
+    if err := validateSecurityLevel(data.SecurityLevel); err != nil {
+        return err
     }
     
     // Validar temperatura si está presente
-    if data.Temperature != 0 {
+    if data.hasReading("temperature", data.Temperature) {
         if data.Temperature < -50 || data.Temperature > 100 {
             return fmt.Errorf("temperatura inválida: %.2f fuera del rango -50°C a 100°C", data.Temperature)
         }
     }
     
     // Validar humedad si está presente
-    if data.Humidity != 0 {
+    if data.hasReading("humidity", data.Humidity) {
         if data.Humidity < 0 || data.Humidity > 100 {
             return fmt.Errorf("humedad inválida: %.2f fuera del rango 0-100%%", data.Humidity)
         }
     }
     
     // Validar nivel de batería si está presente
-    if data.BatteryLevel != 0 {
+    if data.hasReading("battery_level", data.BatteryLevel) {
         if data.BatteryLevel < 0 || data.BatteryLevel > 100 {
             return fmt.Errorf("nivel de batería inválido: %.2f fuera del rango 0-100%%", data.BatteryLevel)
         }
     }
     
     // Validar intensidad de señal si está presente
-    if data.SignalStrength != 0 {
+    if data.hasReading("signal_strength", data.SignalStrength) {
         if data.SignalStrength < 0 || data.SignalStrength > 100 {
             return fmt.Errorf("intensidad de señal inválida: %.2f fuera del rango 0-100%%", data.SignalStrength)
         }
@@ -106,180 +239,353 @@ func validateSensorData(data *SensorData) error {
         return fmt.Errorf("intentos de acceso inválidos: %d fuera del rango 0-1000", data.AccessAttempts)
     }
     
+    // Los intentos fallidos son un subconjunto de los intentos totales
+    if data.FailedAccessAttempts < 0 || data.FailedAccessAttempts > data.AccessAttempts {
+        return fmt.Errorf("intentos de acceso fallidos inválidos: %d (intentos totales: %d)", data.FailedAccessAttempts, data.AccessAttempts)
+    }
+    
     return nil
 }
 
-// Función básica de detección de anomalías
-func detectAnomalies(data *SensorData) string {
-    var anomalies []string
-    
-    // Detectar temperaturas anómalas
-    if data.Temperature != 0 {
-        if data.Temperature > 50 || data.Temperature < -10 {
-            anomalies = append(anomalies, fmt.Sprintf("temperatura extrema: %.2f°C", data.Temperature))
-        }
-    }
-    
-    // Detectar batería crítica
-    if data.BatteryLevel > 0 && data.BatteryLevel < 10 {
-        anomalies = append(anomalies, fmt.Sprintf("batería crítica: %.1f%%", data.BatteryLevel))
-    }
-    
-    // Detectar múltiples intentos de acceso (posible ataque)
-    if data.AccessAttempts > 5 {
-        anomalies = append(anomalies, fmt.Sprintf("múltiples intentos de acceso: %d", data.AccessAttempts))
-    }
-    
-    // Detectar señal muy débil (posible jamming)
-    if data.SignalStrength > 0 && data.SignalStrength < 20 {
-        anomalies = append(anomalies, fmt.Sprintf("señal débil: %.1f%%", data.SignalStrength))
-    }
-    
-    if len(anomalies) > 0 {
-        return fmt.Sprintf("%v", anomalies)
+// Umbrales de detección de anomalías básicas
+type AnomalyThresholds struct {
+    TemperatureMax    float64 `json:"temperature_max"`
+    TemperatureMin    float64 `json:"temperature_min"`
+    BatteryCritical   float64 `json:"battery_critical"`
+    AccessAttemptsMax int     `json:"access_attempts_max"`
+    SignalWeak        float64 `json:"signal_weak"`
+    HumidityMax       float64 `json:"humidity_max"`
+    HumidityMin       float64 `json:"humidity_min"`
+    // Proporción de intentos de acceso fallidos (solo dispositivos que la reportan)
+    AccessFailureRatioMax    float64 `json:"access_failure_ratio_max"`
+    AccessFailureMinAttempts int     `json:"access_failure_min_attempts"`
+    // Punto de rocío máximo verosímil en °C (0 = sin comprobar)
+    DewPointMax float64 `json:"dew_point_max"`
+}
+
+// Umbrales por defecto
+func DefaultAnomalyThresholds() AnomalyThresholds {
+    return AnomalyThresholds{
+        TemperatureMax:           50,
+        TemperatureMin:           -10,
+        BatteryCritical:          10,
+        AccessAttemptsMax:        5,
+        SignalWeak:               20,
+        HumidityMax:              85,
+        HumidityMin:              15,
+        AccessFailureRatioMax:    0.5,
+        AccessFailureMinAttempts: 3,
+        DewPointMax:              32,
     }
-    return ""
+}
+
+// Umbrales activos
+var anomalyThresholds = DefaultAnomalyThresholds()
+
+// Función básica de detección de anomalías (según el perfil del tipo de dispositivo)
+func detectAnomalies(data *SensorData) []Anomaly {
+    return detectAnomaliesWith(data, deviceProfiles.ThresholdsFor(data.DeviceType, anomalyThresholds))
+}
+
+// Detectar anomalías básicas con un conjunto de umbrales concreto
+func detectAnomaliesWith(data *SensorData, thresholds AnomalyThresholds) []Anomaly {
+    return applySecurityLevel(data, ruleEngine.Evaluate(data, thresholds))
 }
 
 var quarantineSystem *QuarantineSystem
 
+// Evitar alertas y comandos duplicados al volver a poner en quarantine un dispositivo
+var deduplicateQuarantine = true
+
+// Modo simulación: se detectan y registran anomalías, pero no se pone ningún
+// dispositivo en quarantine y las notificaciones solo se registran en el log
+// (implica dryRunNotifications)
+var dryRunProcessing = false
+
 // Inicializar sistema de quarantine
 func NewQuarantineSystem() *QuarantineSystem {
     return &QuarantineSystem{
-        quarantinedDevices: make(map[string]time.Time),
-        rateLimits:         make(map[string]*DeviceRateLimit),
-        deviceBehavior:     make(map[string]*DeviceBehavior),
+        quarantinedDevices:  make(map[string]*QuarantineEntry),
+        rateLimiter:         newRateLimiter(),
+        deviceBehavior:      make(map[string]*DeviceBehavior),
+        commandPublisher:    NoopCommandPublisher{},
+        updatingDevices:     make(map[string]time.Time),
+        clock:               RealClock{},
+        notificationRouting: make(map[string][]string),
     }
 }
 
-// Rate limiting: verificar si dispositivo puede enviar mensaje
-func (qs *QuarantineSystem) CheckRateLimit(deviceID string) bool {
+// Cambiar el reloj del sistema de quarantine y de su rate limiter
+func (qs *QuarantineSystem) SetClock(clock Clock) {
+    qs.mutex.Lock()
+    qs.clock = clock
+    qs.mutex.Unlock()
+
+    qs.rateLimiter.SetClock(clock)
+}
+
+// Hora actual según el reloj del sistema de quarantine
+func (qs *QuarantineSystem) Now() time.Time {
+    qs.mutex.RLock()
+    defer qs.mutex.RUnlock()
+
+    return qs.clock.Now()
+}
+
+// Configurar el canal por el que se envían comandos a los dispositivos
+func (qs *QuarantineSystem) SetCommandPublisher(publisher CommandPublisher) {
     qs.mutex.Lock()
     defer qs.mutex.Unlock()
     
-    now := time.Now()
-    
-    // Obtener o crear rate limit para el dispositivo
-    if qs.rateLimits[deviceID] == nil {
-        qs.rateLimits[deviceID] = &DeviceRateLimit{
-            Count:     0,
-            LastReset: now,
-            Blocked:   false,
-        }
-    }
-    
-    rateLimitInfo := qs.rateLimits[deviceID]
-    
-    // Reset contador cada minuto
-    if now.Sub(rateLimitInfo.LastReset) >= time.Minute {
-        rateLimitInfo.Count = 0
-        rateLimitInfo.LastReset = now
-        rateLimitInfo.Blocked = false
-    }
+    qs.commandPublisher = publisher
+}
+
+// Sustituir el rate limiter (p. ej. por uno compartido entre instancias)
+func (qs *QuarantineSystem) SetRateLimiter(rateLimiter RateLimiter) {
+    qs.mutex.Lock()
+    defer qs.mutex.Unlock()
     
-    // Verificar límite
-    if rateLimitInfo.Count >= MAX_MESSAGES_PER_MINUTE {
-        rateLimitInfo.Blocked = true
-        log.Printf("🚫 RATE LIMIT: Dispositivo %s bloqueado por exceder %d mensajes/min", deviceID, MAX_MESSAGES_PER_MINUTE)
+    qs.rateLimiter = rateLimiter
+}
+
+// Rate limiting: verificar si dispositivo puede enviar mensaje
+func (qs *QuarantineSystem) CheckRateLimit(deviceID string) bool {
+    // Token bucket: se toleran ráfagas de rateLimitBurst mensajes,
+    // pero el ritmo sostenido no puede superar rateLimitMaxMessages por ventana
+    if !qs.rateLimiter.IsAllowed(deviceID) {
+        log.Printf("🚫 RATE LIMIT: Dispositivo %s bloqueado por exceder %d mensajes/%v (ráfaga máx. %d)", deviceID, rateLimitMaxMessages, rateLimitWindow, rateLimitBurst)
         return false
     }
     
-    rateLimitInfo.Count++
     return true
 }
 
 // Verificar si dispositivo está en quarantine
 func (qs *QuarantineSystem) IsQuarantined(deviceID string) bool {
     qs.mutex.RLock()
-    quarantineTime, exists := qs.quarantinedDevices[deviceID]
+    entry, exists := qs.quarantinedDevices[deviceID]
+    expired := exists && entry.Expired(qs.clock.Now())
     qs.mutex.RUnlock()
     
     if !exists {
-        return false
+        return qs.isQuarantinedElsewhere(deviceID)
     }
     
     // Verificar si el quarantine ha expirado
-    if time.Since(quarantineTime) > QUARANTINE_DURATION {
+    if expired {
         qs.mutex.Lock()
         // Verificar nuevamente por si otro goroutine ya lo eliminó
-        if quarantineTime, exists := qs.quarantinedDevices[deviceID]; exists {
-            if time.Since(quarantineTime) > QUARANTINE_DURATION {
-                delete(qs.quarantinedDevices, deviceID)
-                log.Printf("✅ QUARANTINE: Dispositivo %s liberado después de %v", deviceID, QUARANTINE_DURATION)
+        if entry, exists := qs.quarantinedDevices[deviceID]; exists {
+            now := qs.clock.Now()
+            if entry.Expired(now) {
+                release := qs.releaseLocked(deviceID, entry, ReleaseReasonExpired, "", now)
                 qs.mutex.Unlock()
-                return false
+                announceRelease(release)
+                return qs.isQuarantinedElsewhere(deviceID)
             }
         }
         qs.mutex.Unlock()
-        return false
+        return qs.isQuarantinedElsewhere(deviceID)
     }
     
     return true
 }
 
-// Poner dispositivo en quarantine
+// Número de dispositivos actualmente en quarantine
+func (qs *QuarantineSystem) QuarantinedCount() int {
+    qs.mutex.RLock()
+    defer qs.mutex.RUnlock()
+    
+    return len(qs.quarantinedDevices)
+}
+
+// Poner dispositivo en quarantine con un motivo en texto libre.
+// Compatibilidad con llamadas anteriores a los códigos de motivo: se
+// registra como QuarantineReasonOther con el texto como detalle.
 func (qs *QuarantineSystem) QuarantineDevice(deviceID string, reason string) {
+    qs.QuarantineDeviceWithReason(deviceID, QuarantineReasonOther, reason)
+}
+
+// Poner dispositivo en quarantine. Con deduplicateQuarantine, si el
+// dispositivo ya está en quarantine solo se actualiza la razón: no se
+// reinicia el tiempo ni se repiten la alerta y el comando.
+func (qs *QuarantineSystem) QuarantineDeviceWithReason(deviceID string, reason QuarantineReason, detail string) {
+    description := describeQuarantineReason(reason, detail)
+    if dryRunProcessing {
+        log.Printf("🧪 [DRY-RUN] Se pondría en cuarentena el dispositivo %s. Razón: %s", deviceID, description)
+        metrics.Inc("iot_dry_run_quarantines", "reason", string(reason))
+        return
+    }
     qs.mutex.Lock()
-    defer qs.mutex.Unlock()
+    now := qs.clock.Now()
+    if entry, exists := qs.quarantinedDevices[deviceID]; exists && deduplicateQuarantine && !entry.Expired(now) {
+        entry.Reason = reason
+        entry.Detail = detail
+        qs.mutex.Unlock()
+        log.Printf("🔒 QUARANTINE: Dispositivo %s ya en cuarentena, razón actualizada: %s", deviceID, description)
+        return
+    }
+    duration := qs.nextQuarantineDurationLocked(deviceID, now)
+    entry := &QuarantineEntry{
+        Since:    now,
+        Duration: duration,
+        Reason:   reason,
+        Detail:   detail,
+    }
+    qs.quarantinedDevices[deviceID] = entry
+    publisher := qs.commandPublisher
+    qs.mutex.Unlock()
+    
+    // Compartir la quarantine con las demás instancias del hub
+    qs.writeRepository(context.Background(), "SaveQuarantine", func(ctx context.Context, repository DeviceRepository) error {
+        return repository.SaveQuarantine(ctx, deviceID, *entry)
+    })
+    
+    metrics.Inc("iot_quarantines", "reason", string(reason))
+    logger.WarnWith(fmt.Sprintf("🔒 QUARANTINE: Dispositivo %s en cuarentena por %v. Razón: %s", deviceID, duration, description), map[string]interface{}{
+        "device_id":   deviceID,
+        "reason":      description,
+        "reason_code": string(reason),
+        "duration":    duration.String(),
+    })
+    notificationManager.SendQuarantineAlert(deviceID, reason, detail, duration)
     
-    qs.quarantinedDevices[deviceID] = time.Now()
-    log.Printf("🔒 QUARANTINE: Dispositivo %s en cuarentena por %v. Razón: %s", deviceID, QUARANTINE_DURATION, reason)
+    // Ordenar al dispositivo que deje de transmitir (fuera del lock)
+    err := publisher.PublishCommand(DeviceCommand{
+        Command:    COMMAND_QUARANTINE,
+        DeviceID:   deviceID,
+        Reason:     description,
+        ReasonCode: string(reason),
+        Duration:   int64(duration.Seconds()),
+        Timestamp:  now.Unix(),
+    })
+    if err != nil {
+        log.Printf("❌ Error enviando comando de cuarentena a %s: %v", deviceID, err)
+    }
+}
+
+// Máximo de lecturas del promedio: a partir de aquí cada lectura nueva pesa
+// 1/BEHAVIOR_AVERAGE_WINDOW y el promedio sigue cambios lentos legítimos
+const BEHAVIOR_AVERAGE_WINDOW = 100
+
+// Media incremental: avg += (x - avg) / n
+func rollingMean(avg *float64, samples *int, value float64) {
+    // Un NaN o Inf dejaría el promedio inservible para siempre
+    if !isFinite(value) {
+        return
+    }
+    if *samples < BEHAVIOR_AVERAGE_WINDOW {
+        *samples++
+    }
+    *avg += (value - *avg) / float64(*samples)
+}
+
+// Obtener o crear el historial de comportamiento (llamar con el lock tomado)
+func (qs *QuarantineSystem) behaviorLocked(deviceID string) *DeviceBehavior {
+    if qs.deviceBehavior[deviceID] == nil {
+        qs.deviceBehavior[deviceID] = &DeviceBehavior{
+            FirstSeen:      qs.clock.Now(),
+            AccessAttempts: make([]int, 0),
+            MetricHistory:  make(map[string][]float64),
+        }
+        qs.deviceRecency.touch(deviceID)
+    }
+    return qs.deviceBehavior[deviceID]
 }
 
 // Detección de patrones avanzados
-func (qs *QuarantineSystem) AnalyzeDeviceBehavior(data *SensorData) []string {
+func (qs *QuarantineSystem) AnalyzeDeviceBehavior(data *SensorData) []Anomaly {
     qs.mutex.Lock()
     
-    var alerts []string
+    var alerts []Anomaly
     var shouldQuarantine bool
-    var quarantineReason string
+    var quarantineDetail string
     
     // Obtener o crear historial de comportamiento
-    if qs.deviceBehavior[data.DeviceID] == nil {
-        qs.deviceBehavior[data.DeviceID] = &DeviceBehavior{
-            AccessAttempts: make([]int, 0),
-        }
-    }
-    
-    behavior := qs.deviceBehavior[data.DeviceID]
-    behavior.LastSeen = time.Now()
+    behavior := qs.behaviorLocked(data.DeviceID)
+    behavior.LastSeen = qs.clock.Now()
+    qs.deviceRecency.touch(data.DeviceID)
     behavior.MessageCount++
+    behavior.RecentReadings.add(ReadingSnapshot{ReceivedAt: behavior.LastSeen, Reading: *data}, deviceHistorySize)
+    
+    // Tipo de dispositivo distinto del asignado
+    alerts = append(alerts, qs.detectDeviceTypeChangeLocked(data, behavior)...)
+    
+    // Nivel de seguridad rebajado por el propio dispositivo
+    alerts = append(alerts, detectSecurityLevelDowngradeLocked(data, behavior)...)
+    
+    // Dispositivo recién aparecido con lecturas sospechosas
+    alerts = append(alerts, detectSuspiciousProvisioningLocked(data, behavior, behavior.LastSeen)...)
     
     // Análisis de temperatura (para sensores)
-    if data.Temperature != 0 {
-        if behavior.AvgTemperature == 0 {
-            behavior.AvgTemperature = data.Temperature
+    if data.hasReading("temperature", data.Temperature) {
+        outlier := false
+        if behavior.TemperatureSamples == 0 {
+            rollingMean(&behavior.AvgTemperature, &behavior.TemperatureSamples, data.Temperature)
             log.Printf("🔍 DEBUG %s: Temperatura inicial: %.1f°C", data.DeviceID, data.Temperature)
         } else {
             oldAvg := behavior.AvgTemperature
-            // Promedio móvil simple
-            behavior.AvgTemperature = (behavior.AvgTemperature + data.Temperature) / 2
+            rollingMean(&behavior.AvgTemperature, &behavior.TemperatureSamples, data.Temperature)
             
             // Detectar cambio drástico de temperatura
             tempDiff := data.Temperature - oldAvg
             log.Printf("🔍 DEBUG %s: Temp actual: %.1f°C, promedio anterior: %.1f°C, diff: %.1f°C", 
                 data.DeviceID, data.Temperature, oldAvg, tempDiff)
             
-            if tempDiff > 20 || tempDiff < -20 {
-                alerts = append(alerts, fmt.Sprintf("cambio drástico temperatura: %.1f°C (promedio: %.1f°C)", data.Temperature, oldAvg))
-                behavior.AnomalyCount++
+            if temperatureOutlierReady(&behavior.TemperatureStats) {
+                // Con historial suficiente se compara con la variabilidad propia del dispositivo
+                if alert := detectTemperatureOutlier(data, &behavior.TemperatureStats); alert != nil {
+                    alerts = append(alerts, *alert)
+                    outlier = true
+                }
+            } else if tempDiff > 20 || tempDiff < -20 {
+                alert := newAnomaly(data, AnomalyBehaviorPattern, data.Temperature, "cambio drástico temperatura: %.1f°C (promedio: %.1f°C)", data.Temperature, oldAvg)
+                alert.Metric = METRIC_TEMPERATURE
+                alert.Severity = behaviorPatternSeverity(BEHAVIOR_TEMPERATURE_CHANGE, data.DeviceType)
+                alert.Confidence = historyConfidence(thresholdConfidence(math.Abs(tempDiff), 20, 20), behavior.TemperatureSamples)
+                alerts = append(alerts, alert)
                 log.Printf("🔍 DEBUG %s: ALERTA temperatura generada!", data.DeviceID)
             }
         }
+        behavior.TemperatureStats.Observe(data.Temperature, outlier)
+    }
+    
+    // Análisis de humedad
+    if data.hasReading("humidity", data.Humidity) {
+        if behavior.HumiditySamples == 0 {
+            rollingMean(&behavior.AvgHumidity, &behavior.HumiditySamples, data.Humidity)
+        } else {
+            oldAvg := behavior.AvgHumidity
+            rollingMean(&behavior.AvgHumidity, &behavior.HumiditySamples, data.Humidity)
+            
+            // Detectar salto brusco de humedad
+            humidityDiff := data.Humidity - oldAvg
+            if humidityDiff > 20 || humidityDiff < -20 {
+                alert := newAnomaly(data, AnomalyBehaviorPattern, data.Humidity, "cambio drástico humedad: %.1f%% (promedio: %.1f%%)", data.Humidity, oldAvg)
+                alert.Metric = METRIC_HUMIDITY
+                alert.Severity = behaviorPatternSeverity(BEHAVIOR_HUMIDITY_CHANGE, data.DeviceType)
+                alert.Confidence = historyConfidence(thresholdConfidence(math.Abs(humidityDiff), 20, 20), behavior.HumiditySamples)
+                alerts = append(alerts, alert)
+            }
+        }
     }
     
     // Análisis de batería
-    if data.BatteryLevel > 0 {
-        if behavior.AvgBattery == 0 {
-            behavior.AvgBattery = data.BatteryLevel
+    if data.hasReading("battery_level", data.BatteryLevel) {
+        if behavior.BatterySamples == 0 {
+            rollingMean(&behavior.AvgBattery, &behavior.BatterySamples, data.BatteryLevel)
         } else {
-            behavior.AvgBattery = (behavior.AvgBattery + data.BatteryLevel) / 2
+            oldAvg := behavior.AvgBattery
+            rollingMean(&behavior.AvgBattery, &behavior.BatterySamples, data.BatteryLevel)
             
             // Detectar caída súbita de batería
-            batteryDiff := behavior.AvgBattery - data.BatteryLevel
+            batteryDiff := oldAvg - data.BatteryLevel
             if batteryDiff > 50 {
-                alerts = append(alerts, fmt.Sprintf("caída súbita batería: %.1f%% (promedio: %.1f%%)", data.BatteryLevel, behavior.AvgBattery))
-                behavior.AnomalyCount++
+                alert := newAnomaly(data, AnomalyBehaviorPattern, data.BatteryLevel, "caída súbita batería: %.1f%% (promedio: %.1f%%)", data.BatteryLevel, oldAvg)
+                alert.Metric = METRIC_BATTERY_LEVEL
+                alert.Severity = behaviorPatternSeverity(BEHAVIOR_BATTERY_DROP, data.DeviceType)
+                alert.Confidence = historyConfidence(thresholdConfidence(batteryDiff, 50, 50), behavior.BatterySamples)
+                alerts = append(alerts, alert)
             }
         }
     }
@@ -301,16 +607,32 @@ func (qs *QuarantineSystem) AnalyzeDeviceBehavior(data *SensorData) []string {
             }
             
             if recentAttempts > 20 {
-                alerts = append(alerts, fmt.Sprintf("posible ataque fuerza bruta: %d intentos en últimos 3 mensajes", recentAttempts))
-                behavior.AnomalyCount++
+                alert := newAnomaly(data, AnomalyBehaviorPattern, float64(recentAttempts), "posible ataque fuerza bruta: %d intentos en últimos 3 mensajes", recentAttempts)
+                alert.Metric = METRIC_ACCESS_ATTEMPTS
+                alert.Severity = behaviorPatternSeverity(BEHAVIOR_BRUTE_FORCE, data.DeviceType)
+                alert.Confidence = thresholdConfidence(float64(recentAttempts), 20, 20)
+                alerts = append(alerts, alert)
             }
         }
     }
     
+    // Durante una actualización de firmware o un mantenimiento de grupo las
+    // anomalías no cuentan para quarantine
+    if !qs.isUpdatingLocked(data.DeviceID) && !groupMaintenance.InMaintenance(data.DeviceID) {
+        behavior.AnomalyCount += len(alerts)
+    }
+    
+    // La deriva de calibración es de mantenimiento: no cuenta para quarantine
+    alerts = append(alerts, qs.detectCalibrationDriftLocked(data, behavior)...)
+    
     // Si hay muchas anomalías, preparar para quarantine
-    if behavior.AnomalyCount >= ANOMALY_THRESHOLD {
+    now := qs.clock.Now()
+    if behavior.AnomalyCount >= behavior.anomalyThreshold(now) {
         shouldQuarantine = true
-        quarantineReason = fmt.Sprintf("múltiples anomalías detectadas (%d)", behavior.AnomalyCount)
+        quarantineDetail = fmt.Sprintf("múltiples anomalías detectadas (%d)", behavior.AnomalyCount)
+        if behavior.inProbation(now) {
+            quarantineDetail = fmt.Sprintf("reincidencia en periodo de prueba (%d anomalías)", behavior.AnomalyCount)
+        }
         behavior.AnomalyCount = 0 // Reset contador
     }
     
@@ -318,41 +640,570 @@ func (qs *QuarantineSystem) AnalyzeDeviceBehavior(data *SensorData) []string {
     
     // Ejecutar quarantine fuera del lock para evitar deadlock
     if shouldQuarantine {
-        qs.QuarantineDevice(data.DeviceID, quarantineReason)
+        qs.QuarantineDeviceWithReason(data.DeviceID, QuarantineReasonBehaviorAnomaly, quarantineDetail)
     }
     
     return alerts
 }
 
+// Liberar dispositivos cuya quarantine ha expirado
+func (qs *QuarantineSystem) CleanExpiredQuarantines() {
+    qs.mutex.Lock()
+    
+    now := qs.clock.Now()
+    released := make([]QuarantineRelease, 0)
+    
+    for deviceID, entry := range qs.quarantinedDevices {
+        if entry.Expired(now) {
+            released = append(released, qs.releaseLocked(deviceID, entry, ReleaseReasonExpired, "", now))
+        }
+    }
+    qs.mutex.Unlock()
+    
+    for _, release := range released {
+        announceRelease(release)
+    }
+    
+    qs.withRepository(context.Background(), "CleanExpiredQuarantines", func(ctx context.Context, repository DeviceRepository) error {
+        return repository.CleanExpiredQuarantines(ctx)
+    })
+}
+
+// Limpieza periódica de quarantine hasta que se cancele el contexto.
+// El canal devuelto se cierra cuando la goroutine termina.
+func startQuarantineCleanup(ctx context.Context, qs *QuarantineSystem, interval time.Duration) <-chan struct{} {
+    done := make(chan struct{})
+    
+    go func() {
+        defer close(done)
+        ticker := time.NewTicker(interval)
+        defer ticker.Stop()
+        
+        for {
+            select {
+            case <-ctx.Done():
+                return
+            case <-ticker.C:
+                qs.CleanExpiredQuarantines()
+            }
+        }
+    }()
+    
+    return done
+}
+
+// Procesar un mensaje MQTT recibido en cualquiera de los topics suscritos. Al
+// cancelarse ctx (apagado) se interrumpe el procesamiento en curso.
+func handleMessage(ctx context.Context, client mqtt.Client, msg mqtt.Message) {
+    ingestPayload(ctx, msg.Topic(), msg.Payload())
+}
+
+// Motivos de rechazo de una lectura que distingue la API de ingesta
+var (
+    ErrPayloadTooLarge = errors.New("payload demasiado grande")
+    ErrUnknownDevice   = errors.New("dispositivo no autorizado")
+    ErrQuarantined     = errors.New("dispositivo en cuarentena")
+    ErrRateLimited     = errors.New("rate limit excedido")
+    ErrInvalidData     = errors.New("dato inválido")
+)
+
+// Procesar un payload recibido por cualquier vía (topic MQTT o ingesta
+// HTTP): tamaño, lotes, decodificación y procesamiento de cada lectura
+func ingestPayload(ctx context.Context, source string, payload []byte) error {
+    _, err := ingestPayloadResults(ctx, source, payload)
+    return err
+}
+
+// Como ingestPayload, devolviendo además el resultado de cada lectura si el
+// payload es un lote (nil si es una lectura suelta o se rechaza entero)
+func ingestPayloadResults(ctx context.Context, source string, payload []byte) ([]BatchReadingResult, error) {
+    fmt.Printf("📨 Mensaje recibido de %s\n", source)
+    metrics.Inc("iot_messages_received")
+    throughput.MessageReceived()
+    defer throughput.MessageDone()
+
+    // 📏 TAMAÑO MÁXIMO: rechazar antes de decodificar
+    if payloadTooLarge(payload) {
+        deviceID := peekDeviceID(payload, maxPayloadSize)
+        log.Printf("🚫 MENSAJE RECHAZADO: payload de %d bytes supera el máximo de %d (dispositivo: %q)", len(payload), maxPayloadSize, deviceID)
+        metrics.Inc("iot_messages_rejected", "reason", "payload_too_large")
+        // Un ID fuera de la lista no crea historial ni quarantine
+        if deviceID != "" && isDeviceAllowed(deviceID) && quarantineSystem.AdmitDevice(deviceID) == nil {
+            quarantineSystem.RecordRejected(deviceID)
+            if quarantineOversizedPayloads {
+                quarantineSystem.QuarantineDeviceWithReason(deviceID, QuarantineReasonOversizedPayload, fmt.Sprintf("%d bytes", len(payload)))
+            }
+        }
+        return nil, fmt.Errorf("%w: %d bytes (máximo %d)", ErrPayloadTooLarge, len(payload), maxPayloadSize)
+    }
+
+    // 📚 LOTE: gateways que agregan varias lecturas en un array JSON
+    if readings, isBatch := splitBatchPayload(payload); isBatch {
+        return processBatch(ctx, source, readings)
+    }
+
+    // Parsear JSON (o CBOR) del mensaje
+    data, droppedFields, err := decodePayload(source, payload, lenientDecoding)
+    if err != nil {
+        log.Printf("❌ Error parseando JSON: %v", err)
+        metrics.Inc("iot_messages_rejected", "reason", "invalid_json")
+        recordDeadLetter(source, payload, err)
+        return nil, fmt.Errorf("%w: %w", ErrInvalidData, err)
+    }
+
+    return nil, processSensorData(ctx, data, droppedFields, payload)
+}
+
+// Procesar una lectura decodificada: quarantine, rate limit, validación y
+// detección de anomalías. Devuelve error si la lectura se rechaza, o el error
+// de ctx si se cancela entre fases.
+func processSensorData(ctx context.Context, data SensorData, droppedFields []string, payload []byte) error {
+    if err := ctx.Err(); err != nil {
+        return err
+    }
+    data.receivedAt = quarantineSystem.Now()
+
+    // 🚷 DISPOSITIVO NO AUTORIZADO
+    if err := checkDeviceAllowed(data.DeviceID); err != nil {
+        return err
+    }
+
+    // 📇 LÍMITE DE DISPOSITIVOS en memoria
+    if err := quarantineSystem.AdmitDevice(data.DeviceID); err != nil {
+        return err
+    }
+
+    // 🛠️ ESTADO DE ACTUALIZACIÓN DE FIRMWARE reportado por el dispositivo
+    applyReportedFirmwareState(&data)
+    updating := quarantineSystem.IsUpdating(data.DeviceID) || groupMaintenance.InMaintenance(data.DeviceID)
+
+    // 🚫 VERIFICAR QUARANTINE
+    if quarantineSystem.IsQuarantined(data.DeviceID) {
+        log.Printf("🔒 MENSAJE RECHAZADO: Dispositivo %s está en cuarentena", data.DeviceID)
+        metrics.Inc("iot_messages_rejected", "reason", "quarantined")
+        quarantineSystem.RecordRejected(data.DeviceID)
+        return fmt.Errorf("%s: %w", data.DeviceID, ErrQuarantined)
+    }
+
+    // 📬 REENVÍO: payload idéntico a una lectura aceptada hace unos segundos
+    digest := payloadDigest(payload)
+    if payloadDeduplicator.Seen(data.DeviceID, digest) {
+        log.Printf("📬 MENSAJE DUPLICADO: %s reenvió un payload idéntico en menos de %v", data.DeviceID, payloadDedupWindow)
+        metrics.Inc("iot_messages_payload_duplicate")
+        return nil
+    }
+
+    // 🛡️ VERIFICAR RATE LIMITING
+    if !quarantineSystem.CheckRateLimit(data.DeviceID) {
+        log.Printf("🚫 MENSAJE RECHAZADO: Rate limit excedido para %s", data.DeviceID)
+        metrics.Inc("iot_messages_rejected", "reason", "rate_limit")
+        quarantineSystem.RecordRejected(data.DeviceID)
+        return fmt.Errorf("%s: %w", data.DeviceID, ErrRateLimited)
+    }
+
+    // 📬 RETRANSMISIÓN: mismo message_id ya procesado. Después del rate limit,
+    // para que un dispositivo no pueda llenar el deduplicador a ritmo libre
+    if messageDeduplicator.IsDuplicate(data.DeviceID, data.MessageID) {
+        log.Printf("📬 MENSAJE DUPLICADO: %s ya procesó el mensaje %s", data.DeviceID, data.MessageID)
+        metrics.Inc("iot_messages_duplicate")
+        return nil
+    }
+
+    // 🔐 VALIDAR DATOS DE SEGURIDAD
+    err := validateSensorData(&data)
+    if err == nil {
+        err = validateRequiredFields(&data)
+    }
+    if err != nil {
+        log.Printf("⚠️ DATO INVÁLIDO de %s: %v", data.DeviceID, err)
+        metrics.Inc("iot_messages_rejected", "reason", "invalid_data")
+        quarantineSystem.RecordRejected(data.DeviceID)
+        // Los reinicios durante una actualización generan datos raros esperables
+        if !updating {
+            quarantineSystem.QuarantineDeviceWithReason(data.DeviceID, QuarantineReasonInvalidData, err.Error())
+        }
+        return fmt.Errorf("%s: %w: %w", data.DeviceID, ErrInvalidData, err)
+    }
+
+    // 🆘 SOLICITUD DE AUTO-CUARENTENA: firma verificada antes del replay
+    selfQuarantine := data.MessageType == MESSAGE_TYPE_SELF_QUARANTINE
+    replayPayload := payload
+    if selfQuarantine {
+        if err := checkSelfQuarantineRequest(&data); err != nil {
+            log.Printf("⚠️ AUTO-CUARENTENA RECHAZADA para %s: %v", data.DeviceID, err)
+            metrics.Inc("iot_messages_rejected", "reason", "self_quarantine")
+            quarantineSystem.RecordRejected(data.DeviceID)
+            return fmt.Errorf("%s: %w", data.DeviceID, err)
+        }
+        // La firma cubre todos los campos: reserializar el JSON no la cambia
+        replayPayload = []byte(data.Signature)
+    }
+
+    // 🔁 PROTECCIÓN CONTRA REPLAY
+    if replayAnomaly := quarantineSystem.CheckReplay(&data, replayPayload); replayAnomaly != nil {
+        log.Printf("🔁 MENSAJE RECHAZADO: %s (%s)", replayAnomaly.Description, data.DeviceID)
+        metrics.Inc("iot_messages_rejected", "reason", "replay")
+        metrics.Inc("iot_anomalies_detected", "source", "replay")
+        quarantineSystem.RecordRejected(data.DeviceID)
+        replayAnomalies := []Anomaly{*replayAnomaly}
+        recordAnomalies(replayAnomalies, !updating)
+        return fmt.Errorf("%s: %s", data.DeviceID, replayAnomaly.Description)
+    }
+
+    // Lectura aceptada: a partir de aquí los reenvíos idénticos son duplicados
+    payloadDeduplicator.Record(data.DeviceID, digest)
+
+    if selfQuarantine {
+        applySelfQuarantineRequest(&data)
+        return nil
+    }
+
+    if err := ctx.Err(); err != nil {
+        return err
+    }
+
+    // 🕰️ HORARIO DE COMUNICACIÓN permitido para el dispositivo o su tipo
+    scheduleAnomaly, schedule := checkCommunicationSchedule(&data, quarantineSystem.Now())
+    if scheduleAnomaly != nil {
+        log.Printf("🚨 FUERA DE HORARIO en %s: %s", data.DeviceID, scheduleAnomaly.Description)
+        metrics.Inc("iot_anomalies_detected", "source", "schedule")
+        scheduleAnomalies := []Anomaly{*scheduleAnomaly}
+        recordAnomalies(scheduleAnomalies, !updating)
+        if schedule.Quarantine && !updating {
+            quarantineSystem.QuarantineDeviceWithReason(data.DeviceID, QuarantineReasonOutsideSchedule, scheduleAnomaly.Description)
+            return fmt.Errorf("%s: %s", data.DeviceID, scheduleAnomaly.Description)
+        }
+    }
+
+    // 🧪 CALIDAD DE DATOS: campos descartados en decodificación tolerante
+    if len(droppedFields) > 0 {
+        qualityAnomaly := newAnomaly(&data, AnomalyDataQuality, float64(len(droppedFields)), "campos mal formados descartados: %v", droppedFields)
+        log.Printf("🚨 ANOMALÍA DE CALIDAD DE DATOS en %s: %s", data.DeviceID, qualityAnomaly.Description)
+        metrics.Inc("iot_anomalies_detected", "source", "data_quality")
+        qualityAnomalies := []Anomaly{qualityAnomaly}
+        recordAnomalies(qualityAnomalies, !updating)
+    }
+
+    // 📦 TAMAÑO DE PAYLOAD fuera de lo habitual para el dispositivo
+    sizeAnomaly := quarantineSystem.CheckPayloadSize(&data, len(payload))
+    if sizeAnomaly != nil {
+        log.Printf("🚨 ANOMALÍA DE TAMAÑO en %s: %s", data.DeviceID, sizeAnomaly.Description)
+        metrics.Inc("iot_anomalies_detected", "source", "payload_size")
+        sizeAnomalies := []Anomaly{*sizeAnomaly}
+        recordAnomalies(sizeAnomalies, !updating)
+    }
+
+    // 🩺 DIAGNÓSTICOS reportados por el dispositivo
+    diagnosticAnomalies := quarantineSystem.RecordDiagnostics(&data)
+    if len(diagnosticAnomalies) > 0 {
+        log.Printf("🚨 DIAGNÓSTICO CRÍTICO en %s: %s", data.DeviceID, describeAnomalies(diagnosticAnomalies))
+        metrics.Add("iot_anomalies_detected", float64(len(diagnosticAnomalies)), "source", "diagnostics")
+        recordAnomalies(diagnosticAnomalies, !updating)
+    }
+    
+    // Historial reciente por métrica para dar contexto a las alertas
+    quarantineSystem.RecordMetricHistory(&data)
+    quarantineSystem.RecordMetadata(&data)
+
+    if err := ctx.Err(); err != nil {
+        return err
+    }
+
+    // 🔍 DETECCIÓN DE ANOMALÍAS BÁSICAS
+    anomalies := detectAnomalies(&data)
+    if len(anomalies) > 0 {
+        quarantineSystem.AttachHistory(anomalies)
+        log.Printf("🚨 ANOMALÍA BÁSICA en %s: %s", data.DeviceID, describeAnomalies(anomalies))
+        metrics.Inc("iot_anomalies_detected", "source", "basic")
+        recordAnomalies(anomalies, !updating)
+    }
+
+    if err := ctx.Err(); err != nil {
+        return err
+    }
+
+    // 🧠 ANÁLISIS DE PATRONES AVANZADOS
+    behaviorAlerts := quarantineSystem.AnalyzeDeviceBehavior(&data)
+    if len(behaviorAlerts) > 0 {
+        quarantineSystem.AttachHistory(behaviorAlerts)
+        log.Printf("🚨 PATRONES SOSPECHOSOS en %s: %v", data.DeviceID, describeAnomalies(behaviorAlerts))
+        metrics.Add("iot_anomalies_detected", float64(len(behaviorAlerts)), "source", "behavior")
+        recordAnomalies(behaviorAlerts, !updating)
+    } else {
+        log.Printf("🔍 DEBUG: Sin alertas de comportamiento para %s", data.DeviceID)
+    }
+
+    // Registrar resultado para la reputación del dispositivo
+    detected := len(anomalies) + len(behaviorAlerts) + len(diagnosticAnomalies)
+    if len(droppedFields) > 0 {
+        detected++
+    }
+    if sizeAnomaly != nil {
+        detected++
+    }
+    if scheduleAnomaly != nil {
+        detected++
+    }
+    quarantineSystem.RecordMessageOutcome(data.DeviceID, detected)
+    
+    // 📈 TASA DE ANOMALÍAS en la ventana deslizante
+    if detected > 0 && !updating && !quarantineSystem.IsQuarantined(data.DeviceID) {
+        checkAnomalyRate(data.DeviceID)
+    }
+
+    // Guardar lectura para análisis "what-if" de umbrales
+    readingHistory.Add(data)
+
+    // ✅ Datos procesados correctamente
+    metrics.Inc("iot_messages_processed")
+    fmt.Printf("✅ Datos de %s procesados y validados\n", data.DeviceID)
+    return nil
+}
+
+// Separar la lista de topics (MQTT_TOPIC admite varios separados por comas)
+func parseTopics(raw string) []string {
+    topics := make([]string, 0)
+    for _, topic := range strings.Split(raw, ",") {
+        topic = strings.TrimSpace(topic)
+        if topic != "" {
+            topics = append(topics, topic)
+        }
+    }
+    return topics
+}
+
+// Espera máxima a la confirmación del broker al anular las suscripciones
+const MQTT_UNSUBSCRIBE_TIMEOUT = 2 * time.Second
+
+// Suscribirse a todos los topics con el mismo handler, indicando cuál falla
+func subscribeTopics(ctx context.Context, client mqtt.Client, topics []string) error {
+    if len(topics) == 0 {
+        return fmt.Errorf("no hay topics MQTT configurados")
+    }
+    
+    filters := make(map[string]byte)
+    for _, topic := range topics {
+        filters[topic] = mqttQoS
+    }
+    
+    token := client.SubscribeMultiple(filters, func(client mqtt.Client, msg mqtt.Message) {
+        if messagePool == nil {
+            handleMessage(ctx, client, msg)
+            return
+        }
+
+        key := messageShardKey(msg.Topic(), msg.Payload())
+        if !messagePool.Submit(key, func() { handleMessage(ctx, client, msg) }) {
+            log.Printf("🚫 MENSAJE DESCARTADO: cola de procesamiento llena (%s, dispositivo: %q)", msg.Topic(), key)
+            metrics.Inc("iot_messages_received")
+            metrics.Inc("iot_messages_rejected", "reason", "queue_full")
+        }
+    })
+    if token.Wait() && token.Error() != nil {
+        return fmt.Errorf("error suscribiendo a %v: %w", topics, token.Error())
+    }
+    
+    // El broker devuelve 0x80 para cada topic rechazado
+    if subToken, ok := token.(*mqtt.SubscribeToken); ok {
+        for topic, code := range subToken.Result() {
+            if code == 0x80 {
+                return fmt.Errorf("el broker rechazó la suscripción al topic %s", topic)
+            }
+        }
+    }
+    
+    return nil
+}
+
+// Anular las suscripciones al apagar para no recibir mensajes que ya no se
+// van a procesar
+func unsubscribeTopics(client mqtt.Client, topics []string) {
+    token := client.Unsubscribe(topics...)
+    if !token.WaitTimeout(MQTT_UNSUBSCRIBE_TIMEOUT) {
+        log.Printf("⚠️ Sin confirmación del broker al anular las suscripciones a %v", topics)
+        return
+    }
+    if err := token.Error(); err != nil {
+        log.Printf("⚠️ Error anulando las suscripciones a %v: %v", topics, err)
+    }
+}
+
 func main() {
+    replayFile := flag.String("replay", "", "reproducir un fichero de lecturas JSON (una por línea) sin conectar al broker")
+    replayPace := flag.Bool("replay-pace", false, "con --replay, respetar los intervalos entre los timestamps de las lecturas")
+    replayLive := flag.Bool("replay-live", false, "con --replay, usar Redis, el snapshot de estado y los canales de notificación reales")
+    flag.Parse()
 
+    // Código de salida aplicado después de los defer (cierre de Redis...),
+    // que log.Fatal se saltaría
+    exitCode := 0
+    defer func() {
+        if exitCode != 0 {
+            os.Exit(exitCode)
+        }
+    }()
+
+    // Con CONFIG_FILE el .env es opcional
     err := godotenv.Load()
-    if err != nil {
+    configFile := os.Getenv("CONFIG_FILE")
+    if err != nil && configFile == "" {
         log.Fatal("Error cargando el .env")
     }
 
-    mqttHost := os.Getenv("MQTT_HOST")
-    mqttTopic := os.Getenv("MQTT_TOPIC")
-    mqttUsername := os.Getenv("MQTT_USERNAME")
-    mqttPassword := os.Getenv("MQTT_PASSWORD")
+    var cfg *Config
+    if configFile != "" {
+        cfg, err = LoadFromFile(configFile)
+    } else {
+        cfg, err = LoadConfig()
+    }
+    if err != nil {
+        log.Fatal(err)
+    }
+    if err := cfg.Validate(); err != nil {
+        log.Fatalf("❌ Configuración inválida:\n%v", err)
+    }
+    if *replayFile != "" && !*replayLive {
+        isolateReplay(cfg)
+    }
+    applyConfig(cfg)
+
+    // Logs en texto con emojis o JSON estructurado
+    logger = NewLoggerWithFormat(cfg.Logging.Format)
+    log.SetOutput(logger)
+    if logger.Format() == LOG_FORMAT_JSON {
+        log.SetFlags(0)
+    }
 
     // Inicializar sistema de seguridad
     quarantineSystem = NewQuarantineSystem()
+    readingHistory = NewReadingHistory(READING_HISTORY_SIZE)
+    var redisRepository *RedisDeviceRepository
+    if cfg.State.RedisAddr != "" {
+        redisRepository = NewRedisDeviceRepository(cfg.State.RedisAddr)
+        defer redisRepository.Close()
+        quarantineSystem.SetRepository(redisRepository)
+        quarantineSystem.SetRateLimiter(redisRepository.NewRateLimiter(rateLimitMaxMessages, rateLimitWindow, quarantineSystem.rateLimiter))
+        fmt.Printf("🗄️ Estado compartido en Redis: %s\n", cfg.State.RedisAddr)
+    }
+    if stateSnapshotFile != "" {
+        state, err := loadStateSnapshot(stateSnapshotFile, quarantineSystem)
+        if err != nil {
+            log.Printf("❌ Error cargando snapshot de estado: %v", err)
+        } else if state != nil {
+            fmt.Printf("♻️ Estado restaurado de %s: %d dispositivos, %d en cuarentena (guardado %s)\n",
+                stateSnapshotFile, len(state.Devices), len(state.Quarantines), state.SavedAt.Format(time.RFC3339))
+        }
+    }
+    metrics.RegisterGaugeFunc("iot_quarantined_devices", "Dispositivos actualmente en cuarentena", func() float64 {
+        return float64(quarantineSystem.QuarantinedCount())
+    })
+    metrics.RegisterGaugeFunc("iot_rate_limited_devices", "Dispositivos con estado de rate limit en memoria", func() float64 {
+        return float64(quarantineSystem.rateLimiter.DeviceCount())
+    })
+    metrics.RegisterGaugeFunc("iot_processing_queue_depth", "Mensajes esperando un worker libre", func() float64 {
+        if messagePool == nil {
+            return 0
+        }
+        return float64(messagePool.QueueDepth())
+    })
+    metrics.RegisterGaugeFunc("iot_ingestion_rate", "Mensajes recibidos por segundo", func() float64 {
+        return throughput.Stats().IngestionRate
+    })
+    metrics.RegisterGaugeFunc("iot_processing_rate", "Mensajes procesados por segundo", func() float64 {
+        return throughput.Stats().ProcessingRate
+    })
+    metrics.RegisterGaugeFunc("iot_queue_depth", "Mensajes pendientes de procesar", func() float64 {
+        return float64(throughput.Stats().QueueDepth)
+    })
+    metrics.RegisterGaugeFunc("iot_anomaly_storm_active", "Protección ante tormenta de anomalías activa (1/0)", func() float64 {
+        if stormProtection.Active() {
+            return 1
+        }
+        return 0
+    })
     fmt.Println("🔒 Sistema de seguridad IoT iniciado")
 
+    // Canales de notificación
+    if cfg.Notifications.EnableDiscord {
+        if cfg.Notifications.DiscordWebhookURL != "" {
+            notificationManager.Register(NewDiscordClient(cfg.Notifications.DiscordWebhookURL))
+        }
+        for name, webhookURL := range cfg.Notifications.DiscordChannels {
+            notificationManager.Register(NewNamedDiscordClient(name, webhookURL))
+        }
+    }
+    if cfg.Notifications.EnableElasticsearch {
+        anomalyExporter.Register(NewElasticExporterWithFormat(cfg.Notifications.ElasticsearchURL, cfg.Notifications.ElasticsearchIndexPrefix, cfg.Notifications.ElasticsearchFormat))
+    }
+    if cfg.Notifications.EnableWebhook {
+        webhookClient, err := NewGenericWebhookClient(cfg.Notifications.WebhookURL, cfg.Notifications.WebhookTemplate, cfg.Notifications.WebhookHeaders, cfg.Notifications.WebhookTimeout)
+        if err != nil {
+            log.Fatal(err)
+        }
+        notificationManager.Register(webhookClient)
+    }
+    notificationManager.SetRoutes(cfg.Notifications.Routes)
+    if unknown := notificationManager.UnknownRouteChannels(); len(unknown) > 0 {
+        log.Printf("⚠️ NOTIFICACIONES: Las rutas nombran canales no registrados: %v", unknown)
+    }
+    notificationManager.SetDeviceChannels(quarantineSystem.NotificationChannels)
+    if dryRunProcessing {
+        fmt.Println("🧪 Procesador en modo dry-run: se detectan anomalías sin aplicar cuarentenas ni enviar notificaciones")
+    } else if dryRunNotifications {
+        fmt.Println("🧪 Notificaciones en modo dry-run: se registran en el log sin enviarse")
+    }
+    if len(deviceAllowlist) > 0 {
+        fmt.Printf("🚷 Solo se aceptan mensajes de %d dispositivos autorizados\n", len(deviceAllowlist))
+    }
+    fmt.Printf("📏 Reglas de anomalías activas: %v\n", ruleEngine.Names())
+
+    // Contexto raíz cancelado al recibir SIGINT/SIGTERM
+    ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+    defer stop()
+
+    // Reproducir un fichero de lecturas en lugar de conectar al broker
+    if *replayFile != "" {
+        if !*replayLive {
+            fmt.Println("🧪 Reproducción aislada: sin Redis, sin snapshot de estado y con notificaciones en dry-run (--replay-live para desactivarlo)")
+        }
+        if err := runReadingsReplay(ctx, *replayFile, *replayPace); err != nil {
+            log.Printf("❌ Reproducción fallida: %v", err)
+            exitCode = 1
+        }
+        return
+    }
+
+    // API REST
+    apiServer := startAPIServer(cfg.HTTP.Addr)
+    fmt.Printf("🌐 API HTTP escuchando en %s\n", cfg.HTTP.Addr)
+
     // ----------------------------
     // 1️⃣ Conectar al broker MQTT
     // ----------------------------
     opts := mqtt.NewClientOptions()
-    opts.AddBroker(mqttHost)
-    opts.SetClientID("iot_security_hub")
-    opts.SetUsername(mqttUsername)
-    opts.SetPassword(mqttPassword)
-    opts.SetCleanSession(true)
+    opts.AddBroker(cfg.MQTT.Host)
+    configureSession(opts, cfg.MQTT)
+    opts.SetUsername(cfg.MQTT.Username)
+    opts.SetPassword(cfg.MQTT.Password)
     opts.SetAutoReconnect(true)
     opts.SetMaxReconnectInterval(10 * time.Second)
+    if cfg.MQTT.UseTLS {
+        tlsConfig, err := newMQTTTLSConfig(cfg.MQTT)
+        if err != nil {
+            log.Fatal(err)
+        }
+        opts.SetTLSConfig(tlsConfig)
+    }
+    configureStatusAnnouncements(opts, cfg.MQTT)
+    topics := parseTopics(cfg.MQTT.Topic)
+    // Contexto del procesamiento de mensajes: no se cancela con la señal de
+    // apagado, sino después de vaciar la cola de workers
+    processingCtx, stopProcessing := context.WithCancel(context.WithoutCancel(ctx))
+    defer stopProcessing()
+    configureConnectionHandlers(processingCtx, opts, cfg.MQTT, topics)
     
     client := mqtt.NewClient(opts)
+    setBrokerConnection(client)
 
     if token := client.Connect(); token.Wait() && token.Error() != nil {
         log.Fatal(token.Error())
@@ -362,83 +1213,80 @@ func main() {
     // ----------------------------
     // 2️⃣ Suscribirse al topic
     // ----------------------------
-    client.Subscribe(mqttTopic, 0, func(client mqtt.Client, msg mqtt.Message) {
-        fmt.Printf("📨 Mensaje recibido de %s\n", msg.Topic())
-
-        // Parsear JSON del mensaje
-        var data SensorData
-        err := json.Unmarshal(msg.Payload(), &data)
-        if err != nil {
-            log.Printf("❌ Error parseando JSON: %v", err)
-            return
-        }
+    if processingWorkers > 0 {
+        messagePool = NewWorkerPool(processingWorkers, processingQueueSize)
+        fmt.Printf("🧵 Procesando mensajes con %d workers (cola de %d)\n", processingWorkers, processingQueueSize)
+    }
+    if err := subscribeTopics(processingCtx, client, topics); err != nil {
+        log.Fatal(err)
+    }
+    fmt.Printf("📡 Suscrito a %d topic(s): %v\n", len(topics), topics)
 
-        // 🚫 VERIFICAR QUARANTINE
-        if quarantineSystem.IsQuarantined(data.DeviceID) {
-            log.Printf("🔒 MENSAJE RECHAZADO: Dispositivo %s está en cuarentena", data.DeviceID)
-            return
-        }
+    // Comandos de control hacia los dispositivos
+    quarantineSystem.SetCommandPublisher(NewMQTTCommandPublisher(client, cfg.MQTT.ControlTopic))
 
-        // 🛡️ VERIFICAR RATE LIMITING
-        if !quarantineSystem.CheckRateLimit(data.DeviceID) {
-            log.Printf("🚫 MENSAJE RECHAZADO: Rate limit excedido para %s", data.DeviceID)
-            return
-        }
+    // Limpiar quarantine periódicamente
+    cleanupDone := startQuarantineCleanup(ctx, quarantineSystem, 1*time.Minute)
+    purgeDone := startDevicePurge(ctx, quarantineSystem, DEVICE_PURGE_INTERVAL)
+    retentionDone := startAnomalyRetention(ctx, anomalyRepository, ANOMALY_RETENTION_INTERVAL)
+    snapshotDone := startStateSnapshotOnSignal(ctx, quarantineSystem)
+    quietHoursDone := startQuietHoursDigest(ctx, notificationManager, QUIET_HOURS_CHECK_INTERVAL)
+    var quarantineWatchDone <-chan struct{}
+    if redisRepository != nil {
+        quarantineWatchDone = redisRepository.WatchQuarantines(ctx)
+    }
 
-        // 🔐 VALIDAR DATOS DE SEGURIDAD
-        err = validateSensorData(&data)
-        if err != nil {
-            log.Printf("⚠️ DATO INVÁLIDO de %s: %v", data.DeviceID, err)
-            quarantineSystem.QuarantineDevice(data.DeviceID, "datos inválidos")
-            return
-        }
+    fmt.Println("🚀 Sistema de seguridad IoT funcionando...")
+    fmt.Printf("📊 Configuración: %d msg/%v máximo (ráfaga %d), quarantine %v, threshold anomalías %d\n", 
+        rateLimitMaxMessages, rateLimitWindow, rateLimitBurst, QUARANTINE_DURATION, ANOMALY_THRESHOLD)
+    
+    // Mantener el programa corriendo hasta recibir SIGINT/SIGTERM
+    <-ctx.Done()
+    fmt.Println("🛑 Señal de apagado recibida, cerrando sistema...")
 
-        // 🔍 DETECCIÓN DE ANOMALÍAS BÁSICAS
-        anomaly := detectAnomalies(&data)
-        if anomaly != "" {
-            log.Printf("🚨 ANOMALÍA BÁSICA en %s: %s", data.DeviceID, anomaly)
-        }
+    <-cleanupDone
+    <-purgeDone
+    <-retentionDone
+    <-snapshotDone
+    <-quietHoursDone
+    if quarantineWatchDone != nil {
+        <-quarantineWatchDone
+    }
+    // Dejar de recibir y procesar lo ya encolado antes de desconectar: las
+    // quarantines que resulten aún pueden publicar sus comandos
+    unsubscribeTopics(client, topics)
+    if messagePool != nil {
+        messagePool.Stop()
+    }
+    stopProcessing()
+    // Las alertas retenidas por las horas de silencio se envían ya en el resumen
+    notificationManager.FlushQuietHours()
+    // El Last Will solo se publica ante desconexiones no limpias
+    publishHubStatus(client, cfg.MQTT.StatusTopic, cfg.MQTT.StatusOffline)
+    client.Disconnect(250)
+    fmt.Println("🔌 Desconectado del broker MQTT")
 
-        // 🧠 ANÁLISIS DE PATRONES AVANZADOS
-        behaviorAlerts := quarantineSystem.AnalyzeDeviceBehavior(&data)
-        if len(behaviorAlerts) > 0 {
-            log.Printf("🚨 PATRONES SOSPECHOSOS en %s: %v", data.DeviceID, behaviorAlerts)
+    shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+    if err := apiServer.Shutdown(shutdownCtx); err != nil {
+        log.Printf("❌ Error cerrando servidor HTTP: %v", err)
+    }
+    if err := notificationManager.Flush(shutdownCtx); err != nil {
+        log.Printf("❌ Notificaciones pendientes sin enviar: %v", err)
+    }
+    if err := anomalyExporter.Flush(shutdownCtx); err != nil {
+        log.Printf("❌ Exportaciones de anomalías pendientes: %v", err)
+    }
+    if err := quarantineSystem.FlushDeviceSaves(shutdownCtx); err != nil {
+        log.Printf("❌ Dispositivos pendientes de guardar en el repositorio: %v", err)
+    }
+    if stateSnapshotFile != "" {
+        if err := saveStateSnapshot(stateSnapshotFile, quarantineSystem); err != nil {
+            log.Printf("❌ Error guardando snapshot de estado: %v", err)
         } else {
-            log.Printf("🔍 DEBUG: Sin alertas de comportamiento para %s", data.DeviceID)
-        }
-
-        // ✅ Datos procesados correctamente
-        fmt.Printf("✅ Datos de %s procesados y validados\n", data.DeviceID)
-    })
-
-    // Limpiar quarantine periódicamente
-    go func() {
-        ticker := time.NewTicker(1 * time.Minute)
-        defer ticker.Stop()
-        
-        for range ticker.C {
-            quarantineSystem.mutex.Lock()
-            now := time.Now()
-            toDelete := make([]string, 0)
-            
-            for deviceID, quarantineTime := range quarantineSystem.quarantinedDevices {
-                if now.Sub(quarantineTime) > QUARANTINE_DURATION {
-                    toDelete = append(toDelete, deviceID)
-                }
-            }
-            
-            for _, deviceID := range toDelete {
-                delete(quarantineSystem.quarantinedDevices, deviceID)
-                log.Printf("✅ QUARANTINE: Dispositivo %s liberado automáticamente", deviceID)
-            }
-            quarantineSystem.mutex.Unlock()
+            fmt.Printf("💾 Estado guardado en %s\n", stateSnapshotFile)
         }
-    }()
+    }
 
-    fmt.Println("🚀 Sistema de seguridad IoT funcionando...")
-    fmt.Printf("📊 Configuración: %d msg/min máximo, quarantine %v, threshold anomalías %d\n", 
-        MAX_MESSAGES_PER_MINUTE, QUARANTINE_DURATION, ANOMALY_THRESHOLD)
-    
-    // Mantener el programa corriendo
-    select {}
+    fmt.Println("👋 Sistema de seguridad IoT detenido")
 }
@@ -0,0 +1,465 @@
+package main
+
+import (
+    "context"
+    "errors"
+    "io"
+    "log"
+    "math"
+    "net/http"
+    "os"
+    "strings"
+    "testing"
+    "time"
+)
+
+// Instante fijo de referencia para los relojes de los tests
+var testEpoch = time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+
+func TestMain(m *testing.M) {
+    // Los emojis del log no aportan nada en la salida de los tests
+    log.SetOutput(io.Discard)
+    logger = NewLoggerWithFormat(LOG_FORMAT_TEXT)
+    logger.out = io.Discard
+    os.Exit(m.Run())
+}
+
+// Dejar el hub como recién arrancado, con un reloj manual, y restaurar los
+// singletons al terminar el test
+func setupTestHub(t *testing.T) *FakeClock {
+    t.Helper()
+
+    saved := struct {
+        quarantineSystem    *QuarantineSystem
+        readingHistory      *ReadingHistory
+        anomalyRepository   *AnomalyRepository
+        stormProtection     *StormProtection
+        notificationManager *NotificationManager
+        anomalyExporter     *AnomalyExporter
+        alertDeduplicator   *AlertDeduplicator
+        acknowledgments     *AcknowledgmentTracker
+        messageDeduplicator *MessageDeduplicator
+        payloadDeduplicator *MessageDeduplicator
+        groupMaintenance    *GroupMaintenance
+        throughput          *ThroughputTracker
+        validationConfig    ValidationConfig
+    }{
+        quarantineSystem, readingHistory, anomalyRepository, stormProtection,
+        notificationManager, anomalyExporter, alertDeduplicator, acknowledgments,
+        messageDeduplicator, payloadDeduplicator, groupMaintenance, throughput, validationConfig,
+    }
+    t.Cleanup(func() {
+        quarantineSystem = saved.quarantineSystem
+        readingHistory = saved.readingHistory
+        anomalyRepository = saved.anomalyRepository
+        stormProtection = saved.stormProtection
+        notificationManager = saved.notificationManager
+        anomalyExporter = saved.anomalyExporter
+        alertDeduplicator = saved.alertDeduplicator
+        acknowledgments = saved.acknowledgments
+        messageDeduplicator = saved.messageDeduplicator
+        payloadDeduplicator = saved.payloadDeduplicator
+        groupMaintenance = saved.groupMaintenance
+        throughput = saved.throughput
+        validationConfig = saved.validationConfig
+    })
+
+    clock := NewFakeClock(testEpoch)
+    quarantineSystem = NewQuarantineSystem()
+    quarantineSystem.SetClock(clock)
+    readingHistory = NewReadingHistory(READING_HISTORY_SIZE)
+    anomalyRepository = NewAnomalyRepository(ANOMALY_REPOSITORY_SIZE)
+    anomalyRepository.SetClock(clock)
+    stormProtection = NewStormProtection()
+    stormProtection.SetClock(clock)
+    notificationManager = NewNotificationManager()
+    notificationManager.SetClock(clock)
+    anomalyExporter = NewAnomalyExporter()
+    alertDeduplicator = NewAlertDeduplicator()
+    acknowledgments = NewAcknowledgmentTracker()
+    messageDeduplicator = NewMessageDeduplicator(&messageDedupWindow)
+    payloadDeduplicator = NewMessageDeduplicator(&payloadDedupWindow)
+    payloadDeduplicator.SetClock(clock)
+    messageDeduplicator.SetClock(clock)
+    groupMaintenance = NewGroupMaintenance()
+    groupMaintenance.SetClock(clock)
+    throughput = NewThroughputTracker()
+    throughput.SetClock(clock)
+    validationConfig = DefaultValidationConfig()
+    validationConfig.Clock = clock
+    return clock
+}
+
+func TestUnsubscribeTopics(t *testing.T) {
+    client := &fakeMQTTClient{}
+    topics := []string{"iot/sensors", "iot/sensors/cbor"}
+    if err := subscribeTopics(context.Background(), client, topics); err != nil {
+        t.Fatalf("subscribeTopics: %v", err)
+    }
+
+    unsubscribeTopics(client, topics)
+    if len(client.subscribed) != 0 {
+        t.Errorf("suscripciones tras anularlas = %v", client.subscribed)
+    }
+}
+
+func TestValidateSensorData_NonFiniteReadings(t *testing.T) {
+    tests := []struct {
+        name    string
+        mutate  func(data *SensorData)
+        wantErr string
+    }{
+        {name: "lectura válida", mutate: func(data *SensorData) {}},
+        {name: "temperatura NaN", mutate: func(data *SensorData) { data.Temperature = math.NaN() }, wantErr: "temperature"},
+        {name: "temperatura +Inf", mutate: func(data *SensorData) { data.Temperature = math.Inf(1) }, wantErr: "temperature"},
+        {name: "humedad -Inf", mutate: func(data *SensorData) { data.Humidity = math.Inf(-1) }, wantErr: "humidity"},
+        {name: "batería NaN", mutate: func(data *SensorData) { data.BatteryLevel = math.NaN() }, wantErr: "battery_level"},
+        {name: "señal NaN", mutate: func(data *SensorData) { data.SignalStrength = math.NaN() }, wantErr: "signal_strength"},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            clock := NewFakeClock(testEpoch)
+            cfg := DefaultValidationConfig()
+            cfg.Clock = clock
+            data := SensorData{DeviceID: "sensor-1", Timestamp: testEpoch.Unix(), Temperature: 21, Humidity: 40, BatteryLevel: 80, SignalStrength: 70}
+            tt.mutate(&data)
+
+            err := validateSensorDataWith(&data, cfg)
+            if tt.wantErr == "" {
+                if err != nil {
+                    t.Fatalf("error inesperado: %v", err)
+                }
+                return
+            }
+            if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+                t.Errorf("error = %v, se esperaba uno sobre %s", err, tt.wantErr)
+            }
+        })
+    }
+}
+
+func TestAnalyzeDeviceBehavior_NonFiniteDoesNotPoisonAverages(t *testing.T) {
+    tests := []struct {
+        name  string
+        value float64
+    }{
+        {name: "NaN", value: math.NaN()},
+        {name: "+Inf", value: math.Inf(1)},
+        {name: "-Inf", value: math.Inf(-1)},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            setupTestHub(t)
+            for _, data := range []SensorData{
+                {DeviceID: "sensor-1", Temperature: 20, Humidity: 40, BatteryLevel: 80},
+                {DeviceID: "sensor-1", Temperature: tt.value, Humidity: tt.value, BatteryLevel: tt.value},
+                {DeviceID: "sensor-1", Temperature: 22, Humidity: 42, BatteryLevel: 80},
+            } {
+                if anomalies := quarantineSystem.AnalyzeDeviceBehavior(&data); len(anomalies) > 0 {
+                    t.Errorf("anomalías con la lectura %v: %v", data.Temperature, describeAnomalies(anomalies))
+                }
+            }
+
+            quarantineSystem.mutex.RLock()
+            behavior := *quarantineSystem.deviceBehavior["sensor-1"]
+            quarantineSystem.mutex.RUnlock()
+            for name, value := range map[string]float64{
+                "temperatura":       behavior.AvgTemperature,
+                "humedad":           behavior.AvgHumidity,
+                "batería":           behavior.AvgBattery,
+                "media estadística": behavior.TemperatureStats.Mean,
+                "varianza":          behavior.TemperatureStats.Variance,
+            } {
+                if !isFinite(value) {
+                    t.Errorf("promedio de %s = %v tras una lectura no finita", name, value)
+                }
+            }
+            if behavior.AvgTemperature != 21 {
+                t.Errorf("promedio de temperatura = %v, se esperaba 21 (sin la lectura no finita)", behavior.AvgTemperature)
+            }
+        })
+    }
+}
+
+func TestProcessSensorData_AnomaliesUseHubClock(t *testing.T) {
+    tests := []struct {
+        name    string
+        advance time.Duration
+    }{
+        {name: "al inicio", advance: 0},
+        {name: "una hora después", advance: time.Hour},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            clock := setupTestHub(t)
+            clock.Advance(tt.advance)
+            data := SensorData{DeviceID: "sensor-1", Timestamp: clock.Now().Unix(), Temperature: 95, Humidity: 40, BatteryLevel: 80}
+
+            if err := processSensorData(context.Background(), data, nil, nil); err != nil {
+                t.Fatalf("processSensorData: %v", err)
+            }
+            anomalies := anomalyRepository.ByDevice("sensor-1", false)
+            if len(anomalies) == 0 {
+                t.Fatal("no se registró la anomalía de temperatura")
+            }
+            for _, anomaly := range anomalies {
+                if !anomaly.Timestamp.Equal(clock.Now()) {
+                    t.Errorf("%s fechada en %v, se esperaba %v", anomaly.Type, anomaly.Timestamp, clock.Now())
+                }
+            }
+        })
+    }
+}
+
+func TestStartQuarantineCleanup(t *testing.T) {
+    tests := []struct {
+        name           string
+        advance        time.Duration
+        wantQuarantine bool
+    }{
+        {name: "quarantine vigente", advance: time.Second, wantQuarantine: true},
+        {name: "quarantine expirada", advance: QUARANTINE_DURATION + time.Second},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            clock := setupTestHub(t)
+            quarantineSystem.QuarantineDeviceWithReason("sensor-1", QuarantineReasonOther, "test")
+            clock.Advance(tt.advance)
+
+            ctx, cancel := context.WithCancel(context.Background())
+            done := startQuarantineCleanup(ctx, quarantineSystem, time.Millisecond)
+
+            // Esperar a que la limpieza pase al menos una vez
+            deadline := time.After(time.Second)
+            for quarantineSystem.QuarantinedCount() > 0 && !tt.wantQuarantine {
+                select {
+                case <-deadline:
+                    t.Fatal("la limpieza no liberó la quarantine expirada")
+                case <-time.After(time.Millisecond):
+                }
+            }
+
+            cancel()
+            select {
+            case <-done:
+            case <-time.After(time.Second):
+                t.Fatal("la limpieza no terminó al cancelar el contexto")
+            }
+            if got := quarantineSystem.QuarantinedCount() > 0; got != tt.wantQuarantine {
+                t.Errorf("en quarantine = %v, se esperaba %v", got, tt.wantQuarantine)
+            }
+        })
+    }
+}
+
+func TestQuarantineDeviceWithReason_Deduplication(t *testing.T) {
+    tests := []struct {
+        name        string
+        deduplicate bool
+        advance     time.Duration
+        wantAlerts  int
+        wantReason  QuarantineReason
+    }{
+        {name: "ya en quarantine", deduplicate: true, wantAlerts: 1, wantReason: QuarantineReasonBehaviorAnomaly},
+        {name: "sin deduplicación", deduplicate: false, wantAlerts: 2, wantReason: QuarantineReasonBehaviorAnomaly},
+        {name: "quarantine expirada", deduplicate: true, advance: QUARANTINE_DURATION + time.Second, wantAlerts: 2, wantReason: QuarantineReasonBehaviorAnomaly},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            clock := setupTestHub(t)
+            saved := deduplicateQuarantine
+            t.Cleanup(func() { deduplicateQuarantine = saved })
+            deduplicateQuarantine = tt.deduplicate
+
+            notifier := newRecordingNotifier("test")
+            notificationManager.Register(notifier)
+
+            quarantineSystem.QuarantineDeviceWithReason("sensor-1", QuarantineReasonInvalidData, "primera")
+            clock.Advance(tt.advance)
+            quarantineSystem.QuarantineDeviceWithReason("sensor-1", QuarantineReasonBehaviorAnomaly, "segunda")
+            flushNotifications(t)
+
+            if got := len(notifier.Quarantines()); got != tt.wantAlerts {
+                t.Errorf("alertas de quarantine = %d, se esperaban %d", got, tt.wantAlerts)
+            }
+            entry, quarantined := quarantineSystem.ActiveQuarantine("sensor-1")
+            if !quarantined {
+                t.Fatal("el dispositivo debería seguir en quarantine")
+            }
+            if entry.Reason != tt.wantReason || entry.Detail != "segunda" {
+                t.Errorf("razón = %s (%q), se esperaba %s (\"segunda\")", entry.Reason, entry.Detail, tt.wantReason)
+            }
+        })
+    }
+}
+
+func TestValidateSensorDataWith_ClockSkew(t *testing.T) {
+    tests := []struct {
+        name      string
+        tolerance string
+        skew      time.Duration
+        wantErr   bool
+    }{
+        {name: "dos horas adelantado con la tolerancia por defecto", skew: 2 * time.Hour, wantErr: true},
+        {name: "dos horas atrasado con la tolerancia por defecto", skew: -2 * time.Hour, wantErr: true},
+        {name: "dos horas adelantado con tolerancia relajada", tolerance: "3h", skew: 2 * time.Hour},
+        {name: "dos horas atrasado con tolerancia relajada", tolerance: "3h", skew: -2 * time.Hour},
+        {name: "fuera de la tolerancia relajada", tolerance: "3h", skew: 4 * time.Hour, wantErr: true},
+        {name: "dos minutos con tolerancia estricta", tolerance: "1m", skew: 2 * time.Minute, wantErr: true},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            clock := setupTestHub(t)
+            if tt.tolerance != "" {
+                t.Setenv("CLOCK_SKEW_TOLERANCE", tt.tolerance)
+            }
+            cfg, err := LoadConfig()
+            if err != nil {
+                t.Fatalf("LoadConfig() error = %v", err)
+            }
+            validation := ValidationConfig{ClockSkewTolerance: cfg.Security.ClockSkewTolerance, Clock: clock}
+
+            data := SensorData{DeviceID: "sensor-1", Timestamp: clock.Now().Add(tt.skew).Unix(), Temperature: 21}
+            if err := validateSensorDataWith(&data, validation); (err != nil) != tt.wantErr {
+                t.Errorf("error = %v, se esperaba error: %v", err, tt.wantErr)
+            }
+        })
+    }
+}
+
+func TestProcessSensorData_DryRun(t *testing.T) {
+    tests := []struct {
+        name           string
+        dryRun         string
+        wantQuarantine bool
+    }{
+        {name: "modo normal", dryRun: "false", wantQuarantine: true},
+        {name: "dry-run", dryRun: "true"},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            clock := setupTestHub(t)
+            t.Setenv("DRY_RUN", tt.dryRun)
+            cfg, err := LoadConfig()
+            if err != nil {
+                t.Fatalf("LoadConfig() error = %v", err)
+            }
+            savedProcessing, savedNotifications := dryRunProcessing, dryRunNotifications
+            t.Cleanup(func() { dryRunProcessing, dryRunNotifications = savedProcessing, savedNotifications })
+            dryRunProcessing = cfg.Security.DryRun
+            dryRunNotifications = cfg.Notifications.DryRun || cfg.Security.DryRun
+
+            notifier := newRecordingNotifier("test")
+            notificationManager.Register(notifier)
+            output := captureLog(t)
+
+            // Suficientes lecturas extremas para superar el umbral de quarantine
+            for i := 0; i < ANOMALY_THRESHOLD+2; i++ {
+                clock.Advance(time.Second)
+                data := SensorData{DeviceID: "sensor-1", Timestamp: clock.Now().Unix(), Temperature: 95, Humidity: 40, BatteryLevel: 80}
+                processSensorData(context.Background(), data, nil, nil)
+            }
+            flushNotifications(t)
+
+            if got := quarantineSystem.IsQuarantined("sensor-1"); got != tt.wantQuarantine {
+                t.Errorf("en quarantine = %v, se esperaba %v", got, tt.wantQuarantine)
+            }
+            if got := len(notifier.Anomalies()) > 0; got != tt.wantQuarantine {
+                t.Errorf("notificado = %v, se esperaba %v", got, tt.wantQuarantine)
+            }
+            if got := strings.Contains(output.String(), "[DRY-RUN] Se pondría en cuarentena el dispositivo sensor-1"); got == tt.wantQuarantine {
+                t.Errorf("registro de la quarantine simulada = %v, se esperaba %v", got, !tt.wantQuarantine)
+            }
+            // Las anomalías se detectan y registran en ambos modos
+            if len(anomalyRepository.ByDevice("sensor-1", false)) == 0 {
+                t.Error("no se registraron las anomalías")
+            }
+        })
+    }
+}
+
+func TestProcessSensorData_CancelledContext(t *testing.T) {
+    expired, cancelExpired := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+    defer cancelExpired()
+    cancelled, cancel := context.WithCancel(context.Background())
+    cancel()
+
+    tests := []struct {
+        name    string
+        ctx     context.Context
+        wantErr error
+    }{
+        {name: "contexto activo", ctx: context.Background()},
+        {name: "contexto cancelado", ctx: cancelled, wantErr: context.Canceled},
+        {name: "plazo vencido", ctx: expired, wantErr: context.DeadlineExceeded},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            clock := setupTestHub(t)
+            notifier := newRecordingNotifier("test")
+            notificationManager.Register(notifier)
+
+            data := SensorData{DeviceID: "sensor-1", Timestamp: clock.Now().Unix(), Temperature: 95, Humidity: 40, BatteryLevel: 80}
+            err := processSensorData(tt.ctx, data, nil, nil)
+            flushNotifications(t)
+
+            if tt.wantErr == nil {
+                if err != nil {
+                    t.Fatalf("processSensorData: %v", err)
+                }
+                if len(anomalyRepository.All()) == 0 || len(notifier.Anomalies()) == 0 {
+                    t.Error("la lectura con contexto activo no se analizó")
+                }
+                return
+            }
+
+            if !errors.Is(err, tt.wantErr) {
+                t.Fatalf("error = %v, se esperaba %v", err, tt.wantErr)
+            }
+            // Vuelve antes de tocar el estado del dispositivo
+            if _, tracked := quarantineSystem.GetDeviceStats("sensor-1"); tracked {
+                t.Error("el dispositivo se registró con el contexto cancelado")
+            }
+            if got := len(anomalyRepository.All()); got != 0 {
+                t.Errorf("anomalías guardadas = %d, se esperaban 0", got)
+            }
+            if got := len(readingHistory.All()); got != 0 {
+                t.Errorf("lecturas guardadas = %d, se esperaban 0", got)
+            }
+            if got := len(notifier.Anomalies()); got != 0 {
+                t.Errorf("alertas enviadas = %d, se esperaban 0", got)
+            }
+        })
+    }
+}
+
+func TestIngestPayloadResults_CancelledBatch(t *testing.T) {
+    setupTestHub(t)
+    ctx, cancel := context.WithCancel(context.Background())
+    cancel()
+
+    payload := "[" + validReadingJSON("sensor-1") + "," + validReadingJSON("sensor-2") + "]"
+    results, err := ingestPayloadResults(ctx, "test", []byte(payload))
+    if !errors.Is(err, context.Canceled) {
+        t.Fatalf("error = %v, se esperaba %v", err, context.Canceled)
+    }
+    if len(results) != 2 {
+        t.Fatalf("resultados = %d, se esperaban 2", len(results))
+    }
+    for _, result := range results {
+        if result.Status != http.StatusServiceUnavailable {
+            t.Errorf("lectura %d: estado = %d, se esperaba %d", result.Index, result.Status, http.StatusServiceUnavailable)
+        }
+    }
+    if got := len(readingHistory.All()); got != 0 {
+        t.Errorf("lecturas guardadas = %d, se esperaban 0", got)
+    }
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+    "crypto/sha256"
+    "encoding/hex"
+    "sync"
+    "time"
+)
+
+// Ventana en la que un message_id repetido se considera retransmisión
+// (redelivery QoS 1, reintentos del dispositivo). 0 = deshabilitado.
+var messageDedupWindow = 10 * time.Minute
+
+// Ventana en la que un payload idéntico byte a byte del mismo dispositivo
+// se considera reenvío del gateway. Corta: dos lecturas periódicas iguales
+// llevan timestamps distintos y por tanto bytes distintos. 0 = deshabilitado.
+var payloadDedupWindow = 5 * time.Second
+
+// Máximo de entradas de cada deduplicador. Si se llena con entradas aún
+// dentro de la ventana, los mensajes nuevos pasan sin deduplicar en lugar
+// de crecer sin límite.
+const MESSAGE_DEDUP_MAX_ENTRIES = 100000
+
+// Identificadores de mensaje ya procesados por dispositivo
+type MessageDeduplicator struct {
+    mutex     sync.Mutex
+    window    *time.Duration
+    seen      map[string]time.Time
+    lastPrune time.Time
+    clock     Clock
+}
+
+var messageDeduplicator = NewMessageDeduplicator(&messageDedupWindow)
+
+// Huellas de los payloads recientes por dispositivo
+var payloadDeduplicator = NewMessageDeduplicator(&payloadDedupWindow)
+
+// Inicializar deduplicador con la ventana indicada (se lee en cada mensaje,
+// así que sigue a la configuración)
+func NewMessageDeduplicator(window *time.Duration) *MessageDeduplicator {
+    return &MessageDeduplicator{
+        window: window,
+        seen:   make(map[string]time.Time),
+        clock:  RealClock{},
+    }
+}
+
+// Cambiar el reloj con el que se mide la ventana
+func (md *MessageDeduplicator) SetClock(clock Clock) {
+    md.mutex.Lock()
+    defer md.mutex.Unlock()
+
+    md.clock = clock
+}
+
+// Huella de un payload para la deduplicación por contenido. Hash
+// criptográfico: un dispositivo no puede fabricar payloads distintos con la
+// misma huella para que se descarte una lectura legítima.
+func payloadDigest(payload []byte) string {
+    if len(payload) == 0 {
+        return ""
+    }
+    digest := sha256.Sum256(payload)
+    return hex.EncodeToString(digest[:])
+}
+
+// Registrar el mensaje y devolver true si ya se había procesado dentro de la ventana
+func (md *MessageDeduplicator) IsDuplicate(deviceID, messageID string) bool {
+    if messageID == "" || *md.window <= 0 {
+        return false
+    }
+
+    md.mutex.Lock()
+    defer md.mutex.Unlock()
+
+    now := md.clock.Now()
+    if md.seenLocked(deviceID, messageID, now) {
+        return true
+    }
+    md.recordLocked(deviceID, messageID, now)
+    return false
+}
+
+// Verificar si el mensaje ya se registró dentro de la ventana, sin registrarlo
+func (md *MessageDeduplicator) Seen(deviceID, messageID string) bool {
+    if messageID == "" || *md.window <= 0 {
+        return false
+    }
+
+    md.mutex.Lock()
+    defer md.mutex.Unlock()
+
+    return md.seenLocked(deviceID, messageID, md.clock.Now())
+}
+
+// Registrar el mensaje (p. ej. una vez aceptada la lectura, para que un
+// reintento de una lectura rechazada no se descarte como duplicado)
+func (md *MessageDeduplicator) Record(deviceID, messageID string) {
+    if messageID == "" || *md.window <= 0 {
+        return
+    }
+
+    md.mutex.Lock()
+    defer md.mutex.Unlock()
+
+    md.recordLocked(deviceID, messageID, md.clock.Now())
+}
+
+// Clave de un mensaje en el deduplicador
+func messageDedupKey(deviceID, messageID string) string {
+    return deviceID + "\x00" + messageID
+}
+
+// Llamar con el lock tomado
+func (md *MessageDeduplicator) seenLocked(deviceID, messageID string, now time.Time) bool {
+    md.pruneLocked(now, false)
+    seenAt, exists := md.seen[messageDedupKey(deviceID, messageID)]
+    return exists && now.Sub(seenAt) < *md.window
+}
+
+// Llamar con el lock tomado
+func (md *MessageDeduplicator) recordLocked(deviceID, messageID string, now time.Time) {
+    key := messageDedupKey(deviceID, messageID)
+    if _, exists := md.seen[key]; !exists && len(md.seen) >= MESSAGE_DEDUP_MAX_ENTRIES {
+        md.pruneLocked(now, true)
+        if len(md.seen) >= MESSAGE_DEDUP_MAX_ENTRIES {
+            metrics.Inc("iot_messages_dedup_overflow")
+            return
+        }
+    }
+    md.seen[key] = now
+}
+
+// Olvidar los mensajes fuera de la ventana (como mucho una vez por ventana,
+// salvo que se fuerce por estar lleno)
+func (md *MessageDeduplicator) pruneLocked(now time.Time, force bool) {
+    window := *md.window
+    if !force && now.Sub(md.lastPrune) < window {
+        return
+    }
+    for key, seenAt := range md.seen {
+        if now.Sub(seenAt) >= window {
+            delete(md.seen, key)
+        }
+    }
+    md.lastPrune = now
+}
+
+// ID determinista de una anomalía detectada en un mensaje con message_id:
+// reprocesar el mismo mensaje produce el mismo ID
+func idempotentAnomalyID(anomaly *Anomaly) string {
+    digest := sha256.Sum256([]byte(anomaly.DeviceID + "\x00" + anomaly.MessageID + "\x00" + string(anomaly.Type) + "\x00" + anomaly.Description))
+    return hex.EncodeToString(digest[:8])
+}
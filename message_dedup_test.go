@@ -0,0 +1,173 @@
+package main
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "testing"
+    "time"
+)
+
+func TestMessageDeduplicator_IsDuplicate(t *testing.T) {
+    tests := []struct {
+        name     string
+        window   time.Duration
+        messages [][2]string
+        want     []bool
+    }{
+        {
+            name:     "mismo message_id",
+            window:   time.Minute,
+            messages: [][2]string{{"sensor-1", "m1"}, {"sensor-1", "m1"}},
+            want:     []bool{false, true},
+        },
+        {
+            name:     "otro dispositivo",
+            window:   time.Minute,
+            messages: [][2]string{{"sensor-1", "m1"}, {"sensor-2", "m1"}},
+            want:     []bool{false, false},
+        },
+        {
+            name:     "sin message_id",
+            window:   time.Minute,
+            messages: [][2]string{{"sensor-1", ""}, {"sensor-1", ""}},
+            want:     []bool{false, false},
+        },
+        {
+            name:     "deduplicación desactivada",
+            window:   0,
+            messages: [][2]string{{"sensor-1", "m1"}, {"sensor-1", "m1"}},
+            want:     []bool{false, false},
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            window := tt.window
+            dedup := NewMessageDeduplicator(&window)
+            for i, message := range tt.messages {
+                if got := dedup.IsDuplicate(message[0], message[1]); got != tt.want[i] {
+                    t.Errorf("mensaje %d: IsDuplicate = %v, se esperaba %v", i, got, tt.want[i])
+                }
+            }
+        })
+    }
+}
+
+func TestMessageDeduplicator_Capped(t *testing.T) {
+    window := time.Hour
+    dedup := NewMessageDeduplicator(&window)
+    for i := 0; i < MESSAGE_DEDUP_MAX_ENTRIES; i++ {
+        dedup.IsDuplicate("sensor-1", fmt.Sprintf("m%d", i))
+    }
+
+    overflows := metrics.Total("iot_messages_dedup_overflow")
+    if dedup.IsDuplicate("sensor-1", "nuevo") || dedup.IsDuplicate("sensor-1", "nuevo") {
+        t.Error("con el deduplicador lleno un mensaje nuevo no debe contar como duplicado")
+    }
+    if len(dedup.seen) != MESSAGE_DEDUP_MAX_ENTRIES {
+        t.Errorf("entradas = %d, se esperaba el máximo %d", len(dedup.seen), MESSAGE_DEDUP_MAX_ENTRIES)
+    }
+    if got := metrics.Total("iot_messages_dedup_overflow") - overflows; got != 2 {
+        t.Errorf("desbordamientos contados = %v, se esperaban 2", got)
+    }
+    if !dedup.IsDuplicate("sensor-1", "m0") {
+        t.Error("los mensajes ya registrados deben seguir deduplicándose")
+    }
+}
+
+func TestProcessSensorData_RateLimitBeforeMessageDedup(t *testing.T) {
+    setupTestHub(t)
+    clock := NewFakeClock(testEpoch)
+    limiter := NewFixedWindowRateLimiter(1, time.Minute)
+    limiter.SetClock(clock)
+    quarantineSystem.SetRateLimiter(limiter)
+
+    // Agotar la cuota del dispositivo
+    if !quarantineSystem.CheckRateLimit("sensor-1") {
+        t.Fatal("el primer mensaje debe pasar el rate limit")
+    }
+
+    data := SensorData{DeviceID: "sensor-1", MessageID: "m1", Temperature: 21, Humidity: 40}
+    err := processSensorData(context.Background(), data, nil, []byte(`{"device_id":"sensor-1","message_id":"m1"}`))
+    if !errors.Is(err, ErrRateLimited) {
+        t.Fatalf("error = %v, se esperaba ErrRateLimited", err)
+    }
+    if messageDeduplicator.IsDuplicate("sensor-1", "m1") {
+        t.Error("un mensaje rechazado por rate limit no debe registrarse en el deduplicador")
+    }
+}
+
+func TestMessageDeduplicator_WindowExpiry(t *testing.T) {
+    tests := []struct {
+        name    string
+        advance time.Duration
+        want    bool
+    }{
+        {name: "dentro de la ventana", advance: time.Minute - time.Second, want: true},
+        {name: "ventana expirada", advance: time.Minute},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            clock := NewFakeClock(testEpoch)
+            window := time.Minute
+            dedup := NewMessageDeduplicator(&window)
+            dedup.SetClock(clock)
+
+            dedup.IsDuplicate("sensor-1", "m1")
+            clock.Advance(tt.advance)
+            if got := dedup.IsDuplicate("sensor-1", "m1"); got != tt.want {
+                t.Errorf("IsDuplicate tras %v = %v, se esperaba %v", tt.advance, got, tt.want)
+            }
+        })
+    }
+}
+
+func TestIngestPayload_PayloadDedup(t *testing.T) {
+    reading := func(timestamp int64) []byte {
+        return []byte(fmt.Sprintf(`{"device_id":"sensor-1","timestamp":%d,"temperature":21,"humidity":40,"battery_level":80}`, timestamp))
+    }
+    first, second := reading(testEpoch.Unix()), reading(testEpoch.Unix()+1)
+
+    tests := []struct {
+        name string
+        // Limitar a un mensaje cada 2 segundos
+        rateLimited bool
+        sends       [][]byte
+        // Avance del reloj antes de cada envío
+        advance        []time.Duration
+        wantProcessed  float64
+        wantDuplicates float64
+    }{
+        {name: "mismos bytes tres veces", sends: [][]byte{first, first, first}, advance: []time.Duration{0, time.Second, time.Second}, wantProcessed: 1, wantDuplicates: 2},
+        {name: "lecturas iguales con timestamps distintos", sends: [][]byte{first, second}, advance: []time.Duration{0, time.Second}, wantProcessed: 2},
+        {name: "reenvío fuera de la ventana", sends: [][]byte{first, first}, advance: []time.Duration{0, 5 * time.Second}, wantProcessed: 1},
+        {name: "reintento de una lectura rechazada por rate limit", rateLimited: true, sends: [][]byte{first, second, second}, advance: []time.Duration{0, time.Second, time.Second}, wantProcessed: 2},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            clock := setupTestHub(t)
+            if tt.rateLimited {
+                limiter := NewFixedWindowRateLimiter(1, 2*time.Second)
+                limiter.SetClock(clock)
+                quarantineSystem.SetRateLimiter(limiter)
+            }
+            processed := metrics.Total("iot_messages_processed")
+            duplicates := metrics.Total("iot_messages_payload_duplicate")
+
+            for i, payload := range tt.sends {
+                clock.Advance(tt.advance[i])
+                ingestPayload(context.Background(), "test", payload)
+            }
+
+            if got := metrics.Total("iot_messages_processed") - processed; got != tt.wantProcessed {
+                t.Errorf("lecturas procesadas = %v, se esperaban %v", got, tt.wantProcessed)
+            }
+            if got := metrics.Total("iot_messages_payload_duplicate") - duplicates; got != tt.wantDuplicates {
+                t.Errorf("duplicados contados = %v, se esperaban %v", got, tt.wantDuplicates)
+            }
+        })
+    }
+}
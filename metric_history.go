@@ -0,0 +1,98 @@
+package main
+
+import (
+    "strconv"
+    "strings"
+)
+
+// Métricas numéricas de los sensores (mismo nombre que el campo JSON)
+const (
+    METRIC_TEMPERATURE     = "temperature"
+    METRIC_HUMIDITY        = "humidity"
+    METRIC_BATTERY_LEVEL   = "battery_level"
+    METRIC_SIGNAL_STRENGTH = "signal_strength"
+    METRIC_ACCESS_ATTEMPTS = "access_attempts"
+)
+
+// Número de lecturas recientes por métrica que se adjuntan a las alertas
+var metricHistoryLength = 5
+
+// Métrica asociada a cada tipo de anomalía
+func metricForType(anomalyType AnomalyType) string {
+    switch anomalyType {
+    case AnomalyTemperature:
+        return METRIC_TEMPERATURE
+    case AnomalyHumidity:
+        return METRIC_HUMIDITY
+    case AnomalyBattery:
+        return METRIC_BATTERY_LEVEL
+    case AnomalyAccessAttempts:
+        return METRIC_ACCESS_ATTEMPTS
+    case AnomalySignal:
+        return METRIC_SIGNAL_STRENGTH
+    default:
+        return ""
+    }
+}
+
+// Valores presentes en una lectura, por métrica
+func metricValues(data *SensorData) map[string]float64 {
+    values := make(map[string]float64)
+    if data.hasReading("temperature", data.Temperature) {
+        values[METRIC_TEMPERATURE] = data.Temperature
+    }
+    if data.hasReading("humidity", data.Humidity) {
+        values[METRIC_HUMIDITY] = data.Humidity
+    }
+    if data.hasReading("battery_level", data.BatteryLevel) {
+        values[METRIC_BATTERY_LEVEL] = data.BatteryLevel
+    }
+    if data.hasReading("signal_strength", data.SignalStrength) {
+        values[METRIC_SIGNAL_STRENGTH] = data.SignalStrength
+    }
+    if data.hasReading("access_attempts", float64(data.AccessAttempts)) {
+        values[METRIC_ACCESS_ATTEMPTS] = float64(data.AccessAttempts)
+    }
+    return values
+}
+
+// Guardar los valores de la lectura en el historial acotado de cada métrica
+func (qs *QuarantineSystem) RecordMetricHistory(data *SensorData) {
+    qs.mutex.Lock()
+    defer qs.mutex.Unlock()
+
+    behavior := qs.behaviorLocked(data.DeviceID)
+    for metric, value := range metricValues(data) {
+        history := append(behavior.MetricHistory[metric], value)
+        if len(history) > metricHistoryLength {
+            history = history[len(history)-metricHistoryLength:]
+        }
+        behavior.MetricHistory[metric] = history
+    }
+}
+
+// Adjuntar a cada anomalía las lecturas recientes de su métrica (de más antigua a más reciente)
+func (qs *QuarantineSystem) AttachHistory(anomalies []Anomaly) {
+    qs.mutex.RLock()
+    defer qs.mutex.RUnlock()
+
+    for i := range anomalies {
+        behavior := qs.deviceBehavior[anomalies[i].DeviceID]
+        if behavior == nil || anomalies[i].Metric == "" {
+            continue
+        }
+
+        history := behavior.MetricHistory[anomalies[i].Metric]
+        anomalies[i].History = make([]float64, len(history))
+        copy(anomalies[i].History, history)
+    }
+}
+
+// Formatear el historial como "24, 25, 26, 80, 85"
+func formatHistory(history []float64) string {
+    values := make([]string, 0, len(history))
+    for _, value := range history {
+        values = append(values, strconv.FormatFloat(value, 'f', -1, 64))
+    }
+    return strings.Join(values, ", ")
+}
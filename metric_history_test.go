@@ -0,0 +1,69 @@
+package main
+
+import (
+    "context"
+    "reflect"
+    "testing"
+    "time"
+)
+
+func TestProcessSensorData_AlertIncludesMetricHistory(t *testing.T) {
+    tests := []struct {
+        name        string
+        length      int
+        wantHistory []float64
+    }{
+        {name: "últimas 5 lecturas", length: 5, wantHistory: []float64{24, 25, 26, 27, 85}},
+        {name: "historial más corto", length: 3, wantHistory: []float64{26, 27, 85}},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            clock := setupTestHub(t)
+            saved := metricHistoryLength
+            t.Cleanup(func() { metricHistoryLength = saved })
+            metricHistoryLength = tt.length
+            notifier := newRecordingNotifier("test")
+            notificationManager.Register(notifier)
+
+            for _, temperature := range []float64{23, 24, 25, 26, 27, 85} {
+                clock.Advance(time.Second)
+                data := SensorData{DeviceID: "sensor-1", Timestamp: clock.Now().Unix(), Temperature: temperature, Humidity: 10, BatteryLevel: 80}
+                if err := processSensorData(context.Background(), data, nil, nil); err != nil {
+                    t.Fatalf("processSensorData(%v): %v", temperature, err)
+                }
+            }
+            flushNotifications(t)
+
+            var alert *Anomaly
+            for _, anomaly := range notifier.Anomalies() {
+                if anomaly.Type == AnomalyTemperature {
+                    alert = &anomaly
+                    break
+                }
+            }
+            if alert == nil {
+                t.Fatal("no se notificó la temperatura extrema")
+            }
+            if !reflect.DeepEqual(alert.History, tt.wantHistory) {
+                t.Errorf("historial = %v, se esperaba %v", alert.History, tt.wantHistory)
+            }
+        })
+    }
+}
+
+func TestFormatHistory(t *testing.T) {
+    tests := []struct {
+        history []float64
+        want    string
+    }{
+        {history: nil, want: ""},
+        {history: []float64{24, 25.5, 80}, want: "24, 25.5, 80"},
+    }
+
+    for _, tt := range tests {
+        if got := formatHistory(tt.history); got != tt.want {
+            t.Errorf("formatHistory(%v) = %q, se esperaba %q", tt.history, got, tt.want)
+        }
+    }
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+    "fmt"
+    "io"
+    "sort"
+    "strings"
+    "sync"
+)
+
+// Tipos de métrica soportados
+const (
+    METRIC_COUNTER = "counter"
+    METRIC_GAUGE   = "gauge"
+)
+
+// Familia de métricas con sus valores por combinación de labels
+type metricFamily struct {
+    name    string
+    kind    string
+    help    string
+    values  map[string]float64
+    collect func() float64
+}
+
+// Registro de métricas en memoria, exportable en formato OpenMetrics
+type MetricsRegistry struct {
+    mutex    sync.Mutex
+    families map[string]*metricFamily
+    order    []string
+}
+
+var metrics = NewMetricsRegistry()
+
+// Inicializar registro de métricas con las métricas del hub
+func NewMetricsRegistry() *MetricsRegistry {
+    registry := &MetricsRegistry{
+        families: make(map[string]*metricFamily),
+        order:    make([]string, 0),
+    }
+
+    registry.Register("iot_messages_received", METRIC_COUNTER, "Mensajes MQTT recibidos")
+    registry.Register("iot_messages_rejected", METRIC_COUNTER, "Mensajes rechazados por motivo")
+    registry.Register("iot_messages_processed", METRIC_COUNTER, "Mensajes procesados y validados")
+    registry.Register("iot_anomalies_detected", METRIC_COUNTER, "Anomalías detectadas por origen")
+    registry.Register("iot_quarantines", METRIC_COUNTER, "Dispositivos puestos en cuarentena")
+    registry.Register("iot_anomaly_storms", METRIC_COUNTER, "Tormentas de anomalías detectadas")
+    registry.Register("iot_dry_run_quarantines", METRIC_COUNTER, "Cuarentenas omitidas en modo simulación")
+    registry.Register("iot_dead_letters", METRIC_COUNTER, "Mensajes no decodificables guardados en el dead-letter log")
+    registry.Register("iot_messages_duplicate", METRIC_COUNTER, "Retransmisiones descartadas por message_id repetido")
+    registry.Register("iot_alerts_deduplicated", METRIC_COUNTER, "Alertas no notificadas por repetir dispositivo y tipo dentro de la ventana")
+    registry.Register("iot_alerts_acknowledged", METRIC_COUNTER, "Alertas no notificadas por estar reconocidas")
+    registry.Register("iot_alerts_below_severity", METRIC_COUNTER, "Alertas no notificadas por severidad inferior a la mínima")
+    registry.Register("iot_anomalies_evicted", METRIC_COUNTER, "Anomalías eliminadas por superar la retención")
+    registry.Register("iot_messages_payload_duplicate", METRIC_COUNTER, "Mensajes descartados por repetir byte a byte un payload reciente")
+    registry.Register("iot_devices_evicted", METRIC_COUNTER, "Dispositivos desplazados al alcanzar el máximo en memoria")
+    registry.Register("iot_alerts_quiet_hours", METRIC_COUNTER, "Alertas retenidas durante las horas de silencio")
+    registry.Register("iot_mqtt_connection_lost", METRIC_COUNTER, "Conexiones con el broker MQTT perdidas")
+    registry.Register("iot_mqtt_reconnects", METRIC_COUNTER, "Intentos de reconexión al broker MQTT")
+    registry.Register("iot_mqtt_resubscriptions", METRIC_COUNTER, "Suscripciones restauradas tras reconectar")
+    registry.Register("iot_repository_write_failures", METRIC_COUNTER, "Escrituras en el repositorio compartido fallidas tras los reintentos")
+    registry.Register("iot_commands_failed", METRIC_COUNTER, "Comandos a dispositivos no confirmados por el broker")
+    registry.Register("iot_anomaly_export_failures", METRIC_COUNTER, "Anomalías no exportadas por destino")
+    registry.Register("iot_rate_limit_fallbacks", METRIC_COUNTER, "Consultas de rate limit resueltas en local por fallo de Redis")
+    registry.Register("iot_messages_dedup_overflow", METRIC_COUNTER, "Mensajes sin deduplicar por estar lleno el deduplicador")
+
+    return registry
+}
+
+// Registrar una familia de métricas
+func (r *MetricsRegistry) Register(name, kind, help string) {
+    r.mutex.Lock()
+    defer r.mutex.Unlock()
+
+    if _, exists := r.families[name]; exists {
+        return
+    }
+    r.families[name] = &metricFamily{
+        name:   name,
+        kind:   kind,
+        help:   help,
+        values: make(map[string]float64),
+    }
+    r.order = append(r.order, name)
+}
+
+// Registrar un gauge cuyo valor se calcula al exportar
+func (r *MetricsRegistry) RegisterGaugeFunc(name, help string, collect func() float64) {
+    r.Register(name, METRIC_GAUGE, help)
+
+    r.mutex.Lock()
+    defer r.mutex.Unlock()
+    r.families[name].collect = collect
+}
+
+// Formatear labels como `k1="v1",k2="v2"` (se pasan como pares clave, valor)
+func formatLabels(labels []string) string {
+    parts := make([]string, 0, len(labels)/2)
+    for i := 0; i+1 < len(labels); i += 2 {
+        value := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(labels[i+1])
+        parts = append(parts, fmt.Sprintf(`%s="%s"`, labels[i], value))
+    }
+    return strings.Join(parts, ",")
+}
+
+// Sumar un valor a una métrica
+func (r *MetricsRegistry) Add(name string, value float64, labels ...string) {
+    r.mutex.Lock()
+    defer r.mutex.Unlock()
+
+    family := r.families[name]
+    if family == nil {
+        return
+    }
+    family.values[formatLabels(labels)] += value
+}
+
+// Incrementar un contador en 1
+func (r *MetricsRegistry) Inc(name string, labels ...string) {
+    r.Add(name, 1, labels...)
+}
+
+// Fijar el valor de un gauge
+func (r *MetricsRegistry) Set(name string, value float64, labels ...string) {
+    r.mutex.Lock()
+    defer r.mutex.Unlock()
+
+    family := r.families[name]
+    if family == nil {
+        return
+    }
+    family.values[formatLabels(labels)] = value
+}
+
+// Obtener el valor actual de una métrica
+func (r *MetricsRegistry) Value(name string, labels ...string) float64 {
+    r.mutex.Lock()
+    defer r.mutex.Unlock()
+
+    family := r.families[name]
+    if family == nil {
+        return 0
+    }
+    return family.values[formatLabels(labels)]
+}
+
+// Suma de una métrica sobre todas sus etiquetas
+func (r *MetricsRegistry) Total(name string) float64 {
+    r.mutex.Lock()
+    defer r.mutex.Unlock()
+
+    family := r.families[name]
+    if family == nil {
+        return 0
+    }
+    var total float64
+    for _, value := range family.values {
+        total += value
+    }
+    return total
+}
+
+// Escribir todas las métricas en formato de texto OpenMetrics
+func (r *MetricsRegistry) WriteOpenMetrics(w io.Writer) error {
+    r.mutex.Lock()
+    families := make([]metricFamily, 0, len(r.order))
+    for _, name := range r.order {
+        family := *r.families[name]
+        values := make(map[string]float64, len(family.values))
+        for labels, value := range family.values {
+            values[labels] = value
+        }
+        family.values = values
+        families = append(families, family)
+    }
+    r.mutex.Unlock()
+
+    var sb strings.Builder
+    for _, family := range families {
+        // Los gauge calculados se evalúan fuera del lock del registro
+        if family.collect != nil {
+            family.values[""] = family.collect()
+        }
+
+        sampleName := family.name
+        if family.kind == METRIC_COUNTER {
+            sampleName += "_total"
+        }
+
+        fmt.Fprintf(&sb, "# TYPE %s %s\n", family.name, family.kind)
+        fmt.Fprintf(&sb, "# HELP %s %s\n", family.name, family.help)
+
+        labelSets := make([]string, 0, len(family.values))
+        for labels := range family.values {
+            labelSets = append(labelSets, labels)
+        }
+        sort.Strings(labelSets)
+
+        for _, labels := range labelSets {
+            if labels == "" {
+                fmt.Fprintf(&sb, "%s %g\n", sampleName, family.values[labels])
+            } else {
+                fmt.Fprintf(&sb, "%s{%s} %g\n", sampleName, labels, family.values[labels])
+            }
+        }
+    }
+    sb.WriteString("# EOF\n")
+
+    _, err := io.WriteString(w, sb.String())
+    return err
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "os"
+    "strings"
+    "testing"
+)
+
+// Usar un registro de métricas vacío durante el test
+func withMetricsRegistry(t *testing.T) *MetricsRegistry {
+    t.Helper()
+    saved := metrics
+    t.Cleanup(func() { metrics = saved })
+    metrics = NewMetricsRegistry()
+    return metrics
+}
+
+func TestWriteOpenMetrics(t *testing.T) {
+    tests := []struct {
+        name   string
+        record func(registry *MetricsRegistry)
+        want   []string
+        absent []string
+    }{
+        {
+            name:   "contador con etiquetas",
+            record: func(registry *MetricsRegistry) { registry.Add("iot_messages_rejected", 3, "reason", "rate_limit") },
+            want:   []string{"# TYPE iot_messages_rejected counter", `iot_messages_rejected_total{reason="rate_limit"} 3`},
+        },
+        {
+            name: "gauge calculado al exportar",
+            record: func(registry *MetricsRegistry) {
+                registry.RegisterGaugeFunc("iot_quarantined_devices", "Dispositivos en cuarentena", func() float64 { return 7 })
+            },
+            want: []string{"# TYPE iot_quarantined_devices gauge", "iot_quarantined_devices 7"},
+        },
+        {
+            name:   "métrica no registrada",
+            record: func(registry *MetricsRegistry) { registry.Inc("iot_desconocida") },
+            absent: []string{"iot_desconocida"},
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            registry := NewMetricsRegistry()
+            tt.record(registry)
+
+            var sb strings.Builder
+            if err := registry.WriteOpenMetrics(&sb); err != nil {
+                t.Fatalf("WriteOpenMetrics: %v", err)
+            }
+            output := sb.String()
+            if !strings.HasSuffix(output, "# EOF\n") {
+                t.Error("la exportación debe terminar en # EOF")
+            }
+            for _, line := range tt.want {
+                if !strings.Contains(output, line+"\n") {
+                    t.Errorf("falta la línea %q", line)
+                }
+            }
+            for _, text := range tt.absent {
+                if strings.Contains(output, text) {
+                    t.Errorf("no se esperaba %q en la exportación", text)
+                }
+            }
+        })
+    }
+}
+
+func TestHandleMetricsDump(t *testing.T) {
+    registry := withMetricsRegistry(t)
+    saved := metricsDumpDir
+    t.Cleanup(func() { metricsDumpDir = saved })
+    metricsDumpDir = t.TempDir()
+    withAPIToken(t)
+
+    registry.Add("iot_messages_processed", 42)
+    registry.Inc("iot_anomalies_detected", "source", "basic")
+
+    recorder := httptest.NewRecorder()
+    newAPIRouter().ServeHTTP(recorder, newAuthorizedRequest(http.MethodPost, "/metrics/dump", nil))
+    if recorder.Code != http.StatusOK {
+        t.Fatalf("estado HTTP = %d, se esperaba 200 (%s)", recorder.Code, recorder.Body.String())
+    }
+
+    var response struct {
+        Path string `json:"path"`
+    }
+    if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+        t.Fatalf("respuesta no es JSON: %v", err)
+    }
+    dump, err := os.ReadFile(response.Path)
+    if err != nil {
+        t.Fatalf("volcado ilegible: %v", err)
+    }
+    for _, line := range []string{"iot_messages_processed_total 42", `iot_anomalies_detected_total{source="basic"} 1`, "# EOF"} {
+        if !strings.Contains(string(dump), line+"\n") {
+            t.Errorf("el volcado no contiene %q", line)
+        }
+    }
+}
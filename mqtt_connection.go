@@ -0,0 +1,67 @@
+package main
+
+import (
+    "context"
+    "sync/atomic"
+
+    mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// Callbacks de conexión del cliente MQTT: anuncian el estado del hub, dan
+// visibilidad a las caídas y reconexiones y restauran las suscripciones,
+// que con sesión limpia el broker no conserva al reconectar
+type mqttConnectionHandlers struct {
+    ctx    context.Context
+    cfg    MQTTConfig
+    topics []string
+    // Ya hubo una conexión: las siguientes son reconexiones
+    connectedOnce atomic.Bool
+}
+
+// Registrar los callbacks de conexión en las opciones del cliente
+func configureConnectionHandlers(ctx context.Context, opts *mqtt.ClientOptions, cfg MQTTConfig, topics []string) *mqttConnectionHandlers {
+    handlers := &mqttConnectionHandlers{ctx: ctx, cfg: cfg, topics: topics}
+    opts.SetOnConnectHandler(handlers.onConnect)
+    opts.SetConnectionLostHandler(handlers.onConnectionLost)
+    opts.SetReconnectingHandler(handlers.onReconnecting)
+    return handlers
+}
+
+// Conexión establecida. La suscripción inicial la hace main tras conectar;
+// en las reconexiones se repite aquí.
+func (h *mqttConnectionHandlers) onConnect(client mqtt.Client) {
+    publishHubStatus(client, h.cfg.StatusTopic, h.cfg.StatusOnline)
+
+    if !h.connectedOnce.Swap(true) {
+        return
+    }
+    logger.InfoWith("🔌 Reconectado al broker MQTT", map[string]interface{}{
+        "broker": h.cfg.Host,
+    })
+    if err := subscribeTopics(h.ctx, client, h.topics); err != nil {
+        logger.ErrorWith("❌ Error restaurando suscripciones MQTT tras reconectar", map[string]interface{}{
+            "broker": h.cfg.Host,
+            "topics": h.topics,
+            "error":  err.Error(),
+        })
+        return
+    }
+    metrics.Inc("iot_mqtt_resubscriptions")
+}
+
+// Conexión perdida; paho reintentará por su cuenta (auto-reconnect)
+func (h *mqttConnectionHandlers) onConnectionLost(client mqtt.Client, err error) {
+    logger.WarnWith("📴 Conexión con el broker MQTT perdida", map[string]interface{}{
+        "broker": h.cfg.Host,
+        "error":  err.Error(),
+    })
+    metrics.Inc("iot_mqtt_connection_lost")
+}
+
+// Intento de reconexión
+func (h *mqttConnectionHandlers) onReconnecting(client mqtt.Client, opts *mqtt.ClientOptions) {
+    logger.WarnWith("🔄 Reconectando al broker MQTT", map[string]interface{}{
+        "broker": h.cfg.Host,
+    })
+    metrics.Inc("iot_mqtt_reconnects")
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+    "context"
+    "errors"
+    "testing"
+
+    mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+func TestConfigureConnectionHandlers_Wired(t *testing.T) {
+    opts := mqtt.NewClientOptions()
+    configureConnectionHandlers(context.Background(), opts, MQTTConfig{}, []string{"iot/sensors"})
+
+    if opts.OnConnect == nil || opts.OnConnectionLost == nil || opts.OnReconnecting == nil {
+        t.Errorf("callbacks registrados: conexión %v, pérdida %v, reconexión %v",
+            opts.OnConnect != nil, opts.OnConnectionLost != nil, opts.OnReconnecting != nil)
+    }
+}
+
+func TestMQTTConnectionHandlers_ResubscribeOnReconnect(t *testing.T) {
+    tests := []struct {
+        name     string
+        topics   []string
+        connects int
+        // Topics suscritos por el callback (la suscripción inicial la hace main)
+        wantSubscribed      int
+        wantResubscriptions float64
+    }{
+        {name: "primera conexión", topics: []string{"iot/sensors", "iot/sensors/cbor"}, connects: 1},
+        {name: "reconexión", topics: []string{"iot/sensors", "iot/sensors/cbor"}, connects: 2, wantSubscribed: 2, wantResubscriptions: 1},
+        {name: "varias reconexiones", topics: []string{"iot/sensors"}, connects: 4, wantSubscribed: 1, wantResubscriptions: 3},
+        {name: "reconexión sin topics", connects: 2},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            registry := withMetricsRegistry(t)
+            withMQTTQoS(t, 1)
+            client := &fakeMQTTClient{}
+            handlers := configureConnectionHandlers(context.Background(), mqtt.NewClientOptions(), MQTTConfig{Host: "tcp://localhost:1883"}, tt.topics)
+
+            for i := 0; i < tt.connects; i++ {
+                handlers.onConnect(client)
+            }
+
+            if got := len(client.subscribed); got != tt.wantSubscribed {
+                t.Errorf("topics suscritos = %d, se esperaban %d", got, tt.wantSubscribed)
+            }
+            for topic, qos := range client.subscribed {
+                if qos != 1 {
+                    t.Errorf("%s resuscrito con QoS %d, se esperaba 1", topic, qos)
+                }
+            }
+            if got := registry.Value("iot_mqtt_resubscriptions"); got != tt.wantResubscriptions {
+                t.Errorf("iot_mqtt_resubscriptions = %v, se esperaba %v", got, tt.wantResubscriptions)
+            }
+        })
+    }
+}
+
+func TestMQTTConnectionHandlers_CountsFlaps(t *testing.T) {
+    registry := withMetricsRegistry(t)
+    opts := mqtt.NewClientOptions()
+    handlers := configureConnectionHandlers(context.Background(), opts, MQTTConfig{Host: "tcp://localhost:1883"}, nil)
+
+    for i := 0; i < 2; i++ {
+        handlers.onConnectionLost(nil, errors.New("EOF"))
+        handlers.onReconnecting(nil, opts)
+    }
+
+    tests := []struct {
+        metric string
+        want   float64
+    }{
+        {metric: "iot_mqtt_connection_lost", want: 2},
+        {metric: "iot_mqtt_reconnects", want: 2},
+    }
+    for _, tt := range tests {
+        if got := registry.Value(tt.metric); got != tt.want {
+            t.Errorf("%s = %v, se esperaba %v", tt.metric, got, tt.want)
+        }
+    }
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+    "fmt"
+    "os"
+
+    mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// QoS por defecto: como mucho una vez, como las suscripciones originales
+const MQTT_DEFAULT_QOS = 0
+
+// Prefijo del client ID cuando no se configura MQTT_CLIENT_ID. Compartido por
+// todas las instancias, así que nunca vale como client ID de una sesión
+// persistente: el broker desconectaría a un hub cada vez que se conecta otro.
+const MQTT_DEFAULT_CLIENT_ID = "iot_security_hub"
+
+// QoS de las suscripciones a lecturas.
+//   - 0: como mucho una vez; lo que llegue durante una reconexión se pierde.
+//   - 1: al menos una vez; el broker reentrega lo no confirmado, así que
+//     pueden llegar duplicados (los filtran message_id y el payload).
+//   - 2: exactamente una vez, con más ida y vuelta por mensaje.
+//
+// Con sesión limpia el broker no guarda mensajes mientras el hub está
+// desconectado: el QoS solo protege los mensajes en vuelo.
+var mqttQoS byte = MQTT_DEFAULT_QOS
+
+// QoS de los comandos de control y del estado del hub: al menos 1 aunque
+// las lecturas se reciban con QoS 0, porque perder una orden de quarantine
+// o el Last Will no es aceptable
+func controlQoS(qos byte) byte {
+    if qos < 1 {
+        return 1
+    }
+    return qos
+}
+
+// Client ID de esta instancia: el configurado o, con sesión limpia, el
+// prefijo por defecto con host y PID para que varios hubs contra el mismo
+// broker no se expulsen entre sí
+func sessionClientID(cfg MQTTConfig) string {
+    if cfg.ClientID != "" {
+        return cfg.ClientID
+    }
+    hostname, err := os.Hostname()
+    if err != nil {
+        hostname = "local"
+    }
+    return fmt.Sprintf("%s_%s_%d", MQTT_DEFAULT_CLIENT_ID, hostname, os.Getpid())
+}
+
+// Configurar la sesión MQTT: sesión limpia por defecto; la persistente (el
+// broker guarda los mensajes QoS ≥1 que lleguen mientras el hub se
+// reconecta) es opcional y exige un MQTT_CLIENT_ID propio de la instancia
+func configureSession(opts *mqtt.ClientOptions, cfg MQTTConfig) {
+    opts.SetClientID(sessionClientID(cfg))
+    opts.SetCleanSession(cfg.CleanSession)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+    "context"
+    "fmt"
+    "os"
+    "testing"
+
+    mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// Usar el QoS de lecturas indicado durante el test
+func withMQTTQoS(t *testing.T, qos byte) {
+    t.Helper()
+    saved := mqttQoS
+    t.Cleanup(func() { mqttQoS = saved })
+    mqttQoS = qos
+}
+
+func TestSubscribeTopics_UsesConfiguredQoS(t *testing.T) {
+    for _, qos := range []byte{0, 1, 2} {
+        t.Run(fmt.Sprintf("QoS %d", qos), func(t *testing.T) {
+            withMQTTQoS(t, qos)
+            client := &fakeMQTTClient{}
+            topics := []string{"iot/sensors", "iot/sensors/cbor"}
+
+            if err := subscribeTopics(context.Background(), client, topics); err != nil {
+                t.Fatalf("subscribeTopics: %v", err)
+            }
+            for _, topic := range topics {
+                if got, subscribed := client.subscribed[topic]; !subscribed || got != qos {
+                    t.Errorf("%s suscrito con QoS %d (suscrito: %v), se esperaba %d", topic, got, subscribed, qos)
+                }
+            }
+        })
+    }
+}
+
+func TestControlPublishes_AtLeastOnce(t *testing.T) {
+    tests := []struct {
+        name    string
+        qos     byte
+        wantQoS byte
+    }{
+        {name: "lecturas con QoS 0", qos: 0, wantQoS: 1},
+        {name: "lecturas con QoS 1", qos: 1, wantQoS: 1},
+        {name: "lecturas con QoS 2", qos: 2, wantQoS: 2},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            withMQTTQoS(t, tt.qos)
+            client := &fakeMQTTClient{}
+
+            NewMQTTCommandPublisher(client, "iot/control/{device_id}").Publish("iot/control/sensor-1", []byte(`{}`))
+            publishHubStatus(client, "iot-hub/status", "online")
+            for _, publish := range client.Published() {
+                if publish.qos != tt.wantQoS {
+                    t.Errorf("%s publicado con QoS %d, se esperaba %d", publish.topic, publish.qos, tt.wantQoS)
+                }
+            }
+
+            opts := mqtt.NewClientOptions()
+            configureStatusAnnouncements(opts, MQTTConfig{QoS: tt.qos, StatusTopic: "iot-hub/status", StatusOffline: "offline"})
+            if opts.WillQos != tt.wantQoS || !opts.WillRetained {
+                t.Errorf("Last Will con QoS %d (retenido: %v), se esperaba %d retenido", opts.WillQos, opts.WillRetained, tt.wantQoS)
+            }
+        })
+    }
+}
+
+func TestConfigureSession(t *testing.T) {
+    hostname, _ := os.Hostname()
+    generated := fmt.Sprintf("%s_%s_%d", MQTT_DEFAULT_CLIENT_ID, hostname, os.Getpid())
+
+    tests := []struct {
+        name         string
+        cfg          MQTTConfig
+        wantClientID string
+    }{
+        {name: "sesión limpia sin client ID: uno por instancia", cfg: MQTTConfig{CleanSession: true}, wantClientID: generated},
+        {name: "sesión limpia con client ID propio", cfg: MQTTConfig{ClientID: "hub-2", CleanSession: true}, wantClientID: "hub-2"},
+        {name: "sesión persistente", cfg: MQTTConfig{ClientID: "hub-planta-2"}, wantClientID: "hub-planta-2"},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            opts := mqtt.NewClientOptions()
+            configureSession(opts, tt.cfg)
+            if opts.ClientID != tt.wantClientID || opts.CleanSession != tt.cfg.CleanSession {
+                t.Errorf("sesión = %q/%v, se esperaba %q/%v", opts.ClientID, opts.CleanSession, tt.wantClientID, tt.cfg.CleanSession)
+            }
+        })
+    }
+}
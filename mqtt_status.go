@@ -0,0 +1,37 @@
+package main
+
+import (
+    "log"
+    "time"
+
+    mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// Tiempo máximo de espera al publicar el estado del hub
+const MQTT_STATUS_PUBLISH_TIMEOUT = 5 * time.Second
+
+// Configurar el Last Will del hub: el broker publica el payload offline
+// (retenido) si la conexión se pierde sin desconexión limpia. El payload
+// online lo publica el callback de conexión. Sin topic no se anuncia nada.
+func configureStatusAnnouncements(opts *mqtt.ClientOptions, cfg MQTTConfig) {
+    if cfg.StatusTopic == "" {
+        return
+    }
+    opts.SetWill(cfg.StatusTopic, cfg.StatusOffline, controlQoS(cfg.QoS), true)
+}
+
+// Publicar el estado del hub como mensaje retenido
+func publishHubStatus(client mqtt.Client, topic, payload string) {
+    if topic == "" {
+        return
+    }
+
+    token := client.Publish(topic, controlQoS(mqttQoS), true, payload)
+    if !token.WaitTimeout(MQTT_STATUS_PUBLISH_TIMEOUT) {
+        log.Printf("⚠️ Timeout publicando estado %q en %s", payload, topic)
+        return
+    }
+    if err := token.Error(); err != nil {
+        log.Printf("⚠️ Error publicando estado %q en %s: %v", payload, topic, err)
+    }
+}
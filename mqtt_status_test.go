@@ -0,0 +1,97 @@
+package main
+
+import (
+    "context"
+    "testing"
+
+    mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+func TestConfigureStatusAnnouncements(t *testing.T) {
+    tests := []struct {
+        name string
+        env  map[string]string
+        // Un valor vacío en el entorno toma el topic por defecto
+        clearTopic  bool
+        wantWill    bool
+        wantTopic   string
+        wantPayload string
+    }{
+        {name: "valores por defecto", wantWill: true, wantTopic: "iot-hub/status", wantPayload: "offline"},
+        {
+            name:        "topic y payload configurados",
+            env:         map[string]string{"MQTT_STATUS_TOPIC": "planta-1/hub", "MQTT_STATUS_OFFLINE": "caído"},
+            wantWill:    true,
+            wantTopic:   "planta-1/hub",
+            wantPayload: "caído",
+        },
+        {name: "sin topic de estado", clearTopic: true},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            for key, value := range tt.env {
+                t.Setenv(key, value)
+            }
+            cfg, err := LoadConfig()
+            if err != nil {
+                t.Fatalf("LoadConfig() error = %v", err)
+            }
+
+            if tt.clearTopic {
+                cfg.MQTT.StatusTopic = ""
+            }
+
+            opts := mqtt.NewClientOptions()
+            configureStatusAnnouncements(opts, cfg.MQTT)
+            if opts.WillEnabled != tt.wantWill {
+                t.Fatalf("Last Will habilitado = %v, se esperaba %v", opts.WillEnabled, tt.wantWill)
+            }
+            if !tt.wantWill {
+                return
+            }
+            if opts.WillTopic != tt.wantTopic || string(opts.WillPayload) != tt.wantPayload || !opts.WillRetained {
+                t.Errorf("Last Will = %s %q (retenido: %v), se esperaba %s %q retenido", opts.WillTopic, opts.WillPayload, opts.WillRetained, tt.wantTopic, tt.wantPayload)
+            }
+        })
+    }
+}
+
+func TestMQTTConnectionHandlers_AnnouncesOnline(t *testing.T) {
+    tests := []struct {
+        name        string
+        cfg         MQTTConfig
+        wantPublish bool
+    }{
+        {name: "con topic de estado", cfg: MQTTConfig{StatusTopic: "iot-hub/status", StatusOnline: "online"}, wantPublish: true},
+        {name: "sin topic de estado", cfg: MQTTConfig{StatusOnline: "online"}},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            client := &fakeMQTTClient{}
+            opts := mqtt.NewClientOptions()
+            handlers := configureConnectionHandlers(context.Background(), opts, tt.cfg, nil)
+            if opts.OnConnect == nil {
+                t.Fatal("no se registró el callback de conexión")
+            }
+
+            handlers.onConnect(client)
+
+            published := client.Published()
+            if !tt.wantPublish {
+                if len(published) != 0 {
+                    t.Errorf("publicaciones = %d, no se esperaba ninguna", len(published))
+                }
+                return
+            }
+            if len(published) != 1 {
+                t.Fatalf("publicaciones = %d, se esperaba 1", len(published))
+            }
+            got := published[0]
+            if got.topic != tt.cfg.StatusTopic || string(got.payload) != tt.cfg.StatusOnline || !got.retained {
+                t.Errorf("publicado %s %q (retenido: %v), se esperaba %s %q retenido", got.topic, got.payload, got.retained, tt.cfg.StatusTopic, tt.cfg.StatusOnline)
+            }
+        })
+    }
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+    "crypto/tls"
+    "crypto/x509"
+    "fmt"
+    "os"
+)
+
+// Construir la configuración TLS del cliente MQTT: CA propia opcional y
+// certificado de cliente opcional (TLS mutuo)
+func newMQTTTLSConfig(cfg MQTTConfig) (*tls.Config, error) {
+    tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+    if cfg.CACertPath != "" {
+        caCert, err := os.ReadFile(cfg.CACertPath)
+        if err != nil {
+            return nil, fmt.Errorf("no se pudo leer el certificado CA %s: %w", cfg.CACertPath, err)
+        }
+        pool := x509.NewCertPool()
+        if !pool.AppendCertsFromPEM(caCert) {
+            return nil, fmt.Errorf("el certificado CA %s no contiene certificados PEM válidos", cfg.CACertPath)
+        }
+        tlsConfig.RootCAs = pool
+    }
+
+    if cfg.ClientCertPath != "" || cfg.ClientKeyPath != "" {
+        if cfg.ClientCertPath == "" || cfg.ClientKeyPath == "" {
+            return nil, fmt.Errorf("TLS mutuo requiere MQTT_CLIENT_CERT y MQTT_CLIENT_KEY")
+        }
+        certificate, err := tls.LoadX509KeyPair(cfg.ClientCertPath, cfg.ClientKeyPath)
+        if err != nil {
+            return nil, fmt.Errorf("no se pudo cargar el certificado de cliente %s / %s: %w", cfg.ClientCertPath, cfg.ClientKeyPath, err)
+        }
+        tlsConfig.Certificates = []tls.Certificate{certificate}
+    }
+
+    return tlsConfig, nil
+}
@@ -0,0 +1,385 @@
+package main
+
+import (
+    "context"
+    "log"
+    "sort"
+    "sync"
+    "time"
+)
+
+// Timeout de cada envío de notificación
+const NOTIFICATION_TIMEOUT = 10 * time.Second
+
+// Canal de notificación (Discord, webhooks, ...)
+type NotificationService interface {
+    Name() string
+    SendAnomalyAlert(ctx context.Context, anomaly *Anomaly) error
+    SendQuarantineAlert(ctx context.Context, deviceID string, reason QuarantineReason, detail string, duration time.Duration) error
+    SendRecoveryAlert(ctx context.Context, deviceID string, reason ReleaseReason, actor string) error
+}
+
+// Canal capaz de mostrar el mensaje que enviaría sin enviarlo (modo dry-run)
+type NotificationRenderer interface {
+    RenderAnomalyAlert(anomaly *Anomaly) (string, error)
+    RenderQuarantineAlert(deviceID string, reason QuarantineReason, detail string, duration time.Duration) (string, error)
+    RenderRecoveryAlert(deviceID string, reason ReleaseReason, actor string) (string, error)
+}
+
+// Modo dry-run: registrar en el log el mensaje de cada canal en lugar de
+// enviarlo, para validar plantillas y enrutado sin molestar a nadie
+var dryRunNotifications = false
+
+// Reparte las alertas entre todos los canales registrados. Los envíos son
+// asíncronos para no bloquear el procesamiento de mensajes MQTT.
+type NotificationManager struct {
+    mutex    sync.RWMutex
+    services []NotificationService
+    routes   NotificationRoutes
+    pending  sync.WaitGroup
+    // Canales fijados por dispositivo (false = enrutado general)
+    deviceChannels func(deviceID string) ([]string, bool)
+    // Horas de silencio: las alertas no altas se retienen y se resumen al final
+    quietHours *CommunicationSchedule
+    quietQueue []Anomaly
+    quietHeld  int
+    clock      Clock
+}
+
+// Enrutado de anomalías a canales concretos (por nombre de canal). Una
+// anomalía va a la unión de los canales de su tipo y de su severidad; si no
+// coincide ninguna ruta, va a todos los canales.
+type NotificationRoutes struct {
+    ByType     map[AnomalyType][]string `json:"by_type"`
+    BySeverity map[string][]string      `json:"by_severity"`
+}
+
+var notificationManager = NewNotificationManager()
+
+// Inicializar gestor de notificaciones
+func NewNotificationManager() *NotificationManager {
+    return &NotificationManager{
+        services: make([]NotificationService, 0),
+        clock:    RealClock{},
+    }
+}
+
+// Registrar un canal de notificación
+func (nm *NotificationManager) Register(service NotificationService) {
+    nm.mutex.Lock()
+    defer nm.mutex.Unlock()
+
+    nm.services = append(nm.services, service)
+    log.Printf("🔔 NOTIFICACIONES: Canal %s registrado", service.Name())
+}
+
+// Configurar el enrutado por tipo/severidad
+func (nm *NotificationManager) SetRoutes(routes NotificationRoutes) {
+    nm.mutex.Lock()
+    defer nm.mutex.Unlock()
+
+    nm.routes = routes
+}
+
+// Canales nombrados en las rutas que no están registrados. Una ruta que solo
+// apunta a canales desconocidos deja sus alertas sin ningún destino.
+func (nm *NotificationManager) UnknownRouteChannels() []string {
+    nm.mutex.RLock()
+    defer nm.mutex.RUnlock()
+
+    registered := make(map[string]bool, len(nm.services))
+    for _, service := range nm.services {
+        registered[service.Name()] = true
+    }
+
+    seen := make(map[string]bool)
+    unknown := make([]string, 0)
+    check := func(names []string) {
+        for _, name := range names {
+            if !registered[name] && !seen[name] {
+                seen[name] = true
+                unknown = append(unknown, name)
+            }
+        }
+    }
+    for _, names := range nm.routes.ByType {
+        check(names)
+    }
+    for _, names := range nm.routes.BySeverity {
+        check(names)
+    }
+    sort.Strings(unknown)
+    return unknown
+}
+
+// Configurar la consulta de canales fijados por dispositivo
+func (nm *NotificationManager) SetDeviceChannels(lookup func(deviceID string) ([]string, bool)) {
+    nm.mutex.Lock()
+    defer nm.mutex.Unlock()
+
+    nm.deviceChannels = lookup
+}
+
+// Canales fijados para el dispositivo, si los tiene
+func (nm *NotificationManager) deviceOverride(deviceID string) ([]NotificationService, bool) {
+    nm.mutex.RLock()
+    lookup := nm.deviceChannels
+    nm.mutex.RUnlock()
+    if lookup == nil {
+        return nil, false
+    }
+
+    channels, found := lookup(deviceID)
+    if !found {
+        return nil, false
+    }
+    names := make(map[string]bool, len(channels))
+    for _, name := range channels {
+        names[name] = true
+    }
+
+    nm.mutex.RLock()
+    defer nm.mutex.RUnlock()
+
+    services := make([]NotificationService, 0, len(channels))
+    for _, service := range nm.services {
+        if names[service.Name()] {
+            services = append(services, service)
+        }
+    }
+    return services, true
+}
+
+// Canales que deben recibir las alertas de quarantine y recuperación de un dispositivo
+func (nm *NotificationManager) servicesForDevice(deviceID string) []NotificationService {
+    if services, found := nm.deviceOverride(deviceID); found {
+        return services
+    }
+    return nm.Services()
+}
+
+// Canales que deben recibir una anomalía según el enrutado
+func (nm *NotificationManager) servicesFor(anomaly *Anomaly) []NotificationService {
+    if services, found := nm.deviceOverride(anomaly.DeviceID); found {
+        return services
+    }
+
+    nm.mutex.RLock()
+    defer nm.mutex.RUnlock()
+
+    names := make(map[string]bool)
+    for _, name := range nm.routes.ByType[anomaly.Type] {
+        names[name] = true
+    }
+    for _, name := range nm.routes.BySeverity[anomaly.Severity] {
+        names[name] = true
+    }
+
+    services := make([]NotificationService, 0, len(nm.services))
+    for _, service := range nm.services {
+        if len(names) == 0 || names[service.Name()] {
+            services = append(services, service)
+        }
+    }
+    return services
+}
+
+// Copia de los canales registrados
+func (nm *NotificationManager) Services() []NotificationService {
+    nm.mutex.RLock()
+    defer nm.mutex.RUnlock()
+
+    services := make([]NotificationService, len(nm.services))
+    copy(services, nm.services)
+    return services
+}
+
+// Enviar en segundo plano a cada canal, registrando los errores
+func (nm *NotificationManager) dispatch(services []NotificationService, send func(ctx context.Context, service NotificationService) error) {
+    for _, service := range services {
+        nm.pending.Add(1)
+        go func(service NotificationService) {
+            defer nm.pending.Done()
+
+            ctx, cancel := context.WithTimeout(context.Background(), NOTIFICATION_TIMEOUT)
+            defer cancel()
+
+            if err := send(ctx, service); err != nil {
+                log.Printf("❌ NOTIFICACIONES: Error enviando por %s: %v", service.Name(), err)
+            }
+        }(service)
+    }
+}
+
+// Registrar el mensaje que cada canal enviaría (modo dry-run)
+func (nm *NotificationManager) logDryRun(services []NotificationService, render func(renderer NotificationRenderer) (string, error)) {
+    for _, service := range services {
+        renderer, ok := service.(NotificationRenderer)
+        if !ok {
+            log.Printf("🧪 DRY-RUN [%s]: el canal no permite previsualizar el mensaje", service.Name())
+            continue
+        }
+        message, err := render(renderer)
+        if err != nil {
+            log.Printf("❌ DRY-RUN [%s]: Error renderizando el mensaje: %v", service.Name(), err)
+            continue
+        }
+        if message == "" {
+            continue
+        }
+        log.Printf("🧪 DRY-RUN [%s]: %s", service.Name(), message)
+    }
+}
+
+// Notificar una anomalía a los canales que le correspondan (o retenerla si
+// estamos en horas de silencio)
+func (nm *NotificationManager) SendAnomalyAlert(anomaly Anomaly) {
+    if nm.holdForQuietHours(anomaly) {
+        log.Printf("🌙 Alerta de %s (%s) retenida hasta el fin de las horas de silencio", anomaly.DeviceID, anomaly.Type)
+        return
+    }
+    nm.sendAnomalyAlert(anomaly)
+}
+
+// Enviar una anomalía a sus canales sin pasar por las horas de silencio. El
+// contexto meteorológico se añade en segundo plano, antes del envío, para
+// que la consulta HTTP no frene el procesamiento de mensajes.
+func (nm *NotificationManager) sendAnomalyAlert(anomaly Anomaly) {
+    services := nm.servicesFor(&anomaly)
+    if len(services) == 0 {
+        return
+    }
+
+    nm.pending.Add(1)
+    go func() {
+        defer nm.pending.Done()
+
+        enrichAnomaly(&anomaly)
+        if dryRunNotifications {
+            nm.logDryRun(services, func(renderer NotificationRenderer) (string, error) {
+                return renderer.RenderAnomalyAlert(&anomaly)
+            })
+            return
+        }
+        nm.dispatch(services, func(ctx context.Context, service NotificationService) error {
+            return service.SendAnomalyAlert(ctx, &anomaly)
+        })
+    }()
+}
+
+// Notificar una cuarentena a los canales del dispositivo
+func (nm *NotificationManager) SendQuarantineAlert(deviceID string, reason QuarantineReason, detail string, duration time.Duration) {
+    if dryRunNotifications {
+        nm.logDryRun(nm.servicesForDevice(deviceID), func(renderer NotificationRenderer) (string, error) {
+            return renderer.RenderQuarantineAlert(deviceID, reason, detail, duration)
+        })
+        return
+    }
+    nm.dispatch(nm.servicesForDevice(deviceID), func(ctx context.Context, service NotificationService) error {
+        return service.SendQuarantineAlert(ctx, deviceID, reason, detail, duration)
+    })
+}
+
+// Notificar a los canales del dispositivo que salió de cuarentena
+func (nm *NotificationManager) SendRecoveryAlert(deviceID string, reason ReleaseReason, actor string) {
+    if dryRunNotifications {
+        nm.logDryRun(nm.servicesForDevice(deviceID), func(renderer NotificationRenderer) (string, error) {
+            return renderer.RenderRecoveryAlert(deviceID, reason, actor)
+        })
+        return
+    }
+    nm.dispatch(nm.servicesForDevice(deviceID), func(ctx context.Context, service NotificationService) error {
+        return service.SendRecoveryAlert(ctx, deviceID, reason, actor)
+    })
+}
+
+// Esperar a que terminen los envíos pendientes (o a que expire el contexto)
+func (nm *NotificationManager) Flush(ctx context.Context) error {
+    done := make(chan struct{})
+    go func() {
+        nm.pending.Wait()
+        close(done)
+    }()
+
+    select {
+    case <-done:
+        return nil
+    case <-ctx.Done():
+        return ctx.Err()
+    }
+}
+
+// Notificar una lista de anomalías
+func notifyAnomalies(anomalies []Anomaly) {
+    for _, anomaly := range anomalies {
+        now := notificationManager.Now()
+        suppressed, escalated := acknowledgments.Check(&anomaly, now)
+        if suppressed {
+            log.Printf("🔕 Alerta de %s (%s) omitida: reconocida hace menos de %v", anomaly.DeviceID, anomaly.Type, acknowledgmentTTL)
+            metrics.Inc("iot_alerts_acknowledged", "type", string(anomaly.Type))
+            continue
+        }
+        // Los umbrales de confianza y severidad se aplican a la severidad escalada
+        if escalated {
+            anomaly.Severity = escalateSeverity(anomaly.Severity)
+        }
+        if anomaly.Confidence < notificationMinConfidence {
+            log.Printf("🔕 Alerta de %s omitida: confianza %.2f por debajo de %.2f", anomaly.DeviceID, anomaly.Confidence, notificationMinConfidence)
+            continue
+        }
+        if severityRank(anomaly.Severity) < severityRank(notificationMinSeverity) {
+            log.Printf("🔕 Alerta de %s omitida: severidad %s por debajo de %s", anomaly.DeviceID, anomaly.Severity, notificationMinSeverity)
+            metrics.Inc("iot_alerts_below_severity", "severity", anomaly.Severity)
+            continue
+        }
+        // La reaparición escalada se notifica aunque coincida con la ventana de deduplicación
+        if !alertDeduplicator.ShouldNotify(&anomaly, now) && !escalated {
+            log.Printf("🔕 Alerta de %s (%s) omitida: ya notificada en los últimos %v", anomaly.DeviceID, anomaly.Type, alertDedupWindow)
+            metrics.Inc("iot_alerts_deduplicated", "type", string(anomaly.Type))
+            continue
+        }
+        if escalated {
+            acknowledgments.ConsumeEscalation(&anomaly, now)
+            log.Printf("📈 Alerta de %s (%s) escalada a %s: reaparece tras su reconocimiento", anomaly.DeviceID, anomaly.Type, anomaly.Severity)
+        }
+        notificationManager.SendAnomalyAlert(anomaly)
+    }
+}
+
+// Emoji por tipo de anomalía para los mensajes de alerta
+func getEmojiByType(anomalyType AnomalyType) string {
+    switch anomalyType {
+    case AnomalyTemperature:
+        return "🌡️"
+    case AnomalyHumidity, AnomalyHumidityTemperature:
+        return "💧"
+    case AnomalyBattery:
+        return "🔋"
+    case AnomalyAccessAttempts, AnomalyAccessFailureRatio:
+        return "🔐"
+    case AnomalySignal:
+        return "📶"
+    case AnomalyBehaviorPattern:
+        return "🧠"
+    case AnomalyDeviceTypeMismatch:
+        return "🎭"
+    case AnomalyOutsideSchedule:
+        return "🕰️"
+    case AnomalyDataQuality:
+        return "🧪"
+    case AnomalyCalibrationDrift:
+        return "📐"
+    case AnomalyPayloadSize:
+        return "📦"
+    case AnomalyStorm:
+        return "🌩️"
+    case AnomalySecurityState:
+        return "🚪"
+    case AnomalyDiagnostic:
+        return "🩺"
+    case AnomalyQuietHoursDigest:
+        return "🌙"
+    default:
+        return "🚨"
+    }
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+    "context"
+    "net/http"
+    "net/http/httptest"
+    "sort"
+    "strings"
+    "sync"
+    "sync/atomic"
+    "testing"
+    "time"
+)
+
+// Canal de notificación que guarda lo recibido
+type recordingNotifier struct {
+    name        string
+    mutex       sync.Mutex
+    anomalies   []Anomaly
+    quarantines []string
+    recoveries  []string
+}
+
+func newRecordingNotifier(name string) *recordingNotifier {
+    return &recordingNotifier{name: name}
+}
+
+func (rn *recordingNotifier) Name() string {
+    return rn.name
+}
+
+func (rn *recordingNotifier) SendAnomalyAlert(ctx context.Context, anomaly *Anomaly) error {
+    rn.mutex.Lock()
+    defer rn.mutex.Unlock()
+
+    rn.anomalies = append(rn.anomalies, *anomaly)
+    return nil
+}
+
+func (rn *recordingNotifier) SendQuarantineAlert(ctx context.Context, deviceID string, reason QuarantineReason, detail string, duration time.Duration) error {
+    rn.mutex.Lock()
+    defer rn.mutex.Unlock()
+
+    rn.quarantines = append(rn.quarantines, deviceID)
+    return nil
+}
+
+func (rn *recordingNotifier) SendRecoveryAlert(ctx context.Context, deviceID string, reason ReleaseReason, actor string) error {
+    rn.mutex.Lock()
+    defer rn.mutex.Unlock()
+
+    rn.recoveries = append(rn.recoveries, deviceID)
+    return nil
+}
+
+func (rn *recordingNotifier) Anomalies() []Anomaly {
+    rn.mutex.Lock()
+    defer rn.mutex.Unlock()
+
+    return append([]Anomaly(nil), rn.anomalies...)
+}
+
+func (rn *recordingNotifier) Quarantines() []string {
+    rn.mutex.Lock()
+    defer rn.mutex.Unlock()
+
+    return append([]string(nil), rn.quarantines...)
+}
+
+// Esperar a que terminen los envíos en segundo plano
+func flushNotifications(t *testing.T) {
+    t.Helper()
+
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+    if err := notificationManager.Flush(ctx); err != nil {
+        t.Fatalf("Flush() error = %v", err)
+    }
+}
+
+func TestNotificationManager_ServicesFor(t *testing.T) {
+    routes := NotificationRoutes{
+        ByType:     map[AnomalyType][]string{AnomalyBattery: {"ops"}},
+        BySeverity: map[string][]string{SEVERITY_HIGH: {"security"}},
+    }
+
+    tests := []struct {
+        name    string
+        anomaly Anomaly
+        want    []string
+    }{
+        {name: "por tipo", anomaly: Anomaly{Type: AnomalyBattery, Severity: SEVERITY_LOW}, want: []string{"ops"}},
+        {name: "por severidad", anomaly: Anomaly{Type: AnomalySignal, Severity: SEVERITY_HIGH}, want: []string{"security"}},
+        {name: "unión de tipo y severidad", anomaly: Anomaly{Type: AnomalyBattery, Severity: SEVERITY_HIGH}, want: []string{"ops", "security"}},
+        {name: "sin ruta va a todos", anomaly: Anomaly{Type: AnomalySignal, Severity: SEVERITY_LOW}, want: []string{"general", "ops", "security"}},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            nm := NewNotificationManager()
+            for _, name := range []string{"general", "ops", "security"} {
+                nm.Register(newRecordingNotifier(name))
+            }
+            nm.SetRoutes(routes)
+
+            got := make([]string, 0)
+            for _, service := range nm.servicesFor(&tt.anomaly) {
+                got = append(got, service.Name())
+            }
+            sort.Strings(got)
+            if len(got) != len(tt.want) {
+                t.Fatalf("canales = %v, se esperaba %v", got, tt.want)
+            }
+            for i := range got {
+                if got[i] != tt.want[i] {
+                    t.Fatalf("canales = %v, se esperaba %v", got, tt.want)
+                }
+            }
+        })
+    }
+}
+
+func TestSendAnomalyAlert_RoutesByType(t *testing.T) {
+    tests := []struct {
+        name           string
+        anomaly        Anomaly
+        wantFacilities int
+        wantSecurity   int
+    }{
+        {name: "temperatura a instalaciones", anomaly: Anomaly{DeviceID: "sensor-1", Type: AnomalyTemperature, Severity: SEVERITY_LOW}, wantFacilities: 1},
+        {name: "accesos a seguridad", anomaly: Anomaly{DeviceID: "sensor-1", Type: AnomalyAccessAttempts, Severity: SEVERITY_LOW}, wantSecurity: 1},
+        {name: "sin ruta a todos", anomaly: Anomaly{DeviceID: "sensor-1", Type: AnomalySignal, Severity: SEVERITY_LOW}, wantFacilities: 1, wantSecurity: 1},
+    }
+
+    // El mapa tipo→canales llega por configuración, como en main
+    t.Setenv("NOTIFICATION_ROUTES", `{"by_type": {"temperature": ["facilities"], "access_attempts": ["security"]}}`)
+    cfg, err := LoadConfig()
+    if err != nil {
+        t.Fatalf("LoadConfig() error = %v", err)
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            setupTestHub(t)
+            facilities, security := newRecordingNotifier("facilities"), newRecordingNotifier("security")
+            notificationManager.Register(facilities)
+            notificationManager.Register(security)
+            notificationManager.SetRoutes(cfg.Notifications.Routes)
+
+            notificationManager.SendAnomalyAlert(tt.anomaly)
+            flushNotifications(t)
+
+            if got := len(facilities.Anomalies()); got != tt.wantFacilities {
+                t.Errorf("alertas en facilities = %d, se esperaban %d", got, tt.wantFacilities)
+            }
+            if got := len(security.Anomalies()); got != tt.wantSecurity {
+                t.Errorf("alertas en security = %d, se esperaban %d", got, tt.wantSecurity)
+            }
+        })
+    }
+}
+
+func TestSendAnomalyAlert_DryRun(t *testing.T) {
+    tests := []struct {
+        name         string
+        dryRun       bool
+        wantRequests int32
+        wantLogged   []string
+    }{
+        {name: "envío real", wantRequests: 2},
+        {
+            name:       "dry-run",
+            dryRun:     true,
+            wantLogged: []string{`DRY-RUN [webhook]: {"device":"sensor-1","type":"temperature"}`, "DRY-RUN [discord]:", "temperatura extrema", "DRY-RUN [test]: el canal no permite previsualizar"},
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            setupTestHub(t)
+            saved := dryRunNotifications
+            t.Cleanup(func() { dryRunNotifications = saved })
+            dryRunNotifications = tt.dryRun
+            output := captureLog(t)
+
+            var requests atomic.Int32
+            server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+                requests.Add(1)
+                w.WriteHeader(http.StatusNoContent)
+            }))
+            defer server.Close()
+
+            webhook, err := NewGenericWebhookClient(server.URL, `{"device":{{json .DeviceID}},"type":{{json .Type}}}`, nil, time.Second)
+            if err != nil {
+                t.Fatalf("NewGenericWebhookClient: %v", err)
+            }
+            recorder := newRecordingNotifier("test")
+            notificationManager.Register(webhook)
+            notificationManager.Register(NewDiscordClient(server.URL))
+            notificationManager.Register(recorder)
+
+            notificationManager.SendAnomalyAlert(Anomaly{DeviceID: "sensor-1", Type: AnomalyTemperature, Severity: SEVERITY_HIGH, Description: "temperatura extrema: 95.00°C"})
+            flushNotifications(t)
+
+            if got := requests.Load(); got != tt.wantRequests {
+                t.Errorf("peticiones enviadas = %d, se esperaban %d", got, tt.wantRequests)
+            }
+            if got := len(recorder.Anomalies()) > 0; got == tt.dryRun {
+                t.Errorf("canal sin previsualización invocado = %v, se esperaba %v", got, !tt.dryRun)
+            }
+            for _, want := range tt.wantLogged {
+                if !strings.Contains(output.String(), want) {
+                    t.Errorf("el log no contiene %q:\n%s", want, output.String())
+                }
+            }
+        })
+    }
+}
+
+func TestRecordAnomalies_SeverityFloor(t *testing.T) {
+    tests := []struct {
+        name     string
+        floor    string
+        severity string
+        wantSent bool
+    }{
+        {name: "sin umbral", floor: "", severity: SEVERITY_LOW, wantSent: true},
+        {name: "low bajo medium", floor: SEVERITY_MEDIUM, severity: SEVERITY_LOW},
+        {name: "medium en el umbral", floor: SEVERITY_MEDIUM, severity: SEVERITY_MEDIUM, wantSent: true},
+        {name: "high sobre medium", floor: SEVERITY_MEDIUM, severity: SEVERITY_HIGH, wantSent: true},
+        {name: "medium bajo high", floor: SEVERITY_HIGH, severity: SEVERITY_MEDIUM},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            clock := setupTestHub(t)
+            t.Setenv("NOTIFICATION_MIN_SEVERITY", tt.floor)
+            cfg, err := LoadConfig()
+            if err != nil {
+                t.Fatalf("LoadConfig() error = %v", err)
+            }
+            saved := notificationMinSeverity
+            t.Cleanup(func() { notificationMinSeverity = saved })
+            notificationMinSeverity = cfg.Notifications.MinSeverity
+
+            notifier := newRecordingNotifier("test")
+            notificationManager.Register(notifier)
+
+            recordAnomalies([]Anomaly{{
+                DeviceID:   "sensor-1",
+                Type:       AnomalyTemperature,
+                Severity:   tt.severity,
+                Confidence: 1,
+                Timestamp:  clock.Now(),
+            }}, true)
+            flushNotifications(t)
+
+            // Se registra siempre, se notifique o no
+            if got := len(anomalyRepository.ByDevice("sensor-1", false)); got != 1 {
+                t.Errorf("anomalías guardadas = %d, se esperaba 1", got)
+            }
+            if got := len(notifier.Anomalies()) > 0; got != tt.wantSent {
+                t.Errorf("notificada = %v, se esperaba %v", got, tt.wantSent)
+            }
+        })
+    }
+}
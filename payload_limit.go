@@ -0,0 +1,68 @@
+package main
+
+import (
+    "bytes"
+    "encoding/json"
+)
+
+// Tamaño máximo de payload aceptado, en bytes (0 = sin límite)
+var maxPayloadSize = 4096
+
+// Poner en quarantine al dispositivo que envía payloads demasiado grandes
+var quarantineOversizedPayloads = false
+
+// Verificar el tamaño del payload antes de decodificarlo
+func payloadTooLarge(payload []byte) bool {
+    return maxPayloadSize > 0 && len(payload) > maxPayloadSize
+}
+
+// Buscar device_id en el nivel superior de un payload JSON leyendo como mucho
+// limit bytes, sin decodificar el resto (para identificar al emisor de un
+// payload rechazado por tamaño)
+func peekDeviceID(payload []byte, limit int) string {
+    if limit > 0 && len(payload) > limit {
+        payload = payload[:limit]
+    }
+
+    decoder := json.NewDecoder(bytes.NewReader(payload))
+    if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+        return ""
+    }
+
+    depth := 1
+    expectKey := true
+    for {
+        token, err := decoder.Token()
+        if err != nil {
+            return ""
+        }
+
+        switch value := token.(type) {
+        case json.Delim:
+            if value == '{' || value == '[' {
+                depth++
+            } else {
+                depth--
+                expectKey = depth == 1
+            }
+            if depth == 0 {
+                return ""
+            }
+            continue
+        case string:
+            if depth == 1 && expectKey && value == "device_id" {
+                if next, err := decoder.Token(); err == nil {
+                    if deviceID, ok := next.(string); ok {
+                        return deviceID
+                    }
+                }
+                return ""
+            }
+        }
+
+        // En el nivel superior se alternan clave y valor
+        if depth == 1 {
+            expectKey = !expectKey
+        }
+    }
+}
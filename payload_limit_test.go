@@ -0,0 +1,85 @@
+package main
+
+import (
+    "context"
+    "errors"
+    "strings"
+    "testing"
+)
+
+func TestIngestPayload_MaxPayloadSize(t *testing.T) {
+    tests := []struct {
+        name           string
+        limit          int
+        size           int
+        quarantine     bool
+        wantRejected   bool
+        wantQuarantine bool
+    }{
+        {name: "justo en el límite", limit: 4096, size: 4096},
+        {name: "un byte por encima", limit: 4096, size: 4097, wantRejected: true},
+        {name: "límite configurado menor", limit: 512, size: 1024, wantRejected: true},
+        {name: "sin límite", limit: 0, size: 64 * 1024},
+        {name: "con quarantine", limit: 4096, size: 8192, quarantine: true, wantRejected: true, wantQuarantine: true},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            setupTestHub(t)
+            registry := withMetricsRegistry(t)
+            savedLimit, savedQuarantine := maxPayloadSize, quarantineOversizedPayloads
+            t.Cleanup(func() { maxPayloadSize, quarantineOversizedPayloads = savedLimit, savedQuarantine })
+            maxPayloadSize, quarantineOversizedPayloads = tt.limit, tt.quarantine
+
+            // Lectura válida rellenada hasta el tamaño pedido
+            reading := validReadingJSON("sensor-1")
+            payload := []byte(reading[:len(reading)-1] + `,"padding":"` + strings.Repeat("x", tt.size-len(reading)-len(`,"padding":""`)) + `"}`)
+            if len(payload) != tt.size {
+                t.Fatalf("payload de %d bytes, se esperaban %d", len(payload), tt.size)
+            }
+
+            err := ingestPayload(context.Background(), "test", payload)
+            if got := errors.Is(err, ErrPayloadTooLarge); got != tt.wantRejected {
+                t.Fatalf("error = %v, se esperaba rechazo por tamaño: %v", err, tt.wantRejected)
+            }
+            if !tt.wantRejected && err != nil {
+                t.Fatalf("error = %v, se esperaba aceptado", err)
+            }
+
+            // Rechazado antes de decodificar: no llega al historial de lecturas
+            if got := len(readingHistory.All()) > 0; got == tt.wantRejected {
+                t.Errorf("lectura procesada = %v, se esperaba %v", got, !tt.wantRejected)
+            }
+            if got := registry.Value("iot_messages_rejected", "reason", "payload_too_large"); (got > 0) != tt.wantRejected {
+                t.Errorf("rechazos por tamaño = %v, se esperaba rechazo: %v", got, tt.wantRejected)
+            }
+            if got := quarantineSystem.IsQuarantined("sensor-1"); got != tt.wantQuarantine {
+                t.Errorf("en quarantine = %v, se esperaba %v", got, tt.wantQuarantine)
+            }
+        })
+    }
+}
+
+func TestPeekDeviceID(t *testing.T) {
+    tests := []struct {
+        name    string
+        payload string
+        limit   int
+        want    string
+    }{
+        {name: "primer campo", payload: `{"device_id":"sensor-1","temperature":21}`, want: "sensor-1"},
+        {name: "tras otros campos", payload: `{"temperature":21,"meta":{"device_id":"interno"},"device_id":"sensor-1"}`, want: "sensor-1"},
+        {name: "solo anidado", payload: `{"meta":{"device_id":"interno"}}`},
+        {name: "fuera del límite de lectura", payload: `{"padding":"xxxxxxxxxxxxxxxx","device_id":"sensor-1"}`, limit: 16},
+        {name: "no es un objeto", payload: `["sensor-1"]`},
+        {name: "device_id no textual", payload: `{"device_id":7}`},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if got := peekDeviceID([]byte(tt.payload), tt.limit); got != tt.want {
+                t.Errorf("peekDeviceID() = %q, se esperaba %q", got, tt.want)
+            }
+        })
+    }
+}
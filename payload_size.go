@@ -0,0 +1,38 @@
+package main
+
+// Mensajes necesarios para conocer el tamaño habitual del payload
+const PAYLOAD_SIZE_MIN_SAMPLES = 10
+
+// Máximo de muestras del promedio: a partir de aquí se adapta a cambios lentos
+const PAYLOAD_SIZE_WINDOW = 50
+
+// Factor de desviación respecto al tamaño habitual que se considera anómalo
+// (3 = más del triple o menos de un tercio; 0 = deshabilitado)
+var payloadSizeDeviationFactor = 3.0
+
+// Comparar el tamaño del payload con el habitual del dispositivo. Los
+// tamaños anómalos no entran en el promedio para no desplazar la referencia.
+func (qs *QuarantineSystem) CheckPayloadSize(data *SensorData, size int) *Anomaly {
+    if payloadSizeDeviationFactor <= 1 || size <= 0 {
+        return nil
+    }
+
+    qs.mutex.Lock()
+    defer qs.mutex.Unlock()
+
+    behavior := qs.behaviorLocked(data.DeviceID)
+    avg := behavior.AvgPayloadSize
+    if behavior.PayloadSamples >= PAYLOAD_SIZE_MIN_SAMPLES {
+        current := float64(size)
+        if current > avg*payloadSizeDeviationFactor || current < avg/payloadSizeDeviationFactor {
+            anomaly := newAnomaly(data, AnomalyPayloadSize, current, "tamaño de payload anómalo: %d bytes (habitual: %.0f bytes)", size, avg)
+            return &anomaly
+        }
+    }
+
+    if behavior.PayloadSamples < PAYLOAD_SIZE_WINDOW {
+        behavior.PayloadSamples++
+    }
+    behavior.AvgPayloadSize += (float64(size) - avg) / float64(behavior.PayloadSamples)
+    return nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import "testing"
+
+func TestCheckPayloadSize(t *testing.T) {
+    tests := []struct {
+        name        string
+        factor      float64
+        baseline    int
+        size        int
+        wantAnomaly bool
+    }{
+        {name: "tamaño habitual", baseline: PAYLOAD_SIZE_MIN_SAMPLES, size: 220},
+        {name: "mucho mayor", baseline: PAYLOAD_SIZE_MIN_SAMPLES, size: 2000, wantAnomaly: true},
+        {name: "mucho menor", baseline: PAYLOAD_SIZE_MIN_SAMPLES, size: 50, wantAnomaly: true},
+        {name: "dentro de un factor mayor", factor: 5, baseline: PAYLOAD_SIZE_MIN_SAMPLES, size: 800},
+        {name: "sin referencia suficiente", baseline: PAYLOAD_SIZE_MIN_SAMPLES - 1, size: 2000},
+        {name: "deshabilitado", factor: 1, baseline: PAYLOAD_SIZE_MIN_SAMPLES, size: 2000},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            setupTestHub(t)
+            saved := payloadSizeDeviationFactor
+            t.Cleanup(func() { payloadSizeDeviationFactor = saved })
+            if tt.factor > 0 {
+                payloadSizeDeviationFactor = tt.factor
+            }
+            data := &SensorData{DeviceID: "sensor-1"}
+
+            // Tamaño habitual de 200 bytes
+            for i := 0; i < tt.baseline; i++ {
+                if anomaly := quarantineSystem.CheckPayloadSize(data, 200); anomaly != nil {
+                    t.Fatalf("anomalía durante la referencia: %s", anomaly.Description)
+                }
+            }
+
+            anomaly := quarantineSystem.CheckPayloadSize(data, tt.size)
+            if got := anomaly != nil; got != tt.wantAnomaly {
+                t.Fatalf("anomalía = %v, se esperaba %v", got, tt.wantAnomaly)
+            }
+            if anomaly != nil && (anomaly.Type != AnomalyPayloadSize || anomaly.Value != float64(tt.size)) {
+                t.Errorf("anomalía = %s con valor %v, se esperaba %s con %d", anomaly.Type, anomaly.Value, AnomalyPayloadSize, tt.size)
+            }
+
+            // El tamaño anómalo no desplaza la referencia
+            if tt.wantAnomaly {
+                if anomaly := quarantineSystem.CheckPayloadSize(data, 200); anomaly != nil {
+                    t.Errorf("un payload habitual tras el anómalo se marcó: %s", anomaly.Description)
+                }
+            }
+        })
+    }
+}
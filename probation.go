@@ -0,0 +1,37 @@
+package main
+
+import "time"
+
+// Periodo de prueba tras salir de quarantine: durante probationWindow basta
+// con probationAnomalyThreshold anomalías (en lugar de ANOMALY_THRESHOLD)
+// para volver a poner el dispositivo en quarantine. Ventana 0 = deshabilitado.
+var (
+    probationWindow           = 30 * time.Minute
+    probationAnomalyThreshold = 1
+)
+
+// Iniciar el periodo de prueba de un dispositivo recién liberado (llamar con el lock tomado)
+func (qs *QuarantineSystem) startProbationLocked(deviceID string, now time.Time) {
+    if probationWindow <= 0 {
+        return
+    }
+    behavior, admitted := qs.admitBehaviorLocked(deviceID)
+    if !admitted {
+        return
+    }
+    behavior.ProbationUntil = now.Add(probationWindow)
+    behavior.AnomalyCount = 0
+}
+
+// Si el dispositivo está en periodo de prueba
+func (behavior *DeviceBehavior) inProbation(now time.Time) bool {
+    return now.Before(behavior.ProbationUntil)
+}
+
+// Anomalías acumuladas que provocan quarantine, más estricto en periodo de prueba
+func (behavior *DeviceBehavior) anomalyThreshold(now time.Time) int {
+    if behavior.inProbation(now) {
+        return probationAnomalyThreshold
+    }
+    return ANOMALY_THRESHOLD
+}
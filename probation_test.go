@@ -0,0 +1,60 @@
+package main
+
+import (
+    "context"
+    "testing"
+    "time"
+)
+
+// Enviar lecturas con un pico de intentos de acceso hasta que el dispositivo
+// entra en quarantine; devuelve cuántas hicieron falta (0 si no entra en max
+// lecturas). Desde la tercera cada lectura es una anomalía de fuerza bruta.
+func readingsUntilQuarantine(t *testing.T, clock *FakeClock, max int) int {
+    t.Helper()
+
+    for i := 1; i <= max; i++ {
+        clock.Advance(time.Second)
+        data := SensorData{DeviceID: "sensor-1", Timestamp: clock.Now().Unix(), Temperature: 21, Humidity: 40, BatteryLevel: 80, AccessAttempts: 8}
+        processSensorData(context.Background(), data, nil, nil)
+        if quarantineSystem.IsQuarantined("sensor-1") {
+            return i
+        }
+    }
+    return 0
+}
+
+func TestProbation_ReoffendingAfterRelease(t *testing.T) {
+    tests := []struct {
+        name   string
+        window time.Duration
+        // Tiempo entre la liberación y la reincidencia
+        wait          time.Duration
+        wantReoffense int
+    }{
+        {name: "reincidencia en periodo de prueba", window: 30 * time.Minute, wait: time.Minute, wantReoffense: probationAnomalyThreshold},
+        {name: "reincidencia tras el periodo de prueba", window: 30 * time.Minute, wait: time.Hour, wantReoffense: ANOMALY_THRESHOLD},
+        {name: "periodo de prueba deshabilitado", window: 0, wait: time.Hour, wantReoffense: ANOMALY_THRESHOLD},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            clock := setupTestHub(t)
+            saved := probationWindow
+            t.Cleanup(func() { probationWindow = saved })
+            probationWindow = tt.window
+
+            // Primera infracción con el umbral normal
+            first := readingsUntilQuarantine(t, clock, 20)
+            if first <= probationAnomalyThreshold {
+                t.Fatalf("primera quarantine tras %d lecturas, se esperaban más de %d", first, probationAnomalyThreshold)
+            }
+
+            quarantineSystem.ReleaseFromQuarantine("sensor-1", ReleaseReasonManual, "test")
+            clock.Advance(tt.wait)
+
+            if got := readingsUntilQuarantine(t, clock, 20); got != tt.wantReoffense {
+                t.Errorf("nueva quarantine tras %d lecturas, se esperaban %d", got, tt.wantReoffense)
+            }
+        })
+    }
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+)
+
+// Perfiles de dispositivo: umbrales de anomalías propios de cada DeviceType
+// (p. ej. un congelador a -20°C es normal, un sensor de oficina no)
+type DeviceProfiles map[string]AnomalyThresholds
+
+// Perfiles activos; los tipos sin perfil usan anomalyThresholds
+var deviceProfiles = DeviceProfiles{}
+
+// Cargar perfiles desde JSON: {"freezer": {"temperature_min": -40, "temperature_max": 0}}
+// Los campos omitidos en cada perfil toman el valor de los umbrales base
+func loadDeviceProfiles(raw string, base AnomalyThresholds) (DeviceProfiles, error) {
+    profiles := DeviceProfiles{}
+    if raw == "" {
+        return profiles, nil
+    }
+
+    var partial map[string]json.RawMessage
+    if err := json.Unmarshal([]byte(raw), &partial); err != nil {
+        return nil, fmt.Errorf("perfiles de dispositivo inválidos: %w", err)
+    }
+
+    for deviceType, rawProfile := range partial {
+        thresholds := base
+        if err := json.Unmarshal(rawProfile, &thresholds); err != nil {
+            return nil, fmt.Errorf("perfil %s inválido: %w", deviceType, err)
+        }
+        profiles[deviceType] = thresholds
+    }
+
+    return profiles, nil
+}
+
+// Umbrales que aplican a un tipo de dispositivo
+func (p DeviceProfiles) ThresholdsFor(deviceType string, base AnomalyThresholds) AnomalyThresholds {
+    if thresholds, exists := p[deviceType]; exists {
+        return thresholds
+    }
+    return base
+}
@@ -0,0 +1,50 @@
+package main
+
+import "time"
+
+// Periodo desde que se ve por primera vez un dispositivo en el que sus
+// primeros mensajes reciben escrutinio extra (posible dispositivo suplantado
+// uniéndose a la red)
+const (
+    PROVISIONING_SCRUTINY_WINDOW   = 10 * time.Minute
+    PROVISIONING_SCRUTINY_MESSAGES = 3
+)
+
+// Señales que delatan un alta sospechosa: muchos intentos de acceso o
+// lecturas extremas. La batería, la señal o la calidad de datos no indican
+// suplantación.
+func isProvisioningSignal(anomaly *Anomaly) bool {
+    switch anomaly.Type {
+    case AnomalyAccessAttempts, AnomalyTemperature, AnomalyHumidity:
+        return true
+    default:
+        return false
+    }
+}
+
+// Marcar como patrón sospechoso un dispositivo nunca visto cuyos primeros
+// mensajes ya traen valores extremos o muchos intentos de acceso (llamar con
+// el lock tomado, después de contar el mensaje). Se marca una sola vez por
+// dispositivo: las mismas señales en los mensajes siguientes ya las reportan
+// sus propias anomalías.
+func detectSuspiciousProvisioningLocked(data *SensorData, behavior *DeviceBehavior, now time.Time) []Anomaly {
+    if behavior.ProvisioningFlagged || behavior.MessageCount > PROVISIONING_SCRUTINY_MESSAGES || now.Sub(behavior.FirstSeen) > PROVISIONING_SCRUTINY_WINDOW {
+        return nil
+    }
+
+    var suspicious []Anomaly
+    for _, anomaly := range detectAnomaliesWith(data, deviceProfiles.ThresholdsFor(data.DeviceType, anomalyThresholds)) {
+        if isProvisioningSignal(&anomaly) {
+            suspicious = append(suspicious, anomaly)
+        }
+    }
+    if len(suspicious) == 0 {
+        return nil
+    }
+
+    behavior.ProvisioningFlagged = true
+    anomaly := newAnomaly(data, AnomalyBehaviorPattern, float64(len(suspicious)), "dispositivo nuevo (mensaje %d, visto por primera vez hace %v) con lecturas sospechosas: %s",
+        behavior.MessageCount, now.Sub(behavior.FirstSeen).Round(time.Second), describeAnomalies(suspicious))
+    anomaly.Severity = SEVERITY_HIGH
+    return []Anomaly{anomaly}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+    "strings"
+    "testing"
+    "time"
+)
+
+// Anomalías de alta sospechosa en las detectadas
+func countProvisioningAnomalies(anomalies []Anomaly) int {
+    count := 0
+    for _, anomaly := range anomalies {
+        if anomaly.Type == AnomalyBehaviorPattern && strings.HasPrefix(anomaly.Description, "dispositivo nuevo") {
+            count++
+        }
+    }
+    return count
+}
+
+func TestDetectSuspiciousProvisioning(t *testing.T) {
+    normal := SensorData{DeviceID: "sensor-1", Temperature: 21, Humidity: 40, BatteryLevel: 80}
+    extreme := SensorData{DeviceID: "sensor-1", Temperature: 95, Humidity: 40, BatteryLevel: 80}
+    attempts := SensorData{DeviceID: "sensor-1", Temperature: 21, Humidity: 40, BatteryLevel: 80, AccessAttempts: 50}
+    lowBattery := SensorData{DeviceID: "sensor-1", Temperature: 21, Humidity: 40, BatteryLevel: 2}
+
+    tests := []struct {
+        name     string
+        messages []SensorData
+        // Tiempo que pasa antes de cada mensaje
+        gap  time.Duration
+        want int
+    }{
+        {name: "lectura extrema en el primer mensaje", messages: []SensorData{extreme}, want: 1},
+        {name: "intentos de acceso en el primer mensaje", messages: []SensorData{attempts}, want: 1},
+        {name: "batería baja no es sospechosa", messages: []SensorData{lowBattery}, want: 0},
+        {name: "primer mensaje normal", messages: []SensorData{normal}, want: 0},
+        {name: "se marca una sola vez", messages: []SensorData{extreme, extreme, attempts}, want: 1},
+        {name: "fuera de los primeros mensajes", messages: []SensorData{normal, normal, normal, extreme}, want: 0},
+        {name: "fuera del periodo desde el alta", messages: []SensorData{normal, extreme}, gap: PROVISIONING_SCRUTINY_WINDOW + time.Minute, want: 0},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            clock := setupTestHub(t)
+
+            got := 0
+            for i := range tt.messages {
+                if i > 0 {
+                    clock.Advance(tt.gap)
+                }
+                data := tt.messages[i]
+                got += countProvisioningAnomalies(quarantineSystem.AnalyzeDeviceBehavior(&data))
+            }
+            if got != tt.want {
+                t.Errorf("altas sospechosas = %d, se esperaban %d", got, tt.want)
+            }
+        })
+    }
+}
@@ -0,0 +1,44 @@
+package main
+
+import "fmt"
+
+// Código de motivo de quarantine, agregable en métricas y consultas
+type QuarantineReason string
+
+const (
+    QuarantineReasonInvalidData      QuarantineReason = "invalid_data"
+    QuarantineReasonBehaviorAnomaly  QuarantineReason = "behavior_anomaly"
+    QuarantineReasonSelfReported     QuarantineReason = "self_reported"
+    QuarantineReasonOversizedPayload QuarantineReason = "oversized_payload"
+    QuarantineReasonOutsideSchedule  QuarantineReason = "outside_schedule"
+    // Motivos en texto libre de llamadas anteriores a los códigos
+    QuarantineReasonOther QuarantineReason = "other"
+)
+
+// Etiqueta legible para notificaciones
+func (r QuarantineReason) Label() string {
+    switch r {
+    case QuarantineReasonInvalidData:
+        return "datos inválidos"
+    case QuarantineReasonBehaviorAnomaly:
+        return "anomalías de comportamiento"
+    case QuarantineReasonSelfReported:
+        return "auto-cuarentena solicitada por el dispositivo"
+    case QuarantineReasonOversizedPayload:
+        return "payload demasiado grande"
+    case QuarantineReasonOutsideSchedule:
+        return "comunicación fuera de horario"
+    case QuarantineReasonOther:
+        return "otro motivo"
+    default:
+        return string(r)
+    }
+}
+
+// Texto completo: etiqueta y detalle opcional
+func describeQuarantineReason(reason QuarantineReason, detail string) string {
+    if detail == "" {
+        return reason.Label()
+    }
+    return fmt.Sprintf("%s: %s", reason.Label(), detail)
+}
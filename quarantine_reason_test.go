@@ -0,0 +1,25 @@
+package main
+
+import "testing"
+
+func TestDescribeQuarantineReason(t *testing.T) {
+    tests := []struct {
+        name   string
+        reason QuarantineReason
+        detail string
+        want   string
+    }{
+        {name: "sin detalle", reason: QuarantineReasonInvalidData, want: "datos inválidos"},
+        {name: "con detalle", reason: QuarantineReasonOversizedPayload, detail: "9000 bytes", want: "payload demasiado grande: 9000 bytes"},
+        {name: "texto libre", reason: QuarantineReasonOther, detail: "prueba", want: "otro motivo: prueba"},
+        {name: "código desconocido de un snapshot antiguo", reason: QuarantineReason("manual_operator"), want: "manual_operator"},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if got := describeQuarantineReason(tt.reason, tt.detail); got != tt.want {
+                t.Errorf("describeQuarantineReason() = %q, se esperaba %q", got, tt.want)
+            }
+        })
+    }
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+    "context"
+    "fmt"
+    "time"
+)
+
+// Motivo de la liberación de una quarantine
+type ReleaseReason string
+
+const (
+    ReleaseReasonExpired ReleaseReason = "expired"
+    ReleaseReasonManual  ReleaseReason = "manual"
+)
+
+// Etiqueta legible para notificaciones
+func (r ReleaseReason) Label() string {
+    switch r {
+    case ReleaseReasonExpired:
+        return "cuarentena expirada"
+    case ReleaseReasonManual:
+        return "liberación manual"
+    default:
+        return string(r)
+    }
+}
+
+// Liberaciones conservadas en el registro de auditoría
+const QUARANTINE_RELEASE_LOG_SIZE = 1000
+
+// Liberación de quarantine registrada para auditoría
+type QuarantineRelease struct {
+    DeviceID         string           `json:"device_id"`
+    Reason           ReleaseReason    `json:"reason"`
+    Actor            string           `json:"actor,omitempty"`
+    QuarantineReason QuarantineReason `json:"quarantine_reason"`
+    QuarantinedAt    time.Time        `json:"quarantined_at"`
+    ReleasedAt       time.Time        `json:"released_at"`
+}
+
+// Sacar un dispositivo de quarantine antes de que expire. Si la quarantine
+// la puso otra instancia y solo está en el repositorio compartido, se libera
+// allí. Devuelve false si no estaba en quarantine.
+func (qs *QuarantineSystem) ReleaseFromQuarantine(deviceID string, reason ReleaseReason, actor string) bool {
+    qs.mutex.Lock()
+    entry, exists := qs.quarantinedDevices[deviceID]
+    if !exists {
+        qs.mutex.Unlock()
+        if !qs.isQuarantinedElsewhere(deviceID) {
+            return false
+        }
+        // Los detalles de la quarantine remota no se conocen aquí
+        entry = &QuarantineEntry{}
+        qs.mutex.Lock()
+    }
+    release := qs.releaseLocked(deviceID, entry, reason, actor, qs.clock.Now())
+    qs.mutex.Unlock()
+
+    qs.writeRepository(context.Background(), "ReleaseQuarantine", func(ctx context.Context, repository DeviceRepository) error {
+        return repository.ReleaseQuarantine(ctx, deviceID)
+    })
+    announceRelease(release)
+    return true
+}
+
+// Quitar la quarantine y registrarla en la auditoría (llamar con el lock tomado)
+func (qs *QuarantineSystem) releaseLocked(deviceID string, entry *QuarantineEntry, reason ReleaseReason, actor string, now time.Time) QuarantineRelease {
+    delete(qs.quarantinedDevices, deviceID)
+    qs.startProbationLocked(deviceID, now)
+    // Las anomalías que llevaron a la quarantine no cuentan contra el dispositivo liberado
+    if behavior := qs.deviceBehavior[deviceID]; behavior != nil {
+        behavior.RateAnomalies = nil
+    }
+
+    release := QuarantineRelease{
+        DeviceID:         deviceID,
+        Reason:           reason,
+        Actor:            actor,
+        QuarantineReason: entry.Reason,
+        QuarantinedAt:    entry.Since,
+        ReleasedAt:       now,
+    }
+    qs.releases = append(qs.releases, release)
+    if len(qs.releases) > QUARANTINE_RELEASE_LOG_SIZE {
+        qs.releases = qs.releases[len(qs.releases)-QUARANTINE_RELEASE_LOG_SIZE:]
+    }
+    return release
+}
+
+// Registrar la liberación en el log y notificarla (fuera del lock)
+func announceRelease(release QuarantineRelease) {
+    message := fmt.Sprintf("✅ QUARANTINE: Dispositivo %s liberado (%s)", release.DeviceID, release.Reason.Label())
+    if release.Actor != "" {
+        message = fmt.Sprintf("%s por %s", message, release.Actor)
+    }
+    fields := map[string]interface{}{
+        "device_id":         release.DeviceID,
+        "release_reason":    string(release.Reason),
+        "actor":             release.Actor,
+        "quarantine_reason": string(release.QuarantineReason),
+    }
+    // Sin inicio conocido cuando la quarantine venía de otra instancia
+    if !release.QuarantinedAt.IsZero() {
+        fields["quarantined_for"] = release.ReleasedAt.Sub(release.QuarantinedAt).Round(time.Second).String()
+    }
+    logger.InfoWith(message, fields)
+    notificationManager.SendRecoveryAlert(release.DeviceID, release.Reason, release.Actor)
+}
+
+// Registro de liberaciones, opcionalmente de un solo dispositivo
+func (qs *QuarantineSystem) Releases(deviceID string) []QuarantineRelease {
+    qs.mutex.RLock()
+    defer qs.mutex.RUnlock()
+
+    result := make([]QuarantineRelease, 0)
+    for _, release := range qs.releases {
+        if deviceID == "" || release.DeviceID == deviceID {
+            result = append(result, release)
+        }
+    }
+    return result
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestReleaseFromQuarantine(t *testing.T) {
+    tests := []struct {
+        name        string
+        local       bool
+        remote      bool
+        want        bool
+        wantRelease int
+    }{
+        {name: "sin cuarentena", want: false},
+        {name: "cuarentena local", local: true, remote: true, want: true, wantRelease: 1},
+        {name: "cuarentena solo en el repositorio compartido", remote: true, want: true, wantRelease: 1},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            clock := setupTestHub(t)
+            repository := newFakeDeviceRepository()
+            quarantineSystem.SetRepository(repository)
+
+            if tt.local {
+                quarantineSystem.QuarantineDevice("sensor-1", "prueba")
+            }
+            if tt.remote {
+                repository.quarantines["sensor-1"] = QuarantineEntry{Since: clock.Now(), Duration: time.Hour}
+            }
+
+            if got := quarantineSystem.ReleaseFromQuarantine("sensor-1", ReleaseReasonManual, "operador"); got != tt.want {
+                t.Fatalf("ReleaseFromQuarantine() = %v, se esperaba %v", got, tt.want)
+            }
+            if quarantineSystem.IsQuarantined("sensor-1") {
+                t.Error("el dispositivo sigue en cuarentena (local o compartida)")
+            }
+            if got := len(quarantineSystem.Releases("sensor-1")); got != tt.wantRelease {
+                t.Errorf("liberaciones registradas = %d, se esperaban %d", got, tt.wantRelease)
+            }
+        })
+    }
+}
+
+func TestHandleReleaseQuarantine(t *testing.T) {
+    tests := []struct {
+        name        string
+        token       string
+        quarantined bool
+        wantStatus  int
+        wantActor   string
+    }{
+        {name: "sin token", quarantined: true, wantStatus: http.StatusUnauthorized},
+        {name: "token compartido", token: testAPIToken, quarantined: true, wantStatus: http.StatusNoContent, wantActor: API_TOKEN_OPERATOR},
+        {name: "token de operador", token: "token-de-ana", quarantined: true, wantStatus: http.StatusNoContent, wantActor: "ana"},
+        {name: "sin cuarentena", token: testAPIToken, wantStatus: http.StatusNotFound},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            setupTestHub(t)
+            withAPIToken(t)
+            saved := apiOperatorTokens
+            t.Cleanup(func() { apiOperatorTokens = saved })
+            apiOperatorTokens = map[string]string{"ana": "token-de-ana"}
+            if tt.quarantined {
+                quarantineSystem.QuarantineDeviceWithReason("sensor-1", QuarantineReasonOther, "prueba")
+            }
+
+            // El nombre que envía el cliente no cuenta: se registra el operador autenticado
+            request := httptest.NewRequest(http.MethodDelete, "/devices/sensor-1/quarantine", strings.NewReader(`{"released_by": "otra-persona"}`))
+            if tt.token != "" {
+                request.Header.Set("Authorization", "Bearer "+tt.token)
+            }
+            recorder := httptest.NewRecorder()
+            newAPIRouter().ServeHTTP(recorder, request)
+            if recorder.Code != tt.wantStatus {
+                t.Fatalf("estado = %d, se esperaba %d", recorder.Code, tt.wantStatus)
+            }
+
+            releases := quarantineSystem.Releases("sensor-1")
+            if tt.wantActor == "" {
+                if len(releases) != 0 || quarantineSystem.IsQuarantined("sensor-1") != tt.quarantined {
+                    t.Errorf("la cuarentena cambió sin autorización: liberaciones %v", releases)
+                }
+                return
+            }
+            if len(releases) != 1 || releases[0].Actor != tt.wantActor {
+                t.Errorf("liberaciones = %+v, se esperaba una a nombre de %q", releases, tt.wantActor)
+            }
+        })
+    }
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+    "context"
+    "fmt"
+    "log"
+    "strings"
+    "time"
+)
+
+// Intervalo con el que se comprueba si han terminado las horas de silencio
+const QUIET_HOURS_CHECK_INTERVAL = 1 * time.Minute
+
+// Alertas retenidas como máximo durante las horas de silencio; las
+// siguientes solo se cuentan en el resumen
+const QUIET_HOURS_MAX_QUEUED = 500
+
+// Descripciones de alertas incluidas en el resumen
+const QUIET_HOURS_DIGEST_ITEMS = 10
+
+// Cargar la ventana de horas de silencio ("HH:MM" en la zona indicada; si
+// end es anterior a start cruza la medianoche). Sin start ni end no hay ventana.
+func loadQuietHours(start, end, timezone string) (*CommunicationSchedule, error) {
+    if start == "" && end == "" {
+        return nil, nil
+    }
+    quietHours := &CommunicationSchedule{Start: start, End: end, Timezone: timezone}
+    if err := quietHours.compile(); err != nil {
+        return nil, fmt.Errorf("horas de silencio inválidas: %w", err)
+    }
+    return quietHours, nil
+}
+
+// Configurar las horas de silencio (nil = sin ventana)
+func (nm *NotificationManager) SetQuietHours(quietHours *CommunicationSchedule) {
+    nm.mutex.Lock()
+    defer nm.mutex.Unlock()
+
+    nm.quietHours = quietHours
+}
+
+// Configurar el reloj con el que se evalúan las horas de silencio
+func (nm *NotificationManager) SetClock(clock Clock) {
+    nm.mutex.Lock()
+    defer nm.mutex.Unlock()
+
+    nm.clock = clock
+}
+
+// Hora actual según el reloj de las notificaciones
+func (nm *NotificationManager) Now() time.Time {
+    nm.mutex.Lock()
+    defer nm.mutex.Unlock()
+
+    return nm.clock.Now()
+}
+
+// Retener la alerta si estamos en horas de silencio y no es de severidad
+// alta. Devuelve si se ha retenido.
+func (nm *NotificationManager) holdForQuietHours(anomaly Anomaly) bool {
+    if severityRank(anomaly.Severity) >= severityRank(SEVERITY_HIGH) {
+        return false
+    }
+
+    nm.mutex.Lock()
+    defer nm.mutex.Unlock()
+
+    if nm.quietHours == nil || !nm.quietHours.Allows(nm.clock.Now()) {
+        return false
+    }
+    if len(nm.quietQueue) < QUIET_HOURS_MAX_QUEUED {
+        nm.quietQueue = append(nm.quietQueue, anomaly)
+    }
+    nm.quietHeld++
+    metrics.Inc("iot_alerts_quiet_hours", "severity", anomaly.Severity)
+    return true
+}
+
+// Alertas retenidas pendientes del resumen, para el snapshot de estado
+type QuietHoursBacklog struct {
+    Queued []Anomaly `json:"queued"`
+    Held   int       `json:"held"`
+}
+
+// Copia de las alertas retenidas (nil si no hay ninguna)
+func (nm *NotificationManager) QuietHoursBacklog() *QuietHoursBacklog {
+    nm.mutex.RLock()
+    defer nm.mutex.RUnlock()
+
+    if nm.quietHeld == 0 {
+        return nil
+    }
+    queued := make([]Anomaly, len(nm.quietQueue))
+    copy(queued, nm.quietQueue)
+    return &QuietHoursBacklog{Queued: queued, Held: nm.quietHeld}
+}
+
+// Restaurar las alertas retenidas de un snapshot, que se resumirán junto a
+// las que se retengan desde ahora
+func (nm *NotificationManager) RestoreQuietHoursBacklog(backlog *QuietHoursBacklog) {
+    if backlog == nil || backlog.Held == 0 {
+        return
+    }
+
+    nm.mutex.Lock()
+    defer nm.mutex.Unlock()
+
+    for _, anomaly := range backlog.Queued {
+        if len(nm.quietQueue) >= QUIET_HOURS_MAX_QUEUED {
+            break
+        }
+        nm.quietQueue = append(nm.quietQueue, anomaly)
+    }
+    nm.quietHeld += backlog.Held
+}
+
+// Enviar el resumen de las alertas retenidas si ya han terminado las horas
+// de silencio. Devuelve el número de alertas resumidas.
+func (nm *NotificationManager) ReleaseQuietHours() int {
+    return nm.releaseQuietHours(false)
+}
+
+// Enviar el resumen de las alertas retenidas aunque sigan las horas de
+// silencio (al apagar, para no perderlas)
+func (nm *NotificationManager) FlushQuietHours() int {
+    return nm.releaseQuietHours(true)
+}
+
+func (nm *NotificationManager) releaseQuietHours(force bool) int {
+    nm.mutex.Lock()
+    now := nm.clock.Now()
+    if nm.quietHeld == 0 || (!force && nm.quietHours != nil && nm.quietHours.Allows(now)) {
+        nm.mutex.Unlock()
+        return 0
+    }
+    queued, held := nm.quietQueue, nm.quietHeld
+    nm.quietQueue, nm.quietHeld = nil, 0
+    nm.mutex.Unlock()
+
+    digest := quietHoursDigest(queued, held, now)
+    log.Printf("🌙 Fin de las horas de silencio: resumen de %d alertas retenidas", held)
+    nm.sendAnomalyAlert(digest)
+    return held
+}
+
+// Alerta resumen de las alertas retenidas durante las horas de silencio
+func quietHoursDigest(queued []Anomaly, held int, now time.Time) Anomaly {
+    severity := SEVERITY_LOW
+    devices := make(map[string]bool)
+    for _, anomaly := range queued {
+        if severityRank(anomaly.Severity) > severityRank(severity) {
+            severity = anomaly.Severity
+        }
+        devices[anomaly.DeviceID] = true
+    }
+
+    lines := make([]string, 0, QUIET_HOURS_DIGEST_ITEMS+1)
+    for i, anomaly := range queued {
+        if i == QUIET_HOURS_DIGEST_ITEMS {
+            break
+        }
+        lines = append(lines, fmt.Sprintf("%s %s: %s", getEmojiByType(anomaly.Type), anomaly.DeviceID, anomaly.Description))
+    }
+    if held > len(lines) {
+        lines = append(lines, fmt.Sprintf("... y %d más", held-len(lines)))
+    }
+
+    return Anomaly{
+        DeviceID:    "*",
+        Type:        AnomalyQuietHoursDigest,
+        Severity:    severity,
+        Value:       float64(held),
+        Description: fmt.Sprintf("resumen de horas de silencio: %d alertas de %d dispositivos\n%s", held, len(devices), strings.Join(lines, "\n")),
+        Timestamp:   now,
+        Confidence:  1,
+    }
+}
+
+// Enviar periódicamente el resumen al terminar las horas de silencio, hasta
+// que se cancele el contexto. El canal devuelto se cierra cuando la goroutine termina.
+func startQuietHoursDigest(ctx context.Context, nm *NotificationManager, interval time.Duration) <-chan struct{} {
+    done := make(chan struct{})
+
+    go func() {
+        defer close(done)
+        ticker := time.NewTicker(interval)
+        defer ticker.Stop()
+
+        for {
+            select {
+            case <-ctx.Done():
+                return
+            case <-ticker.C:
+                nm.ReleaseQuietHours()
+            }
+        }
+    }()
+
+    return done
+}
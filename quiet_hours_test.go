@@ -0,0 +1,109 @@
+package main
+
+import (
+    "fmt"
+    "testing"
+    "time"
+)
+
+func TestQuietHours(t *testing.T) {
+    night := time.Date(2026, 3, 2, 23, 0, 0, 0, time.UTC)
+    morning := time.Date(2026, 3, 3, 8, 0, 0, 0, time.UTC)
+
+    tests := []struct {
+        name      string
+        at        time.Time
+        severity  string
+        releaseAt time.Time
+        force     bool
+        // Alertas enviadas al momento y tras intentar liberar el resumen
+        wantImmediate int
+        wantReleased  int
+    }{
+        {name: "fuera de las horas de silencio", at: morning, severity: SEVERITY_LOW, releaseAt: morning, wantImmediate: 1},
+        {name: "retenida durante la noche", at: night, severity: SEVERITY_LOW, releaseAt: night.Add(time.Hour)},
+        {name: "severidad alta siempre pasa", at: night, severity: SEVERITY_HIGH, releaseAt: night, wantImmediate: 1},
+        {name: "resumen al terminar", at: night, severity: SEVERITY_MEDIUM, releaseAt: morning, wantReleased: 1},
+        {name: "resumen forzado al apagar", at: night, severity: SEVERITY_LOW, releaseAt: night.Add(time.Hour), force: true, wantReleased: 1},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            clock := setupTestHub(t)
+            quietHours, err := loadQuietHours("22:00", "07:00", "UTC")
+            if err != nil {
+                t.Fatal(err)
+            }
+            notificationManager.SetQuietHours(quietHours)
+            notifier := newRecordingNotifier("test")
+            notificationManager.Register(notifier)
+
+            clock.Set(tt.at)
+            notificationManager.SendAnomalyAlert(Anomaly{DeviceID: "sensor-1", Type: AnomalyBattery, Severity: tt.severity, Description: "batería baja"})
+            flushNotifications(t)
+            if got := len(notifier.Anomalies()); got != tt.wantImmediate {
+                t.Fatalf("alertas enviadas al momento = %d, se esperaban %d", got, tt.wantImmediate)
+            }
+
+            clock.Set(tt.releaseAt)
+            if tt.force {
+                notificationManager.FlushQuietHours()
+            } else {
+                notificationManager.ReleaseQuietHours()
+            }
+            flushNotifications(t)
+            sent := notifier.Anomalies()[tt.wantImmediate:]
+            if len(sent) != tt.wantReleased {
+                t.Fatalf("resúmenes enviados = %d, se esperaban %d", len(sent), tt.wantReleased)
+            }
+            if tt.wantReleased > 0 && (sent[0].Type != AnomalyQuietHoursDigest || sent[0].Severity != tt.severity) {
+                t.Errorf("resumen = %s/%s, se esperaba %s/%s", sent[0].Type, sent[0].Severity, AnomalyQuietHoursDigest, tt.severity)
+            }
+        })
+    }
+}
+
+func TestQuietHours_BacklogCapped(t *testing.T) {
+    clock := setupTestHub(t)
+    quietHours, err := loadQuietHours("22:00", "07:00", "UTC")
+    if err != nil {
+        t.Fatal(err)
+    }
+    notificationManager.SetQuietHours(quietHours)
+    clock.Set(time.Date(2026, 3, 2, 23, 0, 0, 0, time.UTC))
+
+    for i := 0; i < QUIET_HOURS_MAX_QUEUED+20; i++ {
+        notificationManager.SendAnomalyAlert(Anomaly{DeviceID: fmt.Sprintf("sensor-%d", i), Type: AnomalyBattery, Severity: SEVERITY_LOW})
+    }
+
+    backlog := notificationManager.QuietHoursBacklog()
+    if backlog == nil || len(backlog.Queued) != QUIET_HOURS_MAX_QUEUED || backlog.Held != QUIET_HOURS_MAX_QUEUED+20 {
+        t.Errorf("retenidas = %+v, se esperaban %d en cola de %d", backlog, QUIET_HOURS_MAX_QUEUED, QUIET_HOURS_MAX_QUEUED+20)
+    }
+}
+
+func TestLoadQuietHours(t *testing.T) {
+    tests := []struct {
+        name       string
+        start, end string
+        wantWindow bool
+        wantErr    bool
+    }{
+        {name: "sin ventana"},
+        {name: "nocturna", start: "22:00", end: "07:00", wantWindow: true},
+        {name: "solo inicio", start: "22:00", wantErr: true},
+        {name: "vacía", start: "22:00", end: "22:00", wantErr: true},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            quietHours, err := loadQuietHours(tt.start, tt.end, "")
+            if (err != nil) != tt.wantErr {
+                t.Fatalf("error = %v, se esperaba error: %v", err, tt.wantErr)
+            }
+            if (quietHours != nil) != tt.wantWindow {
+                t.Errorf("ventana = %v, se esperaba ventana: %v", quietHours, tt.wantWindow)
+            }
+        })
+    }
+}
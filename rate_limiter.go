@@ -0,0 +1,292 @@
+package main
+
+import (
+    "fmt"
+    "math"
+    "sync"
+    "time"
+)
+
+// Estado del bucket de tokens de un dispositivo
+type tokenBucket struct {
+    tokens     float64
+    lastRefill time.Time
+}
+
+// Rate limiter basado en token bucket: permite ráfagas de hasta `burst`
+// mensajes y un ritmo sostenido de `rate` mensajes por segundo
+type TokenBucketRateLimiter struct {
+    mutex   sync.Mutex
+    rate    float64
+    burst   int
+    buckets map[string]*tokenBucket
+    clock   Clock
+}
+
+// Crear un rate limiter token bucket
+func NewTokenBucketRateLimiter(rate float64, burst int) *TokenBucketRateLimiter {
+    if burst < 1 {
+        burst = 1
+    }
+    return &TokenBucketRateLimiter{
+        rate:    rate,
+        burst:   burst,
+        buckets: make(map[string]*tokenBucket),
+        clock:   RealClock{},
+    }
+}
+
+// Rellenar tokens según el tiempo transcurrido (llamar con el lock tomado)
+func (rl *TokenBucketRateLimiter) refill(deviceID string, now time.Time) *tokenBucket {
+    bucket := rl.buckets[deviceID]
+    if bucket == nil {
+        bucket = &tokenBucket{
+            tokens:     float64(rl.burst),
+            lastRefill: now,
+        }
+        rl.buckets[deviceID] = bucket
+        return bucket
+    }
+
+    elapsed := now.Sub(bucket.lastRefill).Seconds()
+    if elapsed > 0 {
+        bucket.tokens = math.Min(float64(rl.burst), bucket.tokens+elapsed*rl.rate)
+        bucket.lastRefill = now
+    }
+    return bucket
+}
+
+// Verificar si el dispositivo puede enviar un mensaje (consume un token)
+func (rl *TokenBucketRateLimiter) IsAllowed(deviceID string) bool {
+    rl.mutex.Lock()
+    defer rl.mutex.Unlock()
+
+    bucket := rl.refill(deviceID, rl.clock.Now())
+    if bucket.tokens < 1 {
+        return false
+    }
+
+    bucket.tokens--
+    return true
+}
+
+// Número de mensajes "en uso": tokens consumidos que aún no se han recargado
+func (rl *TokenBucketRateLimiter) GetRequestCount(deviceID string) int {
+    rl.mutex.Lock()
+    defer rl.mutex.Unlock()
+
+    if rl.buckets[deviceID] == nil {
+        return 0
+    }
+
+    bucket := rl.refill(deviceID, rl.clock.Now())
+    return int(math.Ceil(float64(rl.burst) - bucket.tokens))
+}
+
+// Cambiar el reloj con el que se recargan los tokens
+func (rl *TokenBucketRateLimiter) SetClock(clock Clock) {
+    rl.mutex.Lock()
+    defer rl.mutex.Unlock()
+
+    rl.clock = clock
+}
+
+// Reiniciar el bucket de un dispositivo (vuelve a tener la ráfaga completa)
+func (rl *TokenBucketRateLimiter) Reset(deviceID string) {
+    rl.mutex.Lock()
+    defer rl.mutex.Unlock()
+
+    delete(rl.buckets, deviceID)
+}
+
+// Reiniciar los buckets de todos los dispositivos
+func (rl *TokenBucketRateLimiter) ResetAll() {
+    rl.mutex.Lock()
+    defer rl.mutex.Unlock()
+
+    rl.buckets = make(map[string]*tokenBucket)
+}
+
+// Número de dispositivos con bucket
+func (rl *TokenBucketRateLimiter) DeviceCount() int {
+    rl.mutex.Lock()
+    defer rl.mutex.Unlock()
+
+    return len(rl.buckets)
+}
+
+// Estado serializable del bucket de un dispositivo
+type TokenBucketState struct {
+    Tokens     float64   `json:"tokens"`
+    LastRefill time.Time `json:"last_refill"`
+}
+
+// Copia del estado de todos los buckets
+func (rl *TokenBucketRateLimiter) Snapshot() map[string]TokenBucketState {
+    rl.mutex.Lock()
+    defer rl.mutex.Unlock()
+
+    states := make(map[string]TokenBucketState, len(rl.buckets))
+    for deviceID, bucket := range rl.buckets {
+        states[deviceID] = TokenBucketState{Tokens: bucket.tokens, LastRefill: bucket.lastRefill}
+    }
+    return states
+}
+
+// Restaurar los buckets desde un snapshot (sustituye el estado actual)
+func (rl *TokenBucketRateLimiter) Restore(states map[string]TokenBucketState) {
+    rl.mutex.Lock()
+    defer rl.mutex.Unlock()
+
+    rl.buckets = make(map[string]*tokenBucket, len(states))
+    for deviceID, state := range states {
+        rl.buckets[deviceID] = &tokenBucket{
+            tokens:     math.Min(float64(rl.burst), state.Tokens),
+            lastRefill: state.LastRefill,
+        }
+    }
+}
+
+// Modos de rate limiting
+const (
+    RATE_LIMIT_MODE_TOKEN_BUCKET = "token_bucket"
+    RATE_LIMIT_MODE_FIXED_WINDOW = "fixed_window"
+)
+
+// Modo de rate limiting activo
+var rateLimitMode = RATE_LIMIT_MODE_TOKEN_BUCKET
+
+// Validar el modo de rate limiting
+func validateRateLimitMode(mode string) error {
+    switch mode {
+    case RATE_LIMIT_MODE_TOKEN_BUCKET, RATE_LIMIT_MODE_FIXED_WINDOW:
+        return nil
+    default:
+        return fmt.Errorf("RATE_LIMIT_MODE inválido: %q (token_bucket o fixed_window)", mode)
+    }
+}
+
+// Rate limiter por dispositivo
+type RateLimiter interface {
+    IsAllowed(deviceID string) bool
+    Reset(deviceID string)
+    // Reiniciar el estado de todos los dispositivos
+    ResetAll()
+    // Dispositivos con estado de rate limit
+    DeviceCount() int
+    Snapshot() map[string]TokenBucketState
+    Restore(states map[string]TokenBucketState)
+    SetClock(clock Clock)
+}
+
+// Crear el rate limiter del modo configurado
+func newRateLimiter() RateLimiter {
+    if rateLimitMode == RATE_LIMIT_MODE_FIXED_WINDOW {
+        return NewFixedWindowRateLimiter(rateLimitMaxMessages, rateLimitWindow)
+    }
+    return NewTokenBucketRateLimiter(float64(rateLimitMaxMessages)/rateLimitWindow.Seconds(), rateLimitBurst)
+}
+
+// Contador de la ventana actual de un dispositivo
+type fixedWindow struct {
+    start time.Time
+    count int
+}
+
+// Rate limiter de ventana fija alineada al reloj: el contador se reinicia al
+// comienzo de cada ventana (p. ej. en cada minuto en punto), sin ráfagas
+type FixedWindowRateLimiter struct {
+    mutex   sync.Mutex
+    limit   int
+    window  time.Duration
+    windows map[string]*fixedWindow
+    clock   Clock
+}
+
+// Crear un rate limiter de ventana fija
+func NewFixedWindowRateLimiter(limit int, window time.Duration) *FixedWindowRateLimiter {
+    return &FixedWindowRateLimiter{
+        limit:   limit,
+        window:  window,
+        windows: make(map[string]*fixedWindow),
+        clock:   RealClock{},
+    }
+}
+
+// Verificar si el dispositivo puede enviar un mensaje en la ventana actual
+func (rl *FixedWindowRateLimiter) IsAllowed(deviceID string) bool {
+    rl.mutex.Lock()
+    defer rl.mutex.Unlock()
+
+    start := rl.clock.Now().Truncate(rl.window)
+    current := rl.windows[deviceID]
+    if current == nil || !current.start.Equal(start) {
+        current = &fixedWindow{start: start}
+        rl.windows[deviceID] = current
+    }
+
+    if current.count >= rl.limit {
+        return false
+    }
+    current.count++
+    return true
+}
+
+// Cambiar el reloj con el que se alinean las ventanas
+func (rl *FixedWindowRateLimiter) SetClock(clock Clock) {
+    rl.mutex.Lock()
+    defer rl.mutex.Unlock()
+
+    rl.clock = clock
+}
+
+// Reiniciar el contador de un dispositivo
+func (rl *FixedWindowRateLimiter) Reset(deviceID string) {
+    rl.mutex.Lock()
+    defer rl.mutex.Unlock()
+
+    delete(rl.windows, deviceID)
+}
+
+// Reiniciar los contadores de todos los dispositivos
+func (rl *FixedWindowRateLimiter) ResetAll() {
+    rl.mutex.Lock()
+    defer rl.mutex.Unlock()
+
+    rl.windows = make(map[string]*fixedWindow)
+}
+
+// Número de dispositivos con contador
+func (rl *FixedWindowRateLimiter) DeviceCount() int {
+    rl.mutex.Lock()
+    defer rl.mutex.Unlock()
+
+    return len(rl.windows)
+}
+
+// Copia del estado: Tokens son los mensajes que quedan en la ventana y
+// LastRefill su inicio
+func (rl *FixedWindowRateLimiter) Snapshot() map[string]TokenBucketState {
+    rl.mutex.Lock()
+    defer rl.mutex.Unlock()
+
+    states := make(map[string]TokenBucketState, len(rl.windows))
+    for deviceID, current := range rl.windows {
+        states[deviceID] = TokenBucketState{Tokens: float64(rl.limit - current.count), LastRefill: current.start}
+    }
+    return states
+}
+
+// Restaurar los contadores desde un snapshot (sustituye el estado actual)
+func (rl *FixedWindowRateLimiter) Restore(states map[string]TokenBucketState) {
+    rl.mutex.Lock()
+    defer rl.mutex.Unlock()
+
+    rl.windows = make(map[string]*fixedWindow, len(states))
+    for deviceID, state := range states {
+        rl.windows[deviceID] = &fixedWindow{
+            start: state.LastRefill,
+            count: rl.limit - int(math.Min(float64(rl.limit), state.Tokens)),
+        }
+    }
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+    "testing"
+    "time"
+)
+
+func TestRateLimiter_WindowExpiry(t *testing.T) {
+    tests := []struct {
+        name    string
+        limiter RateLimiter
+        advance time.Duration
+        // Mensajes admitidos tras agotar la cuota y avanzar el reloj
+        wantAllowed bool
+    }{
+        {name: "token bucket sin esperar", limiter: NewTokenBucketRateLimiter(1, 2), advance: 0, wantAllowed: false},
+        {name: "token bucket tras recargar", limiter: NewTokenBucketRateLimiter(1, 2), advance: time.Second, wantAllowed: true},
+        {name: "ventana fija dentro de la ventana", limiter: NewFixedWindowRateLimiter(2, time.Minute), advance: 59 * time.Second, wantAllowed: false},
+        {name: "ventana fija en la siguiente ventana", limiter: NewFixedWindowRateLimiter(2, time.Minute), advance: time.Minute, wantAllowed: true},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            clock := NewFakeClock(testEpoch)
+            tt.limiter.SetClock(clock)
+
+            for i := 0; i < 2; i++ {
+                if !tt.limiter.IsAllowed("sensor-1") {
+                    t.Fatalf("mensaje %d rechazado dentro de la cuota", i)
+                }
+            }
+            if tt.limiter.IsAllowed("sensor-1") {
+                t.Fatal("mensaje admitido con la cuota agotada")
+            }
+
+            clock.Advance(tt.advance)
+            if got := tt.limiter.IsAllowed("sensor-1"); got != tt.wantAllowed {
+                t.Errorf("tras avanzar %v: IsAllowed = %v, se esperaba %v", tt.advance, got, tt.wantAllowed)
+            }
+        })
+    }
+}
+
+func TestFixedWindowRateLimiter_MinuteBoundaries(t *testing.T) {
+    tests := []struct {
+        name string
+        // Segundo del minuto en que se agota la cuota y espera posterior
+        start       time.Duration
+        advance     time.Duration
+        wantAllowed bool
+    }{
+        {name: "agotada al final del minuto, al cambiar de minuto", start: 50 * time.Second, advance: 10 * time.Second, wantAllowed: true},
+        {name: "agotada al inicio del minuto, 50 segundos después", start: 0, advance: 50 * time.Second, wantAllowed: false},
+        {name: "agotada al inicio del minuto, al cambiar de minuto", start: 0, advance: time.Minute, wantAllowed: true},
+        {name: "justo antes del cambio de minuto", start: 50 * time.Second, advance: 9 * time.Second, wantAllowed: false},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            saved := rateLimitMode
+            t.Cleanup(func() { rateLimitMode = saved })
+            rateLimitMode = RATE_LIMIT_MODE_FIXED_WINDOW
+            limiter := newRateLimiter()
+            if _, ok := limiter.(*FixedWindowRateLimiter); !ok {
+                t.Fatalf("rate limiter = %T, se esperaba *FixedWindowRateLimiter", limiter)
+            }
+            clock := NewFakeClock(testEpoch.Add(tt.start))
+            limiter.SetClock(clock)
+
+            // Agotar la cuota del minuto
+            for i := 0; i < rateLimitMaxMessages; i++ {
+                limiter.IsAllowed("sensor-1")
+            }
+            if limiter.IsAllowed("sensor-1") {
+                t.Fatal("mensaje admitido con la cuota agotada")
+            }
+            clock.Advance(tt.advance)
+            if got := limiter.IsAllowed("sensor-1"); got != tt.wantAllowed {
+                t.Errorf("a %v del minuto: IsAllowed = %v, se esperaba %v", tt.start+tt.advance, got, tt.wantAllowed)
+            }
+        })
+    }
+}
+
+func TestRateLimiter_ResetAll(t *testing.T) {
+    devices := []string{"sensor-1", "sensor-2", "sensor-3"}
+
+    tests := []struct {
+        name    string
+        limiter RateLimiter
+    }{
+        {name: "token bucket", limiter: NewTokenBucketRateLimiter(1, 2)},
+        {name: "ventana fija", limiter: NewFixedWindowRateLimiter(2, time.Minute)},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            tt.limiter.SetClock(NewFakeClock(testEpoch))
+
+            // Agotar la cuota (2 mensajes) de todos los dispositivos sin avanzar el reloj
+            for _, deviceID := range devices {
+                tt.limiter.IsAllowed(deviceID)
+                tt.limiter.IsAllowed(deviceID)
+                if tt.limiter.IsAllowed(deviceID) {
+                    t.Fatalf("%s admitido con la cuota agotada", deviceID)
+                }
+            }
+            if got := tt.limiter.DeviceCount(); got != len(devices) {
+                t.Fatalf("DeviceCount antes de reiniciar = %d, se esperaba %d", got, len(devices))
+            }
+
+            tt.limiter.ResetAll()
+            if got := tt.limiter.DeviceCount(); got != 0 {
+                t.Errorf("DeviceCount tras ResetAll = %d, se esperaba 0", got)
+            }
+            for _, deviceID := range devices {
+                if !tt.limiter.IsAllowed(deviceID) {
+                    t.Errorf("%s sigue limitado tras ResetAll", deviceID)
+                }
+            }
+        })
+    }
+}
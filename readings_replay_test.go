@@ -0,0 +1,49 @@
+package main
+
+import (
+    "context"
+    "testing"
+    "time"
+)
+
+func TestReplayReadingsFile_Fixture(t *testing.T) {
+    setupTestHub(t)
+    savedThreshold := anomalyRateThreshold
+    t.Cleanup(func() { anomalyRateThreshold = savedThreshold })
+    anomalyRateThreshold = 3
+
+    summary, err := replayReadingsFile(context.Background(), "testdata/readings_replay.jsonl", false)
+    if err != nil {
+        t.Fatalf("replayReadingsFile: %v", err)
+    }
+
+    want := ReadingsReplaySummary{Lines: 9, Processed: 8, Rejected: 1, Anomalous: 4}
+    if summary.Lines != want.Lines || summary.Processed != want.Processed || summary.Rejected != want.Rejected || summary.Anomalous != want.Anomalous {
+        t.Errorf("resumen = %+v, se esperaba %+v", summary, want)
+    }
+
+    // Las anomalías se fechan con el timestamp de la lectura reproducida
+    for _, anomaly := range anomalyRepository.ByDevice("sensor-1", false) {
+        if hours := anomaly.Timestamp.Sub(testEpoch).Hours(); hours != float64(int(hours)) || int(hours)%2 != 0 {
+            t.Errorf("%s fechada en %v, se esperaba la hora de una lectura anómala", anomaly.Type, anomaly.Timestamp)
+        }
+    }
+    // Cuatro anomalías separadas dos horas nunca coinciden en la ventana de una hora
+    if quarantineSystem.IsQuarantined("sensor-1") {
+        t.Error("sensor-1 en quarantine: la ventana de tasa debe seguir al reloj de la reproducción")
+    }
+    if last := quarantineSystem.Now(); !last.Equal(testEpoch.Add(7 * time.Hour)) {
+        t.Errorf("reloj tras la reproducción = %v, se esperaba el timestamp de la última lectura", last)
+    }
+}
+
+func TestIsolateReplay(t *testing.T) {
+    cfg := &Config{}
+    cfg.State.RedisAddr = "redis:6379"
+    cfg.State.SnapshotFile = "/var/lib/iot-hub/state.json"
+
+    isolateReplay(cfg)
+    if cfg.State.RedisAddr != "" || cfg.State.SnapshotFile != "" || !cfg.Notifications.DryRun {
+        t.Errorf("configuración de la reproducción = %+v / dry-run %v, se esperaba sin Redis, sin snapshot y en dry-run", cfg.State, cfg.Notifications.DryRun)
+    }
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+    "context"
+    "fmt"
+    "sync"
+    "time"
+
+    "github.com/redis/go-redis/v9"
+)
+
+// Timeout de la consulta de rate limit: se hace por cada mensaje, así que es
+// mucho menor que el de las demás operaciones del repositorio
+const REDIS_RATE_LIMIT_TIMEOUT = 250 * time.Millisecond
+
+// Rate limiter compartido entre las instancias del hub: ventana fija con un
+// contador por dispositivo y ventana en Redis (INCR y PEXPIRE en una sola ida
+// y vuelta), de modo que un dispositivo no multiplica su cuota repartiendo
+// mensajes entre instancias. Si Redis no responde se decide con el limitador
+// local, que además guarda el estado para el snapshot.
+type RedisRateLimiter struct {
+    client *redis.Client
+    limit  int
+    window time.Duration
+    local  RateLimiter
+    mutex  sync.Mutex
+    clock  Clock
+}
+
+// Crear rate limiter compartido con `limit` mensajes por `window`
+func NewRedisRateLimiter(client *redis.Client, limit int, window time.Duration, local RateLimiter) *RedisRateLimiter {
+    return &RedisRateLimiter{
+        client: client,
+        limit:  limit,
+        window: window,
+        local:  local,
+        clock:  RealClock{},
+    }
+}
+
+// Clave del contador de un dispositivo en la ventana que empieza en start
+func redisRateLimitKey(deviceID string, start time.Time) string {
+    return fmt.Sprintf("%sratelimit:%s:%d", REDIS_KEY_PREFIX, deviceID, start.Unix())
+}
+
+// Verificar si el dispositivo puede enviar un mensaje en la ventana actual
+func (rl *RedisRateLimiter) IsAllowed(deviceID string) bool {
+    rl.mutex.Lock()
+    now := rl.clock.Now()
+    rl.mutex.Unlock()
+
+    key := redisRateLimitKey(deviceID, now.Truncate(rl.window))
+    ctx, cancel := context.WithTimeout(context.Background(), REDIS_RATE_LIMIT_TIMEOUT)
+    defer cancel()
+
+    var count *redis.IntCmd
+    _, err := rl.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+        count = pipe.Incr(ctx, key)
+        pipe.PExpire(ctx, key, rl.window)
+        return nil
+    })
+    if err != nil {
+        metrics.Inc("iot_rate_limit_fallbacks")
+        return rl.local.IsAllowed(deviceID)
+    }
+    return count.Val() <= int64(rl.limit)
+}
+
+// Los contadores compartidos caducan con su ventana; solo se reinicia el
+// estado local (desplazamiento o borrado en esta instancia)
+func (rl *RedisRateLimiter) Reset(deviceID string) {
+    rl.local.Reset(deviceID)
+}
+
+func (rl *RedisRateLimiter) ResetAll() {
+    rl.local.ResetAll()
+}
+
+func (rl *RedisRateLimiter) DeviceCount() int {
+    return rl.local.DeviceCount()
+}
+
+func (rl *RedisRateLimiter) Snapshot() map[string]TokenBucketState {
+    return rl.local.Snapshot()
+}
+
+func (rl *RedisRateLimiter) Restore(states map[string]TokenBucketState) {
+    rl.local.Restore(states)
+}
+
+// Cambiar el reloj con el que se alinean las ventanas
+func (rl *RedisRateLimiter) SetClock(clock Clock) {
+    rl.mutex.Lock()
+    rl.clock = clock
+    rl.mutex.Unlock()
+
+    rl.local.SetClock(clock)
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+    "context"
+    "encoding/json"
+    "log"
+    "strings"
+    "sync"
+    "sync/atomic"
+    "time"
+
+    "github.com/redis/go-redis/v9"
+)
+
+// Prefijo de las claves del hub en Redis
+const REDIS_KEY_PREFIX = "iot-hub:"
+
+// Canal pub/sub por el que las instancias se avisan de quarantines y liberaciones
+const REDIS_QUARANTINE_CHANNEL = REDIS_KEY_PREFIX + "quarantine-events"
+
+// Cada cuánto se recarga la caché de quarantines desde las claves, por si se
+// perdieron eventos durante una desconexión del canal
+const REDIS_QUARANTINE_RESYNC_INTERVAL = 1 * time.Minute
+
+// Repositorio de dispositivos en Redis: cada dispositivo es un hash y cada
+// quarantine una clave con TTL, de modo que Redis gestiona la expiración.
+// Las consultas de quarantine se responden desde una caché local que se
+// mantiene con el canal de eventos, para no hacer una ida y vuelta a Redis
+// por cada mensaje MQTT.
+type RedisDeviceRepository struct {
+    client *redis.Client
+
+    mutex sync.RWMutex
+    // Quarantines activas conocidas: device_id → fin
+    quarantines map[string]time.Time
+    // La caché está sincronizada y puede responder a las consultas
+    watching atomic.Bool
+}
+
+// Evento de quarantine publicado en REDIS_QUARANTINE_CHANNEL (Until vacío = liberado)
+type redisQuarantineEvent struct {
+    DeviceID string    `json:"device_id"`
+    Until    time.Time `json:"until,omitempty"`
+}
+
+// Crear repositorio Redis para la dirección host:puerto indicada
+func NewRedisDeviceRepository(addr string) *RedisDeviceRepository {
+    return newRedisDeviceRepositoryWithClient(redis.NewClient(&redis.Options{Addr: addr}))
+}
+
+// Crear repositorio sobre un cliente Redis ya configurado
+func newRedisDeviceRepositoryWithClient(client *redis.Client) *RedisDeviceRepository {
+    return &RedisDeviceRepository{
+        client:      client,
+        quarantines: make(map[string]time.Time),
+    }
+}
+
+func redisDeviceKey(deviceID string) string {
+    return REDIS_KEY_PREFIX + "device:" + deviceID
+}
+
+func redisQuarantineKey(deviceID string) string {
+    return REDIS_KEY_PREFIX + "quarantine:" + deviceID
+}
+
+// first_seen solo se escribe la primera vez: otra instancia (o esta antes de
+// reiniciar) puede haber visto el dispositivo antes
+func (rr *RedisDeviceRepository) SaveDevice(ctx context.Context, record DeviceRecord) error {
+    key := redisDeviceKey(record.DeviceID)
+    _, err := rr.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+        pipe.HSetNX(ctx, key, "first_seen", record.FirstSeen.Format(time.RFC3339))
+        pipe.HSet(ctx, key,
+            "last_seen", record.LastSeen.Format(time.RFC3339),
+            "message_count", record.MessageCount,
+            "total_anomalies", record.TotalAnomalies,
+            "rejected_messages", record.RejectedMessages,
+        )
+        return nil
+    })
+    return err
+}
+
+func (rr *RedisDeviceRepository) SaveQuarantine(ctx context.Context, deviceID string, entry QuarantineEntry) error {
+    ttl := time.Until(entry.Since.Add(entry.Duration))
+    if ttl <= 0 {
+        return nil
+    }
+
+    value, err := json.Marshal(entry)
+    if err != nil {
+        return err
+    }
+    event := redisQuarantineEvent{DeviceID: deviceID, Until: entry.Since.Add(entry.Duration)}
+    return rr.writeWithEvent(ctx, event, func(pipe redis.Pipeliner) {
+        pipe.Set(ctx, redisQuarantineKey(deviceID), value, ttl)
+    })
+}
+
+// Consultar la caché si está sincronizada; si no (antes de WatchQuarantines o
+// con Redis caído desde el arranque), preguntar a Redis directamente
+func (rr *RedisDeviceRepository) IsDeviceQuarantined(ctx context.Context, deviceID string) (bool, error) {
+    if rr.watching.Load() {
+        rr.mutex.RLock()
+        until, exists := rr.quarantines[deviceID]
+        rr.mutex.RUnlock()
+        return exists && time.Now().Before(until), nil
+    }
+
+    count, err := rr.client.Exists(ctx, redisQuarantineKey(deviceID)).Result()
+    if err != nil {
+        return false, err
+    }
+    return count > 0, nil
+}
+
+func (rr *RedisDeviceRepository) ReleaseQuarantine(ctx context.Context, deviceID string) error {
+    return rr.writeWithEvent(ctx, redisQuarantineEvent{DeviceID: deviceID}, func(pipe redis.Pipeliner) {
+        pipe.Del(ctx, redisQuarantineKey(deviceID))
+    })
+}
+
+func (rr *RedisDeviceRepository) DeleteDevice(ctx context.Context, deviceID string) error {
+    return rr.writeWithEvent(ctx, redisQuarantineEvent{DeviceID: deviceID}, func(pipe redis.Pipeliner) {
+        pipe.Del(ctx, redisDeviceKey(deviceID), redisQuarantineKey(deviceID))
+    })
+}
+
+// Escribir y avisar a las demás instancias en la misma transacción, y
+// actualizar la caché propia sin esperar al eco del canal
+func (rr *RedisDeviceRepository) writeWithEvent(ctx context.Context, event redisQuarantineEvent, write func(pipe redis.Pipeliner)) error {
+    payload, err := json.Marshal(event)
+    if err != nil {
+        return err
+    }
+    _, err = rr.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+        write(pipe)
+        pipe.Publish(ctx, REDIS_QUARANTINE_CHANNEL, payload)
+        return nil
+    })
+    if err != nil {
+        return err
+    }
+    rr.applyQuarantineEvent(event)
+    return nil
+}
+
+// Aplicar un evento de quarantine a la caché
+func (rr *RedisDeviceRepository) applyQuarantineEvent(event redisQuarantineEvent) {
+    rr.mutex.Lock()
+    defer rr.mutex.Unlock()
+
+    if event.Until.IsZero() {
+        delete(rr.quarantines, event.DeviceID)
+        return
+    }
+    rr.quarantines[event.DeviceID] = event.Until
+}
+
+// Recargar la caché con las claves de quarantine existentes
+func (rr *RedisDeviceRepository) resyncQuarantines(ctx context.Context) error {
+    ctx, cancel := context.WithTimeout(ctx, DEVICE_REPOSITORY_TIMEOUT)
+    defer cancel()
+
+    prefix := redisQuarantineKey("")
+    keys := make([]string, 0)
+    iter := rr.client.Scan(ctx, 0, prefix+"*", 100).Iterator()
+    for iter.Next(ctx) {
+        keys = append(keys, iter.Val())
+    }
+    if err := iter.Err(); err != nil {
+        return err
+    }
+
+    quarantines := make(map[string]time.Time, len(keys))
+    if len(keys) > 0 {
+        values, err := rr.client.MGet(ctx, keys...).Result()
+        if err != nil {
+            return err
+        }
+        for i, value := range values {
+            raw, ok := value.(string)
+            if !ok {
+                continue // expiró entre SCAN y MGET
+            }
+            var entry QuarantineEntry
+            if err := json.Unmarshal([]byte(raw), &entry); err != nil {
+                continue
+            }
+            quarantines[strings.TrimPrefix(keys[i], prefix)] = entry.Since.Add(entry.Duration)
+        }
+    }
+
+    rr.mutex.Lock()
+    rr.quarantines = quarantines
+    rr.mutex.Unlock()
+    return nil
+}
+
+// Mantener la caché de quarantines al día hasta que se cancele el contexto:
+// eventos del canal y una recarga completa cada REDIS_QUARANTINE_RESYNC_INTERVAL.
+// Mientras la recarga no tenga éxito se consulta a Redis directamente. El
+// canal devuelto se cierra cuando la goroutine termina.
+func (rr *RedisDeviceRepository) WatchQuarantines(ctx context.Context) <-chan struct{} {
+    done := make(chan struct{})
+    pubsub := rr.client.Subscribe(ctx, REDIS_QUARANTINE_CHANNEL)
+
+    resync := func() {
+        if err := rr.resyncQuarantines(ctx); err != nil {
+            log.Printf("⚠️ Repositorio compartido: error recargando quarantines: %v", err)
+            rr.watching.Store(false)
+            return
+        }
+        rr.watching.Store(true)
+    }
+
+    go func() {
+        defer close(done)
+        defer pubsub.Close()
+        defer rr.watching.Store(false)
+
+        resync()
+        ticker := time.NewTicker(REDIS_QUARANTINE_RESYNC_INTERVAL)
+        defer ticker.Stop()
+        messages := pubsub.Channel()
+
+        for {
+            select {
+            case <-ctx.Done():
+                return
+            case <-ticker.C:
+                resync()
+            case message, ok := <-messages:
+                if !ok {
+                    return
+                }
+                var event redisQuarantineEvent
+                if err := json.Unmarshal([]byte(message.Payload), &event); err != nil {
+                    log.Printf("⚠️ Repositorio compartido: evento de quarantine inválido: %v", err)
+                    continue
+                }
+                rr.applyQuarantineEvent(event)
+            }
+        }
+    }()
+
+    return done
+}
+
+// Rate limiter compartido sobre la misma conexión
+func (rr *RedisDeviceRepository) NewRateLimiter(limit int, window time.Duration, local RateLimiter) *RedisRateLimiter {
+    return NewRedisRateLimiter(rr.client, limit, window, local)
+}
+
+// El TTL de las claves de quarantine ya libera los dispositivos
+func (rr *RedisDeviceRepository) CleanExpiredQuarantines(ctx context.Context) error {
+    return nil
+}
+
+// Cerrar la conexión con Redis
+func (rr *RedisDeviceRepository) Close() error {
+    return rr.client.Close()
+}
@@ -0,0 +1,126 @@
+//go:build integration
+
+package main
+
+import (
+    "context"
+    "os"
+    "testing"
+    "time"
+
+    "github.com/redis/go-redis/v9"
+)
+
+// Pruebas contra un Redis real: go test -tags integration con REDIS_ADDR
+// apuntando a una instancia desechable (se borran las claves del hub)
+func integrationRedisClient(t *testing.T) *redis.Client {
+    t.Helper()
+
+    addr := os.Getenv("REDIS_ADDR")
+    if addr == "" {
+        t.Skip("REDIS_ADDR no configurado")
+    }
+    client := redis.NewClient(&redis.Options{Addr: addr})
+    ctx := context.Background()
+    if err := client.Ping(ctx).Err(); err != nil {
+        t.Fatalf("Redis no disponible en %s: %v", addr, err)
+    }
+    cleanup := func() {
+        keys, _ := client.Keys(ctx, REDIS_KEY_PREFIX+"*").Result()
+        if len(keys) > 0 {
+            client.Del(ctx, keys...)
+        }
+    }
+    cleanup()
+    t.Cleanup(func() {
+        cleanup()
+        client.Close()
+    })
+    return client
+}
+
+// Esperar a que se cumpla una condición (los eventos pub/sub son asíncronos)
+func eventually(t *testing.T, condition func() bool) {
+    t.Helper()
+
+    deadline := time.Now().Add(2 * time.Second)
+    for !condition() {
+        if time.Now().After(deadline) {
+            t.Fatal("la condición no se cumplió a tiempo")
+        }
+        time.Sleep(10 * time.Millisecond)
+    }
+}
+
+func TestRedisIntegration_QuarantineSharedBetweenInstances(t *testing.T) {
+    client := integrationRedisClient(t)
+    ctx, cancel := context.WithCancel(context.Background())
+    defer cancel()
+
+    first := newRedisDeviceRepositoryWithClient(client)
+    second := newRedisDeviceRepositoryWithClient(redis.NewClient(client.Options()))
+    defer second.Close()
+    secondDone := second.WatchQuarantines(ctx)
+    eventually(t, second.watching.Load)
+
+    quarantined := func(repository *RedisDeviceRepository) func() bool {
+        return func() bool {
+            got, err := repository.IsDeviceQuarantined(ctx, "sensor-1")
+            return err == nil && got
+        }
+    }
+
+    entry := QuarantineEntry{Since: time.Now(), Duration: time.Minute, Reason: QuarantineReasonInvalidData}
+    if err := first.SaveQuarantine(ctx, "sensor-1", entry); err != nil {
+        t.Fatalf("SaveQuarantine() error = %v", err)
+    }
+    eventually(t, quarantined(second))
+
+    if err := first.ReleaseQuarantine(ctx, "sensor-1"); err != nil {
+        t.Fatalf("ReleaseQuarantine() error = %v", err)
+    }
+    eventually(t, func() bool { return !quarantined(second)() })
+
+    cancel()
+    <-secondDone
+}
+
+func TestRedisIntegration_ResyncLoadsExistingQuarantines(t *testing.T) {
+    client := integrationRedisClient(t)
+    ctx := context.Background()
+
+    writer := newRedisDeviceRepositoryWithClient(client)
+    entry := QuarantineEntry{Since: time.Now(), Duration: time.Minute, Reason: QuarantineReasonInvalidData}
+    if err := writer.SaveQuarantine(ctx, "sensor-1", entry); err != nil {
+        t.Fatalf("SaveQuarantine() error = %v", err)
+    }
+
+    // Una instancia que arranca después carga la quarantine de las claves
+    reader := newRedisDeviceRepositoryWithClient(client)
+    if err := reader.resyncQuarantines(ctx); err != nil {
+        t.Fatalf("resyncQuarantines() error = %v", err)
+    }
+    reader.watching.Store(true)
+    if got, _ := reader.IsDeviceQuarantined(ctx, "sensor-1"); !got {
+        t.Error("la quarantine existente no se cargó en la caché")
+    }
+}
+
+func TestRedisIntegration_RateLimitSharedBetweenInstances(t *testing.T) {
+    client := integrationRedisClient(t)
+
+    clock := NewFakeClock(time.Now())
+    first := NewRedisRateLimiter(client, 3, time.Minute, NewFixedWindowRateLimiter(3, time.Minute))
+    second := NewRedisRateLimiter(client, 3, time.Minute, NewFixedWindowRateLimiter(3, time.Minute))
+    first.SetClock(clock)
+    second.SetClock(clock)
+
+    // Los mensajes se reparten entre instancias pero cuentan contra la misma cuota
+    want := []bool{true, true, true, false}
+    limiters := []*RedisRateLimiter{first, second, first, second}
+    for i, limiter := range limiters {
+        if got := limiter.IsAllowed("sensor-1"); got != want[i] {
+            t.Errorf("mensaje %d: IsAllowed() = %v, se esperaba %v", i+1, got, want[i])
+        }
+    }
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+    "context"
+    "testing"
+    "time"
+
+    "github.com/redis/go-redis/v9"
+)
+
+// Cliente Redis contra un puerto sin servidor: todas las operaciones fallan
+func unreachableRedisClient(t *testing.T) *redis.Client {
+    t.Helper()
+
+    client := redis.NewClient(&redis.Options{
+        Addr:        "127.0.0.1:1",
+        DialTimeout: 50 * time.Millisecond,
+        MaxRetries:  -1,
+    })
+    t.Cleanup(func() { client.Close() })
+    return client
+}
+
+func TestRedisDeviceRepository_QuarantineCache(t *testing.T) {
+    now := time.Now()
+
+    tests := []struct {
+        name   string
+        events []redisQuarantineEvent
+        want   bool
+    }{
+        {name: "sin eventos", want: false},
+        {name: "en cuarentena", events: []redisQuarantineEvent{{DeviceID: "sensor-1", Until: now.Add(time.Hour)}}, want: true},
+        {name: "cuarentena caducada", events: []redisQuarantineEvent{{DeviceID: "sensor-1", Until: now.Add(-time.Second)}}, want: false},
+        {
+            name: "liberada después",
+            events: []redisQuarantineEvent{
+                {DeviceID: "sensor-1", Until: now.Add(time.Hour)},
+                {DeviceID: "sensor-1"},
+            },
+            want: false,
+        },
+        {name: "otro dispositivo", events: []redisQuarantineEvent{{DeviceID: "sensor-2", Until: now.Add(time.Hour)}}, want: false},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            repository := newRedisDeviceRepositoryWithClient(unreachableRedisClient(t))
+            for _, event := range tt.events {
+                repository.applyQuarantineEvent(event)
+            }
+            repository.watching.Store(true)
+
+            // Con la caché sincronizada no se consulta a Redis (que no está)
+            got, err := repository.IsDeviceQuarantined(context.Background(), "sensor-1")
+            if err != nil {
+                t.Fatalf("IsDeviceQuarantined() error = %v", err)
+            }
+            if got != tt.want {
+                t.Errorf("IsDeviceQuarantined() = %v, se esperaba %v", got, tt.want)
+            }
+        })
+    }
+}
+
+func TestRedisDeviceRepository_UnsyncedCacheAsksRedis(t *testing.T) {
+    repository := newRedisDeviceRepositoryWithClient(unreachableRedisClient(t))
+    repository.applyQuarantineEvent(redisQuarantineEvent{DeviceID: "sensor-1", Until: time.Now().Add(time.Hour)})
+
+    if _, err := repository.IsDeviceQuarantined(context.Background(), "sensor-1"); err == nil {
+        t.Error("sin sincronizar la caché se esperaba la consulta (fallida) a Redis")
+    }
+}
+
+func TestRedisRateLimiter_FallsBackToLocal(t *testing.T) {
+    local := NewFixedWindowRateLimiter(2, time.Minute)
+    limiter := NewRedisRateLimiter(unreachableRedisClient(t), 2, time.Minute, local)
+    clock := NewFakeClock(testEpoch)
+    limiter.SetClock(clock)
+    before := metrics.Value("iot_rate_limit_fallbacks")
+
+    want := []bool{true, true, false}
+    for i, expected := range want {
+        if got := limiter.IsAllowed("sensor-1"); got != expected {
+            t.Errorf("mensaje %d: IsAllowed() = %v, se esperaba %v", i+1, got, expected)
+        }
+    }
+    if got := metrics.Value("iot_rate_limit_fallbacks") - before; got != 3 {
+        t.Errorf("consultas resueltas en local = %v, se esperaban 3", got)
+    }
+
+    clock.Advance(time.Minute)
+    if !limiter.IsAllowed("sensor-1") {
+        t.Error("en la ventana siguiente el limitador local debería permitir el mensaje")
+    }
+}
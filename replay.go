@@ -0,0 +1,92 @@
+package main
+
+import (
+    "sync"
+)
+
+// Configuración del historial de lecturas
+const (
+    READING_HISTORY_SIZE = 10000
+)
+
+// Historial acotado de lecturas crudas ya validadas
+type ReadingHistory struct {
+    mutex    sync.RWMutex
+    readings []SensorData
+    maxSize  int
+}
+
+var readingHistory *ReadingHistory
+
+// Inicializar historial de lecturas
+func NewReadingHistory(maxSize int) *ReadingHistory {
+    return &ReadingHistory{
+        readings: make([]SensorData, 0),
+        maxSize:  maxSize,
+    }
+}
+
+// Guardar una lectura, descartando la más antigua si se supera el tamaño
+func (rh *ReadingHistory) Add(data SensorData) {
+    rh.mutex.Lock()
+    defer rh.mutex.Unlock()
+
+    rh.readings = append(rh.readings, data)
+    if len(rh.readings) > rh.maxSize {
+        rh.readings = rh.readings[len(rh.readings)-rh.maxSize:]
+    }
+}
+
+// Copia de todas las lecturas almacenadas
+func (rh *ReadingHistory) All() []SensorData {
+    rh.mutex.RLock()
+    defer rh.mutex.RUnlock()
+
+    result := make([]SensorData, len(rh.readings))
+    copy(result, rh.readings)
+    return result
+}
+
+// Resultado de comparar umbrales propuestos contra los actuales
+type WhatIfResult struct {
+    ReadingsEvaluated int `json:"readings_evaluated"`
+    CurrentAnomalies  int `json:"current_anomalies"`
+    ProposedAnomalies int `json:"proposed_anomalies"`
+    Added             int `json:"added"`
+    Removed           int `json:"removed"`
+}
+
+// Reproducir las lecturas con otros umbrales sin tocar la configuración activa.
+// Los umbrales propuestos sustituyen a los base; los tipos con perfil propio
+// conservan su perfil en ambos casos.
+func replayWithThresholds(readings []SensorData, current, proposed AnomalyThresholds) WhatIfResult {
+    result := WhatIfResult{ReadingsEvaluated: len(readings)}
+
+    for i := range readings {
+        deviceType := readings[i].DeviceType
+
+        currentSet := make(map[string]bool)
+        for _, anomaly := range detectAnomaliesWith(&readings[i], deviceProfiles.ThresholdsFor(deviceType, current)) {
+            currentSet[anomaly.Description] = true
+        }
+
+        proposedSet := make(map[string]bool)
+        for _, anomaly := range detectAnomaliesWith(&readings[i], deviceProfiles.ThresholdsFor(deviceType, proposed)) {
+            proposedSet[anomaly.Description] = true
+            if !currentSet[anomaly.Description] {
+                result.Added++
+            }
+        }
+
+        for anomaly := range currentSet {
+            if !proposedSet[anomaly] {
+                result.Removed++
+            }
+        }
+
+        result.CurrentAnomalies += len(currentSet)
+        result.ProposedAnomalies += len(proposedSet)
+    }
+
+    return result
+}
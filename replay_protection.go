@@ -0,0 +1,38 @@
+package main
+
+import "hash/fnv"
+
+// Protección contra replay. El timestamp de los dispositivos tiene resolución
+// de segundos, así que varios mensajes legítimos pueden compartir segundo:
+// con el mismo timestamp solo se rechaza un payload idéntico a uno ya
+// aceptado en ese segundo. Un timestamp anterior al último procesado se
+// rechaza siempre.
+func (qs *QuarantineSystem) CheckReplay(data *SensorData, payload []byte) *Anomaly {
+    hasher := fnv.New64a()
+    hasher.Write(payload)
+    digest := hasher.Sum64()
+
+    qs.mutex.Lock()
+    defer qs.mutex.Unlock()
+
+    behavior := qs.behaviorLocked(data.DeviceID)
+    switch {
+    case data.Timestamp < behavior.LastTimestamp:
+        anomaly := newAnomaly(data, AnomalyBehaviorPattern, float64(data.Timestamp), "posible replay: timestamp %d anterior al último procesado (%d)", data.Timestamp, behavior.LastTimestamp)
+        anomaly.Severity = SEVERITY_HIGH
+        return &anomaly
+    case data.Timestamp == behavior.LastTimestamp:
+        for _, seen := range behavior.LastTimestampDigests {
+            if seen == digest {
+                anomaly := newAnomaly(data, AnomalyBehaviorPattern, float64(data.Timestamp), "posible replay: mensaje duplicado con timestamp %d", data.Timestamp)
+                anomaly.Severity = SEVERITY_HIGH
+                return &anomaly
+            }
+        }
+        behavior.LastTimestampDigests = append(behavior.LastTimestampDigests, digest)
+    default:
+        behavior.LastTimestamp = data.Timestamp
+        behavior.LastTimestampDigests = []uint64{digest}
+    }
+    return nil
+}
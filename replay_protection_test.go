@@ -0,0 +1,61 @@
+package main
+
+import (
+    "context"
+    "fmt"
+    "testing"
+    "time"
+)
+
+func TestProcessSensorData_ReplayProtection(t *testing.T) {
+    tests := []struct {
+        name string
+        // Desplazamiento del segundo timestamp y temperatura del segundo mensaje
+        offset      int64
+        temperature float64
+        // Tiempo entre ambos mensajes: pasada la ventana de reenvíos idénticos
+        // un payload repetido ya no se trata como duplicado sino como replay
+        advance      time.Duration
+        wantReplay   bool
+        wantReadings int
+    }{
+        {name: "mismo timestamp y payload", temperature: 21, advance: time.Hour, wantReplay: true, wantReadings: 1},
+        {name: "reenvío inmediato idéntico", temperature: 21, wantReadings: 1},
+        {name: "mismo segundo con otro payload", temperature: 21.5, wantReadings: 2},
+        {name: "timestamp anterior", offset: -1, temperature: 21.5, wantReplay: true, wantReadings: 1},
+        {name: "timestamp posterior", offset: 1, temperature: 21, wantReadings: 2},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            clock := setupTestHub(t)
+            send := func(timestamp int64, temperature float64) error {
+                data := SensorData{DeviceID: "sensor-1", Timestamp: timestamp, Temperature: temperature, Humidity: 40, BatteryLevel: 80}
+                payload := []byte(fmt.Sprintf(`{"device_id":"sensor-1","timestamp":%d,"temperature":%v,"humidity":40,"battery_level":80}`, timestamp, temperature))
+                return processSensorData(context.Background(), data, nil, payload)
+            }
+
+            if err := send(testEpoch.Unix(), 21); err != nil {
+                t.Fatalf("primer mensaje rechazado: %v", err)
+            }
+            clock.Advance(tt.advance)
+            err := send(testEpoch.Unix()+tt.offset, tt.temperature)
+            if got := err != nil; got != tt.wantReplay {
+                t.Fatalf("segundo mensaje rechazado = %v (%v), se esperaba %v", got, err, tt.wantReplay)
+            }
+
+            replays := 0
+            for _, anomaly := range anomalyRepository.ByDevice("sensor-1", false) {
+                if anomaly.Type == AnomalyBehaviorPattern && anomaly.Severity == SEVERITY_HIGH {
+                    replays++
+                }
+            }
+            if got := replays > 0; got != tt.wantReplay {
+                t.Errorf("anomalía de replay = %v, se esperaba %v", got, tt.wantReplay)
+            }
+            if got := len(readingHistory.All()); got != tt.wantReadings {
+                t.Errorf("lecturas procesadas = %d, se esperaban %d", got, tt.wantReadings)
+            }
+        })
+    }
+}
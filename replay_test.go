@@ -0,0 +1,103 @@
+package main
+
+import (
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+)
+
+// Lecturas de temperatura con el resto de métricas en rango
+func temperatureReadings(temperatures ...float64) []SensorData {
+    readings := make([]SensorData, len(temperatures))
+    for i, temperature := range temperatures {
+        readings[i] = SensorData{DeviceID: "sensor-1", Timestamp: testEpoch.Unix(), Temperature: temperature, Humidity: 15, BatteryLevel: 80}
+    }
+    return readings
+}
+
+func TestReplayWithThresholds(t *testing.T) {
+    readings := temperatureReadings(20, 40, 45, 55)
+
+    tests := []struct {
+        name     string
+        proposed func(thresholds *AnomalyThresholds)
+        want     WhatIfResult
+    }{
+        {
+            name:     "mismos umbrales",
+            proposed: func(thresholds *AnomalyThresholds) {},
+            want:     WhatIfResult{ReadingsEvaluated: 4, CurrentAnomalies: 1, ProposedAnomalies: 1},
+        },
+        {
+            name:     "umbral más estricto",
+            proposed: func(thresholds *AnomalyThresholds) { thresholds.TemperatureMax = 42 },
+            want:     WhatIfResult{ReadingsEvaluated: 4, CurrentAnomalies: 1, ProposedAnomalies: 2, Added: 1},
+        },
+        {
+            name:     "umbral más laxo",
+            proposed: func(thresholds *AnomalyThresholds) { thresholds.TemperatureMax = 60 },
+            want:     WhatIfResult{ReadingsEvaluated: 4, CurrentAnomalies: 1, Removed: 1},
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            current := DefaultAnomalyThresholds()
+            proposed := current
+            tt.proposed(&proposed)
+
+            if got := replayWithThresholds(readings, current, proposed); got != tt.want {
+                t.Errorf("resultado = %+v, se esperaba %+v", got, tt.want)
+            }
+        })
+    }
+}
+
+func TestHandleWhatIf(t *testing.T) {
+    tests := []struct {
+        name       string
+        body       string
+        wantStatus int
+        wantAdded  int
+    }{
+        {name: "umbral más estricto", body: `{"temperature_max": 42}`, wantStatus: http.StatusOK, wantAdded: 1},
+        {name: "cuerpo inválido", body: `{"temperature_max": "alto"}`, wantStatus: http.StatusBadRequest},
+        {
+            name:       "cuerpo demasiado grande",
+            body:       `{"temperature_max": 42, "relleno": "` + strings.Repeat("x", WHATIF_MAX_BODY_BYTES) + `"}`,
+            wantStatus: http.StatusRequestEntityTooLarge,
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            setupTestHub(t)
+            withAPIToken(t)
+            for _, reading := range temperatureReadings(20, 45, 55) {
+                readingHistory.Add(reading)
+            }
+            live := anomalyThresholds
+
+            recorder := httptest.NewRecorder()
+            newAPIRouter().ServeHTTP(recorder, newAuthorizedRequest(http.MethodPost, "/anomalies/whatif", strings.NewReader(tt.body)))
+            if recorder.Code != tt.wantStatus {
+                t.Fatalf("estado HTTP = %d, se esperaba %d (%s)", recorder.Code, tt.wantStatus, recorder.Body.String())
+            }
+            if anomalyThresholds != live {
+                t.Error("la simulación no debe cambiar los umbrales activos")
+            }
+            if tt.wantStatus != http.StatusOK {
+                return
+            }
+            var result WhatIfResult
+            if err := json.NewDecoder(recorder.Body).Decode(&result); err != nil {
+                t.Fatalf("respuesta no es JSON: %v", err)
+            }
+            if result.ReadingsEvaluated != 3 || result.Added != tt.wantAdded {
+                t.Errorf("resultado = %+v, se esperaban 3 lecturas y %d añadidas", result, tt.wantAdded)
+            }
+        })
+    }
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+    "math"
+    "time"
+)
+
+// Pesos de cada factor en la reputación (suman 100)
+const (
+    REPUTATION_WEIGHT_CLEAN_RATIO = 50.0
+    REPUTATION_WEIGHT_ANOMALIES   = 20.0
+    REPUTATION_WEIGHT_QUARANTINES = 20.0
+    REPUTATION_WEIGHT_UPTIME      = 10.0
+)
+
+// Tiempo de actividad a partir del cual el factor de antigüedad es máximo
+const REPUTATION_FULL_UPTIME = 24 * time.Hour
+
+// Factores que contribuyen a la reputación de un dispositivo
+type ReputationFactors struct {
+    MessageCount      int     `json:"message_count"`
+    CleanMessageRatio float64 `json:"clean_message_ratio"`
+    TotalAnomalies    int     `json:"total_anomalies"`
+    QuarantineCount   int     `json:"quarantine_count"`
+    UptimeSeconds     int64   `json:"uptime_seconds"`
+}
+
+// Reputación calculada (0 = nada fiable, 100 = totalmente fiable)
+type DeviceReputation struct {
+    DeviceID      string             `json:"device_id"`
+    Score         float64            `json:"score"`
+    Quarantined   bool               `json:"quarantined"`
+    Factors       ReputationFactors  `json:"factors"`
+    Contributions map[string]float64 `json:"contributions"`
+}
+
+// Registrar el resultado del procesamiento de un mensaje
+func (qs *QuarantineSystem) RecordMessageOutcome(deviceID string, anomalies int) {
+    qs.mutex.Lock()
+    behavior := qs.behaviorLocked(deviceID)
+    bucket := behavior.activityBucketLocked(qs.clock.Now())
+    bucket.Messages++
+    if anomalies > 0 {
+        behavior.AnomalousMessages++
+        behavior.TotalAnomalies += anomalies
+        bucket.Anomalous++
+    }
+    record := DeviceRecord{
+        DeviceID:         deviceID,
+        FirstSeen:        behavior.FirstSeen,
+        LastSeen:         behavior.LastSeen,
+        MessageCount:     behavior.MessageCount,
+        TotalAnomalies:   behavior.TotalAnomalies,
+        RejectedMessages: behavior.RejectedMessages,
+    }
+    qs.mutex.Unlock()
+
+    qs.saveDeviceAsync(record)
+}
+
+// Calcular la reputación de un dispositivo (false si no se conoce)
+func (qs *QuarantineSystem) GetReputation(deviceID string) (*DeviceReputation, bool) {
+    qs.mutex.RLock()
+    behavior, exists := qs.deviceBehavior[deviceID]
+    if !exists {
+        qs.mutex.RUnlock()
+        return nil, false
+    }
+    factors := ReputationFactors{
+        MessageCount:      behavior.MessageCount,
+        CleanMessageRatio: 1,
+        TotalAnomalies:    behavior.TotalAnomalies,
+        QuarantineCount:   behavior.TotalQuarantines,
+        UptimeSeconds:     int64(qs.clock.Now().Sub(behavior.FirstSeen).Seconds()),
+    }
+    if behavior.MessageCount > 0 {
+        clean := behavior.MessageCount - behavior.AnomalousMessages
+        factors.CleanMessageRatio = math.Max(0, float64(clean)/float64(behavior.MessageCount))
+    }
+    qs.mutex.RUnlock()
+
+    contributions := reputationContributions(factors)
+    return &DeviceReputation{
+        DeviceID:      deviceID,
+        Score:         reputationScore(contributions),
+        Quarantined:   qs.IsQuarantined(deviceID),
+        Factors:       factors,
+        Contributions: contributions,
+    }, true
+}
+
+// Contribución de cada factor a la puntuación
+func reputationContributions(factors ReputationFactors) map[string]float64 {
+    uptime := math.Min(1, float64(factors.UptimeSeconds)/REPUTATION_FULL_UPTIME.Seconds())
+
+    return map[string]float64{
+        "clean_message_ratio": REPUTATION_WEIGHT_CLEAN_RATIO * factors.CleanMessageRatio,
+        "anomaly_history":     REPUTATION_WEIGHT_ANOMALIES / (1 + float64(factors.TotalAnomalies)/10),
+        "quarantine_history":  REPUTATION_WEIGHT_QUARANTINES / (1 + float64(factors.QuarantineCount)),
+        "uptime":              REPUTATION_WEIGHT_UPTIME * uptime,
+    }
+}
+
+// Sumar las contribuciones (redondeado a 1 decimal)
+func reputationScore(contributions map[string]float64) float64 {
+    total := 0.0
+    for _, contribution := range contributions {
+        total += contribution
+    }
+    return math.Round(total*10) / 10
+}
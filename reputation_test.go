@@ -0,0 +1,81 @@
+package main
+
+import (
+    "context"
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+    "time"
+)
+
+func TestDeviceReputation_ReflectsHistory(t *testing.T) {
+    tests := []struct {
+        name            string
+        signals         []float64
+        quarantines     int
+        wantCleanRatio  float64
+        wantAnomalies   int
+        wantQuarantines int
+        wantScore       float64
+    }{
+        {name: "historial limpio", signals: []float64{80, 80, 80, 80}, wantCleanRatio: 1, wantScore: 97.5},
+        {name: "mitad anómalos", signals: []float64{80, 5, 80, 5}, wantCleanRatio: 0.5, wantAnomalies: 2, wantScore: 69.2},
+        {name: "con quarantine previa", signals: []float64{80, 80, 80, 80}, quarantines: 1, wantCleanRatio: 1, wantQuarantines: 1, wantScore: 87.5},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            clock := setupTestHub(t)
+            for i := 0; i < tt.quarantines; i++ {
+                quarantineSystem.QuarantineDeviceWithReason("sensor-1", QuarantineReasonOther, "test")
+                quarantineSystem.ReleaseFromQuarantine("sensor-1", ReleaseReasonManual, "test")
+            }
+
+            // Una lectura cada 6 horas: 18 horas de actividad al final
+            for i, signal := range tt.signals {
+                if i > 0 {
+                    clock.Advance(6 * time.Hour)
+                }
+                data := SensorData{DeviceID: "sensor-1", Timestamp: clock.Now().Unix(), Temperature: 22, Humidity: 40, BatteryLevel: 80, SignalStrength: signal}
+                if err := processSensorData(context.Background(), data, nil, nil); err != nil {
+                    t.Fatalf("processSensorData: %v", err)
+                }
+            }
+
+            recorder := httptest.NewRecorder()
+            newAPIRouter().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/devices/sensor-1/reputation", nil))
+            if recorder.Code != http.StatusOK {
+                t.Fatalf("estado = %d, se esperaba %d", recorder.Code, http.StatusOK)
+            }
+            var reputation DeviceReputation
+            if err := json.Unmarshal(recorder.Body.Bytes(), &reputation); err != nil {
+                t.Fatalf("respuesta no válida: %v", err)
+            }
+
+            factors := reputation.Factors
+            if factors.MessageCount != len(tt.signals) || factors.CleanMessageRatio != tt.wantCleanRatio {
+                t.Errorf("mensajes = %d con ratio limpio %v, se esperaban %d con %v", factors.MessageCount, factors.CleanMessageRatio, len(tt.signals), tt.wantCleanRatio)
+            }
+            if factors.TotalAnomalies != tt.wantAnomalies || factors.QuarantineCount != tt.wantQuarantines {
+                t.Errorf("anomalías = %d, quarantines = %d; se esperaban %d y %d", factors.TotalAnomalies, factors.QuarantineCount, tt.wantAnomalies, tt.wantQuarantines)
+            }
+            if factors.UptimeSeconds != int64((18 * time.Hour).Seconds()) {
+                t.Errorf("actividad = %ds, se esperaban %ds", factors.UptimeSeconds, int64((18 * time.Hour).Seconds()))
+            }
+            if reputation.Score != tt.wantScore {
+                t.Errorf("puntuación = %v, se esperaba %v", reputation.Score, tt.wantScore)
+            }
+        })
+    }
+}
+
+func TestDeviceReputation_UnknownDevice(t *testing.T) {
+    setupTestHub(t)
+
+    recorder := httptest.NewRecorder()
+    newAPIRouter().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/devices/desconocido/reputation", nil))
+    if recorder.Code != http.StatusNotFound {
+        t.Errorf("estado = %d, se esperaba %d", recorder.Code, http.StatusNotFound)
+    }
+}
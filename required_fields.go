@@ -0,0 +1,133 @@
+package main
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "math"
+    "reflect"
+    "strings"
+
+    "github.com/fxamacker/cbor/v2"
+)
+
+// Campos obligatorios por tipo de dispositivo: {"temperature_sensor": ["temperature", "battery_level"]}.
+// Una lectura sin ellos probablemente esté corrupta o suplantada.
+var requiredFields = map[string][]string{}
+
+// Nombres de campo presentes (y no null) en un payload JSON
+func jsonFieldNames(payload []byte) map[string]bool {
+    var fields map[string]json.RawMessage
+    if err := json.Unmarshal(payload, &fields); err != nil {
+        return nil
+    }
+    present := make(map[string]bool, len(fields))
+    for name, raw := range fields {
+        if !bytes.Equal(bytes.TrimSpace(raw), []byte("null")) {
+            present[name] = true
+        }
+    }
+    return present
+}
+
+// Nombres de campo presentes (y no null) en un payload CBOR
+func cborFieldNames(payload []byte) map[string]bool {
+    var fields map[string]cbor.RawMessage
+    if err := cbor.Unmarshal(payload, &fields); err != nil {
+        return nil
+    }
+    present := make(map[string]bool, len(fields))
+    for name, raw := range fields {
+        if !bytes.Equal(raw, []byte{0xf6}) {
+            present[name] = true
+        }
+    }
+    return present
+}
+
+// Si la lectura incluía el campo. Los valores cero no sirven para saberlo
+// (0°C es una lectura válida), así que se consulta la presencia registrada
+// al decodificar.
+func (data *SensorData) HasField(name string) bool {
+    return data.present[name]
+}
+
+// Comprobar que las lecturas numéricas son números finitos
+func (data *SensorData) checkFiniteReadings() error {
+    readings := []struct {
+        name  string
+        value float64
+    }{
+        {"temperature", data.Temperature},
+        {"humidity", data.Humidity},
+        {"battery_level", data.BatteryLevel},
+        {"signal_strength", data.SignalStrength},
+    }
+    for _, reading := range readings {
+        if !isFinite(reading.value) {
+            return fmt.Errorf("%s inválido: %v no es un número finito", reading.name, reading.value)
+        }
+    }
+    return nil
+}
+
+// Si un valor es un número finito (ni NaN ni ±Inf)
+func isFinite(value float64) bool {
+    return !math.IsNaN(value) && !math.IsInf(value, 0)
+}
+
+// Si la lectura trae un valor numérico. Con presencia registrada se usa la
+// presencia, de modo que un 0 real cuenta como valor; las lecturas sin
+// payload (construidas en código) siguen tratando el 0 como ausente.
+func (data *SensorData) hasReading(name string, value float64) bool {
+    // Un NaN o Inf no es una lectura utilizable (la validación ya los rechaza)
+    if !isFinite(value) {
+        return false
+    }
+    if data.present == nil {
+        return value != 0
+    }
+    return data.present[name]
+}
+
+// Comprobar los campos obligatorios del tipo de dispositivo. Solo aplica a
+// lecturas decodificadas de un payload, que son las que registran presencia.
+func validateRequiredFields(data *SensorData) error {
+    required := requiredFields[data.DeviceType]
+    // Una solicitud de auto-cuarentena no trae lecturas
+    if len(required) == 0 || data.present == nil || data.MessageType == MESSAGE_TYPE_SELF_QUARANTINE {
+        return nil
+    }
+
+    var missing []string
+    for _, name := range required {
+        if !data.HasField(name) {
+            missing = append(missing, name)
+        }
+    }
+    if len(missing) > 0 {
+        return fmt.Errorf("faltan campos obligatorios para %s: %s", data.DeviceType, strings.Join(missing, ", "))
+    }
+    return nil
+}
+
+// Validar que los campos configurados existan en SensorData
+func validateRequiredFieldsConfig(config map[string][]string) error {
+    known := make(map[string]bool)
+    dataType := reflect.TypeOf(SensorData{})
+    for i := 0; i < dataType.NumField(); i++ {
+        name, _, _ := strings.Cut(dataType.Field(i).Tag.Get("json"), ",")
+        if name != "" && name != "-" {
+            known[name] = true
+        }
+    }
+
+    for deviceType, fields := range config {
+        for _, name := range fields {
+            if !known[name] {
+                return fmt.Errorf("campo obligatorio desconocido para %s: %q", deviceType, name)
+            }
+        }
+    }
+    return nil
+}
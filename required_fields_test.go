@@ -0,0 +1,113 @@
+package main
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "testing"
+
+    "github.com/fxamacker/cbor/v2"
+)
+
+func TestProcessSensorData_RequiredFields(t *testing.T) {
+    tests := []struct {
+        name           string
+        topic          string
+        encode         func(v interface{}) ([]byte, error)
+        reading        map[string]interface{}
+        wantErr        bool
+        wantQuarantine bool
+    }{
+        {
+            name:    "sensor completo",
+            topic:   "iot/sensors",
+            encode:  json.Marshal,
+            reading: map[string]interface{}{"device_type": "temperature_sensor", "temperature": 21.0, "battery_level": 80.0},
+        },
+        {
+            name:    "temperatura a 0°C",
+            topic:   "iot/sensors",
+            encode:  json.Marshal,
+            reading: map[string]interface{}{"device_type": "temperature_sensor", "temperature": 0.0, "battery_level": 80.0},
+        },
+        {
+            name:           "sensor sin temperatura",
+            topic:          "iot/sensors",
+            encode:         json.Marshal,
+            reading:        map[string]interface{}{"device_type": "temperature_sensor", "humidity": 40.0, "battery_level": 80.0},
+            wantErr:        true,
+            wantQuarantine: true,
+        },
+        {
+            name:           "temperatura null",
+            topic:          "iot/sensors",
+            encode:         json.Marshal,
+            reading:        map[string]interface{}{"device_type": "temperature_sensor", "temperature": nil, "battery_level": 80.0},
+            wantErr:        true,
+            wantQuarantine: true,
+        },
+        {
+            name:           "sensor CBOR sin temperatura",
+            topic:          "iot/sensors" + CBOR_TOPIC_SUFFIX,
+            encode:         cbor.Marshal,
+            reading:        map[string]interface{}{"device_type": "temperature_sensor", "battery_level": 80.0},
+            wantErr:        true,
+            wantQuarantine: true,
+        },
+        {
+            name:    "otro tipo sin temperatura",
+            topic:   "iot/sensors",
+            encode:  json.Marshal,
+            reading: map[string]interface{}{"device_type": "door_sensor", "battery_level": 80.0},
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            setupTestHub(t)
+            t.Setenv("REQUIRED_FIELDS", `{"temperature_sensor": ["temperature", "battery_level"]}`)
+            cfg, err := LoadConfig()
+            if err != nil {
+                t.Fatalf("LoadConfig() error = %v", err)
+            }
+            saved := requiredFields
+            t.Cleanup(func() { requiredFields = saved })
+            requiredFields = cfg.RequiredFields
+
+            tt.reading["device_id"] = "sensor-1"
+            tt.reading["timestamp"] = testEpoch.Unix()
+            payload, err := tt.encode(tt.reading)
+            if err != nil {
+                t.Fatalf("codificando lectura: %v", err)
+            }
+
+            err = ingestPayload(context.Background(), tt.topic, payload)
+            if tt.wantErr != errors.Is(err, ErrInvalidData) {
+                t.Fatalf("error = %v, se esperaba rechazo = %v", err, tt.wantErr)
+            }
+            if got := quarantineSystem.IsQuarantined("sensor-1"); got != tt.wantQuarantine {
+                t.Errorf("en quarantine = %v, se esperaba %v", got, tt.wantQuarantine)
+            }
+        })
+    }
+}
+
+func TestValidateRequiredFieldsConfig(t *testing.T) {
+    tests := []struct {
+        name    string
+        config  map[string][]string
+        wantErr bool
+    }{
+        {name: "vacía", config: map[string][]string{}},
+        {name: "campos conocidos", config: map[string][]string{"temperature_sensor": {"temperature", "battery_level"}}},
+        {name: "campo desconocido", config: map[string][]string{"temperature_sensor": {"temperatura"}}, wantErr: true},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if err := validateRequiredFieldsConfig(tt.config); (err != nil) != tt.wantErr {
+                t.Errorf("error = %v, se esperaba error: %v", err, tt.wantErr)
+            }
+        })
+    }
+}
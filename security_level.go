@@ -0,0 +1,62 @@
+package main
+
+import "fmt"
+
+// Niveles de seguridad que puede declarar un dispositivo
+const (
+    SECURITY_LEVEL_LOW    = "low"
+    SECURITY_LEVEL_MEDIUM = "medium"
+    SECURITY_LEVEL_HIGH   = "high"
+)
+
+// Orden de los niveles de seguridad (0 = desconocido)
+func securityLevelRank(level string) int {
+    switch level {
+    case SECURITY_LEVEL_LOW:
+        return 1
+    case SECURITY_LEVEL_MEDIUM:
+        return 2
+    case SECURITY_LEVEL_HIGH:
+        return 3
+    default:
+        return 0
+    }
+}
+
+// Validar el nivel de seguridad declarado, si viene
+func validateSecurityLevel(level string) error {
+    if level != "" && securityLevelRank(level) == 0 {
+        return fmt.Errorf("security_level inválido: %q (low, medium o high)", level)
+    }
+    return nil
+}
+
+// Un dispositivo que declara seguridad alta no debería mostrar lecturas
+// sospechosas: sus anomalías se elevan un nivel de severidad
+func applySecurityLevel(data *SensorData, anomalies []Anomaly) []Anomaly {
+    if data.SecurityLevel != SECURITY_LEVEL_HIGH {
+        return anomalies
+    }
+    for i := range anomalies {
+        anomalies[i].Severity = escalateSeverity(anomalies[i].Severity)
+    }
+    return anomalies
+}
+
+// Registrar el nivel de seguridad declarado y marcar anomalía si el
+// dispositivo lo rebaja respecto al mensaje anterior (llamar con el lock tomado)
+func detectSecurityLevelDowngradeLocked(data *SensorData, behavior *DeviceBehavior) []Anomaly {
+    if data.SecurityLevel == "" {
+        return nil
+    }
+
+    previous := behavior.SecurityLevel
+    behavior.SecurityLevel = data.SecurityLevel
+    if securityLevelRank(data.SecurityLevel) >= securityLevelRank(previous) {
+        return nil
+    }
+
+    anomaly := newAnomaly(data, AnomalyBehaviorPattern, float64(securityLevelRank(data.SecurityLevel)), "rebaja su nivel de seguridad de %q a %q", previous, data.SecurityLevel)
+    anomaly.Severity = behaviorPatternSeverity(BEHAVIOR_SECURITY_DOWNGRADE, data.DeviceType)
+    return []Anomaly{anomaly}
+}
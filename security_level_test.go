@@ -0,0 +1,78 @@
+package main
+
+import "testing"
+
+func TestValidateSecurityLevel(t *testing.T) {
+    tests := []struct {
+        level   string
+        wantErr bool
+    }{
+        {level: ""},
+        {level: SECURITY_LEVEL_LOW},
+        {level: SECURITY_LEVEL_MEDIUM},
+        {level: SECURITY_LEVEL_HIGH},
+        {level: "maximum", wantErr: true},
+        {level: "HIGH", wantErr: true},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.level, func(t *testing.T) {
+            if err := validateSecurityLevel(tt.level); (err != nil) != tt.wantErr {
+                t.Errorf("error = %v, se esperaba error: %v", err, tt.wantErr)
+            }
+        })
+    }
+}
+
+func TestDetectSecurityLevelDowngrade(t *testing.T) {
+    tests := []struct {
+        name   string
+        levels []string
+        want   int
+    }{
+        {name: "nivel constante", levels: []string{"high", "high"}, want: 0},
+        {name: "sube el nivel", levels: []string{"low", "high"}, want: 0},
+        {name: "rebaja el nivel", levels: []string{"high", "low"}, want: 1},
+        {name: "sin nivel no cuenta como rebaja", levels: []string{"high", "", "high"}, want: 0},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            behavior := &DeviceBehavior{}
+            got := 0
+            for _, level := range tt.levels {
+                data := SensorData{DeviceID: "sensor-1", SecurityLevel: level}
+                for _, anomaly := range detectSecurityLevelDowngradeLocked(&data, behavior) {
+                    if anomaly.Severity != SEVERITY_HIGH {
+                        t.Errorf("severidad = %s, se esperaba high", anomaly.Severity)
+                    }
+                    got++
+                }
+            }
+            if got != tt.want {
+                t.Errorf("rebajas detectadas = %d, se esperaban %d", got, tt.want)
+            }
+        })
+    }
+}
+
+func TestApplySecurityLevel(t *testing.T) {
+    tests := []struct {
+        level string
+        want  string
+    }{
+        {level: SECURITY_LEVEL_HIGH, want: SEVERITY_HIGH},
+        {level: SECURITY_LEVEL_LOW, want: SEVERITY_MEDIUM},
+        {level: "", want: SEVERITY_MEDIUM},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.level, func(t *testing.T) {
+            data := SensorData{DeviceID: "sensor-1", SecurityLevel: tt.level}
+            anomalies := applySecurityLevel(&data, []Anomaly{{Severity: SEVERITY_MEDIUM}})
+            if anomalies[0].Severity != tt.want {
+                t.Errorf("severidad = %s, se esperaba %s", anomalies[0].Severity, tt.want)
+            }
+        })
+    }
+}
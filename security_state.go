@@ -0,0 +1,31 @@
+package main
+
+// Tipos de dispositivo con reglas de estado de seguridad
+const (
+    DEVICE_TYPE_SMART_LOCK = "smart_lock"
+    DEVICE_TYPE_CAMERA     = "camera"
+)
+
+// Detectar estados de seguridad incoherentes en cerraduras y cámaras. Solo
+// se evalúan los tipos correspondientes y los campos que el dispositivo reporta.
+func detectSecurityState(data *SensorData, thresholds AnomalyThresholds) []Anomaly {
+    var anomalies []Anomaly
+
+    switch data.DeviceType {
+    case DEVICE_TYPE_SMART_LOCK:
+        // Cerradura abierta durante un pico de intentos de acceso
+        if data.Locked != nil && !*data.Locked && data.AccessAttempts > thresholds.AccessAttemptsMax {
+            anomaly := newAnomaly(data, AnomalySecurityState, float64(data.AccessAttempts), "cerradura abierta con %d intentos de acceso", data.AccessAttempts)
+            anomaly.Severity = SEVERITY_HIGH
+            anomaly.Confidence = thresholdConfidence(float64(data.AccessAttempts), float64(thresholds.AccessAttemptsMax), float64(thresholds.AccessAttemptsMax))
+            anomalies = append(anomalies, anomaly)
+        }
+    case DEVICE_TYPE_CAMERA:
+        // Cámara que detecta movimiento pero no está grabando
+        if data.MotionDetected != nil && *data.MotionDetected && data.Recording != nil && !*data.Recording {
+            anomalies = append(anomalies, newAnomaly(data, AnomalySecurityState, 0, "cámara sin grabar con movimiento detectado"))
+        }
+    }
+
+    return anomalies
+}
@@ -0,0 +1,37 @@
+package main
+
+import "testing"
+
+func TestDetectSecurityState(t *testing.T) {
+    yes, no := true, false
+
+    tests := []struct {
+        name        string
+        data        SensorData
+        wantAnomaly bool
+    }{
+        {name: "cerradura abierta durante un pico de accesos", data: SensorData{DeviceType: DEVICE_TYPE_SMART_LOCK, Locked: &no, AccessAttempts: 8}, wantAnomaly: true},
+        {name: "cerradura cerrada durante un pico de accesos", data: SensorData{DeviceType: DEVICE_TYPE_SMART_LOCK, Locked: &yes, AccessAttempts: 8}},
+        {name: "cerradura abierta sin pico", data: SensorData{DeviceType: DEVICE_TYPE_SMART_LOCK, Locked: &no, AccessAttempts: 2}},
+        {name: "cerradura sin estado reportado", data: SensorData{DeviceType: DEVICE_TYPE_SMART_LOCK, AccessAttempts: 8}},
+        {name: "sensor de temperatura no se evalúa", data: SensorData{DeviceType: "temperature_sensor", Locked: &no, AccessAttempts: 8}},
+        {name: "cámara sin grabar con movimiento", data: SensorData{DeviceType: DEVICE_TYPE_CAMERA, MotionDetected: &yes, Recording: &no}, wantAnomaly: true},
+        {name: "cámara grabando con movimiento", data: SensorData{DeviceType: DEVICE_TYPE_CAMERA, MotionDetected: &yes, Recording: &yes}},
+        {name: "cámara sin movimiento", data: SensorData{DeviceType: DEVICE_TYPE_CAMERA, MotionDetected: &no, Recording: &no}},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            tt.data.DeviceID = "sensor-1"
+            anomalies := detectSecurityState(&tt.data, DefaultAnomalyThresholds())
+            if got := len(anomalies) > 0; got != tt.wantAnomaly {
+                t.Fatalf("anomalía = %v, se esperaba %v", got, tt.wantAnomaly)
+            }
+            for _, anomaly := range anomalies {
+                if anomaly.Type != AnomalySecurityState {
+                    t.Errorf("tipo = %s, se esperaba %s", anomaly.Type, AnomalySecurityState)
+                }
+            }
+        })
+    }
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+    "crypto/hkdf"
+    "crypto/hmac"
+    "crypto/sha256"
+    "encoding/hex"
+    "fmt"
+    "log"
+)
+
+// Tipo de mensaje con el que un dispositivo solicita su propia cuarentena
+const MESSAGE_TYPE_SELF_QUARANTINE = "self_quarantine"
+
+// Secreto maestro del que se deriva la clave de cada dispositivo para firmar
+// solicitudes de auto-cuarentena (vacío = deshabilitado)
+var selfQuarantineSecret string
+
+// Clave de firma de un dispositivo: HKDF-SHA256 del secreto maestro con
+// info "self_quarantine:<device_id>". Cada dispositivo se aprovisiona solo con
+// su clave, así que uno comprometido no puede firmar en nombre de otro.
+func deriveSelfQuarantineKey(secret, deviceID string) []byte {
+    key, err := hkdf.Key(sha256.New, []byte(secret), nil, MESSAGE_TYPE_SELF_QUARANTINE+":"+deviceID, sha256.Size)
+    if err != nil {
+        // Solo falla si se piden más bytes de los que HKDF-SHA256 puede dar
+        panic(err)
+    }
+    return key
+}
+
+// Firma esperada: HMAC-SHA256 en hex, con la clave del dispositivo, de
+// "device_id:timestamp:message_id:self_quarantine:reason"
+func signSelfQuarantineRequest(data *SensorData, key []byte) string {
+    mac := hmac.New(sha256.New, key)
+    mac.Write([]byte(fmt.Sprintf("%s:%d:%s:%s:%s", data.DeviceID, data.Timestamp, data.MessageID, MESSAGE_TYPE_SELF_QUARANTINE, data.Reason)))
+    return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verificar la autenticidad de una solicitud de auto-cuarentena
+func verifySelfQuarantineRequest(data *SensorData, secret string) bool {
+    if secret == "" || data.Signature == "" {
+        return false
+    }
+
+    expected := signSelfQuarantineRequest(data, deriveSelfQuarantineKey(secret, data.DeviceID))
+    return hmac.Equal([]byte(expected), []byte(data.Signature))
+}
+
+// Comprobar una solicitud de auto-cuarentena ya validada. Se llama antes de
+// la protección contra replay, para que una solicitud falsificada no adelante
+// el último timestamp del dispositivo.
+func checkSelfQuarantineRequest(data *SensorData) error {
+    if selfQuarantineSecret == "" {
+        return fmt.Errorf("auto-cuarentena deshabilitada: SELF_QUARANTINE_SECRET no configurado")
+    }
+
+    // El message_id firmado hace de nonce frente a retransmisiones
+    if data.MessageID == "" {
+        return fmt.Errorf("message_id obligatorio en solicitudes de auto-cuarentena")
+    }
+
+    if !verifySelfQuarantineRequest(data, selfQuarantineSecret) {
+        return fmt.Errorf("firma HMAC inválida")
+    }
+    return nil
+}
+
+// Aplicar una solicitud de auto-cuarentena auténtica: quarantine inmediata,
+// sin esperar a alcanzar ANOMALY_THRESHOLD
+func applySelfQuarantineRequest(data *SensorData) {
+    reason := describeQuarantineReason(QuarantineReasonSelfReported, data.Reason)
+    log.Printf("🚨🚨 ALERTA PRIORIDAD ALTA: Dispositivo %s reporta compromiso propio (%s)", data.DeviceID, reason)
+    quarantineSystem.QuarantineDeviceWithReason(data.DeviceID, QuarantineReasonSelfReported, data.Reason)
+}
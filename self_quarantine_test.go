@@ -0,0 +1,145 @@
+package main
+
+import (
+    "context"
+    "encoding/json"
+    "testing"
+    "time"
+)
+
+const testSelfQuarantineSecret = "secreto-maestro"
+
+func withSelfQuarantineSecret(t *testing.T, secret string) {
+    t.Helper()
+    saved := selfQuarantineSecret
+    t.Cleanup(func() { selfQuarantineSecret = saved })
+    selfQuarantineSecret = secret
+}
+
+func TestProcessSensorData_SelfQuarantine(t *testing.T) {
+    deviceKey := deriveSelfQuarantineKey(testSelfQuarantineSecret, "sensor-1")
+
+    tests := []struct {
+        name           string
+        secret         string
+        prepare        func(data *SensorData)
+        wantErr        bool
+        wantQuarantine bool
+    }{
+        {
+            name:           "firma válida",
+            secret:         testSelfQuarantineSecret,
+            prepare:        func(data *SensorData) { data.Signature = signSelfQuarantineRequest(data, deviceKey) },
+            wantQuarantine: true,
+        },
+        {name: "sin firma", secret: testSelfQuarantineSecret, prepare: func(data *SensorData) {}, wantErr: true},
+        {
+            name:   "firmada con el secreto maestro sin derivar",
+            secret: testSelfQuarantineSecret,
+            prepare: func(data *SensorData) {
+                data.Signature = signSelfQuarantineRequest(data, []byte(testSelfQuarantineSecret))
+            },
+            wantErr: true,
+        },
+        {
+            name:   "firmada con la clave de otro dispositivo",
+            secret: testSelfQuarantineSecret,
+            prepare: func(data *SensorData) {
+                data.Signature = signSelfQuarantineRequest(data, deriveSelfQuarantineKey(testSelfQuarantineSecret, "sensor-2"))
+            },
+            wantErr: true,
+        },
+        {
+            name:   "motivo alterado tras firmar",
+            secret: testSelfQuarantineSecret,
+            prepare: func(data *SensorData) {
+                data.Signature = signSelfQuarantineRequest(data, deviceKey)
+                data.Reason = "falsa_alarma"
+            },
+            wantErr: true,
+        },
+        {
+            name:   "sin message_id",
+            secret: testSelfQuarantineSecret,
+            prepare: func(data *SensorData) {
+                data.MessageID = ""
+                data.Signature = signSelfQuarantineRequest(data, deviceKey)
+            },
+            wantErr: true,
+        },
+        {
+            name:    "auto-cuarentena deshabilitada",
+            prepare: func(data *SensorData) { data.Signature = signSelfQuarantineRequest(data, deviceKey) },
+            wantErr: true,
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            clock := setupTestHub(t)
+            withSelfQuarantineSecret(t, tt.secret)
+
+            data := SensorData{DeviceID: "sensor-1", Timestamp: clock.Now().Unix(), MessageID: "sq-1", MessageType: MESSAGE_TYPE_SELF_QUARANTINE, Reason: "attestation_failed"}
+            tt.prepare(&data)
+
+            err := processSensorData(context.Background(), data, nil, nil)
+            if (err != nil) != tt.wantErr {
+                t.Fatalf("error = %v, se esperaba error: %v", err, tt.wantErr)
+            }
+            entry, quarantined := quarantineSystem.ActiveQuarantine("sensor-1")
+            if quarantined != tt.wantQuarantine {
+                t.Fatalf("en quarantine = %v, se esperaba %v", quarantined, tt.wantQuarantine)
+            }
+            if quarantined && entry.Reason != QuarantineReasonSelfReported {
+                t.Errorf("motivo = %s, se esperaba %s", entry.Reason, QuarantineReasonSelfReported)
+            }
+        })
+    }
+}
+
+func TestProcessSensorData_SelfQuarantineReplay(t *testing.T) {
+    clock := setupTestHub(t)
+    withSelfQuarantineSecret(t, testSelfQuarantineSecret)
+
+    data := SensorData{DeviceID: "sensor-1", Timestamp: clock.Now().Unix(), MessageID: "sq-1", MessageType: MESSAGE_TYPE_SELF_QUARANTINE, Reason: "attestation_failed"}
+    data.Signature = signSelfQuarantineRequest(&data, deriveSelfQuarantineKey(testSelfQuarantineSecret, "sensor-1"))
+    payload, err := json.Marshal(data)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    if err := ingestPayload(context.Background(), "test", payload); err != nil {
+        t.Fatalf("solicitud original rechazada: %v", err)
+    }
+    release := func() {
+        t.Helper()
+        if !quarantineSystem.ReleaseFromQuarantine("sensor-1", ReleaseReasonManual, "operador") {
+            t.Fatal("sensor-1 no estaba en quarantine")
+        }
+    }
+    release()
+
+    // Reenvío capturado, pasado el deduplicador de payloads: lo para el message_id
+    clock.Advance(payloadDedupWindow + time.Second)
+    if err := ingestPayload(context.Background(), "test", payload); err != nil {
+        t.Fatalf("retransmisión con el mismo message_id: error = %v", err)
+    }
+    if quarantineSystem.IsQuarantined("sensor-1") {
+        t.Fatal("la retransmisión volvió a poner sensor-1 en quarantine")
+    }
+
+    // Con el deduplicador de message_id vacío, el mismo JSON reserializado lo
+    // para la protección contra replay
+    messageDeduplicator = NewMessageDeduplicator(&messageDedupWindow)
+    messageDeduplicator.SetClock(clock)
+    reserialized, err := json.MarshalIndent(data, "", "  ")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := ingestPayload(context.Background(), "test", reserialized); err == nil {
+        t.Fatal("se esperaba rechazo por replay")
+    }
+    if quarantineSystem.IsQuarantined("sensor-1") {
+        t.Fatal("el replay volvió a poner sensor-1 en quarantine")
+    }
+}
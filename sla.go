@@ -0,0 +1,150 @@
+package main
+
+import (
+    "fmt"
+    "math"
+    "sort"
+    "time"
+)
+
+// Objetivos de tiempo por severidad para reconocer y resolver anomalías
+type SLATargets struct {
+    Acknowledge map[string]time.Duration
+    Resolve     map[string]time.Duration
+}
+
+// Objetivos por defecto
+func DefaultSLATargets() SLATargets {
+    return SLATargets{
+        Acknowledge: map[string]time.Duration{
+            SEVERITY_HIGH:   5 * time.Minute,
+            SEVERITY_MEDIUM: 30 * time.Minute,
+            SEVERITY_LOW:    4 * time.Hour,
+        },
+        Resolve: map[string]time.Duration{
+            SEVERITY_HIGH:   1 * time.Hour,
+            SEVERITY_MEDIUM: 8 * time.Hour,
+            SEVERITY_LOW:    72 * time.Hour,
+        },
+    }
+}
+
+var slaTargets = DefaultSLATargets()
+
+// Cumplimiento de un objetivo (reconocer o resolver) para una severidad
+type SLAObjective struct {
+    Target        string  `json:"target"`
+    Completed     int     `json:"completed"`
+    WithinTarget  int     `json:"within_target"`
+    Breached      int     `json:"breached"`
+    Pending       int     `json:"pending"`
+    CompliancePct float64 `json:"compliance_pct"`
+    AvgSeconds    float64 `json:"avg_seconds"`
+    P95Seconds    float64 `json:"p95_seconds"`
+}
+
+// Informe de SLA de una severidad
+type SLASeverityReport struct {
+    Total       int          `json:"total"`
+    Acknowledge SLAObjective `json:"acknowledge"`
+    Resolve     SLAObjective `json:"resolve"`
+}
+
+// Convertir {"high": "5m", ...} en duraciones
+func parseSLATargets(raw map[string]string, defaults map[string]time.Duration) (map[string]time.Duration, error) {
+    targets := make(map[string]time.Duration, len(defaults))
+    for severity, target := range defaults {
+        targets[severity] = target
+    }
+    for severity, value := range raw {
+        target, err := time.ParseDuration(value)
+        if err != nil {
+            return nil, fmt.Errorf("objetivo SLA inválido para %s: %w", severity, err)
+        }
+        targets[severity] = target
+    }
+    return targets, nil
+}
+
+// Calcular el cumplimiento de SLA por severidad a partir de las transiciones
+// de estado de las anomalías. Las pendientes que ya superan el objetivo
+// cuentan como incumplidas.
+func computeSLAReport(anomalies []Anomaly, targets SLATargets, now time.Time) map[string]SLASeverityReport {
+    type samples struct {
+        total       int
+        acknowledge []time.Duration
+        resolve     []time.Duration
+        ackPending  []time.Duration
+        resPending  []time.Duration
+    }
+
+    bySeverity := make(map[string]*samples)
+    for _, anomaly := range anomalies {
+        s := bySeverity[anomaly.Severity]
+        if s == nil {
+            s = &samples{}
+            bySeverity[anomaly.Severity] = s
+        }
+        s.total++
+
+        if anomaly.AcknowledgedAt != nil {
+            s.acknowledge = append(s.acknowledge, anomaly.AcknowledgedAt.Sub(anomaly.Timestamp))
+        } else {
+            s.ackPending = append(s.ackPending, now.Sub(anomaly.Timestamp))
+        }
+        if anomaly.ResolvedAt != nil {
+            s.resolve = append(s.resolve, anomaly.ResolvedAt.Sub(anomaly.Timestamp))
+        } else {
+            s.resPending = append(s.resPending, now.Sub(anomaly.Timestamp))
+        }
+    }
+
+    report := make(map[string]SLASeverityReport, len(bySeverity))
+    for severity, s := range bySeverity {
+        report[severity] = SLASeverityReport{
+            Total:       s.total,
+            Acknowledge: slaObjective(s.acknowledge, s.ackPending, targets.Acknowledge[severity]),
+            Resolve:     slaObjective(s.resolve, s.resPending, targets.Resolve[severity]),
+        }
+    }
+    return report
+}
+
+// Estadísticas de un objetivo dados los tiempos completados y la edad de los pendientes
+func slaObjective(completed []time.Duration, pending []time.Duration, target time.Duration) SLAObjective {
+    objective := SLAObjective{
+        Target:    target.String(),
+        Completed: len(completed),
+        Pending:   len(pending),
+    }
+
+    var total time.Duration
+    for _, latency := range completed {
+        total += latency
+        if target <= 0 || latency <= target {
+            objective.WithinTarget++
+        } else {
+            objective.Breached++
+        }
+    }
+    for _, age := range pending {
+        if target > 0 && age > target {
+            objective.Breached++
+        }
+    }
+
+    if evaluated := objective.WithinTarget + objective.Breached; evaluated > 0 {
+        objective.CompliancePct = math.Round(float64(objective.WithinTarget)/float64(evaluated)*1000) / 10
+    }
+    if len(completed) > 0 {
+        objective.AvgSeconds = math.Round(total.Seconds()/float64(len(completed))*10) / 10
+
+        sorted := make([]time.Duration, len(completed))
+        copy(sorted, completed)
+        sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+        index := int(math.Ceil(0.95*float64(len(sorted)))) - 1
+        objective.P95Seconds = math.Round(sorted[index].Seconds()*10) / 10
+    }
+
+    return objective
+}
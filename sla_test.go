@@ -0,0 +1,101 @@
+package main
+
+import (
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+    "time"
+)
+
+func TestHandleAnomalySLA(t *testing.T) {
+    tests := []struct {
+        name           string
+        ackLatencies   []time.Duration
+        pendingAge     time.Duration
+        wantWithin     int
+        wantBreached   int
+        wantPending    int
+        wantCompliance float64
+        wantAvg        float64
+        wantP95        float64
+    }{
+        {
+            name:           "todas dentro del objetivo",
+            ackLatencies:   []time.Duration{time.Minute, 2 * time.Minute},
+            wantWithin:     2,
+            wantCompliance: 100,
+            wantAvg:        90,
+            wantP95:        120,
+        },
+        {
+            name:           "una incumplida",
+            ackLatencies:   []time.Duration{time.Minute, 3 * time.Minute, 10 * time.Minute},
+            wantWithin:     2,
+            wantBreached:   1,
+            wantCompliance: 66.7,
+            wantAvg:        280,
+            wantP95:        600,
+        },
+        {
+            name:           "pendiente fuera de plazo",
+            ackLatencies:   []time.Duration{time.Minute},
+            pendingAge:     6 * time.Minute,
+            wantWithin:     1,
+            wantBreached:   1,
+            wantPending:    1,
+            wantCompliance: 50,
+            wantAvg:        60,
+            wantP95:        60,
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            clock := setupTestHub(t)
+
+            // Anomalías altas reconocidas con latencias conocidas (objetivo: 5m)
+            save := func() string {
+                anomalies := []Anomaly{{DeviceID: "sensor-1", Type: AnomalyTemperature, Severity: SEVERITY_HIGH, Timestamp: clock.Now()}}
+                anomalyRepository.Save(anomalies)
+                return anomalies[0].ID
+            }
+            for _, latency := range tt.ackLatencies {
+                id := save()
+                clock.Advance(latency)
+                if _, err := anomalyRepository.Acknowledge(id); err != nil {
+                    t.Fatalf("Acknowledge: %v", err)
+                }
+            }
+            if tt.pendingAge > 0 {
+                save()
+                clock.Advance(tt.pendingAge)
+            }
+
+            recorder := httptest.NewRecorder()
+            newAPIRouter().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/anomalies/sla", nil))
+            if recorder.Code != http.StatusOK {
+                t.Fatalf("estado = %d, se esperaba %d", recorder.Code, http.StatusOK)
+            }
+            var report map[string]SLASeverityReport
+            if err := json.Unmarshal(recorder.Body.Bytes(), &report); err != nil {
+                t.Fatalf("respuesta no válida: %v", err)
+            }
+
+            got := report[SEVERITY_HIGH].Acknowledge
+            want := SLAObjective{
+                Target:        "5m0s",
+                Completed:     len(tt.ackLatencies),
+                WithinTarget:  tt.wantWithin,
+                Breached:      tt.wantBreached,
+                Pending:       tt.wantPending,
+                CompliancePct: tt.wantCompliance,
+                AvgSeconds:    tt.wantAvg,
+                P95Seconds:    tt.wantP95,
+            }
+            if got != want {
+                t.Errorf("reconocimiento = %+v, se esperaba %+v", got, want)
+            }
+        })
+    }
+}
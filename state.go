@@ -0,0 +1,157 @@
+package main
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "log"
+    "os"
+    "os/signal"
+    "path/filepath"
+    "syscall"
+    "time"
+)
+
+// Fichero del snapshot de estado (vacío = deshabilitado)
+var stateSnapshotFile string
+
+// Snapshot del estado en memoria del hub, para reiniciar sin perder líneas
+// base, quarantines, rate limits ni alertas retenidas por horas de silencio.
+// Los envíos en curso no se guardan: se esperan antes del snapshot y, si no
+// terminan a tiempo, se pierden.
+type HubState struct {
+    SavedAt         time.Time                   `json:"saved_at"`
+    Devices         map[string]*DeviceBehavior  `json:"devices"`
+    Quarantines     map[string]*QuarantineEntry `json:"quarantines"`
+    UpdatingDevices map[string]time.Time        `json:"updating_devices"`
+    RateLimits      map[string]TokenBucketState `json:"rate_limits"`
+    QuietHours      *QuietHoursBacklog          `json:"quiet_hours,omitempty"`
+    // Canales fijados por dispositivo vía API
+    NotificationRouting map[string][]string `json:"notification_routing,omitempty"`
+}
+
+// Serializar el estado del sistema de quarantine y las alertas retenidas
+func (qs *QuarantineSystem) MarshalState() ([]byte, error) {
+    rateLimits := qs.rateLimiter.Snapshot()
+    quietHours := notificationManager.QuietHoursBacklog()
+
+    qs.mutex.RLock()
+    defer qs.mutex.RUnlock()
+
+    return json.Marshal(HubState{
+        SavedAt:             time.Now(),
+        Devices:             qs.deviceBehavior,
+        Quarantines:         qs.quarantinedDevices,
+        UpdatingDevices:     qs.updatingDevices,
+        RateLimits:          rateLimits,
+        QuietHours:          quietHours,
+        NotificationRouting: qs.notificationRouting,
+    })
+}
+
+// Restaurar el estado desde un snapshot (sustituye el estado actual)
+func (qs *QuarantineSystem) RestoreState(raw []byte) (*HubState, error) {
+    var state HubState
+    if err := json.Unmarshal(raw, &state); err != nil {
+        return nil, fmt.Errorf("snapshot de estado inválido: %w", err)
+    }
+
+    qs.mutex.Lock()
+    if state.Devices != nil {
+        qs.deviceBehavior = state.Devices
+        qs.deviceRecency.rebuild(state.Devices)
+    }
+    if state.Quarantines != nil {
+        qs.quarantinedDevices = state.Quarantines
+    }
+    if state.UpdatingDevices != nil {
+        qs.updatingDevices = state.UpdatingDevices
+    }
+    if state.NotificationRouting != nil {
+        qs.notificationRouting = state.NotificationRouting
+    }
+    qs.mutex.Unlock()
+
+    qs.rateLimiter.Restore(state.RateLimits)
+    notificationManager.RestoreQuietHoursBacklog(state.QuietHours)
+    return &state, nil
+}
+
+// Guardar el estado en un fichero (escritura atómica vía fichero temporal)
+func saveStateSnapshot(path string, qs *QuarantineSystem) error {
+    raw, err := qs.MarshalState()
+    if err != nil {
+        return err
+    }
+
+    tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+    if err != nil {
+        return err
+    }
+    if _, err := tmp.Write(raw); err != nil {
+        tmp.Close()
+        os.Remove(tmp.Name())
+        return err
+    }
+    if err := tmp.Close(); err != nil {
+        os.Remove(tmp.Name())
+        return err
+    }
+    return os.Rename(tmp.Name(), path)
+}
+
+// Cargar el estado desde un fichero; si no existe se arranca en limpio
+func loadStateSnapshot(path string, qs *QuarantineSystem) (*HubState, error) {
+    raw, err := os.ReadFile(path)
+    if errors.Is(err, os.ErrNotExist) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    return qs.RestoreState(raw)
+}
+
+// Guardar el snapshot al recibir SIGUSR1 hasta que se cancele el contexto.
+// Antes de guardar se esperan los envíos en curso, que no forman parte del
+// snapshot. El canal devuelto se cierra cuando la goroutine termina.
+func startStateSnapshotOnSignal(ctx context.Context, qs *QuarantineSystem) <-chan struct{} {
+    done := make(chan struct{})
+    signals := make(chan os.Signal, 1)
+    signal.Notify(signals, syscall.SIGUSR1)
+
+    go func() {
+        defer close(done)
+        defer signal.Stop(signals)
+
+        for {
+            select {
+            case <-ctx.Done():
+                return
+            case <-signals:
+                if stateSnapshotFile == "" {
+                    log.Printf("⚠️ SIGUSR1 recibido pero STATE_SNAPSHOT_FILE no está configurado")
+                    continue
+                }
+
+                flushCtx, cancel := context.WithTimeout(ctx, NOTIFICATION_TIMEOUT)
+                if err := notificationManager.Flush(flushCtx); err != nil {
+                    log.Printf("⚠️ Notificaciones pendientes al guardar el estado: %v", err)
+                }
+                if err := anomalyExporter.Flush(flushCtx); err != nil {
+                    log.Printf("⚠️ Exportaciones pendientes al guardar el estado: %v", err)
+                }
+                cancel()
+
+                if err := saveStateSnapshot(stateSnapshotFile, qs); err != nil {
+                    log.Printf("❌ Error guardando snapshot de estado: %v", err)
+                    continue
+                }
+                log.Printf("💾 Estado guardado en %s", stateSnapshotFile)
+            }
+        }
+    }()
+
+    return done
+}
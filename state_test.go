@@ -0,0 +1,75 @@
+package main
+
+import (
+    "path/filepath"
+    "testing"
+    "time"
+)
+
+func TestStateSnapshot_RoundTrip(t *testing.T) {
+    tests := []struct {
+        name       string
+        quarantine bool
+        heldAlerts int
+    }{
+        {name: "estado vacío"},
+        {name: "dispositivo en cuarentena", quarantine: true},
+        {name: "alertas retenidas por horas de silencio", heldAlerts: 3},
+        {name: "cuarentena y alertas retenidas", quarantine: true, heldAlerts: 2},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            clock := setupTestHub(t)
+            quietHours, err := loadQuietHours("22:00", "07:00", "UTC")
+            if err != nil {
+                t.Fatal(err)
+            }
+            notificationManager.SetQuietHours(quietHours)
+            clock.Set(time.Date(2026, 3, 2, 23, 0, 0, 0, time.UTC))
+
+            if tt.quarantine {
+                quarantineSystem.QuarantineDevice("sensor-1", "prueba")
+            }
+            for i := 0; i < tt.heldAlerts; i++ {
+                notificationManager.SendAnomalyAlert(Anomaly{DeviceID: "sensor-2", Type: AnomalyBattery, Severity: SEVERITY_LOW})
+            }
+
+            path := filepath.Join(t.TempDir(), "state.json")
+            if err := saveStateSnapshot(path, quarantineSystem); err != nil {
+                t.Fatalf("saveStateSnapshot() error = %v", err)
+            }
+
+            // Reinicio: hub limpio con la misma ventana de silencio
+            clock = setupTestHub(t)
+            notificationManager.SetQuietHours(quietHours)
+            clock.Set(time.Date(2026, 3, 2, 23, 0, 30, 0, time.UTC))
+            state, err := loadStateSnapshot(path, quarantineSystem)
+            if err != nil {
+                t.Fatalf("loadStateSnapshot() error = %v", err)
+            }
+            if state == nil {
+                t.Fatal("loadStateSnapshot() no devolvió estado")
+            }
+
+            if got := quarantineSystem.IsQuarantined("sensor-1"); got != tt.quarantine {
+                t.Errorf("sensor-1 en cuarentena = %v, se esperaba %v", got, tt.quarantine)
+            }
+
+            // Al terminar la ventana se resumen las alertas restauradas
+            clock.Set(time.Date(2026, 3, 3, 8, 0, 0, 0, time.UTC))
+            if got := notificationManager.ReleaseQuietHours(); got != tt.heldAlerts {
+                t.Errorf("alertas resumidas = %d, se esperaban %d", got, tt.heldAlerts)
+            }
+        })
+    }
+}
+
+func TestLoadStateSnapshot_MissingFile(t *testing.T) {
+    setupTestHub(t)
+
+    state, err := loadStateSnapshot(filepath.Join(t.TempDir(), "no-existe.json"), quarantineSystem)
+    if err != nil || state != nil {
+        t.Errorf("loadStateSnapshot() = %v, %v; se esperaba arranque en limpio", state, err)
+    }
+}
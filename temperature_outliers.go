@@ -0,0 +1,92 @@
+package main
+
+import "math"
+
+// Desviaciones típicas respecto a la media del dispositivo a partir de las
+// que una temperatura es atípica (0 = solo la regla fija de ±20°C)
+var temperatureOutlierSigma = 3.0
+
+// Lecturas necesarias antes de aplicar la regla de desviaciones típicas;
+// hasta entonces se usa la regla fija
+var temperatureOutlierMinSamples = 10
+
+// Desviación típica mínima considerada: un sensor muy estable no debe
+// disparar alertas por décimas de grado
+const TEMPERATURE_OUTLIER_MIN_STDDEV = 0.5
+
+// Lecturas que pesan en la línea base de temperatura: la media y la varianza
+// son exponenciales con el peso de una media de este número de lecturas, de
+// modo que el dispositivo se compara con su comportamiento reciente y no con
+// todo su historial (un sensor que pasa del invierno al verano no queda
+// marcado para siempre)
+const TEMPERATURE_BASELINE_SAMPLES = 50
+
+// Lecturas atípicas seguidas a partir de las que se asume un cambio de nivel
+// real y se incorporan a la línea base
+const TEMPERATURE_OUTLIER_REBASELINE = 5
+
+// Media y varianza exponenciales (EWMA) de las lecturas. Mientras no hay
+// TEMPERATURE_BASELINE_SAMPLES lecturas el peso es 1/n, es decir, la media y
+// la varianza de todas las lecturas vistas.
+type RunningStats struct {
+    Count    int
+    Mean     float64
+    Variance float64
+    // Lecturas atípicas seguidas excluidas de la línea base
+    Outliers int
+}
+
+// Incorporar un valor
+func (rs *RunningStats) Add(value float64) {
+    if !isFinite(value) {
+        return
+    }
+    rs.Count++
+    rs.Outliers = 0
+    alpha := math.Max(1/float64(rs.Count), 2/float64(TEMPERATURE_BASELINE_SAMPLES+1))
+    delta := value - rs.Mean
+    rs.Mean += alpha * delta
+    rs.Variance = (1 - alpha) * (rs.Variance + alpha*delta*delta)
+}
+
+// Incorporar un valor salvo que sea atípico: las lecturas atípicas no mueven
+// la línea base con la que se detectan, salvo que se repitan
+// TEMPERATURE_OUTLIER_REBASELINE veces seguidas
+func (rs *RunningStats) Observe(value float64, outlier bool) {
+    if outlier {
+        rs.Outliers++
+        if rs.Outliers < TEMPERATURE_OUTLIER_REBASELINE {
+            return
+        }
+    }
+    rs.Add(value)
+}
+
+// Desviación típica (0 con menos de dos valores)
+func (rs *RunningStats) StdDev() float64 {
+    if rs.Count < 2 {
+        return 0
+    }
+    return math.Sqrt(rs.Variance)
+}
+
+// Si la regla de desviaciones típicas ya aplica a estas estadísticas
+func temperatureOutlierReady(stats *RunningStats) bool {
+    return temperatureOutlierSigma > 0 && stats.Count >= temperatureOutlierMinSamples
+}
+
+// Temperatura a más de temperatureOutlierSigma desviaciones típicas de la
+// media reciente del dispositivo (se evalúa antes de incorporar la lectura)
+func detectTemperatureOutlier(data *SensorData, stats *RunningStats) *Anomaly {
+    stdDev := math.Max(stats.StdDev(), TEMPERATURE_OUTLIER_MIN_STDDEV)
+    z := math.Abs(data.Temperature-stats.Mean) / stdDev
+    if z <= temperatureOutlierSigma {
+        return nil
+    }
+
+    anomaly := newAnomaly(data, AnomalyBehaviorPattern, data.Temperature, "temperatura atípica: %.1f°C a %.1fσ del promedio (%.1f°C ± %.1f°C)", data.Temperature, z, stats.Mean, stdDev)
+    anomaly.Metric = METRIC_TEMPERATURE
+    anomaly.Severity = behaviorPatternSeverity(BEHAVIOR_TEMPERATURE_CHANGE, data.DeviceType)
+    anomaly.Confidence = thresholdConfidence(z, temperatureOutlierSigma, temperatureOutlierSigma)
+    return &anomaly
+}
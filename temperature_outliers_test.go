@@ -0,0 +1,122 @@
+package main
+
+import (
+    "math"
+    "strings"
+    "testing"
+)
+
+// Alimentar la línea base con lecturas que alternan entre low y high
+func stableStats(low, high float64, samples int) RunningStats {
+    var stats RunningStats
+    for i := 0; i < samples; i++ {
+        value := low
+        if i%2 == 1 {
+            value = high
+        }
+        stats.Add(value)
+    }
+    return stats
+}
+
+func TestDetectTemperatureOutlier(t *testing.T) {
+    tests := []struct {
+        name        string
+        stats       RunningStats
+        temperature float64
+        want        bool
+    }{
+        {name: "lectura habitual", stats: stableStats(20, 22, 20), temperature: 21.5},
+        {name: "salto por encima de 3σ", stats: stableStats(20, 22, 20), temperature: 30, want: true},
+        {name: "caída por debajo de 3σ", stats: stableStats(20, 22, 20), temperature: 12, want: true},
+        {name: "sensor muy estable y décimas de grado", stats: stableStats(20, 20, 20), temperature: 20.4},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            data := SensorData{DeviceID: "sensor-1", Temperature: tt.temperature}
+            if got := detectTemperatureOutlier(&data, &tt.stats) != nil; got != tt.want {
+                t.Errorf("atípica = %v, se esperaba %v", got, tt.want)
+            }
+        })
+    }
+}
+
+func TestRunningStats_Observe(t *testing.T) {
+    tests := []struct {
+        name     string
+        outliers int
+        wantMean float64
+    }{
+        {name: "una atípica no mueve la línea base", outliers: 1, wantMean: 21},
+        {name: "atípicas por debajo del cambio de nivel", outliers: TEMPERATURE_OUTLIER_REBASELINE - 1, wantMean: 21},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            stats := stableStats(20, 22, 20)
+            mean := stats.Mean
+            for i := 0; i < tt.outliers; i++ {
+                stats.Observe(40, true)
+            }
+            if math.Abs(stats.Mean-mean) > 1e-9 {
+                t.Errorf("media = %.3f, se esperaba sin cambios %.3f", stats.Mean, mean)
+            }
+        })
+    }
+
+    // Un cambio de nivel sostenido acaba incorporándose
+    stats := stableStats(20, 22, 20)
+    for i := 0; i < TEMPERATURE_OUTLIER_REBASELINE; i++ {
+        stats.Observe(40, true)
+    }
+    if stats.Mean <= 21 {
+        t.Errorf("media = %.3f, se esperaba que incorporase el cambio de nivel", stats.Mean)
+    }
+}
+
+func TestRunningStats_FollowsRecentReadings(t *testing.T) {
+    var stats RunningStats
+    for i := 0; i < 1000; i++ {
+        stats.Add(5)
+    }
+    for i := 0; i < 3*TEMPERATURE_BASELINE_SAMPLES; i++ {
+        stats.Add(25)
+    }
+
+    // Con la media de todo el historial seguiría cerca de 5 °C
+    if stats.Mean < 24 {
+        t.Errorf("media = %.2f, se esperaba cercana a las lecturas recientes (25)", stats.Mean)
+    }
+    data := SensorData{DeviceID: "sensor-1", Temperature: 25}
+    if detectTemperatureOutlier(&data, &stats) != nil {
+        t.Error("una lectura al nivel reciente no debe ser atípica")
+    }
+}
+
+func TestAnalyzeDeviceBehavior_TemperatureOutlier(t *testing.T) {
+    setupTestHub(t)
+
+    outliers := func(anomalies []Anomaly) int {
+        count := 0
+        for _, anomaly := range anomalies {
+            if strings.HasPrefix(anomaly.Description, "temperatura atípica") {
+                count++
+            }
+        }
+        return count
+    }
+
+    for i := 0; i < 20; i++ {
+        data := SensorData{DeviceID: "sensor-1", Temperature: 20 + float64(i%2), Humidity: 40, BatteryLevel: 80}
+        if got := outliers(quarantineSystem.AnalyzeDeviceBehavior(&data)); got != 0 {
+            t.Fatalf("lectura estable %d marcada como atípica", i)
+        }
+    }
+
+    // El dispositivo estable salta a más de 3σ
+    data := SensorData{DeviceID: "sensor-1", Temperature: 30, Humidity: 40, BatteryLevel: 80}
+    if got := outliers(quarantineSystem.AnalyzeDeviceBehavior(&data)); got != 1 {
+        t.Errorf("atípicas = %d, se esperaba 1", got)
+    }
+}
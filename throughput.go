@@ -0,0 +1,111 @@
+package main
+
+import (
+    "log"
+    "sync"
+    "time"
+)
+
+// Ventana sobre la que se calculan las tasas de ingesta y procesamiento
+const THROUGHPUT_WINDOW = 1 * time.Minute
+
+// Nivel de cola a partir del cual se avisa de backpressure (0 = deshabilitado)
+var backpressureHighWaterMark = 100
+
+// Estadísticas de procesamiento expuestas en /stats
+type ProcessingStats struct {
+    IngestionRate  float64 `json:"ingestion_rate"`
+    ProcessingRate float64 `json:"processing_rate"`
+    QueueDepth     int     `json:"queue_depth"`
+    HighWaterMark  int     `json:"high_water_mark"`
+    Backpressure   bool    `json:"backpressure"`
+}
+
+// Seguimiento de mensajes recibidos, terminados y pendientes de procesar
+type ThroughputTracker struct {
+    mutex        sync.Mutex
+    received     []time.Time
+    processed    []time.Time
+    queueDepth   int
+    backpressure bool
+    clock        Clock
+}
+
+var throughput = NewThroughputTracker()
+
+// Crear tracker de throughput
+func NewThroughputTracker() *ThroughputTracker {
+    return &ThroughputTracker{
+        received:  make([]time.Time, 0),
+        processed: make([]time.Time, 0),
+        clock:     RealClock{},
+    }
+}
+
+// Cambiar el reloj con el que se miden las tasas
+func (t *ThroughputTracker) SetClock(clock Clock) {
+    t.mutex.Lock()
+    defer t.mutex.Unlock()
+
+    t.clock = clock
+}
+
+// Registrar la llegada de un mensaje (entra en la cola)
+func (t *ThroughputTracker) MessageReceived() {
+    t.mutex.Lock()
+    defer t.mutex.Unlock()
+
+    now := t.clock.Now()
+    t.received = append(pruneBefore(t.received, now.Add(-THROUGHPUT_WINDOW)), now)
+    t.queueDepth++
+
+    // Avisar una sola vez al superar el high-water mark
+    if backpressureHighWaterMark > 0 && t.queueDepth > backpressureHighWaterMark && !t.backpressure {
+        t.backpressure = true
+        log.Printf("⚠️ BACKPRESSURE: %d mensajes en cola (high-water mark %d)", t.queueDepth, backpressureHighWaterMark)
+    }
+}
+
+// Registrar que un mensaje terminó de procesarse (sale de la cola)
+func (t *ThroughputTracker) MessageDone() {
+    t.mutex.Lock()
+    defer t.mutex.Unlock()
+
+    now := t.clock.Now()
+    t.processed = append(pruneBefore(t.processed, now.Add(-THROUGHPUT_WINDOW)), now)
+    if t.queueDepth > 0 {
+        t.queueDepth--
+    }
+
+    if t.backpressure && t.queueDepth <= backpressureHighWaterMark {
+        t.backpressure = false
+        log.Printf("✅ Backpressure resuelto: %d mensajes en cola", t.queueDepth)
+    }
+}
+
+// Instantánea de las tasas (mensajes por segundo) y la profundidad de la cola
+func (t *ThroughputTracker) Stats() ProcessingStats {
+    t.mutex.Lock()
+    defer t.mutex.Unlock()
+
+    cutoff := t.clock.Now().Add(-THROUGHPUT_WINDOW)
+    t.received = pruneBefore(t.received, cutoff)
+    t.processed = pruneBefore(t.processed, cutoff)
+
+    return ProcessingStats{
+        IngestionRate:  float64(len(t.received)) / THROUGHPUT_WINDOW.Seconds(),
+        ProcessingRate: float64(len(t.processed)) / THROUGHPUT_WINDOW.Seconds(),
+        QueueDepth:     t.queueDepth,
+        HighWaterMark:  backpressureHighWaterMark,
+        Backpressure:   t.backpressure,
+    }
+}
+
+// Descartar marcas de tiempo anteriores al corte (están ordenadas)
+func pruneBefore(times []time.Time, cutoff time.Time) []time.Time {
+    i := 0
+    for i < len(times) && times[i].Before(cutoff) {
+        i++
+    }
+    return times[i:]
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+    "bytes"
+    "encoding/json"
+    "io"
+    "log"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "time"
+)
+
+// Capturar el log estándar durante el test
+func captureLog(t *testing.T) *bytes.Buffer {
+    t.Helper()
+
+    var buffer bytes.Buffer
+    log.SetOutput(&buffer)
+    t.Cleanup(func() { log.SetOutput(io.Discard) })
+    return &buffer
+}
+
+func TestThroughputTracker_Backpressure(t *testing.T) {
+    tests := []struct {
+        name             string
+        highWaterMark    int
+        queued           int
+        wantWarnings     int
+        wantBackpressure bool
+    }{
+        {name: "por debajo del umbral", highWaterMark: 5, queued: 5},
+        {name: "por encima del umbral", highWaterMark: 5, queued: 6, wantWarnings: 1, wantBackpressure: true},
+        {name: "muy por encima avisa una vez", highWaterMark: 5, queued: 20, wantWarnings: 1, wantBackpressure: true},
+        {name: "deshabilitado", highWaterMark: 0, queued: 20},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            setupTestHub(t)
+            saved := backpressureHighWaterMark
+            t.Cleanup(func() { backpressureHighWaterMark = saved })
+            backpressureHighWaterMark = tt.highWaterMark
+            output := captureLog(t)
+
+            // Mensajes recibidos que los workers aún no han terminado
+            for i := 0; i < tt.queued; i++ {
+                throughput.MessageReceived()
+            }
+
+            if got := strings.Count(output.String(), "BACKPRESSURE"); got != tt.wantWarnings {
+                t.Errorf("avisos de backpressure = %d, se esperaban %d", got, tt.wantWarnings)
+            }
+            stats := throughput.Stats()
+            if stats.QueueDepth != tt.queued || stats.Backpressure != tt.wantBackpressure {
+                t.Errorf("cola = %d con backpressure %v, se esperaba %d con %v", stats.QueueDepth, stats.Backpressure, tt.queued, tt.wantBackpressure)
+            }
+
+            // Vaciar la cola resuelve la situación
+            for i := 0; i < tt.queued; i++ {
+                throughput.MessageDone()
+            }
+            if stats := throughput.Stats(); stats.QueueDepth != 0 || stats.Backpressure {
+                t.Errorf("tras vaciar la cola: %d en cola con backpressure %v", stats.QueueDepth, stats.Backpressure)
+            }
+        })
+    }
+}
+
+func TestHandleStats_Rates(t *testing.T) {
+    tests := []struct {
+        name          string
+        advance       time.Duration
+        wantIngestion float64
+        wantQueue     int
+    }{
+        {name: "dentro de la ventana", advance: 30 * time.Second, wantIngestion: 3.0 / 60, wantQueue: 1},
+        {name: "fuera de la ventana", advance: THROUGHPUT_WINDOW + time.Second, wantQueue: 1},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            clock := setupTestHub(t)
+            for i := 0; i < 3; i++ {
+                throughput.MessageReceived()
+            }
+            throughput.MessageDone()
+            throughput.MessageDone()
+            clock.Advance(tt.advance)
+
+            recorder := httptest.NewRecorder()
+            newAPIRouter().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/stats", nil))
+            if recorder.Code != http.StatusOK {
+                t.Fatalf("estado = %d, se esperaba %d", recorder.Code, http.StatusOK)
+            }
+            var stats ProcessingStats
+            if err := json.Unmarshal(recorder.Body.Bytes(), &stats); err != nil {
+                t.Fatalf("respuesta no válida: %v", err)
+            }
+            if stats.IngestionRate != tt.wantIngestion || stats.QueueDepth != tt.wantQueue {
+                t.Errorf("ingesta = %v/s con %d en cola, se esperaba %v/s con %d", stats.IngestionRate, stats.QueueDepth, tt.wantIngestion, tt.wantQueue)
+            }
+        })
+    }
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+    "net/http"
+    "sort"
+    "time"
+)
+
+// Tipos de evento de la línea de tiempo de un dispositivo
+const (
+    TIMELINE_EVENT_ANOMALY    = "anomaly"
+    TIMELINE_EVENT_QUARANTINE = "quarantine"
+    TIMELINE_EVENT_RELEASE    = "release"
+)
+
+// Evento de la línea de tiempo: anomalía, entrada en quarantine o liberación
+type TimelineEvent struct {
+    Timestamp   time.Time          `json:"timestamp"`
+    Kind        string             `json:"kind"`
+    Description string             `json:"description"`
+    Anomaly     *Anomaly           `json:"anomaly,omitempty"`
+    Release     *QuarantineRelease `json:"release,omitempty"`
+}
+
+// Quarantine activa de un dispositivo (false si no está en quarantine)
+func (qs *QuarantineSystem) ActiveQuarantine(deviceID string) (QuarantineEntry, bool) {
+    qs.mutex.RLock()
+    defer qs.mutex.RUnlock()
+
+    entry, exists := qs.quarantinedDevices[deviceID]
+    if !exists {
+        return QuarantineEntry{}, false
+    }
+    return *entry, true
+}
+
+// Intercalar anomalías, quarantines y liberaciones en orden cronológico,
+// desde since (cero = todo el historial). Cada liberación aporta también el
+// evento de entrada en la quarantine que cierra.
+func buildDeviceTimeline(anomalies []Anomaly, releases []QuarantineRelease, active *QuarantineEntry, since time.Time) []TimelineEvent {
+    events := make([]TimelineEvent, 0, len(anomalies)+2*len(releases)+1)
+    add := func(event TimelineEvent) {
+        if since.IsZero() || !event.Timestamp.Before(since) {
+            events = append(events, event)
+        }
+    }
+
+    for i := range anomalies {
+        add(TimelineEvent{
+            Timestamp:   anomalies[i].Timestamp,
+            Kind:        TIMELINE_EVENT_ANOMALY,
+            Description: anomalies[i].Description,
+            Anomaly:     &anomalies[i],
+        })
+    }
+    for i := range releases {
+        // Las quarantines de otra instancia solo tienen liberación conocida
+        if !releases[i].QuarantinedAt.IsZero() {
+            add(TimelineEvent{
+                Timestamp:   releases[i].QuarantinedAt,
+                Kind:        TIMELINE_EVENT_QUARANTINE,
+                Description: releases[i].QuarantineReason.Label(),
+            })
+        }
+        add(TimelineEvent{
+            Timestamp:   releases[i].ReleasedAt,
+            Kind:        TIMELINE_EVENT_RELEASE,
+            Description: releases[i].Reason.Label(),
+            Release:     &releases[i],
+        })
+    }
+    if active != nil {
+        add(TimelineEvent{
+            Timestamp:   active.Since,
+            Kind:        TIMELINE_EVENT_QUARANTINE,
+            Description: describeQuarantineReason(active.Reason, active.Detail),
+        })
+    }
+
+    sort.SliceStable(events, func(i, j int) bool {
+        return events[i].Timestamp.Before(events[j].Timestamp)
+    })
+    return events
+}
+
+// GET /devices/{id}/timeline?since=2024-01-01T00:00:00Z: historial del dispositivo
+func handleDeviceTimeline(w http.ResponseWriter, r *http.Request) {
+    since, err := parseTimeParam(r, "since")
+    if err != nil {
+        writeError(w, http.StatusBadRequest, err.Error())
+        return
+    }
+
+    deviceID := r.PathValue("id")
+    var active *QuarantineEntry
+    if entry, quarantined := quarantineSystem.ActiveQuarantine(deviceID); quarantined {
+        active = &entry
+    }
+
+    timeline := buildDeviceTimeline(anomalyRepository.ByDevice(deviceID, false), quarantineSystem.Releases(deviceID), active, since)
+    writeJSON(w, http.StatusOK, timeline)
+}
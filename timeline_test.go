@@ -0,0 +1,118 @@
+package main
+
+import (
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "reflect"
+    "testing"
+    "time"
+)
+
+// Historial de sensor-1: anomalía, quarantine, anomalía, liberación,
+// anomalía y una segunda quarantine que sigue activa (un evento por minuto)
+func seedDeviceTimeline(t *testing.T, clock *FakeClock) {
+    t.Helper()
+    anomalyAt := func(minute int, description string) Anomaly {
+        return Anomaly{
+            DeviceID:    "sensor-1",
+            Type:        AnomalyTemperature,
+            Severity:    SEVERITY_HIGH,
+            Description: description,
+            Timestamp:   testEpoch.Add(time.Duration(minute) * time.Minute),
+        }
+    }
+
+    // Guardadas fuera de orden: la línea de tiempo debe ordenarlas
+    anomalyRepository.Save([]Anomaly{anomalyAt(5, "tercera"), anomalyAt(1, "primera"), anomalyAt(3, "segunda")})
+    anomalyRepository.Save([]Anomaly{{DeviceID: "sensor-2", Type: AnomalyTemperature, Timestamp: testEpoch}})
+
+    clock.Set(testEpoch.Add(2 * time.Minute))
+    quarantineSystem.QuarantineDeviceWithReason("sensor-1", QuarantineReasonInvalidData, "")
+    clock.Set(testEpoch.Add(4 * time.Minute))
+    if !quarantineSystem.ReleaseFromQuarantine("sensor-1", ReleaseReasonManual, "operador") {
+        t.Fatal("sensor-1 no estaba en quarantine")
+    }
+    clock.Set(testEpoch.Add(6 * time.Minute))
+    quarantineSystem.QuarantineDeviceWithReason("sensor-1", QuarantineReasonBehaviorAnomaly, "")
+}
+
+func TestHandleDeviceTimeline(t *testing.T) {
+    tests := []struct {
+        name       string
+        path       string
+        wantStatus int
+        wantKinds  []string
+        wantFirst  time.Time
+    }{
+        {
+            name:       "historial completo",
+            path:       "/devices/sensor-1/timeline",
+            wantStatus: http.StatusOK,
+            wantKinds: []string{
+                TIMELINE_EVENT_ANOMALY, TIMELINE_EVENT_QUARANTINE, TIMELINE_EVENT_ANOMALY,
+                TIMELINE_EVENT_RELEASE, TIMELINE_EVENT_ANOMALY, TIMELINE_EVENT_QUARANTINE,
+            },
+            wantFirst: testEpoch.Add(time.Minute),
+        },
+        {
+            name:       "desde la primera liberación",
+            path:       "/devices/sensor-1/timeline?since=" + testEpoch.Add(4*time.Minute).Format(time.RFC3339),
+            wantStatus: http.StatusOK,
+            wantKinds:  []string{TIMELINE_EVENT_RELEASE, TIMELINE_EVENT_ANOMALY, TIMELINE_EVENT_QUARANTINE},
+            wantFirst:  testEpoch.Add(4 * time.Minute),
+        },
+        {
+            name:       "dispositivo sin quarantines",
+            path:       "/devices/sensor-2/timeline",
+            wantStatus: http.StatusOK,
+            wantKinds:  []string{TIMELINE_EVENT_ANOMALY},
+            wantFirst:  testEpoch,
+        },
+        {
+            name:       "dispositivo desconocido",
+            path:       "/devices/sensor-9/timeline",
+            wantStatus: http.StatusOK,
+            wantKinds:  []string{},
+        },
+        {
+            name:       "since inválido",
+            path:       "/devices/sensor-1/timeline?since=ayer",
+            wantStatus: http.StatusBadRequest,
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            clock := setupTestHub(t)
+            seedDeviceTimeline(t, clock)
+
+            recorder := httptest.NewRecorder()
+            newAPIRouter().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, tt.path, nil))
+            if recorder.Code != tt.wantStatus {
+                t.Fatalf("estado = %d, se esperaba %d", recorder.Code, tt.wantStatus)
+            }
+            if tt.wantStatus != http.StatusOK {
+                return
+            }
+
+            var timeline []TimelineEvent
+            if err := json.Unmarshal(recorder.Body.Bytes(), &timeline); err != nil {
+                t.Fatalf("respuesta no válida: %v", err)
+            }
+            kinds := make([]string, 0, len(timeline))
+            for i, event := range timeline {
+                kinds = append(kinds, event.Kind)
+                if i > 0 && event.Timestamp.Before(timeline[i-1].Timestamp) {
+                    t.Errorf("evento %d (%s) anterior al evento %d", i, event.Timestamp, i-1)
+                }
+            }
+            if !reflect.DeepEqual(kinds, tt.wantKinds) {
+                t.Fatalf("eventos = %v, se esperaban %v", kinds, tt.wantKinds)
+            }
+            if len(timeline) > 0 && !timeline[0].Timestamp.Equal(tt.wantFirst) {
+                t.Errorf("primer evento = %s, se esperaba %s", timeline[0].Timestamp, tt.wantFirst)
+            }
+        })
+    }
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "log"
+    "net/http"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+)
+
+// Timeout de cada consulta al servicio meteorológico
+const WEATHER_TIMEOUT = 3 * time.Second
+
+// Ubicación de un dispositivo
+type DeviceLocation struct {
+    Latitude  float64 `json:"lat"`
+    Longitude float64 `json:"lon"`
+}
+
+// Condiciones exteriores en la ubicación del dispositivo
+type WeatherConditions struct {
+    OutdoorTemperature float64   `json:"outdoor_temperature"`
+    OutdoorHumidity    float64   `json:"outdoor_humidity"`
+    FetchedAt          time.Time `json:"fetched_at"`
+}
+
+// Fuente de condiciones meteorológicas actuales
+type WeatherClient interface {
+    CurrentConditions(ctx context.Context, location DeviceLocation) (*WeatherConditions, error)
+}
+
+// Ubicaciones de los dispositivos: device_id → coordenadas
+var deviceLocations = map[string]DeviceLocation{}
+
+// Cliente meteorológico activo (nil = enriquecimiento deshabilitado)
+var weatherClient WeatherClient
+
+// Cliente HTTP para APIs tipo Open-Meteo. La URL admite {lat} y {lon} y la
+// respuesta debe incluir "current": {"temperature_2m", "relative_humidity_2m"}
+type HTTPWeatherClient struct {
+    urlTemplate string
+    httpClient  *http.Client
+}
+
+type openMeteoResponse struct {
+    Current struct {
+        Temperature float64 `json:"temperature_2m"`
+        Humidity    float64 `json:"relative_humidity_2m"`
+    } `json:"current"`
+}
+
+// Crear cliente meteorológico HTTP
+func NewHTTPWeatherClient(urlTemplate string) *HTTPWeatherClient {
+    return &HTTPWeatherClient{
+        urlTemplate: urlTemplate,
+        httpClient:  &http.Client{Timeout: WEATHER_TIMEOUT},
+    }
+}
+
+func (wc *HTTPWeatherClient) CurrentConditions(ctx context.Context, location DeviceLocation) (*WeatherConditions, error) {
+    url := strings.NewReplacer(
+        "{lat}", strconv.FormatFloat(location.Latitude, 'f', 4, 64),
+        "{lon}", strconv.FormatFloat(location.Longitude, 'f', 4, 64),
+    ).Replace(wc.urlTemplate)
+
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+    if err != nil {
+        return nil, err
+    }
+
+    resp, err := wc.httpClient.Do(req)
+    if err != nil {
+        return nil, err
+    }
+    defer resp.Body.Close()
+
+    if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+        return nil, fmt.Errorf("servicio meteorológico respondió con estado %d", resp.StatusCode)
+    }
+
+    var body openMeteoResponse
+    if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+        return nil, fmt.Errorf("respuesta meteorológica inválida: %w", err)
+    }
+
+    return &WeatherConditions{
+        OutdoorTemperature: body.Current.Temperature,
+        OutdoorHumidity:    body.Current.Humidity,
+        FetchedAt:          time.Now(),
+    }, nil
+}
+
+// Caché de condiciones por ubicación para no consultar en cada anomalía
+type CachedWeatherClient struct {
+    mutex   sync.Mutex
+    client  WeatherClient
+    ttl     time.Duration
+    entries map[DeviceLocation]*WeatherConditions
+}
+
+// Envolver un cliente meteorológico con caché
+func NewCachedWeatherClient(client WeatherClient, ttl time.Duration) *CachedWeatherClient {
+    return &CachedWeatherClient{
+        client:  client,
+        ttl:     ttl,
+        entries: make(map[DeviceLocation]*WeatherConditions),
+    }
+}
+
+func (cw *CachedWeatherClient) CurrentConditions(ctx context.Context, location DeviceLocation) (*WeatherConditions, error) {
+    cw.mutex.Lock()
+    cached, exists := cw.entries[location]
+    cw.mutex.Unlock()
+
+    if exists && time.Since(cached.FetchedAt) < cw.ttl {
+        return cached, nil
+    }
+
+    conditions, err := cw.client.CurrentConditions(ctx, location)
+    if err != nil {
+        return nil, err
+    }
+
+    cw.mutex.Lock()
+    cw.entries[location] = conditions
+    cw.mutex.Unlock()
+    return conditions, nil
+}
+
+// Adjuntar las condiciones exteriores a una anomalía de temperatura de un
+// dispositivo con ubicación conocida. Se llama desde la goroutine de
+// notificación, nunca desde el procesamiento de mensajes.
+func enrichAnomaly(anomaly *Anomaly) {
+    if weatherClient == nil || anomaly.Metric != METRIC_TEMPERATURE {
+        return
+    }
+    location, known := deviceLocations[anomaly.DeviceID]
+    if !known {
+        return
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), WEATHER_TIMEOUT)
+    defer cancel()
+    conditions, err := weatherClient.CurrentConditions(ctx, location)
+    if err != nil {
+        log.Printf("⚠️ No se pudo obtener el clima para %s: %v", anomaly.DeviceID, err)
+        return
+    }
+    anomaly.ExternalContext = conditions
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+    "context"
+    "errors"
+    "testing"
+    "time"
+)
+
+// Cliente meteorológico que no responde hasta que se libera
+type blockingWeatherClient struct {
+    release    chan struct{}
+    conditions *WeatherConditions
+    err        error
+}
+
+func (bw *blockingWeatherClient) CurrentConditions(ctx context.Context, location DeviceLocation) (*WeatherConditions, error) {
+    select {
+    case <-bw.release:
+    case <-ctx.Done():
+        return nil, ctx.Err()
+    }
+    return bw.conditions, bw.err
+}
+
+func TestSendAnomalyAlert_EnrichesInBackground(t *testing.T) {
+    tests := []struct {
+        name        string
+        deviceID    string
+        metric      string
+        err         error
+        wantWeather bool
+    }{
+        {name: "temperatura con ubicación", deviceID: "sensor-1", metric: METRIC_TEMPERATURE, wantWeather: true},
+        {name: "sin ubicación", deviceID: "sensor-2", metric: METRIC_TEMPERATURE},
+        {name: "otra métrica", deviceID: "sensor-1", metric: METRIC_HUMIDITY},
+        {name: "fallo del servicio", deviceID: "sensor-1", metric: METRIC_TEMPERATURE, err: errors.New("caído")},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            setupTestHub(t)
+            savedClient, savedLocations := weatherClient, deviceLocations
+            t.Cleanup(func() { weatherClient, deviceLocations = savedClient, savedLocations })
+
+            client := &blockingWeatherClient{
+                release:    make(chan struct{}),
+                conditions: &WeatherConditions{OutdoorTemperature: 31},
+                err:        tt.err,
+            }
+            weatherClient = client
+            deviceLocations = map[string]DeviceLocation{"sensor-1": {Latitude: 40.4, Longitude: -3.7}}
+            notifier := newRecordingNotifier("test")
+            notificationManager.Register(notifier)
+
+            // El envío vuelve sin esperar al servicio meteorológico
+            returned := make(chan struct{})
+            go func() {
+                notificationManager.SendAnomalyAlert(Anomaly{DeviceID: tt.deviceID, Type: AnomalyTemperature, Metric: tt.metric, Severity: SEVERITY_HIGH})
+                close(returned)
+            }()
+            select {
+            case <-returned:
+            case <-time.After(time.Second):
+                t.Fatal("SendAnomalyAlert bloqueó esperando al servicio meteorológico")
+            }
+
+            close(client.release)
+            flushNotifications(t)
+
+            sent := notifier.Anomalies()
+            if len(sent) != 1 {
+                t.Fatalf("alertas enviadas = %d, se esperaba 1", len(sent))
+            }
+            if got := sent[0].ExternalContext != nil; got != tt.wantWeather {
+                t.Errorf("con contexto meteorológico = %v, se esperaba %v", got, tt.wantWeather)
+            }
+        })
+    }
+}
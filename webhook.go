@@ -0,0 +1,115 @@
+package main
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "text/template"
+    "time"
+)
+
+// Cliente de webhook genérico: POST de un cuerpo JSON renderizado con una
+// plantilla text/template sobre la anomalía ({{.DeviceID}}, {{.Severity}}, ...).
+// La función json escapa valores: {"device": {{json .DeviceID}}}
+type GenericWebhookClient struct {
+    url        string
+    body       *template.Template
+    headers    map[string]string
+    httpClient *http.Client
+}
+
+// Plantilla por defecto: la anomalía completa
+const DEFAULT_WEBHOOK_TEMPLATE = `{{json .}}`
+
+var webhookTemplateFuncs = template.FuncMap{
+    "json": func(value interface{}) (string, error) {
+        body, err := json.Marshal(value)
+        return string(body), err
+    },
+}
+
+// Crear cliente de webhook; falla si la plantilla no es válida
+func NewGenericWebhookClient(url string, bodyTemplate string, headers map[string]string, timeout time.Duration) (*GenericWebhookClient, error) {
+    if bodyTemplate == "" {
+        bodyTemplate = DEFAULT_WEBHOOK_TEMPLATE
+    }
+    body, err := template.New("webhook").Funcs(webhookTemplateFuncs).Parse(bodyTemplate)
+    if err != nil {
+        return nil, fmt.Errorf("plantilla de webhook inválida: %w", err)
+    }
+    if timeout <= 0 {
+        timeout = NOTIFICATION_TIMEOUT
+    }
+
+    return &GenericWebhookClient{
+        url:        url,
+        body:       body,
+        headers:    headers,
+        httpClient: &http.Client{Timeout: timeout},
+    }, nil
+}
+
+func (wc *GenericWebhookClient) Name() string {
+    return "webhook"
+}
+
+// Enviar la anomalía renderizada
+func (wc *GenericWebhookClient) SendAnomalyAlert(ctx context.Context, anomaly *Anomaly) error {
+    body, err := wc.render(anomaly)
+    if err != nil {
+        return err
+    }
+    return wc.post(ctx, body)
+}
+
+// Cuerpo que se enviaría para una anomalía (modo dry-run)
+func (wc *GenericWebhookClient) RenderAnomalyAlert(anomaly *Anomaly) (string, error) {
+    body, err := wc.render(anomaly)
+    return string(body), err
+}
+
+// Las cuarentenas y recuperaciones no se envían: la plantilla es de anomalías
+func (wc *GenericWebhookClient) SendQuarantineAlert(ctx context.Context, deviceID string, reason QuarantineReason, detail string, duration time.Duration) error {
+    return nil
+}
+
+func (wc *GenericWebhookClient) SendRecoveryAlert(ctx context.Context, deviceID string, reason ReleaseReason, actor string) error {
+    return nil
+}
+
+func (wc *GenericWebhookClient) RenderQuarantineAlert(deviceID string, reason QuarantineReason, detail string, duration time.Duration) (string, error) {
+    return "", nil
+}
+
+func (wc *GenericWebhookClient) RenderRecoveryAlert(deviceID string, reason ReleaseReason, actor string) (string, error) {
+    return "", nil
+}
+
+// Renderizar la plantilla y comprobar que el resultado es JSON
+func (wc *GenericWebhookClient) render(anomaly *Anomaly) ([]byte, error) {
+    var body bytes.Buffer
+    if err := wc.body.Execute(&body, anomaly); err != nil {
+        return nil, fmt.Errorf("error renderizando la plantilla de webhook: %w", err)
+    }
+    if !json.Valid(body.Bytes()) {
+        return nil, fmt.Errorf("la plantilla de webhook no produjo JSON válido")
+    }
+    return body.Bytes(), nil
+}
+
+// POST del cuerpo con las cabeceras configuradas
+func (wc *GenericWebhookClient) post(ctx context.Context, body []byte) error {
+    return sendWithRetry(ctx, wc.httpClient, "webhook", func(ctx context.Context) (*http.Request, error) {
+        req, err := http.NewRequestWithContext(ctx, http.MethodPost, wc.url, bytes.NewReader(body))
+        if err != nil {
+            return nil, err
+        }
+        req.Header.Set("Content-Type", "application/json")
+        for name, value := range wc.headers {
+            req.Header.Set(name, value)
+        }
+        return req, nil
+    })
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+    "context"
+    "encoding/json"
+    "io"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+    "time"
+)
+
+func TestGenericWebhookClient_SendAnomalyAlert(t *testing.T) {
+    anomaly := Anomaly{ID: "a1", DeviceID: "sensor-\"1\"", Type: AnomalyTemperature, Severity: SEVERITY_HIGH, Value: 95, Description: "temperatura extrema"}
+    // La plantilla por defecto envía la anomalía completa
+    defaultBody, err := json.Marshal(anomaly)
+    if err != nil {
+        t.Fatalf("json.Marshal: %v", err)
+    }
+
+    tests := []struct {
+        name        string
+        template    string
+        headers     map[string]string
+        wantBody    string
+        wantHeaders map[string]string
+        wantErr     bool
+    }{
+        {
+            name:     "plantilla propia con cabecera de autenticación",
+            template: `{"device": {{json .DeviceID}}, "severity": {{json .Severity}}, "value": {{.Value}}}`,
+            headers:  map[string]string{"Authorization": "Bearer secreto"},
+            wantBody: `{"device": "sensor-\"1\"", "severity": "high", "value": 95}`,
+            wantHeaders: map[string]string{
+                "Authorization": "Bearer secreto",
+                "Content-Type":  "application/json",
+            },
+        },
+        {
+            name:        "plantilla por defecto",
+            wantBody:    string(defaultBody),
+            wantHeaders: map[string]string{"Content-Type": "application/json"},
+        },
+        {name: "plantilla que no produce JSON", template: `device={{.DeviceID}}`, wantErr: true},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            var body string
+            var headers http.Header
+            server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+                raw, _ := io.ReadAll(r.Body)
+                body, headers = string(raw), r.Header
+                w.WriteHeader(http.StatusNoContent)
+            }))
+            defer server.Close()
+
+            client, err := NewGenericWebhookClient(server.URL, tt.template, tt.headers, time.Second)
+            if err != nil {
+                t.Fatalf("NewGenericWebhookClient: %v", err)
+            }
+            err = client.SendAnomalyAlert(context.Background(), &anomaly)
+            if (err != nil) != tt.wantErr {
+                t.Fatalf("SendAnomalyAlert() error = %v, se esperaba error: %v", err, tt.wantErr)
+            }
+            if tt.wantErr {
+                if body != "" {
+                    t.Errorf("se envió un cuerpo inválido: %s", body)
+                }
+                return
+            }
+
+            if body != tt.wantBody {
+                t.Errorf("cuerpo = %s, se esperaba %s", body, tt.wantBody)
+            }
+            for name, want := range tt.wantHeaders {
+                if got := headers.Get(name); got != want {
+                    t.Errorf("cabecera %s = %q, se esperaba %q", name, got, want)
+                }
+            }
+        })
+    }
+}
+
+func TestNewGenericWebhookClient_InvalidTemplate(t *testing.T) {
+    if _, err := NewGenericWebhookClient("http://localhost", `{"device": {{.DeviceID}`, nil, time.Second); err == nil {
+        t.Error("se esperaba error con una plantilla mal formada")
+    }
+}
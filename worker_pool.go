@@ -0,0 +1,120 @@
+package main
+
+import (
+    "hash/fnv"
+    "strings"
+    "sync"
+)
+
+// Workers que procesan mensajes en paralelo (0 = procesar en el callback de
+// MQTT, sin cola)
+var processingWorkers = 4
+
+// Mensajes en espera como máximo entre todos los workers; el resto se descarta
+var processingQueueSize = 1000
+
+// Pool de workers de mensajes
+var messagePool *WorkerPool
+
+// Clave con la que se reparte un mensaje entre los workers: su device_id,
+// leído del JSON o del CBOR sin decodificar el resto, o el topic si no se
+// puede leer (los payloads ilegibles se rechazan igualmente al procesarlos)
+func messageShardKey(topic string, payload []byte) string {
+    if payloadTooLarge(payload) {
+        // Solo se mira el principio del JSON; un CBOR tan grande no se lee
+        if deviceID := peekDeviceID(payload, maxPayloadSize); deviceID != "" {
+            return deviceID
+        }
+        return topic
+    }
+
+    deviceID := ""
+    if !strings.HasSuffix(topic, CBOR_TOPIC_SUFFIX) {
+        deviceID = peekDeviceID(payload, maxPayloadSize)
+    }
+    if deviceID == "" {
+        deviceID = peekCBORDeviceID(payload)
+    }
+    if deviceID == "" {
+        return topic
+    }
+    return deviceID
+}
+
+// Pool acotado de workers. Cada clave (device_id) se asigna siempre al mismo
+// worker para conservar el orden de sus mensajes, del que dependen la
+// protección contra replay y el análisis de comportamiento.
+type WorkerPool struct {
+    mutex  sync.RWMutex
+    queues []chan func()
+    closed bool
+    wg     sync.WaitGroup
+}
+
+// Crear el pool y arrancar sus workers
+func NewWorkerPool(workers, queueSize int) *WorkerPool {
+    if workers < 1 {
+        workers = 1
+    }
+    perWorker := (queueSize + workers - 1) / workers
+    if perWorker < 1 {
+        perWorker = 1
+    }
+
+    pool := &WorkerPool{queues: make([]chan func(), workers)}
+    for i := range pool.queues {
+        queue := make(chan func(), perWorker)
+        pool.queues[i] = queue
+        pool.wg.Add(1)
+        go func() {
+            defer pool.wg.Done()
+            for job := range queue {
+                job()
+            }
+        }()
+    }
+    return pool
+}
+
+// Encolar un trabajo sin bloquear. Devuelve false si la cola de su worker
+// está llena o el pool está detenido.
+func (p *WorkerPool) Submit(key string, job func()) bool {
+    p.mutex.RLock()
+    defer p.mutex.RUnlock()
+
+    if p.closed {
+        return false
+    }
+
+    hash := fnv.New32a()
+    hash.Write([]byte(key))
+    select {
+    case p.queues[hash.Sum32()%uint32(len(p.queues))] <- job:
+        return true
+    default:
+        return false
+    }
+}
+
+// Trabajos en espera
+func (p *WorkerPool) QueueDepth() int {
+    depth := 0
+    for _, queue := range p.queues {
+        depth += len(queue)
+    }
+    return depth
+}
+
+// Dejar de aceptar trabajos y esperar a que terminen los encolados
+func (p *WorkerPool) Stop() {
+    p.mutex.Lock()
+    if !p.closed {
+        p.closed = true
+        for _, queue := range p.queues {
+            close(queue)
+        }
+    }
+    p.mutex.Unlock()
+
+    p.wg.Wait()
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+    "sync"
+    "sync/atomic"
+    "testing"
+    "time"
+
+    "github.com/fxamacker/cbor/v2"
+)
+
+func TestMessageShardKey(t *testing.T) {
+    cborPayload, err := cbor.Marshal(map[string]interface{}{"device_id": "sensor-7", "temperature": 21.5})
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    tests := []struct {
+        name    string
+        topic   string
+        payload []byte
+        want    string
+    }{
+        {name: "JSON", topic: "iot/sensors", payload: []byte(`{"device_id":"sensor-1","temperature":21}`), want: "sensor-1"},
+        {name: "CBOR por topic", topic: "iot/sensors/cbor", payload: cborPayload, want: "sensor-7"},
+        {name: "CBOR en topic JSON", topic: "iot/sensors", payload: cborPayload, want: "sensor-7"},
+        {name: "sin device_id", topic: "iot/sensors", payload: []byte(`{"temperature":21}`), want: "iot/sensors"},
+        {name: "ilegible", topic: "iot/sensors", payload: []byte("\xff\x00basura"), want: "iot/sensors"},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if got := messageShardKey(tt.topic, tt.payload); got != tt.want {
+                t.Errorf("clave = %q, se esperaba %q", got, tt.want)
+            }
+        })
+    }
+}
+
+func TestWorkerPool_BoundedAndShedsOverflow(t *testing.T) {
+    tests := []struct {
+        name      string
+        workers   int
+        queueSize int
+        jobs      int
+    }{
+        {name: "un worker", workers: 1, queueSize: 2, jobs: 10},
+        {name: "varios workers", workers: 4, queueSize: 4, jobs: 40},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            pool := NewWorkerPool(tt.workers, tt.queueSize)
+            release := make(chan struct{})
+            var running, maxRunning, done atomic.Int32
+
+            accepted := 0
+            submitted := make(chan struct{})
+            go func() {
+                defer close(submitted)
+                for i := 0; i < tt.jobs; i++ {
+                    // Claves distintas para repartir entre los workers
+                    ok := pool.Submit(string(rune('a'+i%26)), func() {
+                        now := running.Add(1)
+                        for {
+                            seen := maxRunning.Load()
+                            if now <= seen || maxRunning.CompareAndSwap(seen, now) {
+                                break
+                            }
+                        }
+                        <-release
+                        running.Add(-1)
+                        done.Add(1)
+                    })
+                    if ok {
+                        accepted++
+                    }
+                }
+            }()
+
+            // Con la cola llena se descarta en lugar de bloquear
+            select {
+            case <-submitted:
+            case <-time.After(time.Second):
+                t.Fatal("Submit bloqueó con la cola llena")
+            }
+            if accepted >= tt.jobs {
+                t.Errorf("aceptados = %d, se esperaba descartar parte de %d", accepted, tt.jobs)
+            }
+
+            close(release)
+            pool.Stop()
+            if got := int(maxRunning.Load()); got > tt.workers {
+                t.Errorf("concurrencia máxima = %d, se esperaba como mucho %d", got, tt.workers)
+            }
+            if got := int(done.Load()); got != accepted {
+                t.Errorf("procesados = %d, se esperaban los %d aceptados", got, accepted)
+            }
+        })
+    }
+}
+
+func TestWorkerPool_StopDrainsQueue(t *testing.T) {
+    pool := NewWorkerPool(2, 100)
+    var mutex sync.Mutex
+    processed := 0
+    for i := 0; i < 50; i++ {
+        if !pool.Submit("sensor-1", func() {
+            time.Sleep(time.Millisecond)
+            mutex.Lock()
+            processed++
+            mutex.Unlock()
+        }) {
+            t.Fatalf("trabajo %d descartado con cola libre", i)
+        }
+    }
+
+    pool.Stop()
+    if processed != 50 {
+        t.Errorf("procesados al detener = %d, se esperaban 50", processed)
+    }
+    if pool.Submit("sensor-1", func() {}) {
+        t.Error("un pool detenido no debe aceptar trabajos")
+    }
+}